@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/spolu/settle/lib/env"
@@ -60,12 +61,24 @@ func New(
 			s := strings.Split(a, "=")
 			if len(s) == 2 {
 				flags[s[0]] = s[1]
+			} else if len(s) == 1 {
+				// Boolean flags.
+				flags[s[0]] = "true"
 			}
 		} else {
 			args = append(args, strings.TrimSpace(a))
 		}
 	}
 
+	// JSON output flag.
+	if value, ok := flags["json"]; ok {
+		json, err := parseBoolFlag("json", value)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		out.SetJSON(json)
+	}
+
 	cliEnv := env.Env{
 		Environment: env.Production,
 		Config:      map[env.ConfigKey]string{},
@@ -76,6 +89,7 @@ func New(
 		cliEnv.Environment = env.QA
 	}
 	ctx = env.With(ctx, &cliEnv)
+	ctx = WithFlags(ctx, flags)
 
 	creds, err := CurrentUser(ctx)
 	if err != nil {
@@ -114,7 +128,8 @@ func (c *Cli) Run() error {
 	err := command.Parse(c.Ctx, args)
 	if err != nil {
 		command.Help(c.Ctx)
-		return errors.Trace(err)
+		return errors.Trace(errors.NewUserError(
+			err, 0, "arguments_invalid", err.Error()))
 	}
 
 	err = command.Execute(c.Ctx)
@@ -124,3 +139,57 @@ func (c *Cli) Run() error {
 
 	return nil
 }
+
+const (
+	// flagsKey the context.Context key to store the flags
+	flagsKey ContextKey = "cli.flags"
+)
+
+// WithFlags stores the flags passed to the cli in the provided context.
+func WithFlags(
+	ctx context.Context,
+	flags map[string]string,
+) context.Context {
+	return context.WithValue(ctx, flagsKey, flags)
+}
+
+// GetFlag returns the value of the specified flag passed to the cli and
+// whether it was passed.
+func GetFlag(
+	ctx context.Context,
+	name string,
+) (string, bool) {
+	flags, ok := ctx.Value(flagsKey).(map[string]string)
+	if !ok {
+		return "", false
+	}
+	value, ok := flags[name]
+	return value, ok
+}
+
+// GetBoolFlag returns the value of the specified boolean flag passed to the
+// cli (false if it was not passed). Boolean flags passed without value are
+// true, their values are otherwise parsed with strconv.ParseBool.
+func GetBoolFlag(
+	ctx context.Context,
+	name string,
+) (bool, error) {
+	value, ok := GetFlag(ctx, name)
+	if !ok {
+		return false, nil
+	}
+	return parseBoolFlag(name, value)
+}
+
+// parseBoolFlag parses the value of a boolean flag.
+func parseBoolFlag(
+	name string,
+	value string,
+) (bool, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.Trace(
+			errors.Newf("Invalid value for --%s: %s", name, value))
+	}
+	return b, nil
+}
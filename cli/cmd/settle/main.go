@@ -5,17 +5,40 @@ import (
 
 	"github.com/spolu/settle/cli"
 	_ "github.com/spolu/settle/cli/command"
+	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/out"
 )
 
 func main() {
-	cli, err := cli.New(os.Args[1:])
+	c, err := cli.New(os.Args[1:])
 	if err != nil {
-		out.Errof("[Error] %s\n", err.Error())
+		fail(err)
 	}
 
-	err = cli.Run()
+	err = c.Run()
 	if err != nil {
+		fail(err)
+	}
+}
+
+// fail prints out the error (as JSON if JSON output is enabled) and exits.
+func fail(
+	err error,
+) {
+	if out.IsJSON() {
+		e := errors.ConcreteUserError{
+			ErrCode:    "command_failed",
+			ErrMessage: err.Error(),
+		}
+		if u := errors.ExtractUserError(err); u != nil {
+			e.ErrCode = u.Code()
+			e.ErrMessage = u.Message()
+		}
+		out.JSON(map[string]interface{}{
+			"error": e,
+		})
+	} else {
 		out.Errof("[Error] %s\n", err.Error())
 	}
+	os.Exit(1)
 }
@@ -121,6 +121,10 @@ func (c *Close) Execute(
 		return errors.Trace(err)
 	}
 
+	out.JSON(map[string]interface{}{
+		"offer": offer,
+	})
+
 	out.Boldf("Trustline closed:\n")
 	out.Normf("  ID        : ")
 	out.Valuf("%s\n", offer.ID)
@@ -49,6 +49,15 @@ func Confirm(
 	ctx context.Context,
 	action string,
 ) error {
+	yes, err := cli.GetBoolFlag(ctx, "yes")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if yes {
+		out.Normf("You are about to %s, confirmed with --yes.\n", action)
+		return nil
+	}
+
 	reader := bufio.NewReader(os.Stdin)
 
 	out.Normf(
@@ -58,12 +67,35 @@ func Confirm(
 	confirmation, _ := reader.ReadString('\n')
 	confirmation = strings.TrimSpace(confirmation)
 	if confirmation != "" && confirmation != "Y" && confirmation != "y" {
-		return errors.Trace(errors.Newf("%s aborted by user.", action))
+		return errors.Trace(
+			userErrorf("aborted", "%s aborted by user.", action))
 	}
 
 	return nil
 }
 
+// userErrorf returns an error carrying a code, reported as such to the user
+// when JSON output is enabled.
+func userErrorf(
+	code string,
+	format string,
+	args ...interface{},
+) error {
+	message := fmt.Sprintf(format, args...)
+	return errors.NewUserError(errors.Newf("%s", message), 0, code, message)
+}
+
+// mintError returns the error to report to the user when a mint (or register
+// service) replies with an error, retaining its error code.
+func mintError(
+	status int,
+	e errors.ConcreteUserError,
+) error {
+	return errors.NewUserError(
+		errors.Newf("(%s) %s", e.ErrCode, e.ErrMessage),
+		status, e.ErrCode, e.ErrMessage)
+}
+
 // RegisterUser registers a user on the provded mint register service.
 func RegisterUser(
 	ctx context.Context,
@@ -104,7 +136,7 @@ func RegisterUser(
 			return nil, errors.Trace(err)
 		}
 		return nil, errors.Trace(
-			errors.Newf("(%s) %s", e.ErrCode, e.ErrMessage))
+			mintError(r.StatusCode, e))
 	}
 
 	var user register.UserResource
@@ -153,7 +185,7 @@ func CreateAsset(
 			return nil, errors.Trace(err)
 		}
 		return nil, errors.Trace(
-			errors.Newf("(%s) %s", e.ErrCode, e.ErrMessage))
+			mintError(*status, e))
 	}
 
 	var asset mint.AssetResource
@@ -200,7 +232,7 @@ func CreateOffer(
 			return nil, errors.Trace(err)
 		}
 		return nil, errors.Trace(
-			errors.Newf("(%s) %s", e.ErrCode, e.ErrMessage))
+			mintError(*status, e))
 	}
 
 	var offer mint.OfferResource
@@ -251,7 +283,7 @@ func CreateTransaction(
 			return nil, errors.Trace(err)
 		}
 		return nil, errors.Trace(
-			errors.Newf("(%s) %s", e.ErrCode, e.ErrMessage))
+			mintError(*status, e))
 	}
 
 	var transaction mint.TransactionResource
@@ -292,7 +324,48 @@ func SettleTransaction(
 			return nil, errors.Trace(err)
 		}
 		return nil, errors.Trace(
-			errors.Newf("(%s) %s", e.ErrCode, e.ErrMessage))
+			mintError(*status, e))
+	}
+
+	var transaction mint.TransactionResource
+	err = raw.Extract("transaction", &transaction)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &transaction, nil
+}
+
+// CancelTransaction cancels a transaction for the currently authenticated
+// user.
+func CancelTransaction(
+	ctx context.Context,
+	id string,
+) (*mint.TransactionResource, error) {
+	m, err := cli.MintFromContextCredentials(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	out.Statf("[Canceling transaction] user=%s@%s transaction=%s\n",
+		m.Credentials.Username, m.Credentials.Host, id)
+
+	status, raw, err := m.Post(ctx,
+		fmt.Sprintf("/transactions/%s/cancel", id),
+		url.Values{},
+		url.Values{})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if *status != http.StatusOK {
+		var e errors.ConcreteUserError
+		err = raw.Extract("error", &e)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return nil, errors.Trace(
+			mintError(*status, e))
 	}
 
 	var transaction mint.TransactionResource
@@ -332,7 +405,7 @@ func CloseOffer(
 			return nil, errors.Trace(err)
 		}
 		return nil, errors.Trace(
-			errors.Newf("(%s) %s", e.ErrCode, e.ErrMessage))
+			mintError(*status, e))
 	}
 
 	var offer mint.OfferResource
@@ -370,7 +443,7 @@ func ListAssets(
 			return nil, errors.Trace(err)
 		}
 		return nil, errors.Trace(
-			errors.Newf("(%s) %s", e.ErrCode, e.ErrMessage))
+			mintError(*status, e))
 	}
 
 	var assets []mint.AssetResource
@@ -408,7 +481,7 @@ func ListBalances(
 			return nil, errors.Trace(err)
 		}
 		return nil, errors.Trace(
-			errors.Newf("(%s) %s", e.ErrCode, e.ErrMessage))
+			mintError(*status, e))
 	}
 
 	var balances []mint.BalanceResource
@@ -447,7 +520,7 @@ func ListAssetBalances(
 			return nil, errors.Trace(err)
 		}
 		return nil, errors.Trace(
-			errors.Newf("(%s) %s", e.ErrCode, e.ErrMessage))
+			mintError(*status, e))
 	}
 
 	var balances []mint.BalanceResource
@@ -502,7 +575,45 @@ func ListAssetOffers(
 			return nil, errors.Trace(err)
 		}
 		return nil, errors.Trace(
-			errors.Newf("(%s) %s", e.ErrCode, e.ErrMessage))
+			mintError(*status, e))
+	}
+
+	var offers []mint.OfferResource
+	err = raw.Extract("offers", &offers)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return offers, nil
+}
+
+// ListOffers list the canonical offers of the current user.
+func ListOffers(
+	ctx context.Context,
+) ([]mint.OfferResource, error) {
+	m, err := cli.MintFromContextCredentials(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	out.Statf("[Listing offers] user=%s@%s\n",
+		m.Credentials.Username, m.Credentials.Host)
+
+	status, raw, err := m.Get(ctx,
+		"/offers",
+		url.Values{})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if *status != http.StatusOK {
+		var e errors.ConcreteUserError
+		err = raw.Extract("error", &e)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return nil, errors.Trace(
+			mintError(*status, e))
 	}
 
 	var offers []mint.OfferResource
@@ -514,6 +625,45 @@ func ListAssetOffers(
 	return offers, nil
 }
 
+// ListOfferCrossings list the crossings of one of the current user's offers.
+func ListOfferCrossings(
+	ctx context.Context,
+	id string,
+) ([]mint.CrossingResource, error) {
+	m, err := cli.MintFromContextCredentials(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	out.Statf("[Listing offer crossings] user=%s@%s offer=%s\n",
+		m.Credentials.Username, m.Credentials.Host, id)
+
+	status, raw, err := m.Get(ctx,
+		fmt.Sprintf("/offers/%s/crossings", id),
+		url.Values{})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if *status != http.StatusOK {
+		var e errors.ConcreteUserError
+		err = raw.Extract("error", &e)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return nil, errors.Trace(
+			mintError(*status, e))
+	}
+
+	var crossings []mint.CrossingResource
+	err = raw.Extract("crossings", &crossings)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return crossings, nil
+}
+
 // RetrieveAsset retrieves an asset, returning nil if it does not exist.
 func RetrieveAsset(
 	ctx context.Context,
@@ -556,7 +706,7 @@ func RetrieveAsset(
 			return nil, nil
 		}
 		return nil, errors.Trace(
-			errors.Newf("(%s) %s", e.ErrCode, e.ErrMessage))
+			mintError(*status, e))
 	}
 
 	var asset mint.AssetResource
@@ -610,7 +760,7 @@ func RetrieveOffer(
 			return nil, nil
 		}
 		return nil, errors.Trace(
-			errors.Newf("(%s) %s", e.ErrCode, e.ErrMessage))
+			mintError(*status, e))
 	}
 
 	var offer mint.OfferResource
@@ -621,3 +771,51 @@ func RetrieveOffer(
 
 	return &offer, nil
 }
+
+// RetrieveTransaction retrieves a transaction as known by the mint of the
+// specified host (which may differ from the mint of its owner for the mints
+// involved in the transaction), returning nil if it does not exist there.
+func RetrieveTransaction(
+	ctx context.Context,
+	id string,
+	host string,
+) (*mint.TransactionResource, error) {
+	m, err := cli.MintFromContextCredentials(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if host != m.Credentials.Host {
+		m = &cli.Mint{Host: host}
+	}
+
+	out.Statf("[Retrieving transaction] mint=%s transaction=%s\n", host, id)
+
+	status, raw, err := m.Get(ctx,
+		fmt.Sprintf("/transactions/%s", id),
+		url.Values{})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if *status != http.StatusOK {
+		var e errors.ConcreteUserError
+		err = raw.Extract("error", &e)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if e.ErrCode == "transaction_not_found" {
+			return nil, nil
+		}
+		return nil, errors.Trace(
+			mintError(*status, e))
+	}
+
+	var transaction mint.TransactionResource
+	err = raw.Extract("transaction", &transaction)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &transaction, nil
+}
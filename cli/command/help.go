@@ -62,11 +62,21 @@ func (c *Help) Help(
 	out.Valuf("    settle pay von.neumann@ias.edu GBP.2 20\n")
 	out.Normf("\n")
 
+	out.Boldf("  tx <action> <transaction>\n")
+	out.Normf("    Show, settle or cancel a transaction.\n")
+	out.Valuf("    settle tx show spolu@m.settle.network[transaction_Z1vJtLYFUFgSWwy0]\n")
+	out.Normf("\n")
+
 	out.Boldf("  list <type>\n")
 	out.Normf("    List balances, assets, trustlines.\n")
 	out.Valuf("    settle list balances\n")
 	out.Normf("\n")
 
+	out.Boldf("  offers [<action> <trustline> [<value>]]\n")
+	out.Normf("    List, show, top up or reprice your trustlines.\n")
+	out.Valuf("    settle offers show spolu@m.settle.network[offer_Z1vJtLYFUFgSWwy0]\n")
+	out.Normf("\n")
+
 	out.Boldf("  close <trustline>\n")
 	out.Normf("    Close a trustline.\n")
 	out.Valuf("    settle close spolu@m.settle.network[offer_Z1vJtLYFUFgSWwy0]\n")
@@ -86,6 +96,20 @@ func (c *Help) Help(
 	out.Normf("    Log the current user out.\n")
 	out.Valuf("    settle logout\n")
 	out.Normf("\n")
+
+	out.Normf("Flags:\n")
+
+	out.Boldf("  --json\n")
+	out.Normf("    Print the objects returned by the command (assets, balances, offers,\n")
+	out.Normf("    transactions) and errors as JSON on stdout (other messages are printed on\n")
+	out.Normf("    stderr).\n")
+	out.Valuf("    settle --json list balances\n")
+	out.Normf("\n")
+
+	out.Boldf("  --yes\n")
+	out.Normf("    Skip confirmations and select the default choice when prompted.\n")
+	out.Valuf("    settle --json --yes pay von.neumann@ias.edu GBP.2 20\n")
+	out.Normf("\n")
 }
 
 // Parse parses the arguments passed to the command.
@@ -171,6 +171,10 @@ func (c *List) ExecuteAssets(
 		return errors.Trace(err)
 	}
 
+	out.JSON(map[string]interface{}{
+		"assets": assets,
+	})
+
 	out.Boldf("Assets:\n")
 	data := [][][2]string{}
 	for _, a := range assets {
@@ -206,6 +210,10 @@ func (c *List) ExecuteBalances(
 		}
 	}
 
+	out.JSON(map[string]interface{}{
+		"balances": balances,
+	})
+
 	out.Boldf("Balances:\n")
 	data := [][][2]string{}
 	for _, b := range balances {
@@ -237,6 +245,10 @@ func (c *List) ExecuteTrustlines(
 		return errors.Trace(err)
 	}
 
+	out.JSON(map[string]interface{}{
+		"offers": append(cOffers, pOffers...),
+	})
+
 	out.Boldf("Trustlines from you:\n")
 	data := [][][2]string{}
 	for _, o := range cOffers {
@@ -23,6 +23,7 @@ func init() {
 
 // Login a user up to a certain amount of a given asset they issued.
 type Login struct {
+	APIKey bool
 }
 
 // NewLogin constructs and initializes the command.
@@ -40,7 +41,7 @@ func (c *Login) Help(
 	ctx context.Context,
 ) {
 	out.Normf("\nUsage: ")
-	out.Boldf("settle login\n")
+	out.Boldf("settle login [--api-key]\n")
 	out.Normf("\n")
 	out.Normf("  Logging in will store your credentials locally under:\n")
 	out.Valuf("  ~/.settle/credentials-" + string(env.Get(ctx).Environment) + "\n")
@@ -49,7 +50,15 @@ func (c *Login) Help(
 	out.Valuf("von.neumann@ias.edu")
 	out.Normf(" where ")
 	out.Valuf("ias.edu")
-	out.Normf(" is your mint) along with your password.\n")
+	out.Normf(" is your mint) along with your password or an API key (of the form\n  ")
+	out.Valuf("key_*.secret")
+	out.Normf(") granted the ")
+	out.Valuf("read")
+	out.Normf(" scope.\n")
+	out.Normf("\n")
+	out.Normf("Flags:\n")
+	out.Boldf("  api-key\n")
+	out.Normf("    Log in with an API key instead of a password.\n")
 	out.Normf("\n")
 	out.Normf("  If you don't already have access to a mint, you can register on publicly\n")
 	out.Normf("  accessible mints using: ")
@@ -62,6 +71,12 @@ func (c *Login) Parse(
 	ctx context.Context,
 	args []string,
 ) error {
+	apiKey, err := cli.GetBoolFlag(ctx, "api-key")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	c.APIKey = apiKey
+
 	return nil
 }
 
@@ -75,15 +90,19 @@ func (c *Login) Execute(
 	out.Normf("Address []: ")
 	address, _ := reader.ReadString('\n')
 
-	out.Normf("Password []: ")
-	password, _ := reader.ReadString('\n')
+	if c.APIKey {
+		out.Normf("API key []: ")
+	} else {
+		out.Normf("Password []: ")
+	}
+	secret, _ := reader.ReadString('\n')
 
 	if err := Confirm(ctx, "login"); err != nil {
 		return errors.Trace(err)
 	}
 
 	err := cli.Login(ctx,
-		strings.TrimSpace(address), strings.TrimSpace(password))
+		strings.TrimSpace(address), strings.TrimSpace(secret), c.APIKey)
 	if err != nil {
 		return errors.Trace(err)
 	}
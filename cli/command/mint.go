@@ -104,6 +104,10 @@ func (c *Mint) Execute(
 		return errors.Trace(err)
 	}
 
+	out.JSON(map[string]interface{}{
+		"asset": asset,
+	})
+
 	out.Boldf("Asset created:\n")
 	out.Normf("  ID      : ")
 	out.Valuf("%s\n", asset.ID)
@@ -0,0 +1,414 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/spolu/settle/cli"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/out"
+	"github.com/spolu/settle/mint"
+)
+
+// OffersAction represents an action of the offers command.
+type OffersAction string
+
+const (
+	// CmdNmOffers is the command name.
+	CmdNmOffers cli.CmdName = "offers"
+
+	// OfAcList lists the offers of the current user.
+	OfAcList OffersAction = "list"
+	// OfAcShow shows an offer along with its crossings.
+	OfAcShow OffersAction = "show"
+	// OfAcTopUp tops up an offer.
+	OfAcTopUp OffersAction = "topup"
+	// OfAcReprice reprices an offer.
+	OfAcReprice OffersAction = "reprice"
+)
+
+func init() {
+	cli.Registrar[CmdNmOffers] = NewOffers
+}
+
+// Offers lists, shows, tops up or reprices the trustlines (canonical offers)
+// of the current user.
+type Offers struct {
+	Action OffersAction
+	ID     string
+	Amount big.Int
+	Price  string
+}
+
+// NewOffers constructs and initializes the command.
+func NewOffers() cli.Command {
+	return &Offers{}
+}
+
+// Name returns the command name.
+func (c *Offers) Name() cli.CmdName {
+	return CmdNmOffers
+}
+
+// Help prints out the help message for the command.
+func (c *Offers) Help(
+	ctx context.Context,
+) {
+	out.Normf("\nUsage: ")
+	out.Boldf("settle offers [<action> <trustline> [<value>]]\n")
+	out.Normf("\n")
+	out.Normf("  Lists your trustlines (active, consumed and closed) along with their remainder\n")
+	out.Normf("  and utilisation, shows the crossings that consumed one of them, or tops up or\n")
+	out.Normf("  reprices one of them.\n")
+	out.Normf("\n")
+	out.Normf("  Trustlines can't be modified once created: topping up or repricing a\n")
+	out.Normf("  trustline closes it and creates a new one for the same pair in its place.\n")
+	out.Normf("  Transactions that reserved part of the closed trustline before it was closed\n")
+	out.Normf("  still cross it: if they are canceled, the reserved amount is credited back to\n")
+	out.Normf("  the closed trustline, not to the new one.\n")
+	out.Normf("\n")
+	out.Normf("Arguments:\n")
+	out.Boldf("  action\n")
+	out.Normf("    The action to perform (defaults to list):\n")
+	out.Normf("     - list: lists your trustlines.\n")
+	out.Normf("     - show: shows a trustline along with the crossings that consumed it.\n")
+	out.Normf("     - topup: replaces a trustline with one whose amount is its remainder\n")
+	out.Normf("       increased by the specified amount, at the same price.\n")
+	out.Normf("     - reprice: replaces a trustline with one of the same remainder at the\n")
+	out.Normf("       specified price.\n")
+	out.Valuf("    list show topup reprice\n")
+	out.Normf("\n")
+	out.Boldf("  trustline\n")
+	out.Normf("    The ID of the trustline as returned by the ")
+	out.Boldf("offers")
+	out.Normf(" command.\n")
+	out.Valuf("    spolu@m.settle.network[offer_Z1vJtLYFUFgSWwy0]\n")
+	out.Normf("\n")
+	out.Boldf("  value\n")
+	out.Normf("    The amount to add to the trustline (topup) or its new price (reprice),\n")
+	out.Normf("    expressed as for the ")
+	out.Boldf("trust")
+	out.Normf(" command.\n")
+	out.Valuf("    42 100/125\n")
+	out.Normf("\n")
+	out.Normf("Examples:\n")
+	out.Valuf("  settle offers\n")
+	out.Valuf("  settle offers show spolu@m.settle.network[offer_Z1vJtLYFUFgSWwy0]\n")
+	out.Valuf("  settle offers topup spolu@m.settle.network[offer_Z1vJtLYFUFgSWwy0] 100\n")
+	out.Valuf("  settle offers reprice spolu@m.settle.network[offer_Z1vJtLYFUFgSWwy0] 100/125\n")
+	out.Normf("\n")
+}
+
+// Parse parses the arguments passed to the command.
+func (c *Offers) Parse(
+	ctx context.Context,
+	args []string,
+) error {
+	creds := cli.GetCredentials(ctx)
+	if creds == nil {
+		return errors.Trace(
+			errors.Newf("You need to be logged in (try `settle help login`)."))
+	}
+
+	if len(args) == 0 {
+		c.Action = OfAcList
+		return nil
+	}
+	action, args := args[0], args[1:]
+
+	c.Action = OffersAction(action)
+	switch c.Action {
+	case OfAcList:
+		return nil
+	case OfAcShow, OfAcTopUp, OfAcReprice:
+	default:
+		return errors.Trace(
+			errors.Newf("Unknown action: %s", action))
+	}
+
+	if len(args) == 0 {
+		return errors.Trace(
+			errors.Newf("Trustline ID required."))
+	}
+	id, args := args[0], args[1:]
+
+	owner, _, err := mint.NormalizedOwnerAndTokenFromID(ctx, id)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if owner != fmt.Sprintf("%s@%s", creds.Username, creds.Host) {
+		return errors.Trace(
+			errors.Newf("You can only manage your own trustlines."))
+	}
+	c.ID = id
+
+	switch c.Action {
+	case OfAcTopUp:
+		if len(args) == 0 {
+			return errors.Trace(
+				errors.Newf("Amount required."))
+		}
+		amount := args[0]
+
+		var amt big.Int
+		if _, success := amt.SetString(amount, 10); !success ||
+			amt.Sign() <= 0 {
+			return errors.Newf("Invalid amount: %s", amount)
+		}
+		c.Amount = amt
+
+	case OfAcReprice:
+		if len(args) == 0 {
+			return errors.Trace(
+				errors.Newf("Price required."))
+		}
+		price := args[0]
+
+		m := PriceRegexp.FindStringSubmatch(price)
+		if len(m) == 0 {
+			return errors.Trace(errors.Newf("Invalid price: %s", price))
+		}
+		c.Price = price
+	}
+
+	return nil
+}
+
+// Execute the command or return a human-friendly error.
+func (c *Offers) Execute(
+	ctx context.Context,
+) error {
+	switch c.Action {
+	case OfAcList:
+		return c.ExecuteList(ctx)
+	case OfAcShow:
+		return c.ExecuteShow(ctx)
+	case OfAcTopUp, OfAcReprice:
+		return c.ExecuteReplace(ctx)
+	}
+	return nil
+}
+
+// ExecuteList lists the trustlines of the current user grouped by status.
+func (c *Offers) ExecuteList(
+	ctx context.Context,
+) error {
+	offers, err := ListOffers(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	out.JSON(map[string]interface{}{
+		"offers": offers,
+	})
+
+	for _, status := range []mint.OfStatus{
+		mint.OfStActive, mint.OfStConsumed, mint.OfStClosed,
+	} {
+		out.Boldf("%s trustlines:\n", strings.Title(string(status)))
+		data := [][][2]string{}
+		for _, o := range offers {
+			if o.Status != status {
+				continue
+			}
+			data = append(data, [][2]string{
+				[2]string{"ID", o.ID},
+				[2]string{"Pair", o.Pair},
+				[2]string{"Price", o.Price},
+				[2]string{"Amount", o.Amount.String()},
+				[2]string{"Remainder", o.Remainder.String()},
+				[2]string{"Utilisation", Utilisation(&o)},
+			})
+		}
+		if len(data) == 0 {
+			out.Normf("  No trustline.\n")
+		} else {
+			(&List{}).OutList(ctx, data)
+		}
+	}
+
+	return nil
+}
+
+// ExecuteShow shows a trustline of the current user along with its crossings.
+func (c *Offers) ExecuteShow(
+	ctx context.Context,
+) error {
+	offer, err := RetrieveOffer(ctx, c.ID)
+	if err != nil {
+		return errors.Trace(err)
+	} else if offer == nil {
+		return errors.Trace(
+			errors.Newf("Offer does not exists."))
+	}
+
+	crossings, err := ListOfferCrossings(ctx, c.ID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	out.JSON(map[string]interface{}{
+		"offer":     offer,
+		"crossings": crossings,
+	})
+
+	out.Boldf("Trustline:\n")
+	PrintOffer(ctx, offer)
+
+	out.Boldf("Crossings:\n")
+	data := [][][2]string{}
+	for _, cr := range crossings {
+		data = append(data, [][2]string{
+			[2]string{"Created", fmt.Sprintf("%d", cr.Created)},
+			[2]string{"Transaction", cr.Transaction},
+			[2]string{"Amount", cr.Amount.String()},
+			[2]string{"Status", string(cr.Status)},
+		})
+	}
+	if len(data) == 0 {
+		out.Normf("  No crossing.\n")
+	} else {
+		(&List{}).OutList(ctx, data)
+	}
+
+	return nil
+}
+
+// ExecuteReplace tops up or reprices a trustline of the current user by
+// closing it and creating a new one for the same pair.
+func (c *Offers) ExecuteReplace(
+	ctx context.Context,
+) error {
+	offer, err := RetrieveOffer(ctx, c.ID)
+	if err != nil {
+		return errors.Trace(err)
+	} else if offer == nil {
+		return errors.Trace(
+			errors.Newf("Offer does not exists."))
+	} else if offer.Status != mint.OfStActive {
+		return errors.Trace(
+			errors.Newf("Only active trustlines can be modified (%s).",
+				offer.Status))
+	}
+
+	out.Boldf("Trustline to %s:\n", c.Action)
+	PrintOffer(ctx, offer)
+
+	out.Boldf("Proposed trustline:\n")
+	out.Normf("  Pair        : ")
+	out.Valuf("%s\n", offer.Pair)
+	out.Normf("  Price       : ")
+	out.Valuf("%s\n", c.price(offer))
+	out.Normf("  Amount      : ")
+	out.Valuf("%s\n", c.amount(offer).String())
+
+	if err := Confirm(ctx, string(c.Action)); err != nil {
+		return errors.Trace(err)
+	}
+
+	// The offer is closed first so that its remainder can't be consumed
+	// anymore and the new offer is computed from its final remainder.
+	offer, err = CloseOffer(ctx, c.ID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	created, err := CreateOffer(ctx,
+		offer.Pair, *c.amount(offer), c.price(offer))
+	if err != nil {
+		c.recover(ctx, offer)
+		return errors.Trace(err)
+	}
+
+	out.JSON(map[string]interface{}{
+		"offer":        created,
+		"closed_offer": offer,
+	})
+
+	out.Boldf("Trustline created:\n")
+	PrintOffer(ctx, created)
+
+	return nil
+}
+
+// recover prints the offer that was closed and how to recreate its
+// replacement when it failed to be created.
+func (c *Offers) recover(
+	ctx context.Context,
+	offer *mint.OfferResource,
+) {
+	out.Warnf("[Warning] The trustline was closed but its replacement failed to be created.\n")
+	out.Boldf("Closed trustline:\n")
+	PrintOffer(ctx, offer)
+
+	pair, err := mint.AssetResourcesFromPair(ctx, offer.Pair)
+	if err != nil {
+		return
+	}
+	out.Normf("You can create the replacement trustline with:\n")
+	out.Valuf("  settle trust %s %s.%d %s with %s.%d at %s\n",
+		pair[1].Owner, pair[1].Code, pair[1].Scale, c.amount(offer).String(),
+		pair[0].Code, pair[0].Scale, c.price(offer))
+}
+
+// amount returns the amount of the offer replacing the provided offer.
+func (c *Offers) amount(
+	offer *mint.OfferResource,
+) *big.Int {
+	amount := new(big.Int).Set(offer.Remainder)
+	if c.Action == OfAcTopUp {
+		amount.Add(amount, &c.Amount)
+	}
+	return amount
+}
+
+// price returns the price of the offer replacing the provided offer.
+func (c *Offers) price(
+	offer *mint.OfferResource,
+) string {
+	if c.Action == OfAcReprice {
+		return c.Price
+	}
+	return offer.Price
+}
+
+// Utilisation returns the share of an offer's amount that was consumed by
+// transactions, as a percentage.
+func Utilisation(
+	offer *mint.OfferResource,
+) string {
+	if offer.Amount.Sign() == 0 {
+		return "0%"
+	}
+	used := new(big.Int).Sub(offer.Amount, offer.Remainder)
+	used.Mul(used, big.NewInt(100))
+	used.Quo(used, offer.Amount)
+	return fmt.Sprintf("%s%%", used.String())
+}
+
+// PrintOffer prints out an offer.
+func PrintOffer(
+	ctx context.Context,
+	offer *mint.OfferResource,
+) {
+	out.Normf("  ID          : ")
+	out.Valuf("%s\n", offer.ID)
+	out.Normf("  Created     : ")
+	out.Valuf("%d\n", offer.Created)
+	out.Normf("  Owner       : ")
+	out.Valuf("%s\n", offer.Owner)
+	out.Normf("  Pair        : ")
+	out.Valuf("%s\n", offer.Pair)
+	out.Normf("  Price       : ")
+	out.Valuf("%s\n", offer.Price)
+	out.Normf("  Amount      : ")
+	out.Valuf("%s\n", offer.Amount.String())
+	out.Normf("  Status      : ")
+	out.Valuf("%s\n", offer.Status)
+	out.Normf("  Remainder   : ")
+	out.Valuf("%s\n", offer.Remainder.String())
+	out.Normf("  Utilisation : ")
+	out.Valuf("%s\n", Utilisation(offer))
+}
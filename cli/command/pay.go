@@ -24,6 +24,10 @@ const (
 	CmdNmPay cli.CmdName = "pay"
 )
 
+// DefaultPathDepth is the default maximal length of the offer paths explored
+// to pay a user (see the `depth` flag).
+const DefaultPathDepth = 3
+
 func init() {
 	cli.Registrar[CmdNmPay] = NewPay
 }
@@ -32,6 +36,7 @@ func init() {
 // the required amount of quote asset.
 type Candidate struct {
 	Path      []mint.OfferResource
+	Amounts   []big.Int // Amount of base asset of each offer of Path crossed.
 	BaseAsset string
 	Amount    big.Int
 }
@@ -44,9 +49,13 @@ func (s Candidates) Len() int {
 	return len(s)
 }
 
-// Less implenents the sort.Interface
+// Less implenents the sort.Interface. Candidates are ranked by total amount
+// of base asset and then by path length.
 func (s Candidates) Less(i, j int) bool {
-	return s[i].Amount.Cmp(&s[j].Amount) < 0
+	if cmp := s[i].Amount.Cmp(&s[j].Amount); cmp != 0 {
+		return cmp < 0
+	}
+	return len(s[i].Path) < len(s[j].Path)
 }
 
 // Swap implenents the sort.Interface
@@ -56,8 +65,10 @@ func (s Candidates) Swap(i, j int) {
 
 // Pay a user up to a certain amount of a given asset they issued.
 type Pay struct {
-	QuoteAsset string
-	Amount     big.Int
+	QuoteAsset  string
+	Amount      big.Int
+	Depth       int
+	ReserveOnly bool
 }
 
 // NewPay constructs and initializes the command.
@@ -75,7 +86,7 @@ func (c *Pay) Help(
 	ctx context.Context,
 ) {
 	out.Normf("\nUsage: ")
-	out.Boldf("settle pay <user> <quote_asset> <amount>\n")
+	out.Boldf("settle pay <user> <quote_asset> <amount> [--depth=<depth>] [--reserve-only]\n")
 	out.Normf("\n")
 	out.Normf("  Paying a user with the specified asset (quote_asset) consists in finding a\n")
 	out.Normf("  path of trust betwen an asset you own or control (the base asset) and the\n")
@@ -85,11 +96,15 @@ func (c *Pay) Help(
 	out.Normf("\n")
 	out.Normf("  Finding a path and a base asset consists in:\n")
 	out.Normf("   - retrieving all the assets you control or own a balance in.\n")
-	out.Normf("   - compute set of assets trusted by the quote asset.\n")
-	out.Normf("   - use these sets to compute a trust path of length 0 or 1.\n")
+	out.Normf("   - exploring breadth-first the assets trusted by the quote asset, then the\n")
+	out.Normf("     assets trusted by these assets, and so on, skipping the offers whose\n")
+	out.Normf("     remainder does not cover the amount to pay through them.\n")
+	out.Normf("   - ranking the paths leading to an asset you control or own enough of by\n")
+	out.Normf("     the total amount of base asset they cost.\n")
 	out.Normf("\n")
-	out.Normf("  Currently, only paths of length at most 1 with one base asset are supported\n")
-	out.Normf("  (transactions with longer paths can be created using your mint API directly).\n")
+	out.Normf("  Each candidate is displayed with the offers it crosses along with the\n")
+	out.Normf("  cumulative price of each hop (the amount of the hop's asset received for the\n")
+	out.Normf("  amount of base asset paid). Only paths with one base asset are supported.\n")
 	out.Normf("\n")
 	out.Normf("Arguments:\n")
 	out.Boldf("  user\n")
@@ -107,8 +122,22 @@ func (c *Pay) Help(
 	out.Normf("    252912 represents 0.00252912 BTC).\n")
 	out.Valuf("    42\n")
 	out.Normf("\n")
+	out.Normf("Flags:\n")
+	out.Boldf("  depth\n")
+	out.Normf("    The maximal length of the trust paths explored (defaults to %d).\n",
+		DefaultPathDepth)
+	out.Valuf("    1 2 3\n")
+	out.Normf("\n")
+	out.Boldf("  reserve-only\n")
+	out.Normf("    Only reserve the transaction without settling it. It can then be settled\n")
+	out.Normf("    or canceled with the ")
+	out.Boldf("tx")
+	out.Normf(" command (reserved transactions expire after an hour).\n")
+	out.Normf("\n")
 	out.Normf("Examples:\n")
 	out.Valuf("  settle pay von.neumann@ias.edu EUR.2 150\n")
+	out.Valuf("  settle pay von.neumann@ias.edu EUR.2 150 --depth=4\n")
+	out.Valuf("  settle pay von.neumann@ias.edu EUR.2 150 --reserve-only\n")
 	out.Normf("\n")
 }
 
@@ -159,6 +188,22 @@ func (c *Pay) Parse(
 	}
 	c.Amount = amt
 
+	c.Depth = DefaultPathDepth
+	if depth, ok := cli.GetFlag(ctx, "depth"); ok {
+		d, err := strconv.Atoi(depth)
+		if err != nil || d < 1 {
+			return errors.Trace(
+				errors.Newf("Invalid depth: %s", depth))
+		}
+		c.Depth = d
+	}
+
+	reserveOnly, err := cli.GetBoolFlag(ctx, "reserve-only")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	c.ReserveOnly = reserveOnly
+
 	return nil
 }
 
@@ -185,26 +230,24 @@ func (c *Pay) Execute(
 		out.Normf("      Amount    : ")
 		out.Valuf("%s\n", c.Amount.String())
 		out.Normf("      Path      : ")
-		if len(c.Path) == 0 {
-			out.Normf("(empty)\n")
-		} else {
-			for j, o := range c.Path {
-				if j > 0 {
-					out.Normf("\n                  ")
-				}
-				out.Valuf("%s", o.Pair)
-				out.Normf(" ")
-				out.Valuf("%s", o.Price)
-			}
-			out.Normf("\n")
-		}
+		printCandidatePath(ctx, c, "                  ")
 	}
 
-	reader := bufio.NewReader(os.Stdin)
+	yes, err := cli.GetBoolFlag(ctx, "yes")
+	if err != nil {
+		return errors.Trace(err)
+	}
 
-	out.Normf("Candidate selection [0]: ")
-	choice, _ := reader.ReadString('\n')
-	choice = strings.TrimSpace(choice)
+	choice := ""
+	if yes {
+		out.Normf("Candidate selection [0]: selected with --yes.\n")
+	} else {
+		reader := bufio.NewReader(os.Stdin)
+
+		out.Normf("Candidate selection [0]: ")
+		choice, _ = reader.ReadString('\n')
+		choice = strings.TrimSpace(choice)
+	}
 
 	i := int64(0)
 	if choice != "" {
@@ -233,19 +276,7 @@ func (c *Pay) Execute(
 	out.Normf("  They receive : ")
 	out.Valuf("%s %s\n", c.QuoteAsset, c.Amount.String())
 	out.Normf("  Path         : ")
-	if len(candidate.Path) == 0 {
-		out.Normf("(empty)\n")
-	} else {
-		for j, o := range candidate.Path {
-			if j > 0 {
-				out.Normf("\n                 ")
-			}
-			out.Valuf("%s", o.Pair)
-			out.Normf(" ")
-			out.Valuf("%s", o.Price)
-		}
-		out.Normf("\n")
-	}
+	printCandidatePath(ctx, candidate, "                 ")
 
 	if err := Confirm(ctx, "pay"); err != nil {
 		return errors.Trace(err)
@@ -264,41 +295,92 @@ func (c *Pay) Execute(
 		return errors.Trace(err)
 	}
 
+	if c.ReserveOnly {
+		out.JSON(map[string]interface{}{
+			"transaction": tx,
+		})
+
+		out.Boldf("Transaction reserved:\n")
+		PrintTransaction(ctx, tx)
+		out.Normf("Settle it with: ")
+		out.Valuf("settle tx settle %s\n", tx.ID)
+		return nil
+	}
+
 	// Settle the transaction.
 	tx, err = SettleTransaction(ctx, tx.ID)
 	if err != nil {
 		return errors.Trace(err)
 	}
 
+	out.JSON(map[string]interface{}{
+		"transaction": tx,
+	})
+
 	out.Boldf("Transaction settled:\n")
-	out.Normf("  ID          : ")
-	out.Valuf("%s\n", tx.ID)
-	out.Normf("  Created     : ")
-	out.Valuf("%d\n", tx.Created)
-	out.Normf("  Owner       : ")
-	out.Valuf("%s\n", tx.Owner)
-	out.Normf("  Pair        : ")
-	out.Valuf("%s\n", tx.Pair)
-	out.Normf("  Amount      : ")
-	out.Valuf("%s\n", tx.Amount.String())
-	out.Normf("  Destination : ")
-	out.Valuf("%s\n", tx.Destination)
-	out.Normf("  Path        : ")
-	if len(tx.Path) == 0 {
+	PrintTransaction(ctx, tx)
+
+	return nil
+}
+
+// printCandidatePath prints the offers crossed by a candidate along with the
+// cumulative price of each hop, that is the amount of the base asset of the
+// offer received for the amount of base asset of the candidate paid.
+func printCandidatePath(
+	ctx context.Context,
+	c Candidate,
+	indent string,
+) {
+	if len(c.Path) == 0 {
 		out.Normf("(empty)\n")
-	} else {
-		for j, o := range tx.Path {
-			if j > 0 {
-				out.Normf("\n                ")
-			}
-			out.Valuf(o)
+		return
+	}
+	for j, o := range c.Path {
+		if j > 0 {
+			out.Normf("\n%s", indent)
+		}
+		out.Valuf("%s", o.Pair)
+		out.Normf(" ")
+		out.Valuf("%s", o.Price)
+
+		pair, err := mint.AssetResourcesFromPair(ctx, o.Pair)
+		if err != nil {
+			continue
 		}
-		out.Normf("\n")
+		out.Normf(" (cumulative ")
+		out.Valuf("%s/%s", pair[0].Name, c.BaseAsset)
+		out.Normf(" ")
+		out.Valuf("%s/%s", c.Amounts[j].String(), c.Amount.String())
+		out.Normf(")")
 	}
-	out.Normf("  Status      : ")
-	out.Valuf("%s\n", tx.Status)
+	out.Normf("\n")
+}
 
-	return nil
+// hop is a step of the breadth-first exploration of trust paths: paying
+// Amount of Asset through Path pays the required amount of quote asset.
+type hop struct {
+	Asset   string
+	Amount  big.Int
+	Path    []mint.OfferResource
+	Amounts []big.Int
+}
+
+// visited returns whether the hop's path already goes through the specified
+// asset (to avoid cycles).
+func (h hop) visited(
+	ctx context.Context,
+	asset string,
+) bool {
+	if h.Asset == asset {
+		return true
+	}
+	for _, o := range h.Path {
+		pair, err := mint.AssetResourcesFromPair(ctx, o.Pair)
+		if err == nil && pair[0].Name == asset {
+			return true
+		}
+	}
+	return false
 }
 
 // ComputeCandidates computes candidates to pay the require amount of quote
@@ -309,15 +391,17 @@ func (c *Pay) ComputeCandidates(
 	// Finding a path consists, given the quote asset `q`:
 	// - listing all the assets you control or own a balance in: `cSet`
 	//   - if `q \in cSet`, use that if possible
-	// - compute the set of assets trusted by `q`: `qSet`
-	//   - if `cSet \cap qSet` not empty, use that if possible
-	// This will generate offer path of length `{0, 1}`.
-	g, ctx := errgroup.WithContext(ctx)
+	// - exploring breadth-first the assets trusted by `q`, then the assets
+	//   trusted by these assets, up to c.Depth hops, skipping offers whose
+	//   remainder does not cover the amount to pay through them.
+	//   - each asset of `cSet` reached generates a candidate if it can cover
+	//     the amount to pay.
+	g, gCtx := errgroup.WithContext(ctx)
 
 	var cSetAssets []mint.AssetResource
 	g.Go(func() error {
 		var err error
-		cSetAssets, err = ListAssets(ctx)
+		cSetAssets, err = ListAssets(gCtx)
 		if err != nil {
 			return errors.Trace(err)
 		}
@@ -327,7 +411,7 @@ func (c *Pay) ComputeCandidates(
 	var cSetBalances []mint.BalanceResource
 	g.Go(func() error {
 		var err error
-		cSetBalances, err = ListBalances(ctx)
+		cSetBalances, err = ListBalances(gCtx)
 		if err != nil {
 			return errors.Trace(err)
 		}
@@ -337,117 +421,152 @@ func (c *Pay) ComputeCandidates(
 		return nil, errors.Trace(err)
 	}
 
+	return c.exploreCandidates(ctx, cSetAssets, cSetBalances,
+		func(ctx context.Context, asset string) ([]mint.OfferResource, error) {
+			return ListAssetOffers(ctx, asset, mint.PgTpCanonical)
+		})
+}
+
+// exploreCandidates computes candidates to pay the required amount of quote
+// asset given the assets and balances of the current user, exploring offers
+// as returned by listOffers.
+func (c *Pay) exploreCandidates(
+	ctx context.Context,
+	cSetAssets []mint.AssetResource,
+	cSetBalances []mint.BalanceResource,
+	listOffers func(context.Context, string) ([]mint.OfferResource, error),
+) (Candidates, error) {
 	for _, b := range cSetBalances {
 		if b.Asset == c.QuoteAsset && b.Value.Cmp(&c.Amount) >= 0 {
 			// We own enough of the quote asset itself to pay directly with a
 			// path of length 0 and exit early with a unique candidate.
 			return Candidates{
 				Candidate{
-					[]mint.OfferResource{},
-					c.QuoteAsset,
-					c.Amount,
+					Path:      []mint.OfferResource{},
+					Amounts:   []big.Int{},
+					BaseAsset: c.QuoteAsset,
+					Amount:    c.Amount,
 				},
 			}, nil
 		}
 	}
 
-	// Trigger the retrieval of the qSet to compute path of length 1.
-	qSetOffers, err := ListAssetOffers(ctx,
-		c.QuoteAsset, mint.PgTpCanonical)
-	if err != nil {
-		return nil, errors.Trace(err)
+	// covers returns whether we control or own enough of an asset to pay the
+	// specified amount with it.
+	covers := func(asset string, amount *big.Int) bool {
+		for _, a := range cSetAssets {
+			if a.Name == asset {
+				return true
+			}
+		}
+		for _, b := range cSetBalances {
+			if b.Asset == asset && b.Value.Cmp(amount) >= 0 {
+				return true
+			}
+		}
+		return false
 	}
 
 	candidates := Candidates{}
 
-	// Compute length 1 candidates from qSet.
-	for _, o := range qSetOffers {
-		pair, err := mint.AssetResourcesFromPair(ctx, o.Pair)
-		if err != nil {
-			// ignore error.
-			continue
+	// Offers listed so far by asset, ignoring errors (as assets of unreachable
+	// or misbehaving mints can't be paid through anyway).
+	offers := map[string][]mint.OfferResource{}
+
+	frontier := []hop{hop{
+		Asset:   c.QuoteAsset,
+		Amount:  c.Amount,
+		Path:    []mint.OfferResource{},
+		Amounts: []big.Int{},
+	}}
+	for depth := 1; depth <= c.Depth && len(frontier) > 0; depth++ {
+		// Retrieve in parallel the offers of the assets of the frontier that
+		// were not retrieved yet.
+		assets := []string{}
+		for _, h := range frontier {
+			if _, ok := offers[h.Asset]; !ok {
+				offers[h.Asset] = nil
+				assets = append(assets, h.Asset)
+			}
 		}
-
-		basePrice, quotePrice, err := plan.ExtractPrice(ctx, o.Price)
-		if err != nil {
-			// ignore error.
-			continue
+		retrieved := make([][]mint.OfferResource, len(assets))
+
+		g, gCtx := errgroup.WithContext(ctx)
+		for i := range assets {
+			i := i
+			g.Go(func() error {
+				retrieved[i], _ = listOffers(gCtx, assets[i])
+				// ignore errors.
+				return nil
+			})
 		}
-		amount := new(big.Int).Mul(&c.Amount, quotePrice)
-		amount, remainder := new(big.Int).QuoRem(
-			amount, basePrice, new(big.Int))
-
-		// Transactions do cross offers on non congruent prices, costing one
-		// base unit of quote asset. If the difference of scale between assets
-		// is high, this can cost a lot to the owner of the transaction (but if
-		// they issued it, they know).
-		if remainder.Cmp(big.NewInt(0)) > 0 {
-			amount = new(big.Int).Add(amount, big.NewInt(1))
+		if err := g.Wait(); err != nil {
+			return nil, errors.Trace(err)
+		}
+		for i, asset := range assets {
+			offers[asset] = retrieved[i]
 		}
 
-		if o.Remainder.Cmp(&c.Amount) >= 0 {
-			for _, b := range cSetBalances {
-				if b.Asset == pair[1].Name &&
-					b.Value.Cmp(amount) >= 0 {
-					candidates = append(candidates, Candidate{
-						[]mint.OfferResource{o},
-						b.Asset,
-						*amount,
-					})
+		next := []hop{}
+		for _, h := range frontier {
+			for _, o := range offers[h.Asset] {
+				if o.Status != mint.OfStActive || o.Remainder == nil {
+					continue
 				}
-			}
-			for _, a := range cSetAssets {
-				if a.Name == pair[1].Name {
+
+				pair, err := mint.AssetResourcesFromPair(ctx, o.Pair)
+				if err != nil {
+					// ignore error.
+					continue
+				}
+				if h.visited(ctx, pair[1].Name) {
+					continue
+				}
+
+				basePrice, quotePrice, err := plan.ExtractPrice(ctx, o.Price)
+				if err != nil {
+					// ignore error.
+					continue
+				}
+				amount := new(big.Int).Mul(&h.Amount, quotePrice)
+				amount, remainder := new(big.Int).QuoRem(
+					amount, basePrice, new(big.Int))
+
+				// Transactions do cross offers on non congruent prices,
+				// costing one base unit of quote asset. If the difference of
+				// scale between assets is high, this can cost a lot to the
+				// owner of the transaction (but if they issued it, they
+				// know).
+				if remainder.Cmp(big.NewInt(0)) > 0 {
+					amount = new(big.Int).Add(amount, big.NewInt(1))
+				}
+
+				// The remainder of the offer is expressed in its quote asset.
+				if o.Remainder.Cmp(amount) < 0 {
+					continue
+				}
+
+				n := hop{
+					Asset:   pair[1].Name,
+					Amount:  *amount,
+					Path:    append([]mint.OfferResource{o}, h.Path...),
+					Amounts: append([]big.Int{h.Amount}, h.Amounts...),
+				}
+
+				if covers(n.Asset, &n.Amount) {
 					candidates = append(candidates, Candidate{
-						[]mint.OfferResource{o},
-						a.Name,
-						*amount,
+						Path:      n.Path,
+						Amounts:   n.Amounts,
+						BaseAsset: n.Asset,
+						Amount:    n.Amount,
 					})
 				}
+				next = append(next, n)
 			}
 		}
+		frontier = next
 	}
 
-	// Trigger the retrieval of the tSet (asset that trust the cSet) in
-	// parrallel to compute paths of length 2.
-	// g, ctx = errgroup.WithContext(ctx)
-	//
-	// tSetOffers := make(
-	// 	[][]mint.OfferResource,
-	// 	len(cSetAssets)+len(cSetBalances),
-	// )
-	// g.Go(func() error {
-	// 	gT, ctx := errgroup.WithContext(ctx)
-	//
-	// 	for i, a := range cSetAssets {
-	// 		gT.Go(func() error {
-	// 			var err error
-	// 			tSetOffers[i], _ = ListAssetOffers(ctx,
-	// 				a.Name, mint.PgTpPropagated)
-	// 			// ignore errors.
-	// 			return nil
-	// 		})
-	// 	}
-	// 	for i, b := range cSetBalances {
-	// 		gT.Go(func() error {
-	// 			var err error
-	// 			tSetOffers[len(cSetAssets+i)], _ = ListAssetOffers(ctx,
-	// 				b.Asset, mint.PgTpPropagated)
-	// 			// ignore errors.
-	// 			return nil
-	// 		})
-	// 	}
-	//
-	// 	if err := gT.Wait(); err != nil {
-	// 		return errors.Trace(err)
-	// 	}
-	// 	return nil
-	// })
-	//
-	// if err := g.Wait(); err != nil {
-	// 	return nil, errors.Trace(err)
-	// }
-
 	sort.Sort(candidates)
 
 	return candidates, nil
@@ -0,0 +1,156 @@
+package command
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/spolu/settle/mint"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	qAsset = "q@q.test[USD.2]"
+	xAsset = "x@x.test[USD.2]"
+	yAsset = "y@y.test[USD.2]"
+	mAsset = "me@me.test[USD.2]"
+)
+
+func testOffer(
+	id string,
+	base string,
+	quote string,
+	price string,
+	remainder int64,
+) mint.OfferResource {
+	return mint.OfferResource{
+		ID:        id,
+		Pair:      base + "/" + quote,
+		Price:     price,
+		Status:    mint.OfStActive,
+		Remainder: big.NewInt(remainder),
+	}
+}
+
+type testCandidate struct {
+	Path   []string
+	Base   string
+	Amount int64
+}
+
+func TestPayExploreCandidates(
+	t *testing.T,
+) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		depth    int
+		balances []mint.BalanceResource
+		offers   []mint.OfferResource
+		expected []testCandidate
+	}{
+		{
+			name:  "ranking by amount then path length",
+			depth: 3,
+			offers: []mint.OfferResource{
+				testOffer("expensive", qAsset, mAsset, "1/2", 100),
+				testOffer("direct", qAsset, mAsset, "1/1", 100),
+				testOffer("qx", qAsset, xAsset, "1/1", 100),
+				testOffer("xm", xAsset, mAsset, "1/1", 100),
+			},
+			expected: []testCandidate{
+				{[]string{"direct"}, mAsset, 10},
+				{[]string{"xm", "qx"}, mAsset, 10},
+				{[]string{"expensive"}, mAsset, 20},
+			},
+		},
+		{
+			name:  "cycles are skipped",
+			depth: 4,
+			offers: []mint.OfferResource{
+				testOffer("qx", qAsset, xAsset, "1/1", 100),
+				testOffer("xq", xAsset, qAsset, "1/1", 100),
+				testOffer("xy", xAsset, yAsset, "1/1", 100),
+				testOffer("yx", yAsset, xAsset, "1/1", 100),
+				testOffer("ym", yAsset, mAsset, "1/1", 100),
+			},
+			expected: []testCandidate{
+				{[]string{"ym", "xy", "qx"}, mAsset, 10},
+			},
+		},
+		{
+			name:  "paths longer than depth are not explored",
+			depth: 1,
+			offers: []mint.OfferResource{
+				testOffer("qx", qAsset, xAsset, "1/1", 100),
+				testOffer("xm", xAsset, mAsset, "1/1", 100),
+			},
+			expected: []testCandidate{},
+		},
+		{
+			name:  "remainder pruned in quote asset",
+			depth: 3,
+			offers: []mint.OfferResource{
+				testOffer("short", qAsset, mAsset, "1/3", 20),
+				testOffer("enough", qAsset, mAsset, "1/3", 30),
+			},
+			expected: []testCandidate{
+				{[]string{"enough"}, mAsset, 30},
+			},
+		},
+		{
+			name:  "balances cover candidates",
+			depth: 3,
+			balances: []mint.BalanceResource{
+				{Asset: xAsset, Holder: "me@me.test", Value: big.NewInt(10)},
+				{Asset: yAsset, Holder: "me@me.test", Value: big.NewInt(5)},
+			},
+			offers: []mint.OfferResource{
+				testOffer("qx", qAsset, xAsset, "1/1", 100),
+				testOffer("qy", qAsset, yAsset, "1/1", 100),
+			},
+			expected: []testCandidate{
+				{[]string{"qx"}, xAsset, 10},
+			},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Pay{
+				QuoteAsset: qAsset,
+				Amount:     *big.NewInt(10),
+				Depth:      tt.depth,
+			}
+
+			candidates, err := c.exploreCandidates(context.Background(),
+				[]mint.AssetResource{{Name: mAsset}}, tt.balances,
+				func(
+					ctx context.Context,
+					asset string,
+				) ([]mint.OfferResource, error) {
+					offers := []mint.OfferResource{}
+					for _, o := range tt.offers {
+						pair, err := mint.AssetResourcesFromPair(ctx, o.Pair)
+						assert.Nil(t, err)
+						if pair[0].Name == asset {
+							offers = append(offers, o)
+						}
+					}
+					return offers, nil
+				})
+			assert.Nil(t, err)
+
+			actual := []testCandidate{}
+			for _, c := range candidates {
+				path := []string{}
+				for _, o := range c.Path {
+					path = append(path, o.ID)
+				}
+				actual = append(actual,
+					testCandidate{path, c.BaseAsset, c.Amount.Int64()})
+			}
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -245,6 +245,10 @@ func (c *Trust) Execute(
 		return errors.Trace(err)
 	}
 
+	out.JSON(map[string]interface{}{
+		"offer": offer,
+	})
+
 	out.Boldf("Trustline created:\n")
 	out.Normf("  ID        : ")
 	out.Valuf("%s\n", offer.ID)
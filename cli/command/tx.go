@@ -0,0 +1,335 @@
+package command
+
+import (
+	"context"
+	"sort"
+
+	"github.com/spolu/settle/cli"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/out"
+	"github.com/spolu/settle/mint"
+)
+
+// TxAction represents an action of the tx command.
+type TxAction string
+
+const (
+	// CmdNmTx is the command name.
+	CmdNmTx cli.CmdName = "tx"
+
+	// TxAcShow shows a transaction.
+	TxAcShow TxAction = "show"
+	// TxAcSettle settles a reserved transaction.
+	TxAcSettle TxAction = "settle"
+	// TxAcCancel cancels a reserved transaction.
+	TxAcCancel TxAction = "cancel"
+)
+
+func init() {
+	cli.Registrar[CmdNmTx] = NewTx
+}
+
+// Tx shows, settles or cancels an existing transaction.
+type Tx struct {
+	Action TxAction
+	ID     string
+}
+
+// NewTx constructs and initializes the command.
+func NewTx() cli.Command {
+	return &Tx{}
+}
+
+// Name returns the command name.
+func (c *Tx) Name() cli.CmdName {
+	return CmdNmTx
+}
+
+// Help prints out the help message for the command.
+func (c *Tx) Help(
+	ctx context.Context,
+) {
+	out.Normf("\nUsage: ")
+	out.Boldf("settle tx <action> <transaction>\n")
+	out.Normf("\n")
+	out.Normf("  Shows, settles or cancels a transaction. Transactions created by the pay\n")
+	out.Normf("  command with --reserve-only remain reserved until they are settled or\n")
+	out.Normf("  canceled (or expire).\n")
+	out.Normf("\n")
+	out.Normf("Arguments:\n")
+	out.Boldf("  action\n")
+	out.Normf("    The action to perform on the transaction:\n")
+	out.Normf("     - show: shows the transaction along with its operations and crossings\n")
+	out.Normf("       for each hop, as known by each of the mints involved.\n")
+	out.Normf("     - settle: settles a reserved transaction you created.\n")
+	out.Normf("     - cancel: cancels a reserved transaction you created.\n")
+	out.Valuf("    show settle cancel\n")
+	out.Normf("\n")
+	out.Boldf("  transaction\n")
+	out.Normf("    The ID of the transaction as returned by the ")
+	out.Boldf("pay")
+	out.Normf(" command.\n")
+	out.Valuf("    spolu@m.settle.network[transaction_Z1vJtLYFUFgSWwy0]\n")
+	out.Normf("\n")
+	out.Normf("Examples:\n")
+	out.Valuf("  settle tx show spolu@m.settle.network[transaction_Z1vJtLYFUFgSWwy0]\n")
+	out.Valuf("  settle tx settle spolu@m.settle.network[transaction_Z1vJtLYFUFgSWwy0]\n")
+	out.Normf("\n")
+}
+
+// Parse parses the arguments passed to the command.
+func (c *Tx) Parse(
+	ctx context.Context,
+	args []string,
+) error {
+	creds := cli.GetCredentials(ctx)
+	if creds == nil {
+		return errors.Trace(
+			errors.Newf("You need to be logged in (try `settle help login`)."))
+	}
+
+	if len(args) == 0 {
+		return errors.Trace(
+			errors.Newf("Action required."))
+	}
+	action, args := args[0], args[1:]
+
+	c.Action = TxAction(action)
+	switch c.Action {
+	case TxAcShow, TxAcSettle, TxAcCancel:
+	default:
+		return errors.Trace(
+			errors.Newf("Unknown action: %s", action))
+	}
+
+	if len(args) == 0 {
+		return errors.Trace(
+			errors.Newf("Transaction ID required."))
+	}
+	id, args := args[0], args[1:]
+
+	_, _, err := mint.NormalizedOwnerAndTokenFromID(ctx, id)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	c.ID = id
+
+	return nil
+}
+
+// Execute the command or return a human-friendly error.
+func (c *Tx) Execute(
+	ctx context.Context,
+) error {
+	owner, _, err := mint.NormalizedOwnerAndTokenFromID(ctx, c.ID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, host, err := mint.UsernameAndMintHostFromAddress(ctx, owner)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	tx, err := RetrieveTransaction(ctx, c.ID, host)
+	if err != nil {
+		return errors.Trace(err)
+	} else if tx == nil {
+		return errors.Trace(
+			errors.Newf("Transaction does not exist."))
+	}
+
+	switch c.Action {
+	case TxAcShow:
+		copies, err := RetrieveTransactionCopies(ctx, tx)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		out.JSON(map[string]interface{}{
+			"transaction": tx,
+			"copies":      copies,
+		})
+
+		out.Boldf("Transaction:\n")
+		PrintTransaction(ctx, tx)
+		PrintTransactionHops(ctx, copies)
+
+	case TxAcSettle, TxAcCancel:
+		out.Boldf("Transaction to %s:\n", c.Action)
+		PrintTransaction(ctx, tx)
+
+		if err := Confirm(ctx, string(c.Action)); err != nil {
+			return errors.Trace(err)
+		}
+
+		if c.Action == TxAcSettle {
+			tx, err = SettleTransaction(ctx, c.ID)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			out.Boldf("Transaction settled:\n")
+		} else {
+			tx, err = CancelTransaction(ctx, c.ID)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			out.Boldf("Transaction canceled:\n")
+		}
+		out.JSON(map[string]interface{}{
+			"transaction": tx,
+		})
+		PrintTransaction(ctx, tx)
+	}
+
+	return nil
+}
+
+// RetrieveTransactionCopies retrieves the transaction as known by each of the
+// mints involved in it (the mint of its owner, the mints of the owners of the
+// offers of its path and the mint of its destination). Mints that can't be
+// reached or don't know the transaction are skipped. The result is keyed by
+// mint host.
+func RetrieveTransactionCopies(
+	ctx context.Context,
+	tx *mint.TransactionResource,
+) (map[string]*mint.TransactionResource, error) {
+	owners := []string{tx.Owner}
+	for _, id := range tx.Path {
+		owner, _, err := mint.NormalizedOwnerAndTokenFromID(ctx, id)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		owners = append(owners, owner)
+	}
+	owners = append(owners, tx.Destination)
+
+	copies := map[string]*mint.TransactionResource{}
+	for _, owner := range owners {
+		_, host, err := mint.UsernameAndMintHostFromAddress(ctx, owner)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if _, ok := copies[host]; ok {
+			continue
+		}
+		t, err := RetrieveTransaction(ctx, tx.ID, host)
+		if err != nil {
+			out.Warnf("[Warning] Failed to retrieve transaction from %s: %s\n",
+				host, err.Error())
+			continue
+		} else if t == nil {
+			continue
+		}
+		copies[host] = t
+	}
+
+	return copies, nil
+}
+
+// PrintTransaction prints out a transaction.
+func PrintTransaction(
+	ctx context.Context,
+	tx *mint.TransactionResource,
+) {
+	out.Normf("  ID          : ")
+	out.Valuf("%s\n", tx.ID)
+	out.Normf("  Created     : ")
+	out.Valuf("%d\n", tx.Created)
+	out.Normf("  Owner       : ")
+	out.Valuf("%s\n", tx.Owner)
+	out.Normf("  Pair        : ")
+	out.Valuf("%s\n", tx.Pair)
+	out.Normf("  Amount      : ")
+	out.Valuf("%s\n", tx.Amount.String())
+	out.Normf("  Destination : ")
+	out.Valuf("%s\n", tx.Destination)
+	out.Normf("  Path        : ")
+	if len(tx.Path) == 0 {
+		out.Normf("(empty)\n")
+	} else {
+		for j, o := range tx.Path {
+			if j > 0 {
+				out.Normf("\n                ")
+			}
+			out.Valuf("%s", o)
+		}
+		out.Normf("\n")
+	}
+	out.Normf("  Status      : ")
+	out.Valuf("%s\n", tx.Status)
+}
+
+// PrintTransactionHops prints out the status of a transaction on each mint
+// involved in it along with its operations and crossings grouped by hop.
+func PrintTransactionHops(
+	ctx context.Context,
+	copies map[string]*mint.TransactionResource,
+) {
+	hosts := []string{}
+	for host := range copies {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	out.Boldf("Mints:\n")
+	for _, host := range hosts {
+		out.Normf("  %s : ", host)
+		out.Valuf("%s\n", copies[host].Status)
+	}
+
+	operations := map[int][]mint.OperationResource{}
+	crossings := map[int][]mint.CrossingResource{}
+	hops := []int{}
+	seen := map[int]bool{}
+	for _, host := range hosts {
+		for _, op := range copies[host].Operations {
+			hop := 0
+			if op.TransactionHop != nil {
+				hop = int(*op.TransactionHop)
+			}
+			operations[hop] = append(operations[hop], op)
+			if !seen[hop] {
+				seen[hop] = true
+				hops = append(hops, hop)
+			}
+		}
+		for _, cr := range copies[host].Crossings {
+			hop := int(cr.TransactionHop)
+			crossings[hop] = append(crossings[hop], cr)
+			if !seen[hop] {
+				seen[hop] = true
+				hops = append(hops, hop)
+			}
+		}
+	}
+	sort.Ints(hops)
+
+	for _, hop := range hops {
+		out.Boldf("Hop %d:\n", hop)
+		for _, op := range operations[hop] {
+			out.Normf("  Operation   : ")
+			out.Valuf("%s\n", op.ID)
+			out.Normf("    Asset       : ")
+			out.Valuf("%s\n", op.Asset)
+			out.Normf("    Amount      : ")
+			out.Valuf("%s\n", op.Amount.String())
+			out.Normf("    Source      : ")
+			out.Valuf("%s\n", op.Source)
+			out.Normf("    Destination : ")
+			out.Valuf("%s\n", op.Destination)
+			out.Normf("    Status      : ")
+			out.Valuf("%s\n", op.Status)
+		}
+		for _, cr := range crossings[hop] {
+			out.Normf("  Crossing    : ")
+			out.Valuf("%s\n", cr.ID)
+			out.Normf("    Offer       : ")
+			out.Valuf("%s\n", cr.Offer)
+			out.Normf("    Amount      : ")
+			out.Valuf("%s\n", cr.Amount.String())
+			out.Normf("    Status      : ")
+			out.Valuf("%s\n", cr.Status)
+		}
+	}
+}
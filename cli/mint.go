@@ -35,6 +35,24 @@ func MintFromContextCredentials(
 	}, nil
 }
 
+// mintURL constructs the URL of a path of the mint API, as advertised by the
+// discovery document of the mint.
+func (m *Mint) mintURL(
+	ctx context.Context,
+	path string,
+	query url.Values,
+) (*url.URL, error) {
+	c := &mint.Client{}
+	if err := c.Init(ctx); err != nil {
+		return nil, errors.Trace(err)
+	}
+	u, err := c.MintURL(ctx, m.Host, path, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return u, nil
+}
+
 // Post performs a POST request to the mint.
 func (m *Mint) Post(
 	ctx context.Context,
@@ -42,8 +60,11 @@ func (m *Mint) Post(
 	query url.Values,
 	params url.Values,
 ) (*int, *svc.Resp, error) {
-	req, err := http.NewRequest("POST",
-		mint.FullMintURL(ctx, m.Host, path, query).String(),
+	u, err := m.mintURL(ctx, path, query)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	req, err := http.NewRequest("POST", u.String(),
 		strings.NewReader(params.Encode()))
 	if err != nil {
 		return nil, nil, errors.Trace(err)
@@ -52,7 +73,7 @@ func (m *Mint) Post(
 	req.Header.Add("Mint-Protocol-Version", mint.ProtocolVersion)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	if m.Credentials != nil {
-		req.SetBasicAuth(m.Credentials.Username, m.Credentials.Password)
+		m.Credentials.Authenticate(req)
 	}
 
 	r, err := client.Default(ctx).Do(req)
@@ -75,15 +96,18 @@ func (m *Mint) Get(
 	path string,
 	query url.Values,
 ) (*int, *svc.Resp, error) {
-	req, err := http.NewRequest("GET",
-		mint.FullMintURL(ctx, m.Host, path, query).String(), nil)
+	u, err := m.mintURL(ctx, path, query)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	req, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
 		return nil, nil, errors.Trace(err)
 	}
 
 	req.Header.Add("Mint-Protocol-Version", mint.ProtocolVersion)
 	if m.Credentials != nil {
-		req.SetBasicAuth(m.Credentials.Username, m.Credentials.Password)
+		m.Credentials.Authenticate(req)
 	}
 
 	r, err := client.Default(ctx).Do(req)
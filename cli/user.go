@@ -17,11 +17,25 @@ import (
 	"github.com/spolu/settle/mint"
 )
 
-// Credentials rerpesents the credentials of the currently logged in user.
+// Credentials rerpesents the credentials of the currently logged in user,
+// either a password or an API key.
 type Credentials struct {
 	Username string `json:"username"`
 	Host     string `json:"mint"`
-	Password string `json:"password"`
+	Password string `json:"password,omitempty"`
+	APIKey   string `json:"api_key,omitempty"`
+}
+
+// Authenticate sets the authentication header of a request to the mint, using
+// the API key as bearer token if present or the password otherwise.
+func (c *Credentials) Authenticate(
+	req *http.Request,
+) {
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	} else {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
 }
 
 const (
@@ -90,11 +104,13 @@ func CurrentUser(
 }
 
 // Login logs the user in by storing its credentials after valdation in
-// CredentialsPath.
+// CredentialsPath. The secret is either the password of the user or, if apiKey
+// is true, an API key (of the form `key_*.secret`) with the read scope.
 func Login(
 	ctx context.Context,
 	address string,
-	password string,
+	secret string,
+	apiKey bool,
 ) error {
 	username, host, err := mint.UsernameAndMintHostFromAddress(ctx, address)
 	if err != nil {
@@ -103,7 +119,11 @@ func Login(
 	creds := &Credentials{
 		Host:     host,
 		Username: username,
-		Password: password,
+	}
+	if apiKey {
+		creds.APIKey = secret
+	} else {
+		creds.Password = secret
 	}
 
 	// Check the credentials validity.
@@ -135,7 +155,7 @@ func Login(
 		return errors.Trace(err)
 	}
 
-	err = ioutil.WriteFile(*path, formatted, 0644)
+	err = ioutil.WriteFile(*path, formatted, 0600)
 	if err != nil {
 		return errors.Trace(err)
 	}
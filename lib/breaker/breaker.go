@@ -0,0 +1,187 @@
+package breaker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is the state of a circuit breaker.
+type State string
+
+const (
+	// Closed circuits let calls through.
+	Closed State = "closed"
+	// Open circuits fail calls fast until their cooldown elapses.
+	Open State = "open"
+	// HalfOpen circuits let one trial call through to decide whether to close
+	// or open again.
+	HalfOpen State = "half_open"
+)
+
+// ErrOpen is returned by Allow when the circuit of a key is open.
+type ErrOpen struct {
+	Key   string
+	Until time.Time
+}
+
+func (e ErrOpen) Error() string {
+	return fmt.Sprintf("Circuit open for %s until %s",
+		e.Key, e.Until.Format(time.RFC3339))
+}
+
+// circuit is the circuit of a key.
+type circuit struct {
+	state    State
+	failures int
+	opened   time.Time
+}
+
+// Status is the representation of the circuit of a key.
+type Status struct {
+	Key      string
+	State    State
+	Failures int       // Consecutive failures.
+	Opened   time.Time // Time at which the circuit last opened.
+}
+
+// Breakers is a set of circuit breakers by key. A circuit opens after
+// Threshold consecutive failures, fails calls fast for Cooldown and then lets
+// one trial call through, closing on its success and opening again on its
+// failure.
+type Breakers struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mutex    *sync.Mutex
+	circuits map[string]*circuit
+}
+
+// New constructs a new set of circuit breakers.
+func New(
+	threshold int,
+	cooldown time.Duration,
+) *Breakers {
+	return &Breakers{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		mutex:     &sync.Mutex{},
+		circuits:  map[string]*circuit{},
+	}
+}
+
+// Allow returns an ErrOpen if calls for key must fail fast. Otherwise the
+// caller must report the outcome of its call with Success or Failure, or
+// Release it if the call ended without an outcome.
+func (b *Breakers) Allow(
+	key string,
+	now time.Time,
+) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	c, ok := b.circuits[key]
+	if !ok {
+		return nil
+	}
+	switch c.state {
+	case Open:
+		if now.Sub(c.opened) < b.Cooldown {
+			return ErrOpen{key, c.opened.Add(b.Cooldown)}
+		}
+		c.state = HalfOpen
+		return nil
+	case HalfOpen:
+		// A trial call is in flight.
+		return ErrOpen{key, now}
+	}
+	return nil
+}
+
+// Success records a successful call for key, closing its circuit.
+func (b *Breakers) Success(
+	key string,
+	now time.Time,
+) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// Closed circuits without failures are not tracked.
+	delete(b.circuits, key)
+}
+
+// Failure records a failed call for key, opening its circuit if the
+// threshold of consecutive failures is reached or if it was half open.
+func (b *Breakers) Failure(
+	key string,
+	now time.Time,
+) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{state: Closed}
+		b.circuits[key] = c
+	}
+	c.failures++
+	if c.state == HalfOpen || c.failures >= b.Threshold {
+		c.state = Open
+		c.opened = now
+	}
+}
+
+// Release records a call for key that ended without an outcome (canceled or
+// timed out by its caller). If it was the trial call of a half open circuit,
+// the circuit opens again without restarting its cooldown so that the next
+// call is let through as a new trial.
+func (b *Breakers) Release(
+	key string,
+	now time.Time,
+) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if c, ok := b.circuits[key]; ok && c.state == HalfOpen {
+		c.state = Open
+	}
+}
+
+// Reset closes the circuit of key, forgetting its failures.
+func (b *Breakers) Reset(
+	key string,
+) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.circuits, key)
+}
+
+// State returns the state of the circuit of key.
+func (b *Breakers) State(
+	key string,
+) State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if c, ok := b.circuits[key]; ok {
+		return c.state
+	}
+	return Closed
+}
+
+// Statuses returns the status of the circuits with failures, sorted by key.
+func (b *Breakers) Statuses() []Status {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	statuses := []Status{}
+	for key, c := range b.circuits {
+		statuses = append(statuses, Status{key, c.state, c.failures, c.opened})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Key < statuses[j].Key
+	})
+	return statuses
+}
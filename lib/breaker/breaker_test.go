@@ -0,0 +1,105 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakers(t *testing.T) {
+	b := New(3, time.Minute)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow("foo", now); err != nil {
+			t.Fatalf("Expected call %d to be allowed: %s", i, err)
+		}
+		b.Failure("foo", now)
+	}
+	if s := b.State("foo"); s != Closed {
+		t.Fatalf("Unexpected state after 2 failures: %s", s)
+	}
+
+	// A success resets the consecutive failures.
+	b.Success("foo", now)
+	if len(b.Statuses()) != 0 {
+		t.Fatalf("Expected no circuit to be tracked after a success")
+	}
+
+	for i := 0; i < 3; i++ {
+		b.Failure("foo", now)
+	}
+	if s := b.State("foo"); s != Open {
+		t.Fatalf("Unexpected state after 3 failures: %s", s)
+	}
+	if _, ok := b.Allow("foo", now.Add(30*time.Second)).(ErrOpen); !ok {
+		t.Fatalf("Expected call to fail fast while open")
+	}
+	if err := b.Allow("bar", now); err != nil {
+		t.Fatalf("Expected other keys to be allowed: %s", err)
+	}
+
+	// One trial call is let through after the cooldown.
+	if err := b.Allow("foo", now.Add(time.Minute)); err != nil {
+		t.Fatalf("Expected trial call to be allowed: %s", err)
+	}
+	if s := b.State("foo"); s != HalfOpen {
+		t.Fatalf("Unexpected state after cooldown: %s", s)
+	}
+	if _, ok := b.Allow("foo", now.Add(time.Minute)).(ErrOpen); !ok {
+		t.Fatalf("Expected concurrent calls to fail fast while half open")
+	}
+
+	// A failed trial opens the circuit again.
+	b.Failure("foo", now.Add(time.Minute))
+	if s := b.State("foo"); s != Open {
+		t.Fatalf("Unexpected state after failed trial: %s", s)
+	}
+
+	// A successful trial closes it.
+	if err := b.Allow("foo", now.Add(2*time.Minute)); err != nil {
+		t.Fatalf("Expected trial call to be allowed: %s", err)
+	}
+	b.Success("foo", now.Add(2*time.Minute))
+	if s := b.State("foo"); s != Closed {
+		t.Fatalf("Unexpected state after successful trial: %s", s)
+	}
+
+	// Reset closes an open circuit.
+	for i := 0; i < 3; i++ {
+		b.Failure("foo", now)
+	}
+	b.Reset("foo")
+	if err := b.Allow("foo", now); err != nil {
+		t.Fatalf("Expected call to be allowed after reset: %s", err)
+	}
+}
+
+func TestBreakersRelease(t *testing.T) {
+	b := New(1, time.Minute)
+	now := time.Now()
+
+	b.Failure("foo", now)
+	if err := b.Allow("foo", now.Add(time.Minute)); err != nil {
+		t.Fatalf("Expected trial call to be allowed: %s", err)
+	}
+
+	// A trial call canceled by its caller is released and the next call is
+	// let through as a new trial.
+	b.Release("foo", now.Add(time.Minute))
+	if s := b.State("foo"); s != Open {
+		t.Fatalf("Unexpected state after released trial: %s", s)
+	}
+	if err := b.Allow("foo", now.Add(time.Minute)); err != nil {
+		t.Fatalf("Expected new trial call to be allowed: %s", err)
+	}
+	if s := b.State("foo"); s != HalfOpen {
+		t.Fatalf("Unexpected state after new trial: %s", s)
+	}
+
+	// Releasing a call of a closed circuit has no effect.
+	b.Success("foo", now.Add(time.Minute))
+	b.Release("foo", now.Add(time.Minute))
+	if s := b.State("foo"); s != Closed {
+		t.Fatalf("Unexpected state after released call: %s", s)
+	}
+}
@@ -3,8 +3,13 @@ package client
 import (
 	"context"
 	"net/http"
+	"time"
 )
 
+// DefaultTimeout is the timeout of the default HTTP client. It is a backstop
+// for requests whose context has no deadline.
+const DefaultTimeout = 60 * time.Second
+
 var defaultClient = (*http.Client)(nil)
 
 // Default returns the default HTTP client to use (to avoid re-instantiating
@@ -13,7 +18,9 @@ func Default(
 	ctx context.Context,
 ) *http.Client {
 	if defaultClient == nil {
-		defaultClient = &http.Client{}
+		defaultClient = &http.Client{
+			Timeout: DefaultTimeout,
+		}
 	}
 	return defaultClient
 }
@@ -0,0 +1,106 @@
+package clock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock is the source of the current time. It is carried in context so that
+// the time used to stamp objects, compute expiries and schedule tasks can be
+// controlled in tests (see Fake).
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// system is the clock backed by the system time.
+type system struct{}
+
+// Now returns the current system time.
+func (c system) Now() time.Time {
+	return time.Now()
+}
+
+// System is the clock backed by the system time. It is the clock used when no
+// clock is stored in context.
+var System Clock = system{}
+
+// Fake is a clock whose time only changes when it is set or advanced. It is
+// safe for concurrent use.
+type Fake struct {
+	mutex *sync.Mutex
+	now   time.Time
+}
+
+// NewFake returns a fake clock set to the provided time.
+func NewFake(
+	now time.Time,
+) *Fake {
+	return &Fake{
+		mutex: &sync.Mutex{},
+		now:   now,
+	}
+}
+
+// Now returns the current time of the fake clock.
+func (c *Fake) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Set sets the time of the fake clock.
+func (c *Fake) Set(
+	now time.Time,
+) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = now
+}
+
+// Advance moves the time of the fake clock forward by the provided duration
+// and returns the new time.
+func (c *Fake) Advance(
+	d time.Duration,
+) time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
+
+// ContextKey is the type of the key used with context to carry contextual
+// clock.
+type ContextKey string
+
+const (
+	// clockKey the context.Context key to store the clock.
+	clockKey ContextKey = "clock.clock"
+)
+
+// With stores the clock in the provided context.
+func With(
+	ctx context.Context,
+	clock Clock,
+) context.Context {
+	return context.WithValue(ctx, clockKey, clock)
+}
+
+// Get returns the clock currently stored in the context or the System clock
+// if there is none.
+func Get(
+	ctx context.Context,
+) Clock {
+	if c, ok := ctx.Value(clockKey).(Clock); ok {
+		return c
+	}
+	return System
+}
+
+// Now returns the current time of the clock stored in the context.
+func Now(
+	ctx context.Context,
+) time.Time {
+	return Get(ctx).Now()
+}
@@ -0,0 +1,44 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	ctx := context.Background()
+	if Get(ctx) != System {
+		t.Fatalf("Expected the system clock without clock in context")
+	}
+
+	now := time.Unix(1000, 0)
+	f := NewFake(now)
+	ctx = With(ctx, f)
+	if Get(ctx) != f {
+		t.Fatalf("Expected the clock stored in context")
+	}
+	if !Now(ctx).Equal(now) {
+		t.Errorf("Unexpected time: %v", Now(ctx))
+	}
+}
+
+func TestFake(t *testing.T) {
+	now := time.Unix(1000, 0)
+	f := NewFake(now)
+
+	if !f.Now().Equal(now) {
+		t.Errorf("Unexpected time: %v", f.Now())
+	}
+	if a := f.Advance(time.Hour); !a.Equal(now.Add(time.Hour)) {
+		t.Errorf("Unexpected advanced time: %v", a)
+	}
+	if !f.Now().Equal(now.Add(time.Hour)) {
+		t.Errorf("Unexpected time after advance: %v", f.Now())
+	}
+
+	f.Set(now)
+	if !f.Now().Equal(now) {
+		t.Errorf("Unexpected time after set: %v", f.Now())
+	}
+}
@@ -0,0 +1,29 @@
+package clock
+
+import "net/http"
+
+type middleware struct {
+	http.Handler
+	Clock
+}
+
+// ServeHTTPC handles incoming HTTP requests and injects the current Clock in
+// their context.
+func (m middleware) ServeHTTP(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+	withClock := With(ctx, m.Clock)
+	m.Handler.ServeHTTP(w, r.WithContext(withClock))
+}
+
+// Middleware returns a middleware that injects the specified Clock in
+// requests.
+func Middleware(
+	clock Clock,
+) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return middleware{h, clock}
+	}
+}
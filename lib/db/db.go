@@ -15,43 +15,98 @@ const (
 	mapKey ContextKey = "db.map"
 )
 
+// Backend is a store that does not go through database/sql (such as an
+// in-memory store) and that can be stored in the context in place of a SQL db.
+// Transactions on a backend are begun, committed and rolled back through the
+// same Begin, Commit and LoggedRollback functions as SQL transactions.
+type Backend interface {
+	// Begin starts a new transaction on the backend.
+	Begin() (BackendTx, error)
+}
+
+// BackendTx is a transaction on a Backend. Rollback must return sql.ErrTxDone
+// if the transaction was already committed or rolled back.
+type BackendTx interface {
+	Commit() error
+	Rollback() error
+}
+
 // WithDB stores the db in the provided context under tag.
 func WithDB(
 	ctx context.Context,
 	tag string,
 	db *sqlx.DB,
 ) context.Context {
-	m := map[string]*sqlx.DB{}
+	return with(ctx, tag, db)
+}
+
+// WithBackend stores the backend in the provided context under tag.
+func WithBackend(
+	ctx context.Context,
+	tag string,
+	backend Backend,
+) context.Context {
+	return with(ctx, tag, backend)
+}
+
+// with stores a db or a backend in the provided context under tag.
+func with(
+	ctx context.Context,
+	tag string,
+	db interface{},
+) context.Context {
+	m := map[string]interface{}{}
 	if ctx.Value(mapKey) != nil {
-		m = ctx.Value(mapKey).(map[string]*sqlx.DB)
+		m = ctx.Value(mapKey).(map[string]interface{})
 	}
 	m[tag] = db
 	return context.WithValue(ctx, mapKey, m)
 }
 
-// GetDB returns the db currently stored in the context under tag.
+// GetDB returns the db currently stored in the context under tag. It returns
+// nil if no db or a backend is stored under tag.
 func GetDB(
 	ctx context.Context,
 	tag string,
 ) *sqlx.DB {
-	m := ctx.Value(mapKey).(map[string]*sqlx.DB)
-	if db, ok := m[tag]; ok {
+	if ctx.Value(mapKey) == nil {
+		return nil
+	}
+	m := ctx.Value(mapKey).(map[string]interface{})
+	if db, ok := m[tag].(*sqlx.DB); ok {
 		return db
 	}
 	return nil
 }
 
-// GetDBMap returns the db map currently stored in the context.
+// GetBackend returns the backend currently stored in the context under tag.
+// It returns nil if no backend or a SQL db is stored under tag.
+func GetBackend(
+	ctx context.Context,
+	tag string,
+) Backend {
+	if ctx.Value(mapKey) == nil {
+		return nil
+	}
+	m := ctx.Value(mapKey).(map[string]interface{})
+	if backend, ok := m[tag].(Backend); ok {
+		return backend
+	}
+	return nil
+}
+
+// GetDBMap returns the db map currently stored in the context. Its values are
+// either *sqlx.DB or Backend.
 func GetDBMap(
 	ctx context.Context,
-) map[string]*sqlx.DB {
-	return ctx.Value(mapKey).(map[string]*sqlx.DB)
+) map[string]interface{} {
+	return ctx.Value(mapKey).(map[string]interface{})
 }
 
 // WithDBMap stores the db map in the provided context.
 func WithDBMap(
 	ctx context.Context,
-	m map[string]*sqlx.DB,
+	m map[string]interface{},
 ) context.Context {
 	return context.WithValue(ctx, mapKey, m)
 }
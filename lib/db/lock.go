@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+)
+
+// MaxRetries is the maximum number of times Retry runs a DB transaction that
+// keeps failing on a serialization failure.
+const MaxRetries = 5
+
+// ErrSerializationFailure is returned by backends (see WithBackend) when a
+// transaction conflicts with a concurrent one and must be re-run.
+type ErrSerializationFailure struct {
+	Reason string
+}
+
+func (e ErrSerializationFailure) Error() string {
+	return fmt.Sprintf("Serialization failure: %s", e.Reason)
+}
+
+// ForUpdate returns the locking clause to append to a SELECT statement loading
+// rows that are about to be updated in the current transaction. Postgres locks
+// the selected rows until the transaction ends so that concurrent
+// transactions touching other rows do not serialize. Sqlite3 has no row level
+// locks and relies on exclusive transactions instead (see
+// NewSqlite3DBInMemory).
+func ForUpdate(
+	ctx context.Context,
+	tag string,
+) string {
+	if db := GetDB(ctx, tag); db != nil && db.DriverName() == "postgres" {
+		return "FOR UPDATE"
+	}
+	return ""
+}
+
+// IsSerializationFailure returns whether the error is (or was caused by) a
+// postgres serialization failure, a deadlock between transactions locking the
+// same rows in a different order, a unique violation between transactions
+// creating the same row or a backend ErrSerializationFailure. The transaction
+// that failed can safely be re-run.
+func IsSerializationFailure(
+	err error,
+) bool {
+	for err != nil {
+		if _, ok := err.(ErrSerializationFailure); ok {
+			return true
+		}
+		if e, ok := err.(*pq.Error); ok {
+			switch e.Code.Name() {
+			case "serialization_failure", "deadlock_detected",
+				"unique_violation":
+				return true
+			}
+			return false
+		}
+		cause := errors.Cause(err)
+		if cause == err {
+			return false
+		}
+		err = cause
+	}
+	return false
+}
+
+// Retry runs fn within a new transaction for the provided tag, committing it
+// if fn succeeds and rolling it back otherwise. If fn fails on a serialization
+// failure the transaction is re-run from scratch, up to MaxRetries times. fn
+// must therefore reload everything it updates from the context it receives.
+func Retry(
+	ctx context.Context,
+	tag string,
+	fn func(ctx context.Context) error,
+) error {
+	for attempt := 1; ; attempt++ {
+		tCtx := Begin(ctx, tag)
+		err := fn(tCtx)
+		if err == nil {
+			Commit(tCtx)
+			return nil
+		}
+		LoggedRollback(tCtx)
+
+		if attempt >= MaxRetries || !IsSerializationFailure(err) {
+			return errors.Trace(err)
+		}
+		logging.Logf(ctx,
+			"Transaction RETRY: tag=%s attempt=%d error=%s",
+			tag, attempt, err.Error())
+	}
+}
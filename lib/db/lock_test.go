@@ -0,0 +1,30 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSerializationFailure(
+	t *testing.T,
+) {
+	assert.False(t, IsSerializationFailure(nil))
+	assert.False(t, IsSerializationFailure(errors.Newf("foo")))
+
+	assert.True(t, IsSerializationFailure(&pq.Error{Code: "40001"}))
+	assert.True(t, IsSerializationFailure(&pq.Error{Code: "40P01"}))
+	assert.True(t, IsSerializationFailure(&pq.Error{Code: "23505"}))
+	assert.False(t, IsSerializationFailure(&pq.Error{Code: "23502"}))
+	assert.True(t, IsSerializationFailure(
+		errors.Trace(ErrSerializationFailure{"conflict"})))
+
+	err := errors.Trace(&pq.Error{Code: "40P01"})
+	assert.True(t, IsSerializationFailure(err))
+
+	err = errors.Trace(errors.NewUserErrorf(errors.Trace(err),
+		402, "transaction_failed", "The plan execution failed"))
+	assert.True(t, IsSerializationFailure(err))
+}
@@ -2,13 +2,11 @@ package db
 
 import (
 	"net/http"
-
-	"github.com/jmoiron/sqlx"
 )
 
 type middleware struct {
 	Handler http.Handler
-	M       map[string]*sqlx.DB
+	M       map[string]interface{}
 }
 
 // ServeHTTPC handles incoming HTTP requests and injects the current db.
@@ -23,7 +21,7 @@ func (m middleware) ServeHTTP(
 
 // Middleware returns a middleware that injects the specified DB in requests.
 func Middleware(
-	m map[string]*sqlx.DB,
+	m map[string]interface{},
 ) func(http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return middleware{h, m}
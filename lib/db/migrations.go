@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+)
+
+// Migration upgrades a table created by a previous version of its schema. A
+// migration adds a column to its table (along with any other change that
+// comes with it) and is only run on tables that exist and lack that column,
+// which makes migrations idempotent: tables created by CreateDBTables are
+// already up to date and are left untouched.
+type Migration struct {
+	Name    string
+	Table   string
+	Column  string
+	Migrate func(context.Context, *sqlx.Tx) error
+}
+
+var migrations = map[string][]Migration{}
+
+// RegisterMigration lets schemas register their migrations. Migrations are
+// run in the order they are registered.
+func RegisterMigration(
+	tag string,
+	migration Migration,
+) {
+	migrations[tag] = append(migrations[tag], migration)
+}
+
+// MigrateDBTables runs the migrations registered under tag that the DB
+// requires, each in its own transaction.
+func MigrateDBTables(
+	ctx context.Context,
+	tag string,
+	db *sqlx.DB,
+) error {
+	for _, m := range migrations[tag] {
+		if !hasColumn(db, m.Table, "*") || hasColumn(db, m.Table, m.Column) {
+			continue
+		}
+
+		logging.Logf(ctx, "Executing migration: tag=%s name=%s table=%s\n",
+			tag, m.Name, m.Table)
+
+		tx, err := db.Beginx()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err := m.Migrate(ctx, tx); err != nil {
+			tx.Rollback()
+			return errors.Trace(err)
+		}
+		if err := tx.Commit(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// hasColumn returns whether the table exists and has the column (or whether
+// the table exists if the column is "*").
+func hasColumn(
+	db *sqlx.DB,
+	table string,
+	column string,
+) bool {
+	rows, err := db.Query(
+		fmt.Sprintf("SELECT %s FROM %s LIMIT 0", column, table))
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
+
+// RebuildTable recreates a table with a new schema within the transaction,
+// copying its rows over. The columns of the new table are filled with the
+// expressions of selects (evaluated against the previous table, with args as
+// bind parameters). It is used for changes that can't be expressed with ALTER
+// TABLE on all drivers (such as changing the primary key).
+func RebuildTable(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	table string,
+	schema string,
+	columns string,
+	selects string,
+	args ...interface{},
+) error {
+	previous := table + "_migration"
+	for _, q := range []string{
+		fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM %s", previous, table),
+		fmt.Sprintf("DROP TABLE %s", table),
+		schema,
+	} {
+		if _, err := tx.Exec(q); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if _, err := tx.Exec(tx.Rebind(fmt.Sprintf(
+		"INSERT INTO %s (%s) SELECT %s FROM %s",
+		table, columns, selects, previous)), args...); err != nil {
+		return errors.Trace(err)
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("DROP TABLE %s", previous)); err != nil {
+		return errors.Trace(err)
+	}
+	return nil
+}
@@ -22,12 +22,16 @@ func RegisterSchema(
 	schemas[tag][table] = schema
 }
 
-// CreateDBTables creates the Mint DB tables if they don't exist.
+// CreateDBTables creates the Mint DB tables if they don't exist, after
+// migrating the existing ones (see MigrateDBTables).
 func CreateDBTables(
 	ctx context.Context,
 	tag string,
 	db *sqlx.DB,
 ) error {
+	if err := MigrateDBTables(ctx, tag, db); err != nil {
+		return errors.Trace(err)
+	}
 	for name, sch := range schemas[tag] {
 		logging.Logf(ctx, "Executing schema: tag=%s name=%s\n", tag, name)
 		_, err := db.Exec(sch)
@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/lib/metrics"
 	"github.com/spolu/settle/lib/token"
 )
 
@@ -15,10 +17,19 @@ const (
 	transactionKey ContextKey = "db.transaction"
 )
 
-// Transaction stores the current mintDB transaction.
+var transactionDuration = metrics.NewHistogram(
+	"settle_db_transaction_duration_seconds",
+	"Duration of DB transactions by tag and outcome (commit, rollback).",
+	metrics.DefaultBuckets, "tag", "outcome")
+
+// Transaction stores the current mintDB transaction. Tx is set for SQL dbs and
+// Backend for backends (see WithBackend).
 type Transaction struct {
-	Tx    *sqlx.Tx
-	Token string
+	Tx      *sqlx.Tx
+	Backend BackendTx
+	Token   string
+	Tag     string
+	Started time.Time
 }
 
 // WithTransaction stores the transaction in the provided context.
@@ -36,15 +47,26 @@ func GetTransaction(
 	return ctx.Value(transactionKey).(Transaction)
 }
 
+// GetBackendTx returns the backend transaction of the current transaction if
+// one has begun on a backend, nil otherwise.
+func GetBackendTx(
+	ctx context.Context,
+) BackendTx {
+	if ctx.Value(transactionKey) == nil {
+		return nil
+	}
+	return GetTransaction(ctx).Backend
+}
+
 // Begin returns a new context with a new transaction set.
 func Begin(
 	ctx context.Context,
 	tag string,
 ) context.Context {
-	if ctx.Value(mapKey) == nil || GetDB(ctx, tag) == nil {
+	if GetDB(ctx, tag) == nil && GetBackend(ctx, tag) == nil {
 		panic(fmt.Sprintf("db: no DB in context for tag %s", tag))
 	}
-	if ctx.Value(transactionKey) != nil && GetTransaction(ctx).Tx != nil {
+	if ctx.Value(transactionKey) != nil && GetTransaction(ctx).active() {
 		panic(fmt.Sprintf(
 			"db: re-entrant transaction %s (re-entrant tag: %s)",
 			GetTransaction(ctx).Token, tag))
@@ -52,10 +74,40 @@ func Begin(
 	token := token.New("tx")
 	logging.Logf(ctx,
 		"Transaction BEGIN: tag=%s token=%s", tag, token)
-	return WithTransaction(ctx, Transaction{
-		Tx:    GetDB(ctx, tag).MustBegin(),
-		Token: token,
-	})
+	transaction := Transaction{
+		Token:   token,
+		Tag:     tag,
+		Started: time.Now(),
+	}
+	if backend := GetBackend(ctx, tag); backend != nil {
+		tx, err := backend.Begin()
+		if err != nil {
+			panic(err)
+		}
+		transaction.Backend = tx
+	} else {
+		transaction.Tx = GetDB(ctx, tag).MustBegin()
+	}
+	return WithTransaction(ctx, transaction)
+}
+
+// BeginSnapshot returns a new context with a new read-only transaction set
+// whose reads all see the same consistent snapshot of the DB. Sqlite3
+// transactions already provide that guarantee, postgres ones need to be
+// explicitly upgraded to repeatable read.
+func BeginSnapshot(
+	ctx context.Context,
+	tag string,
+) context.Context {
+	ctx = Begin(ctx, tag)
+	if GetDB(ctx, tag) != nil && GetDB(ctx, tag).DriverName() == "postgres" {
+		_, err := GetTransaction(ctx).Tx.Exec(
+			"SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY")
+		if err != nil {
+			panic(err)
+		}
+	}
+	return ctx
 }
 
 // Commit commits the transaction in the current context.
@@ -64,10 +116,11 @@ func Commit(
 ) {
 	logging.Logf(ctx,
 		"Transaction COMMIT: token=%s", GetTransaction(ctx).Token)
-	err := GetTransaction(ctx).Tx.Commit()
+	err := GetTransaction(ctx).commit()
 	if err != nil {
 		panic(err)
 	}
+	GetTransaction(ctx).observe("commit")
 }
 
 // LoggedRollback logs a rollback a commit or another rollback didn't take
@@ -78,17 +131,47 @@ func Commit(
 //   defer tx.LoggedRollback(ctx)
 // ```
 func LoggedRollback(ctx context.Context) {
-	err := GetTransaction(ctx).Tx.Rollback()
+	err := GetTransaction(ctx).rollback()
 	if err != sql.ErrTxDone && err != nil {
 		panic(err)
 	} else if err == nil {
 		logging.Logf(ctx,
 			"Transaction ROLLBACK: token=%s", GetTransaction(ctx).Token)
+		GetTransaction(ctx).observe("rollback")
+	}
+}
+
+// active returns whether the transaction was begun on a SQL db or a backend.
+func (t Transaction) active() bool {
+	return t.Tx != nil || t.Backend != nil
+}
+
+// commit commits the underlying SQL or backend transaction.
+func (t Transaction) commit() error {
+	if t.Backend != nil {
+		return t.Backend.Commit()
 	}
+	return t.Tx.Commit()
+}
+
+// rollback rolls back the underlying SQL or backend transaction.
+func (t Transaction) rollback() error {
+	if t.Backend != nil {
+		return t.Backend.Rollback()
+	}
+	return t.Tx.Rollback()
+}
+
+// observe records the duration of the transaction.
+func (t Transaction) observe(
+	outcome string,
+) {
+	transactionDuration.Observe(
+		time.Now().Sub(t.Started).Seconds(), t.Tag, outcome)
 }
 
 // Ext returns the current Ext (a transaction if one has begin, or the DB
-// otherwise).
+// otherwise). It must only be used for tags storing a SQL db.
 func Ext(
 	ctx context.Context,
 	tag string,
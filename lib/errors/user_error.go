@@ -18,6 +18,7 @@ type ConcreteUserError struct {
 	ErrStatus  int    `json:"-"`
 	ErrCode    string `json:"code"`
 	ErrMessage string `json:"message"`
+	ErrTrace   string `json:"trace,omitempty"`
 }
 
 // Build constructs a ConcreteUserError from a UserError.
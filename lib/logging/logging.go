@@ -2,10 +2,104 @@ package logging
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/trace"
 )
 
+// Level is the severity of a log entry.
+type Level int
+
+const (
+	// LvDebug is used for verbose entries only useful while debugging.
+	LvDebug Level = iota
+	// LvInfo is the default level.
+	LvInfo
+	// LvWarn is used for entries that may require attention.
+	LvWarn
+	// LvError is used for unexpected errors.
+	LvError
+)
+
+var levelNames = map[Level]string{
+	LvDebug: "debug",
+	LvInfo:  "info",
+	LvWarn:  "warn",
+	LvError: "error",
+}
+
+// String returns the name of the level.
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel parses a level name (debug, info, warn, error).
+func ParseLevel(
+	name string,
+) (Level, error) {
+	for l, n := range levelNames {
+		if n == name {
+			return l, nil
+		}
+	}
+	return LvInfo, errors.Trace(errors.Newf("Invalid log level: %s", name))
+}
+
+// Format is the output format of log entries.
+type Format string
+
+const (
+	// FmText outputs entries as a message followed by key=value fields.
+	FmText Format = "text"
+	// FmJSON outputs entries as one JSON object per line.
+	FmJSON Format = "json"
+)
+
+// ParseFormat parses a format name (text, json).
+func ParseFormat(
+	name string,
+) (Format, error) {
+	switch Format(name) {
+	case FmText, FmJSON:
+		return Format(name), nil
+	}
+	return FmText, errors.Trace(errors.Newf("Invalid log format: %s", name))
+}
+
+// config is the process wide logging configuration.
+var config = struct {
+	mutex  *sync.Mutex
+	level  Level
+	format Format
+}{
+	mutex:  &sync.Mutex{},
+	level:  LvInfo,
+	format: FmText,
+}
+
+// Configure sets the minimum level and the output format of log entries.
+func Configure(
+	level Level,
+	format Format,
+) {
+	config.mutex.Lock()
+	defer config.mutex.Unlock()
+	config.level = level
+	config.format = format
+}
+
+// Fields are key/value pairs attached to a log entry.
+type Fields map[string]interface{}
+
 var silentKey = new(int)
+var fieldsKey = new(int)
 
 // SetSilent indicates that logs should not actually be omitted for this ctx
 func SetSilent(ctx context.Context, val bool) context.Context {
@@ -19,26 +113,182 @@ func Silent(ctx context.Context) bool {
 	return ok && val
 }
 
-// Log shells out to log.Print if Silent is not set.
-func Log(c context.Context, v ...interface{}) {
-	if c != nil {
-		if !Silent(c) {
-			log.Print(v...)
+// WithFields returns a context carrying the provided fields in addition to
+// the ones already carried by ctx. They are added to every entry logged with
+// the returned context.
+func WithFields(
+	ctx context.Context,
+	fields Fields,
+) context.Context {
+	merged := Fields{}
+	for k, v := range GetFields(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, fieldsKey, merged)
+}
+
+// GetFields returns the fields carried by the context.
+func GetFields(
+	ctx context.Context,
+) Fields {
+	if ctx == nil {
+		return Fields{}
+	}
+	if fields, ok := ctx.Value(fieldsKey).(Fields); ok {
+		return fields
+	}
+	return Fields{}
+}
+
+// Stack returns the stack of an error as a field value. Stacks are logged as
+// a list of lines within a single entry.
+func Stack(
+	err error,
+) []string {
+	return errors.ErrorStack(err)
+}
+
+// formatText formats an entry as a single line message followed by sorted
+// key=value fields.
+func formatText(
+	level Level,
+	msg string,
+	fields Fields,
+) string {
+	keys := []string{}
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	line := strings.Replace(msg, "\n", "\\n", -1)
+	if level != LvInfo {
+		line = strings.ToUpper(level.String()) + " " + line
+	}
+	for _, k := range keys {
+		var value string
+		switch v := fields[k].(type) {
+		case string:
+			value = v
+			if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+				value = fmt.Sprintf("%q", v)
+			}
+		case []string:
+			value = fmt.Sprintf("%q", v)
+		case fmt.Stringer:
+			value = fmt.Sprintf("%q", v.String())
+		default:
+			value = fmt.Sprintf("%v", v)
 		}
-	} else {
-		log.Print(v...)
+		line += fmt.Sprintf(" %s=%s", k, value)
 	}
+	return line
 }
 
-// Logf shells out to log.Printf if Silent is not set.
-func Logf(c context.Context, format string, v ...interface{}) {
-	if c != nil {
-		if !Silent(c) {
-			log.Printf(format, v...)
+// formatJSON formats an entry as a JSON object.
+func formatJSON(
+	level Level,
+	msg string,
+	fields Fields,
+) string {
+	entry := map[string]interface{}{}
+	for k, v := range fields {
+		if s, ok := v.(fmt.Stringer); ok {
+			v = s.String()
 		}
-	} else {
-		log.Printf(format, v...)
+		entry[k] = v
+	}
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		b, _ = json.Marshal(map[string]interface{}{
+			"time":  entry["time"],
+			"level": LvError.String(),
+			"msg":   fmt.Sprintf("Failed to format entry: %s", msg),
+			"error": err.Error(),
+		})
+	}
+	return string(b)
+}
+
+// Entry logs an entry at the provided level with the fields carried by the
+// context, the trace ID of the context and the provided fields. Entries below
+// the configured level or logged with a silent context are dropped. Entries
+// are always output on a single line.
+func Entry(
+	ctx context.Context,
+	level Level,
+	msg string,
+	fields Fields,
+) {
+	if ctx != nil && Silent(ctx) {
+		return
+	}
+
+	config.mutex.Lock()
+	minLevel, format := config.level, config.format
+	config.mutex.Unlock()
+
+	if level < minLevel {
+		return
+	}
+
+	all := Fields{}
+	for k, v := range GetFields(ctx) {
+		all[k] = v
+	}
+	if id := trace.Get(ctx); id != "" {
+		all["trace"] = id
 	}
+	for k, v := range fields {
+		all[k] = v
+	}
+	msg = strings.TrimRight(msg, "\n")
+
+	switch format {
+	case FmJSON:
+		// JSON entries carry their own timestamp and are written without the
+		// prefix and flags of the standard logger.
+		fmt.Fprintln(log.Writer(), formatJSON(level, msg, all))
+	default:
+		log.Print(formatText(level, msg, all))
+	}
+}
+
+// Debug logs an entry at the debug level.
+func Debug(ctx context.Context, msg string, fields Fields) {
+	Entry(ctx, LvDebug, msg, fields)
+}
+
+// Info logs an entry at the info level.
+func Info(ctx context.Context, msg string, fields Fields) {
+	Entry(ctx, LvInfo, msg, fields)
+}
+
+// Warn logs an entry at the warn level.
+func Warn(ctx context.Context, msg string, fields Fields) {
+	Entry(ctx, LvWarn, msg, fields)
+}
+
+// Error logs an entry at the error level.
+func Error(ctx context.Context, msg string, fields Fields) {
+	Entry(ctx, LvError, msg, fields)
+}
+
+// Log logs its arguments (formatted as by fmt.Sprint) at the info level.
+func Log(c context.Context, v ...interface{}) {
+	Entry(c, LvInfo, fmt.Sprint(v...), nil)
+}
+
+// Logf logs its arguments (formatted as by fmt.Sprintf) at the info level.
+func Logf(c context.Context, format string, v ...interface{}) {
+	Entry(c, LvInfo, fmt.Sprintf(format, v...), nil)
 }
 
 // PadRight right-pads a string.
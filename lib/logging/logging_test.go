@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/trace"
+)
+
+// capture configures the logger and returns the lines logged by f.
+func capture(
+	level Level,
+	format Format,
+	f func(),
+) []string {
+	var buf bytes.Buffer
+
+	out, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	Configure(level, format)
+	defer func() {
+		log.SetOutput(out)
+		log.SetFlags(flags)
+		Configure(LvInfo, FmText)
+	}()
+
+	f()
+
+	return strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+}
+
+func TestEntryText(t *testing.T) {
+	ctx := WithFields(trace.With(context.Background(), "trace_test"),
+		Fields{"host": "foo.bar"})
+
+	lines := capture(LvInfo, FmText, func() {
+		Debug(ctx, "Dropped", nil)
+		Warn(ctx, "Failed", Fields{
+			"code":  "not_found",
+			"stack": []string{"a", "b"},
+		})
+	})
+
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %d: %v", len(lines), lines)
+	}
+	expected := `WARN Failed code=not_found host=foo.bar stack=["a" "b"] ` +
+		`trace=trace_test`
+	if lines[0] != expected {
+		t.Errorf("expected %q, got %q", expected, lines[0])
+	}
+}
+
+func TestEntryJSON(t *testing.T) {
+	ctx := WithFields(trace.With(context.Background(), "trace_test"),
+		Fields{"host": "foo.bar"})
+	err := errors.Trace(errors.Newf("Failure"))
+
+	lines := capture(LvDebug, FmJSON, func() {
+		Error(ctx, "Unexpected error", Fields{
+			"status": 500,
+			"stack":  Stack(err),
+		})
+	})
+
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %d: %v", len(lines), lines)
+	}
+	var entry struct {
+		Level  string   `json:"level"`
+		Msg    string   `json:"msg"`
+		Host   string   `json:"host"`
+		Trace  string   `json:"trace"`
+		Status int      `json:"status"`
+		Stack  []string `json:"stack"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Level != "error" || entry.Msg != "Unexpected error" ||
+		entry.Host != "foo.bar" || entry.Trace != "trace_test" ||
+		entry.Status != 500 || len(entry.Stack) == 0 {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+}
+
+func TestParse(t *testing.T) {
+	if l, err := ParseLevel("warn"); err != nil || l != LvWarn {
+		t.Errorf("expected warn, got %v %v", l, err)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Errorf("expected error for invalid level")
+	}
+	if f, err := ParseFormat("json"); err != nil || f != FmJSON {
+		t.Errorf("expected json, got %v %v", f, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Errorf("expected error for invalid format")
+	}
+}
@@ -0,0 +1,300 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the default histogram buckets, in seconds, suited to
+// measure request and transaction latencies.
+var DefaultBuckets = []float64{
+	.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10,
+}
+
+// MtType is the type of a metric.
+type MtType string
+
+const (
+	// MtTpCounter is a monotonically increasing value.
+	MtTpCounter MtType = "counter"
+	// MtTpGauge is a value that can go up and down.
+	MtTpGauge MtType = "gauge"
+	// MtTpHistogram samples observations in configurable buckets.
+	MtTpHistogram MtType = "histogram"
+)
+
+// series is the state of a metric for one set of label values.
+type series struct {
+	values  []string
+	value   float64
+	buckets []uint64
+	count   uint64
+}
+
+// vec is a metric and its series, indexed by label values.
+type vec struct {
+	name    string
+	help    string
+	typ     MtType
+	labels  []string
+	buckets []float64
+
+	mutex  *sync.Mutex
+	series map[string]*series
+}
+
+// registry holds all the metrics registered by the process.
+var registry = struct {
+	mutex   *sync.Mutex
+	metrics map[string]*vec
+}{
+	mutex:   &sync.Mutex{},
+	metrics: map[string]*vec{},
+}
+
+// register creates and registers a new metric. It panics if a metric with the
+// same name was already registered (metrics are meant to be declared as
+// package variables).
+func register(
+	name string,
+	help string,
+	typ MtType,
+	buckets []float64,
+	labels []string,
+) *vec {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if _, ok := registry.metrics[name]; ok {
+		panic(fmt.Sprintf("metrics: duplicate metric %s", name))
+	}
+	v := &vec{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		mutex:   &sync.Mutex{},
+		series:  map[string]*series{},
+	}
+	registry.metrics[name] = v
+	return v
+}
+
+// with returns the series for the provided label values, creating it if
+// needed. v.mutex must be held.
+func (v *vec) with(
+	values []string,
+) *series {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf(
+			"metrics: %s expects %d label values, got %d",
+			v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{
+			values:  append([]string{}, values...),
+			buckets: make([]uint64, len(v.buckets)),
+		}
+		v.series[key] = s
+	}
+	return s
+}
+
+// Counter is a counter metric partitioned by labels.
+type Counter struct {
+	v *vec
+}
+
+// NewCounter creates and registers a new counter.
+func NewCounter(
+	name string,
+	help string,
+	labels ...string,
+) *Counter {
+	return &Counter{register(name, help, MtTpCounter, nil, labels)}
+}
+
+// Inc increments the counter for the provided label values by 1.
+func (c *Counter) Inc(
+	values ...string,
+) {
+	c.Add(1, values...)
+}
+
+// Add increments the counter for the provided label values by delta.
+func (c *Counter) Add(
+	delta float64,
+	values ...string,
+) {
+	c.v.mutex.Lock()
+	defer c.v.mutex.Unlock()
+	c.v.with(values).value += delta
+}
+
+// Gauge is a gauge metric partitioned by labels.
+type Gauge struct {
+	v *vec
+}
+
+// NewGauge creates and registers a new gauge.
+func NewGauge(
+	name string,
+	help string,
+	labels ...string,
+) *Gauge {
+	return &Gauge{register(name, help, MtTpGauge, nil, labels)}
+}
+
+// Set sets the gauge for the provided label values.
+func (g *Gauge) Set(
+	value float64,
+	values ...string,
+) {
+	g.v.mutex.Lock()
+	defer g.v.mutex.Unlock()
+	g.v.with(values).value = value
+}
+
+// Histogram is an histogram metric partitioned by labels.
+type Histogram struct {
+	v *vec
+}
+
+// NewHistogram creates and registers a new histogram with the provided
+// (sorted) buckets upper bounds.
+func NewHistogram(
+	name string,
+	help string,
+	buckets []float64,
+	labels ...string,
+) *Histogram {
+	return &Histogram{register(name, help, MtTpHistogram, buckets, labels)}
+}
+
+// Observe adds an observation to the histogram for the provided label values.
+func (h *Histogram) Observe(
+	value float64,
+	values ...string,
+) {
+	h.v.mutex.Lock()
+	defer h.v.mutex.Unlock()
+	s := h.v.with(values)
+	for i, b := range h.v.buckets {
+		if value <= b {
+			s.buckets[i]++
+		}
+	}
+	s.value += value
+	s.count++
+}
+
+// formatValue formats a sample value.
+func formatValue(
+	value float64,
+) string {
+	switch {
+	case math.IsInf(value, +1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// formatLabels formats label pairs, escaping values.
+func formatLabels(
+	names []string,
+	values []string,
+) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := []string{}
+	for i, n := range names {
+		v := strings.NewReplacer(
+			"\\", "\\\\", "\n", "\\n", "\"", "\\\"").Replace(values[i])
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", n, v))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// write writes the metric in the Prometheus text exposition format.
+func (v *vec) write(
+	w io.Writer,
+) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, v.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.typ)
+
+	keys := []string{}
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := v.series[k]
+		switch v.typ {
+		case MtTpHistogram:
+			names := append(append([]string{}, v.labels...), "le")
+			values := append(append([]string{}, s.values...), "")
+			for i, b := range v.buckets {
+				values[len(values)-1] = formatValue(b)
+				fmt.Fprintf(w, "%s_bucket%s %d\n", v.name,
+					formatLabels(names, values), s.buckets[i])
+			}
+			values[len(values)-1] = "+Inf"
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.name,
+				formatLabels(names, values), s.count)
+			fmt.Fprintf(w, "%s_sum%s %s\n", v.name,
+				formatLabels(v.labels, s.values), formatValue(s.value))
+			fmt.Fprintf(w, "%s_count%s %d\n", v.name,
+				formatLabels(v.labels, s.values), s.count)
+		default:
+			fmt.Fprintf(w, "%s%s %s\n", v.name,
+				formatLabels(v.labels, s.values), formatValue(s.value))
+		}
+	}
+}
+
+// Write writes all the registered metrics in the Prometheus text exposition
+// format.
+func Write(
+	w io.Writer,
+) {
+	registry.mutex.Lock()
+	names := []string{}
+	for n := range registry.metrics {
+		names = append(names, n)
+	}
+	registry.mutex.Unlock()
+	sort.Strings(names)
+
+	for _, n := range names {
+		registry.mutex.Lock()
+		v := registry.metrics[n]
+		registry.mutex.Unlock()
+		v.write(w)
+	}
+}
+
+// Handler serves all the registered metrics for scraping by Prometheus.
+func Handler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	Write(w)
+}
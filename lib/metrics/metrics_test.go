@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	c := NewCounter("test_counter_total", "A test counter.", "name")
+	g := NewGauge("test_gauge", "A test gauge.")
+	h := NewHistogram("test_histogram_seconds", "A test histogram.",
+		[]float64{0.1, 1}, "name")
+
+	c.Inc("foo")
+	c.Add(2, "foo")
+	c.Inc("b\"ar")
+	g.Set(7)
+	h.Observe(0.05, "foo")
+	h.Observe(0.5, "foo")
+	h.Observe(5, "foo")
+
+	var buf bytes.Buffer
+	Write(&buf)
+	out := buf.String()
+
+	expected := []string{
+		"# HELP test_counter_total A test counter.",
+		"# TYPE test_counter_total counter",
+		"test_counter_total{name=\"foo\"} 3",
+		"test_counter_total{name=\"b\\\"ar\"} 1",
+		"# TYPE test_gauge gauge",
+		"test_gauge 7",
+		"# TYPE test_histogram_seconds histogram",
+		"test_histogram_seconds_bucket{name=\"foo\",le=\"0.1\"} 1",
+		"test_histogram_seconds_bucket{name=\"foo\",le=\"1\"} 2",
+		"test_histogram_seconds_bucket{name=\"foo\",le=\"+Inf\"} 3",
+		"test_histogram_seconds_sum{name=\"foo\"} 5.55",
+		"test_histogram_seconds_count{name=\"foo\"} 3",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out, e+"\n") {
+			t.Errorf("Expected line %q in output:\n%s", e, out)
+		}
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	NewCounter("test_duplicate_total", "A test counter.")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected registering a duplicate metric to panic")
+		}
+	}()
+	NewCounter("test_duplicate_total", "A test counter.")
+}
@@ -1,7 +1,10 @@
 package out
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/fatih/color"
 )
@@ -13,6 +16,12 @@ var yellow *color.Color
 var magenta *color.Color
 var redBold *color.Color
 
+// human is where human-readable messages are printed.
+var human io.Writer
+
+// machine is whether JSON output is enabled (see SetJSON).
+var machine bool
+
 func init() {
 	white = color.New(color.FgWhite)
 	bold = color.New(color.Bold)
@@ -20,34 +29,69 @@ func init() {
 	yellow = color.New(color.FgYellow)
 	magenta = color.New(color.FgMagenta)
 	redBold = color.New(color.FgRed, color.Bold)
+
+	human = color.Output
+}
+
+// SetJSON enables or disables JSON output. When enabled, human-readable
+// messages are printed without colors on stderr, leaving stdout to the objects
+// printed with JSON.
+func SetJSON(enabled bool) {
+	machine = enabled
+	if enabled {
+		color.NoColor = true
+		human = os.Stderr
+	} else {
+		human = color.Output
+	}
+}
+
+// IsJSON returns whether JSON output is enabled.
+func IsJSON() bool {
+	return machine
+}
+
+// JSON prints an object encoded as JSON on stdout if JSON output is enabled.
+func JSON(v interface{}) {
+	if !machine {
+		return
+	}
+	e := json.NewEncoder(os.Stdout)
+	e.SetIndent("", "  ")
+	e.Encode(v)
+}
+
+// printf prints a message with the specified color.
+func printf(c *color.Color, format string, v ...interface{}) {
+	fmt.Fprint(human, c.SprintfFunc()(format, v...))
 }
 
 // Normf prints a normal message.
 func Normf(format string, v ...interface{}) {
-	fmt.Printf(format, v...)
+	fmt.Fprintf(human, format, v...)
 }
 
 // Boldf prints a bold message.
 func Boldf(format string, v ...interface{}) {
-	bold.PrintfFunc()(format, v...)
+	printf(bold, format, v...)
 }
 
 // Valuf prints an example message.
 func Valuf(format string, v ...interface{}) {
-	cyan.PrintfFunc()(format, v...)
+	printf(cyan, format, v...)
 }
 
 // Warnf prints a warning message.
 func Warnf(format string, v ...interface{}) {
-	yellow.PrintfFunc()(format, v...)
+	printf(yellow, format, v...)
 }
 
 // Errof prints an error message.
 func Errof(format string, v ...interface{}) {
-	redBold.PrintfFunc()(format, v...)
+	printf(redBold, format, v...)
 }
 
 // Statf prints an error message.
 func Statf(format string, v ...interface{}) {
-	magenta.PrintfFunc()(format, v...)
+	printf(magenta, format, v...)
 }
@@ -0,0 +1,196 @@
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spolu/settle/lib/errors"
+)
+
+// Rate is a number of events allowed per period. Events are allowed in bursts
+// of up to Count events, the budget being refilled continuously over the
+// period.
+type Rate struct {
+	Count  int
+	Period time.Duration
+}
+
+// Off is the rate of disabled limiters.
+var Off = Rate{}
+
+var periods = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// ParseRate parses a rate of the form `count/unit` where unit is one of `s`,
+// `m` or `h` (for example `60/m`). `off` (or `0`) disables limiting.
+func ParseRate(
+	s string,
+) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return Off, nil
+	}
+	ss := strings.Split(s, "/")
+	if len(ss) != 2 {
+		return Off, errors.Trace(errors.Newf("Invalid rate: %s", s))
+	}
+	count, err := strconv.Atoi(ss[0])
+	if err != nil || count < 0 {
+		return Off, errors.Trace(errors.Newf("Invalid rate count: %s", s))
+	}
+	period, ok := periods[ss[1]]
+	if !ok {
+		return Off, errors.Trace(errors.Newf("Invalid rate unit: %s", s))
+	}
+	return Rate{count, period}, nil
+}
+
+// Disabled returns whether the rate disables limiting.
+func (r Rate) Disabled() bool {
+	return r.Count == 0
+}
+
+// String returns the `count/unit` representation of the rate.
+func (r Rate) String() string {
+	if r.Disabled() {
+		return "off"
+	}
+	for unit, period := range periods {
+		if period == r.Period {
+			return strconv.Itoa(r.Count) + "/" + unit
+		}
+	}
+	return strconv.Itoa(r.Count) + "/" + r.Period.String()
+}
+
+// bucket is the token bucket of a key.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// cleanupInterval is the number of calls after which buckets that are full
+// are dropped from a limiter to bound its memory usage.
+const cleanupInterval = 1024
+
+// Limiter limits the rate of events per key with token buckets. It is safe
+// for concurrent use.
+type Limiter struct {
+	rate Rate
+
+	mutex   *sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+}
+
+// NewLimiter constructs a new limiter for the provided rate.
+func NewLimiter(
+	rate Rate,
+) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		mutex:   &sync.Mutex{},
+		buckets: map[string]*bucket{},
+	}
+}
+
+// Rate returns the rate of the limiter.
+func (l *Limiter) Rate() Rate {
+	return l.rate
+}
+
+// refill returns the bucket of the key refilled up to now. It must be called
+// with the mutex held.
+func (l *Limiter) refill(
+	key string,
+	now time.Time,
+) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens:  float64(l.rate.Count),
+			updated: now,
+		}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(float64(l.rate.Count),
+			b.tokens+float64(l.rate.Count)*
+				float64(elapsed)/float64(l.rate.Period))
+		b.updated = now
+	}
+	return b
+}
+
+// wait returns the time until the bucket holds one token.
+func (l *Limiter) wait(
+	b *bucket,
+) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration(
+		(1 - b.tokens) * float64(l.rate.Period) / float64(l.rate.Count))
+}
+
+// cleanup drops the buckets that are full (as if they did not exist). It
+// must be called with the mutex held.
+func (l *Limiter) cleanup(
+	now time.Time,
+) {
+	l.calls++
+	if l.calls%cleanupInterval != 0 {
+		return
+	}
+	for key := range l.buckets {
+		if b := l.refill(key, now); b.tokens >= float64(l.rate.Count) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Allow consumes one event for the key if its budget allows it. If it does
+// not, it returns false and the time after which an event will be allowed.
+func (l *Limiter) Allow(
+	key string,
+	now time.Time,
+) (bool, time.Duration) {
+	if l.rate.Disabled() {
+		return true, 0
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	defer l.cleanup(now)
+
+	b := l.refill(key, now)
+	if b.tokens < 1 {
+		return false, l.wait(b)
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Check returns whether an event would be allowed for the key without
+// consuming it, and if not, the time after which an event will be allowed.
+func (l *Limiter) Check(
+	key string,
+	now time.Time,
+) (bool, time.Duration) {
+	if l.rate.Disabled() {
+		return true, 0
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	b := l.refill(key, now)
+	if b.tokens < 1 {
+		return false, l.wait(b)
+	}
+	return true, 0
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	for s, expected := range map[string]Rate{
+		"60/m": Rate{60, time.Minute},
+		"5/s":  Rate{5, time.Second},
+		"1/h":  Rate{1, time.Hour},
+		"off":  Off,
+		"0":    Off,
+	} {
+		r, err := ParseRate(s)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %s", s, err)
+		}
+		if r != expected {
+			t.Errorf("Unexpected rate for %q: %v", s, r)
+		}
+	}
+
+	for _, s := range []string{"", "60", "60/d", "a/m", "-1/m"} {
+		if _, err := ParseRate(s); err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(Rate{2, time.Minute})
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("foo", now); !ok {
+			t.Fatalf("Expected event %d to be allowed", i)
+		}
+	}
+	if ok, _ := l.Check("foo", now); ok {
+		t.Errorf("Expected check to fail once the budget is exhausted")
+	}
+	ok, wait := l.Allow("foo", now)
+	if ok {
+		t.Errorf("Expected event to be limited")
+	}
+	if wait != 30*time.Second {
+		t.Errorf("Unexpected wait: %s", wait)
+	}
+
+	// Other keys have their own budget.
+	if ok, _ := l.Allow("bar", now); !ok {
+		t.Errorf("Expected event for another key to be allowed")
+	}
+
+	// The budget is refilled over time.
+	if ok, _ := l.Allow("foo", now.Add(30*time.Second)); !ok {
+		t.Errorf("Expected event to be allowed after refill")
+	}
+	if ok, _ := l.Allow("foo", now.Add(30*time.Second)); ok {
+		t.Errorf("Expected event to be limited after partial refill")
+	}
+
+	// Check does not consume events.
+	if ok, _ := l.Check("baz", now); !ok {
+		t.Errorf("Expected check to succeed")
+	}
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("baz", now); !ok {
+			t.Fatalf("Expected event %d to be allowed after check", i)
+		}
+	}
+
+	off := NewLimiter(Off)
+	for i := 0; i < 10; i++ {
+		if ok, _ := off.Allow("foo", now); !ok {
+			t.Fatalf("Expected disabled limiter to allow events")
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package recoverer
 
 import (
+	"fmt"
 	"net/http"
 	"runtime/debug"
+	"strings"
 
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/logging"
@@ -21,16 +23,19 @@ func (m middleware) ServeHTTP(
 	ctx := r.Context()
 	defer func() {
 		if err := recover(); err != nil {
-			logging.Logf(ctx, "---------------------")
-			logging.Logf(ctx, "RECOVERER PRINT STACK")
-			logging.Logf(ctx, "---------------------")
-			debug.PrintStack()
-			logging.Logf(ctx, "---------------------")
+			stack := strings.Split(
+				strings.TrimSpace(string(debug.Stack())), "\n")
 			if e, ok := err.(error); ok {
-				logging.Logf(ctx, "Panic: error=%q", e.Error())
+				logging.Error(ctx, "Panic", logging.Fields{
+					"error": e.Error(),
+					"stack": stack,
+				})
 				respond.Error(ctx, w, errors.Trace(e))
 			} else {
-				logging.Logf(ctx, "Non error panic: dump=%+v", err)
+				logging.Error(ctx, "Non error panic", logging.Fields{
+					"dump":  fmt.Sprintf("%+v", err),
+					"stack": stack,
+				})
 				respond.Error(ctx, w, errors.Newf("Non error panic: %+v", err))
 			}
 		}
@@ -27,19 +27,24 @@ func (m middleware) ServeHTTP(
 	url := *r.URL
 	wp := mutil.WrapWriter(w)
 
-	logging.Logf(ctx, "HTTP Request: method=%q path=%q remote=%q",
-		r.Method, url.RequestURI(), r.RemoteAddr)
+	logging.Info(ctx, "HTTP Request", logging.Fields{
+		"method": r.Method,
+		"path":   url.RequestURI(),
+		"remote": r.RemoteAddr,
+	})
 
 	defer func() {
 		wp.WriteHeader(http.StatusOK)
-		logging.Logf(ctx, "HTTP Response: status=%d latency=%d",
-			wp.Status(), time.Now().Sub(start)/time.Millisecond)
+		logging.Info(ctx, "HTTP Response", logging.Fields{
+			"status":   wp.Status(),
+			"duration": time.Now().Sub(start).Seconds(),
+		})
 	}()
 
 	m.Handler.ServeHTTP(wp, r)
 }
 
-// Middleware that logs methods, URLs, remote addresses, status, lantency.
+// Middleware that logs methods, URLs, remote addresses, status, duration.
 func Middleware(h http.Handler) http.Handler {
 	return middleware{h}
 }
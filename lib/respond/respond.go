@@ -11,6 +11,7 @@ import (
 	"github.com/spolu/settle/lib/format"
 	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/lib/trace"
 )
 
 func panicError() errors.UserError {
@@ -27,6 +28,7 @@ func errorResponse(
 	ctx context.Context,
 	err *errors.ConcreteUserError,
 ) svc.Resp {
+	err.ErrTrace = trace.Get(ctx)
 	resp := svc.Resp{
 		"error": format.JSONPtr(err),
 	}
@@ -60,24 +62,26 @@ func Error(
 ) {
 	// Handle UserError
 	if e := errors.ExtractUserError(err); e != nil {
-		logging.Logf(ctx,
-			"UserError: status=%d code=%q message=%q",
-			e.Status(), e.Code(), e.Message())
-		for _, line := range errors.ErrorStack(err) {
-			logging.Logf(ctx, "  %s", line)
-		}
-		for _, line := range errors.ErrorStack(e.Cause()) {
-			logging.Logf(ctx, "    %s", line)
+		level := logging.LvWarn
+		if e.Status() >= http.StatusInternalServerError {
+			level = logging.LvError
 		}
+		logging.Entry(ctx, level, "UserError", logging.Fields{
+			"status":  e.Status(),
+			"code":    e.Code(),
+			"message": e.Message(),
+			"stack":   logging.Stack(err),
+			"cause":   logging.Stack(e.Cause()),
+		})
 
 		resp := errorResponse(ctx, errors.Build(e))
 		Respond(ctx, w, e.Status(), nil, resp)
 	} else {
-		logging.Logf(ctx,
-			"Unexpected error: error=%q", err.Error())
-		for _, line := range errors.ErrorStack(err) {
-			logging.Logf(ctx, "  %s", line)
-		}
+		logging.Error(ctx, "Unexpected error", logging.Fields{
+			"status": http.StatusInternalServerError,
+			"error":  err.Error(),
+			"stack":  logging.Stack(err),
+		})
 
 		resp := errorResponse(ctx, errors.Build(panicError()))
 		Respond(ctx, w, http.StatusInternalServerError, nil, resp)
@@ -0,0 +1,87 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/spolu/settle/lib/token"
+)
+
+const (
+	// Header is the HTTP header used to pass the trace ID across services.
+	Header string = "Settle-Trace-Id"
+)
+
+// IDRegexp matches the trace IDs accepted from the trace header of incoming
+// requests. Other IDs are replaced by a new one.
+var IDRegexp = regexp.MustCompile("^[a-zA-Z0-9_\\-\\.:]{1,128}$")
+
+// ContextKey is the type of the key used with context to carry the trace ID.
+type ContextKey string
+
+const (
+	// traceKey the context.Context key to store the trace ID.
+	traceKey ContextKey = "trace.id"
+)
+
+// New generates a new trace ID.
+func New() string {
+	return token.New("trace")
+}
+
+// With stores the trace ID in the provided context.
+func With(
+	ctx context.Context,
+	id string,
+) context.Context {
+	return context.WithValue(ctx, traceKey, id)
+}
+
+// Get retrieves the trace ID from the context (empty if none was set).
+func Get(
+	ctx context.Context,
+) string {
+	if ctx == nil {
+		return ""
+	}
+	if id, ok := ctx.Value(traceKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// SetHeader sets the trace header of an outbound request to the trace ID of
+// the context if any.
+func SetHeader(
+	ctx context.Context,
+	req *http.Request,
+) {
+	if id := Get(ctx); id != "" {
+		req.Header.Set(Header, id)
+	}
+}
+
+type middleware struct {
+	http.Handler
+}
+
+// ServeHTTP handles incoming HTTP requests, continuing the trace passed in
+// the trace header if valid (see IDRegexp) or starting a new one.
+func (m middleware) ServeHTTP(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	id := r.Header.Get(Header)
+	if !IDRegexp.MatchString(id) {
+		id = New()
+	}
+	w.Header().Set(Header, id)
+
+	m.Handler.ServeHTTP(w, r.WithContext(With(r.Context(), id)))
+}
+
+// Middleware that sets the trace ID of incoming requests.
+func Middleware(h http.Handler) http.Handler {
+	return middleware{h}
+}
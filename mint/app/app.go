@@ -4,20 +4,26 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"goji.io"
 
 	"github.com/facebookgo/grace/gracehttp"
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/env"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/lib/recoverer"
 	"github.com/spolu/settle/lib/requestlogger"
+	"github.com/spolu/settle/lib/trace"
 	"github.com/spolu/settle/mint"
 	"github.com/spolu/settle/mint/async"
 	"github.com/spolu/settle/mint/lib/authentication"
+	"github.com/spolu/settle/mint/lib/hosting"
+	"github.com/spolu/settle/mint/lib/peering"
+	"github.com/spolu/settle/mint/lib/throttling"
 
 	// force initialization of schemas
 	_ "github.com/spolu/settle/mint/model/schemas"
@@ -30,6 +36,12 @@ func BackgroundContextFromFlags(
 	dsnFlag string,
 	hstFlag string,
 	prtFlag string,
+	wrkFlag string,
+	admFlag string,
+	rtlFlag string,
+	ppfFlag string,
+	pubFlag string,
+	pbkFlag string,
 ) (context.Context, error) {
 	ctx := context.Background()
 
@@ -47,9 +59,27 @@ func BackgroundContextFromFlags(
 		port = prtFlag
 	}
 	mintEnv.Config[mint.EnvCfgPort] = port
+	mintEnv.Config[mint.EnvCfgWorkers] = wrkFlag
+	mintEnv.Config[mint.EnvCfgAdminSecret] = admFlag
+
+	if _, err := throttling.ParseLimits(rtlFlag); err != nil {
+		return nil, errors.Trace(err)
+	}
+	mintEnv.Config[mint.EnvCfgRateLimits] = rtlFlag
+
+	if _, err := peering.LoadFile(ppfFlag); err != nil {
+		return nil, errors.Trace(err)
+	}
+	mintEnv.Config[mint.EnvCfgPeerPolicy] = ppfFlag
+	mintEnv.Config[mint.EnvCfgPublicURL] = pubFlag
+	mintEnv.Config[mint.EnvCfgPublicKeys] = pbkFlag
 
 	ctx = env.With(ctx, &mintEnv)
 
+	if _, err := mint.GetPublicURL(ctx); err != nil {
+		return nil, errors.Trace(err)
+	}
+
 	mintDB, err := db.NewDBForDSN(ctx,
 		dsnFlag,
 		fmt.Sprintf("sqlite3://~/.mint/mint-%s.db",
@@ -88,19 +118,24 @@ func Build(
 	}
 
 	mux := goji.NewMux()
+	mux.Use(trace.Middleware)
 	mux.Use(requestlogger.Middleware)
 	mux.Use(recoverer.Middleware)
 	mux.Use(db.Middleware(db.GetDBMap(ctx)))
 	mux.Use(env.Middleware(env.Get(ctx)))
+	mux.Use(clock.Middleware(clock.Get(ctx)))
 	mux.Use(async.Middleware(async.Get(ctx)))
+	mux.Use(hosting.Middleware)
 	mux.Use(authentication.Middleware)
+	mux.Use(throttling.Middleware)
 
-	logging.Logf(ctx, "Initializing: environment=%s host=%s port=%s",
-		env.Get(ctx).Environment, mint.GetHost(ctx), mint.GetPort(ctx))
+	logging.Logf(ctx, "Initializing: environment=%s hosts=%s port=%s",
+		env.Get(ctx).Environment,
+		strings.Join(mint.GetHosts(ctx), ","), mint.GetPort(ctx))
 
 	(&Controller{}).Bind(mux)
 
-	// Start on async worker.
+	// Start the async workers.
 	go func() {
 		async.Get(ctx).Run()
 	}()
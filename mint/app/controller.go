@@ -1,6 +1,8 @@
 package app
 
 import (
+	"github.com/spolu/settle/lib/metrics"
+	"github.com/spolu/settle/mint"
 	"github.com/spolu/settle/mint/endpoint"
 	"goji.io"
 	"goji.io/pat"
@@ -22,13 +24,21 @@ func (c *Controller) Bind(
 	mux.HandleFunc(pat.Get("/assets"), endpoint.HandlerFor(endpoint.EndPtListAssets))
 	mux.HandleFunc(pat.Get("/balances"), endpoint.HandlerFor(endpoint.EndPtListBalances))
 	mux.HandleFunc(pat.Get("/assets/:asset/balances"), endpoint.HandlerFor(endpoint.EndPtListAssetBalances))
+	mux.HandleFunc(pat.Get("/offers"), endpoint.HandlerFor(endpoint.EndPtListOffers))
+	mux.HandleFunc(pat.Get("/offers/:offer/crossings"), endpoint.HandlerFor(endpoint.EndPtListOfferCrossings))
 	// mux.HandleFunc(pat.Get("/assets/:asset/operations"), endpoint.HandlerFor(endpoint.EndPtListOperations))
 
+	mux.HandleFunc(pat.Post("/keys"), endpoint.HandlerFor(endpoint.EndPtCreateAPIKey))
+	mux.HandleFunc(pat.Get("/keys"), endpoint.HandlerFor(endpoint.EndPtListAPIKeys))
+	mux.HandleFunc(pat.Post("/keys/:key/revoke"), endpoint.HandlerFor(endpoint.EndPtRevokeAPIKey))
+
 	// Mixed.
 	mux.HandleFunc(pat.Post("/transactions/:transaction/settle"), endpoint.HandlerFor(endpoint.EndPtSettleTransaction))
 	mux.HandleFunc(pat.Post("/transactions/:transaction/cancel"), endpoint.HandlerFor(endpoint.EndPtCancelTransaction))
 
 	// Public.
+	mux.HandleFunc(pat.Get(mint.DiscoveryPath), endpoint.HandlerFor(endpoint.EndPtRetrieveMint))
+
 	mux.HandleFunc(pat.Get("/offers/:offer"), endpoint.HandlerFor(endpoint.EndPtRetrieveOffer))
 	mux.HandleFunc(pat.Get("/operations/:operation"), endpoint.HandlerFor(endpoint.EndPtRetrieveOperation))
 	mux.HandleFunc(pat.Get("/transactions/:transaction"), endpoint.HandlerFor(endpoint.EndPtRetrieveTransaction))
@@ -41,4 +51,26 @@ func (c *Controller) Bind(
 
 	mux.HandleFunc(pat.Get("/assets/:asset"), endpoint.HandlerFor(endpoint.EndPtRetrieveAsset))
 	mux.HandleFunc(pat.Get("/assets/:asset/offers"), endpoint.HandlerFor(endpoint.EndPtListAssetOffers))
+
+	// Admin.
+	mux.HandleFunc(pat.Get("/admin/tasks"), endpoint.HandlerFor(endpoint.EndPtListTasks))
+	mux.HandleFunc(pat.Post("/admin/tasks/:task/requeue"), endpoint.HandlerFor(endpoint.EndPtRequeueTask))
+	mux.HandleFunc(pat.Post("/admin/tasks/:task/cancel"), endpoint.HandlerFor(endpoint.EndPtCancelTask))
+	mux.HandleFunc(pat.Post("/admin/tasks/:task/run"), endpoint.HandlerFor(endpoint.EndPtRunTask))
+
+	mux.HandleFunc(pat.Post("/admin/users"), endpoint.HandlerFor(endpoint.EndPtCreateUser))
+	mux.HandleFunc(pat.Get("/admin/users"), endpoint.HandlerFor(endpoint.EndPtListUsers))
+	mux.HandleFunc(pat.Post("/admin/users/:username/disable"), endpoint.HandlerFor(endpoint.EndPtDisableUser))
+	mux.HandleFunc(pat.Post("/admin/users/:username/enable"), endpoint.HandlerFor(endpoint.EndPtEnableUser))
+	mux.HandleFunc(pat.Post("/admin/users/:username/password"), endpoint.HandlerFor(endpoint.EndPtResetUserPassword))
+
+	mux.HandleFunc(pat.Get("/admin/peers"), endpoint.HandlerFor(endpoint.EndPtListPeers))
+	mux.HandleFunc(pat.Post("/admin/peers/:peer"), endpoint.HandlerFor(endpoint.EndPtUpdatePeer))
+	mux.HandleFunc(pat.Delete("/admin/peers/:peer"), endpoint.HandlerFor(endpoint.EndPtDeletePeer))
+	mux.HandleFunc(pat.Get("/admin/breakers"), endpoint.HandlerFor(endpoint.EndPtListBreakers))
+
+	// Monitoring (metrics require the admin secret as bearer token).
+	mux.HandleFunc(pat.Get("/metrics"), metrics.Handler)
+	mux.HandleFunc(pat.Get("/healthz"), endpoint.HandlerFor(endpoint.EndPtCheckLiveness))
+	mux.HandleFunc(pat.Get("/readyz"), endpoint.HandlerFor(endpoint.EndPtCheckReadiness))
 }
@@ -1,8 +1,20 @@
 package mint
 
+import "time"
+
 // TkName represents a task name.
 type TkName string
 
+const (
+	// TkExpireTransaction is the name of the tasks expiring transactions
+	// TransactionExpiryMs after their creation.
+	TkExpireTransaction TkName = "ExpireTransaction"
+)
+
+// PropagationRetryInterval is the interval before the first retry of the tasks
+// propagating objects to other mints. It doubles after each retry.
+const PropagationRetryInterval = 1 * time.Second
+
 // TkStatus represents a task status.
 type TkStatus string
 
@@ -13,4 +25,6 @@ const (
 	TkStSucceeded TkStatus = "succeeded"
 	// TkStFailed retried more than max retries with no success.
 	TkStFailed TkStatus = "failed"
+	// TkStCanceled canceled by an administrator before its execution.
+	TkStCanceled TkStatus = "canceled"
 )
@@ -0,0 +1,192 @@
+package async
+
+import (
+	"context"
+
+	"github.com/spolu/settle/lib/clock"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+// loadTask loads a task by token or returns a 404 user error.
+func loadTask(
+	ctx context.Context,
+	token string,
+) (*model.Task, error) {
+	m, err := model.LoadTaskByToken(ctx, token)
+	if err != nil {
+		return nil, errors.Trace(err) // 500
+	} else if m == nil {
+		return nil, errors.Trace(errors.NewUserErrorf(nil,
+			404, "task_not_found",
+			"The task you are trying to access does not exist: %s.",
+			token,
+		))
+	}
+	return m, nil
+}
+
+// leased returns whether a task is currently leased by a worker.
+func leased(
+	ctx context.Context,
+	m *model.Task,
+) bool {
+	return m.LeaseExpiry != nil && m.LeaseExpiry.After(clock.Now(ctx))
+}
+
+// RequeueTask marks a failed or canceled task as pending for immediate
+// execution. Its retry count is reset and its retries are scheduled from its
+// requeue time so that it benefits from its full retry schedule again.
+func (a *Async) RequeueTask(
+	ctx context.Context,
+	token string,
+) (*model.Task, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	m, err := loadTask(ctx, token)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if m.Status != mint.TkStFailed && m.Status != mint.TkStCanceled {
+		return nil, errors.Trace(errors.NewUserErrorf(nil,
+			400, "task_not_requeueable",
+			"Only failed or canceled tasks can be requeued, the task you "+
+				"are trying to requeue is %s: %s.",
+			m.Status, token,
+		))
+	}
+
+	now := clock.Now(ctx).UTC()
+	m.Status = mint.TkStPending
+	m.Retry = 0
+	m.Deadline = now
+	m.Requeued = &now
+	m.LeaseOwner = nil
+	m.LeaseExpiry = nil
+
+	if err := m.Save(ctx); err != nil {
+		return nil, errors.Trace(err) // 500
+	}
+
+	db.Commit(ctx)
+
+	mint.Log(ctx, logging.LvInfo, "Requeued task", logging.Fields{
+		"task":    m.Name,
+		"subject": m.Subject,
+		"token":   m.Token,
+	})
+
+	a.wake()
+
+	return m, nil
+}
+
+// CancelTask marks a pending task that is not being executed as canceled.
+func (a *Async) CancelTask(
+	ctx context.Context,
+	token string,
+) (*model.Task, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	canceled, err := model.CancelTaskByToken(ctx, token, clock.Now(ctx))
+	if err != nil {
+		return nil, errors.Trace(err) // 500
+	}
+
+	m, err := loadTask(ctx, token)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if !canceled {
+		if m.Status != mint.TkStPending {
+			return nil, errors.Trace(errors.NewUserErrorf(nil,
+				400, "task_not_cancelable",
+				"Only pending tasks can be canceled, the task you are "+
+					"trying to cancel is %s: %s.",
+				m.Status, token,
+			))
+		}
+		return nil, errors.Trace(errors.NewUserErrorf(nil,
+			409, "task_leased",
+			"The task you are trying to cancel is currently being "+
+				"executed by a worker: %s.",
+			token,
+		))
+	}
+
+	db.Commit(ctx)
+
+	mint.Log(ctx, logging.LvInfo, "Canceled task", logging.Fields{
+		"task":    m.Name,
+		"subject": m.Subject,
+		"token":   m.Token,
+	})
+
+	return m, nil
+}
+
+// RunTask executes a pending or failed task immediately and synchronously,
+// regardless of its deadline, and returns its updated state.
+func (a *Async) RunTask(
+	ctx context.Context,
+	token string,
+) (*model.Task, error) {
+	claimed, err := func() (*model.Task, error) {
+		ctx := db.Begin(ctx, "mint")
+		defer db.LoggedRollback(ctx)
+
+		m, err := loadTask(ctx, token)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		now := clock.Now(ctx)
+		claimed, err := model.ClaimTaskByToken(ctx,
+			a.Owner, token, now, now.Add(a.Lease))
+		if err != nil {
+			return nil, errors.Trace(err) // 500
+		} else if claimed == nil {
+			if leased(ctx, m) {
+				return nil, errors.Trace(errors.NewUserErrorf(nil,
+					409, "task_leased",
+					"The task you are trying to run is currently being "+
+						"executed by a worker: %s.",
+					token,
+				))
+			}
+			return nil, errors.Trace(errors.NewUserErrorf(nil,
+				400, "task_not_runnable",
+				"Only pending or failed tasks can be run, the task you are "+
+					"trying to run is %s: %s.",
+				m.Status, token,
+			))
+		}
+
+		db.Commit(ctx)
+
+		return claimed, nil
+	}()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	a.RunOne(claimed)
+
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	m, err := loadTask(ctx, token)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	db.Commit(ctx)
+
+	return m, nil
+}
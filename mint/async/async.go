@@ -2,12 +2,19 @@ package async
 
 import (
 	"context"
-	"sort"
-	"sync"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/env"
 	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/lib/metrics"
+	"github.com/spolu/settle/lib/token"
+	"github.com/spolu/settle/lib/trace"
 	"github.com/spolu/settle/mint"
 	"github.com/spolu/settle/mint/model"
 )
@@ -23,266 +30,445 @@ type Task interface {
 	// Subject is the subject of the task, generally an object ID.
 	Subject() string
 
-	// MaxRetries caps the total number of retries.
-	MaxRetries() uint
+	// Payload is the JSON serializable value holding the arguments of the
+	// task other than its subject. It is nil if the task has none.
+	Payload() interface{}
 
-	// DeadlineforRetry returns the deadline for the provided retry count.
-	DeadlineForRetry(retry uint) time.Time
+	// Policy is the retry policy of the task.
+	Policy() RetryPolicy
 
 	// Execute idempotently runs the task to completion or errors.
 	Execute(ctx context.Context) error
 }
 
+// Generator reconstructs a task from its creation time, subject and JSON
+// payload as stored in the DB.
+type Generator func(
+	ctx context.Context,
+	created time.Time,
+	subject string,
+	payload []byte,
+) (Task, error)
+
 // Registrar is used to register task generators within the module. The role of
 // the generator for a given mint.TkName is to reconstruct a task from its
-// subject, status and retry.
-var Registrar = map[mint.TkName](func(
-	context.Context,
-	time.Time,
-	string,
-) Task){}
-
-// Deadline represent an execution deadline for task.
-type Deadline struct {
-	Task  Task
-	Model *model.Task
-}
-
-// Deadlines is a slice of Deadline implementing sort.Interface
-type Deadlines []Deadline
-
-// Len implenents the sort.Interface
-func (s Deadlines) Len() int {
-	return len(s)
-}
-
-// Less implenents the sort.Interface
-func (s Deadlines) Less(i, j int) bool {
-	return s[i].Deadline().After(s[j].Deadline())
-}
-
-// Swap implenents the sort.Interface
-func (s Deadlines) Swap(i, j int) {
-	s[i], s[j] = s[j], s[i]
-}
-
-// Deadline returns the current deadline for the task.
-func (d Deadline) Deadline() time.Time {
-	return d.Task.DeadlineForRetry(d.Model.Retry)
+// creation time, subject and payload.
+var Registrar = map[mint.TkName]Generator{}
+
+// WithoutPayload returns a generator for tasks whose only argument is their
+// subject.
+func WithoutPayload(
+	constructor func(context.Context, time.Time, string) Task,
+) Generator {
+	return func(
+		ctx context.Context,
+		created time.Time,
+		subject string,
+		payload []byte,
+	) (Task, error) {
+		return constructor(ctx, created, subject), nil
+	}
 }
 
-// Async represents the state of an async queue.
+var pendingTasks = metrics.NewGauge(
+	"settle_mint_async_pending_tasks",
+	"Number of tasks pending in the async queue.")
+
+var taskExecutions = metrics.NewCounter(
+	"settle_mint_task_executions_total",
+	"Number of task executions by task name.",
+	"task")
+
+var taskRetries = metrics.NewCounter(
+	"settle_mint_task_retries_total",
+	"Number of failed task executions scheduled for retry by task name.",
+	"task")
+
+var taskFailures = metrics.NewCounter(
+	"settle_mint_task_failures_total",
+	"Number of tasks that failed after exhausting their retries by task "+
+		"name.",
+	"task")
+
+// DefaultLeaseDuration is the default duration of the lease a worker takes on
+// a task it executes. Leases are renewed while the task executes so that a
+// task is only executed again by another worker if its worker died.
+const DefaultLeaseDuration = 1 * time.Minute
+
+// DefaultPollInterval is the default interval at which idle workers poll the
+// DB for eligible tasks (tasks queued by other processes or whose deadline
+// was reached).
+const DefaultPollInterval = 2 * time.Second
+
+// maxLastErrorLength is the maximum length of the task errors persisted with
+// tasks.
+const maxLastErrorLength = 4096
+
+// Async represents the state of an async queue. Tasks are stored in the DB and
+// executed by workers that claim them with a lease, so that multiple mint
+// processes can share the same DB without executing a task twice.
 type Async struct {
-	Ctx       context.Context
-	Pending   Deadlines
-	Scheduled chan Deadline
-
-	mutex *sync.Mutex
+	Ctx     context.Context
+	Owner   string // Identifies this process's workers in task leases.
+	Workers int
+	Lease   time.Duration
+	Poll    time.Duration
+
+	wakeup   chan struct{}
+	lastTick int64 // Unix time in ns of the last scheduler loop iteration.
 }
 
-// NewAsync constructs a new async state.
+// NewAsync constructs a new async state. The number of workers is read from
+// the mint env config (see mint.EnvCfgWorkers).
 func NewAsync(
 	ctx context.Context,
 ) (*Async, error) {
-	a := &Async{
-		Ctx:       ctx,
-		Pending:   nil,
-		Scheduled: make(chan Deadline),
-		mutex:     &sync.Mutex{},
-	}
-
-	ctx = db.Begin(ctx, "mint")
-	defer db.LoggedRollback(ctx)
-
-	tasks, err := model.LoadPendingTasks(ctx)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	db.Commit(ctx)
-
-	deadlines := Deadlines{}
-	for _, m := range tasks {
-		generator, ok := Registrar[m.Name]
-		if !ok {
-			return nil, errors.Trace(
-				errors.Newf("Unregistered task name: %s", m.Name))
+	workers := 1
+	if w := env.Get(ctx).Config[mint.EnvCfgWorkers]; w != "" {
+		n, err := strconv.Atoi(w)
+		if err != nil || n < 1 {
+			return nil, errors.Trace(errors.Newf(
+				"Invalid number of async workers: %s", w))
 		}
-
-		d := Deadline{
-			Task:  generator(ctx, m.Created, m.Subject),
-			Model: m,
-		}
-		deadlines = append(deadlines, d)
-		mint.Logf(ctx, "Retrieved task: "+
-			"name=%s subject=%s retry=%d deadline=%q",
-			d.Task.Name(), d.Task.Subject(), d.Model.Retry, d.Deadline())
+		workers = n
 	}
 
-	a.Pending = deadlines
-	sort.Sort(a.Pending)
+	a := &Async{
+		Ctx:     ctx,
+		Owner:   token.New("worker"),
+		Workers: workers,
+		Lease:   DefaultLeaseDuration,
+		Poll:    DefaultPollInterval,
+		wakeup:  make(chan struct{}, workers),
+	}
 
-	a.schedule(ctx)
+	mint.Log(ctx, logging.LvInfo, "Initialized async", logging.Fields{
+		"owner":   a.Owner,
+		"workers": a.Workers,
+	})
 
 	return a, nil
 }
 
-// schedule attempts to schedule an eligible task in a non blocking way. If
-// there is no task to schedule or the Scheduled channel is blocked, it's a
-// no-op. Can be called as often as needed.
-// a.mutex must be held.
-func (a *Async) schedule(
-	ctx context.Context,
-) {
-	if len(a.Pending) == 0 {
-		return
-	}
-	d := a.Pending[len(a.Pending)-1]
-	if d.Deadline().Before(time.Now()) {
-		select {
-		case a.Scheduled <- d:
-			a.Pending = a.Pending[:len(a.Pending)-1]
-
-			mint.Logf(ctx, "Scheduled task: "+
-				"name=%s subject=%s retry=%d deadline=%s",
-				d.Task.Name(), d.Task.Subject(), d.Model.Retry,
-				d.Deadline().String())
-		default:
-		}
-	} else {
-		mint.Logf(ctx, "Scheduler next task: "+
-			"name=%s subject=%s retry=%d deadline=%s duration=%s",
-			d.Task.Name(), d.Task.Subject(), d.Model.Retry,
-			d.Deadline().String(), d.Deadline().Sub(time.Now()).String())
+// tick records an iteration of the scheduler loop of a worker.
+func (a *Async) tick() {
+	atomic.StoreInt64(&a.lastTick, time.Now().UnixNano())
+}
+
+// LastTick returns the time of the last iteration of the scheduler loop of the
+// workers of this process. It is the zero time if no worker ever ran.
+func (a *Async) LastTick() time.Time {
+	t := atomic.LoadInt64(&a.lastTick)
+	if t == 0 {
+		return time.Time{}
 	}
+	return time.Unix(0, t)
+}
 
+// wake notifies an idle worker (if any) that a task may be eligible for
+// execution. It never blocks.
+func (a *Async) wake() {
+	select {
+	case a.wakeup <- struct{}{}:
+	default:
+	}
 }
 
-// Queue queues a new task by adding it to the list of pending tasks and
-// calling Schedule. Queue does not begin a new transaction as it is meant to
-// be called within a transaction block (offer creation, operation creation,
-// ...).
+// Queue queues a new task by storing it in the DB and waking up a worker.
+// Queue does not begin a new transaction as it is meant to be called within a
+// transaction block (offer creation, operation creation, ...).
 func (a *Async) Queue(
 	ctx context.Context,
 	t Task,
 ) error {
+	payload := model.JSON("{}")
+	if p := t.Payload(); p != nil {
+		raw, err := json.Marshal(p)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		payload = model.JSON(raw)
+	}
+
 	m, err := model.CreateTask(ctx,
 		t.Created(),
 		t.Name(),
 		t.Subject(),
+		payload,
 		mint.TkStPending,
 		0,
+		t.Policy().DeadlineForRetry(t.Created(), 0),
 	)
 	if err != nil {
 		return errors.Trace(err)
 	}
 
-	a.AppendAndSchedule(ctx, Deadline{
-		Task:  t,
-		Model: m,
+	mint.Log(ctx, logging.LvInfo, "Queued task", logging.Fields{
+		"task":     string(t.Name()),
+		"subject":  t.Subject(),
+		"retry":    m.Retry,
+		"deadline": m.Deadline,
 	})
 
+	a.wake()
+
 	return nil
 }
 
-// LockAndSchedule locks and schedule a task if possible
-func (a *Async) LockAndSchedule(
-	ctx context.Context,
-) {
-	a.mutex.Lock()
-	defer a.mutex.Unlock()
+// claim attempts to lease an eligible task. It returns nil if there is no
+// task to execute.
+func (a *Async) claim() (*model.Task, error) {
+	ctx := db.Begin(a.Ctx, "mint")
+	defer db.LoggedRollback(ctx)
 
-	sort.Sort(a.Pending)
+	now := clock.Now(a.Ctx)
+	m, err := model.ClaimTask(ctx, a.Owner, now, now.Add(a.Lease))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	count, err := model.CountPendingTasks(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	pendingTasks.Set(float64(count))
 
-	a.schedule(ctx)
+	db.Commit(ctx)
+
+	return m, nil
+}
+
+// leaseHolder is implemented by the objects leased by workers (tasks and
+// schedules).
+type leaseHolder interface {
+	RenewLease(
+		ctx context.Context,
+		owner string,
+		expiry time.Time,
+	) (bool, error)
 }
 
-// AppendAndSchedule appends a deadline to the list of pending deadlines while
-// preserving its order and calls schedule.
-func (a *Async) AppendAndSchedule(
+// renew renews the lease on a task or schedule until done is closed. It
+// closes stopped when it returns so that callers can wait for it before
+// releasing the lease.
+func (a *Async) renew(
 	ctx context.Context,
-	d Deadline,
+	m leaseHolder,
+	done chan struct{},
+	stopped chan struct{},
 ) {
-	a.mutex.Lock()
-	defer a.mutex.Unlock()
+	defer close(stopped)
+	ticker := time.NewTicker(a.Lease / 3)
+	defer ticker.Stop()
 
-	a.Pending = append(a.Pending, d)
-	sort.Sort(a.Pending)
-
-	mint.Logf(ctx, "Queued task: "+
-		"name=%s subject=%s retry=%d deadline=%q",
-		d.Task.Name(), d.Task.Subject(), d.Model.Retry, d.Deadline())
-
-	a.schedule(ctx)
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			held, err := func() (bool, error) {
+				ctx := db.Begin(ctx, "mint")
+				defer db.LoggedRollback(ctx)
+				held, err := m.RenewLease(ctx,
+					a.Owner, clock.Now(ctx).Add(a.Lease))
+				if err != nil {
+					return false, errors.Trace(err)
+				}
+				db.Commit(ctx)
+				return held, nil
+			}()
+			if err != nil {
+				mint.Log(ctx, logging.LvWarn, "Error renewing task lease",
+					logging.Fields{
+						"error": err.Error(),
+						"stack": logging.Stack(err),
+					})
+			} else if !held {
+				mint.Log(ctx, logging.LvWarn, "Lost task lease", nil)
+				return
+			}
+		}
+	}
 }
 
-// RunOne runs the specified deadline and re-add it to the list of pending
-// deadline if it fails.
+// RunOne executes a task leased by this process and releases it, marking it
+// as succeeded, failed or pending with the deadline of its next retry. The
+// task is executed for the mint host that queued it and within the trace of
+// the request that queued it.
 func (a *Async) RunOne(
-	d Deadline,
+	m *model.Task,
 ) {
-	mint.Logf(a.Ctx, "Executing task: "+
-		"name=%s subject=%s retry=%d deadline=%q",
-		d.Task.Name(), d.Task.Subject(), d.Model.Retry, d.Deadline())
+	id := m.Trace
+	if id == "" {
+		id = trace.New()
+	}
+	hostCtx := logging.WithFields(
+		trace.With(mint.WithHost(a.Ctx, m.Mint), id),
+		logging.Fields{
+			"task":    string(m.Name),
+			"subject": m.Subject,
+		})
+	start := time.Now()
+
+	generator, ok := Registrar[m.Name]
+	if !ok {
+		mint.Log(hostCtx, logging.LvError, "Unregistered task name", nil)
+		m.Status = mint.TkStFailed
+		a.release(hostCtx, m)
+		return
+	}
+	t, err := generator(hostCtx, m.Created, m.Subject, m.Payload)
+	if err != nil {
+		mint.Log(hostCtx, logging.LvError, "Invalid task payload",
+			logging.Fields{
+				"error": err.Error(),
+				"stack": logging.Stack(err),
+			})
+		lastError := err.Error()
+		m.LastError = &lastError
+		m.Status = mint.TkStFailed
+		a.release(hostCtx, m)
+		return
+	}
+	policy := t.Policy()
 
-	err := d.Task.Execute(With(a.Ctx, a))
+	mint.Log(hostCtx, logging.LvInfo, "Executing task", logging.Fields{
+		"retry":    m.Retry,
+		"deadline": m.Deadline,
+	})
 
-	ctx := db.Begin(a.Ctx, "mint")
-	defer db.LoggedRollback(ctx)
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go a.renew(hostCtx, m, done, stopped)
+	execCtx, cancel := context.WithTimeout(
+		With(hostCtx, a), policy.ExecutionTimeout())
+	err = t.Execute(execCtx)
+	cancel()
+	close(done)
+	<-stopped
+	taskExecutions.Inc(string(t.Name()))
 
 	if err != nil {
-		mint.Logf(ctx, "Error executing task: "+
-			"name=%s subject=%s retry=%d error=%s",
-			d.Task.Name(), d.Task.Subject(), d.Model.Retry, err.Error())
-		for _, line := range errors.ErrorStack(err) {
-			mint.Logf(ctx, "  %s", line)
+		lastError := err.Error()
+		if len(lastError) > maxLastErrorLength {
+			lastError = lastError[:maxLastErrorLength]
 		}
-
-		d.Model.Retry++
-		if d.Model.Retry > d.Task.MaxRetries() {
-			d.Model.Status = mint.TkStFailed
+		m.LastError = &lastError
+
+		level := logging.LvWarn
+		m.Retry++
+		if policy.Exhausted(m.Scheduled(), m.Retry, clock.Now(hostCtx)) {
+			m.Status = mint.TkStFailed
+			taskFailures.Inc(string(t.Name()))
+			level = logging.LvError
+		} else {
+			m.Deadline = policy.DeadlineForRetry(m.Scheduled(), m.Retry).UTC()
+			taskRetries.Inc(string(t.Name()))
 		}
+
+		mint.Log(hostCtx, level, "Error executing task", logging.Fields{
+			"retry":    m.Retry - 1,
+			"status":   m.Status,
+			"duration": time.Now().Sub(start).Seconds(),
+			"error":    err.Error(),
+			"stack":    logging.Stack(err),
+		})
 	} else {
-		mint.Logf(ctx, "Successfuly executed task: "+
-			"name=%s subject=%s retry=%d",
-			d.Task.Name(), d.Task.Subject(), d.Model.Retry)
+		m.Status = mint.TkStSucceeded
 
-		d.Model.Status = mint.TkStSucceeded
+		mint.Log(hostCtx, logging.LvInfo, "Successfuly executed task",
+			logging.Fields{
+				"retry":    m.Retry,
+				"duration": time.Now().Sub(start).Seconds(),
+			})
 	}
 
-	err = d.Model.Save(ctx)
+	a.release(hostCtx, m)
+}
+
+// release saves the task and releases its lease.
+func (a *Async) release(
+	ctx context.Context,
+	m *model.Task,
+) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	held, err := m.Release(ctx, a.Owner)
 	if err != nil {
-		mint.Logf(ctx, "Error saving task: "+
-			"name=%s subject=%s retry=%d error=%s",
-			d.Task.Name(), d.Task.Subject(), d.Model.Retry, err.Error())
+		mint.Log(ctx, logging.LvError, "Error saving task", logging.Fields{
+			"retry": m.Retry,
+			"error": err.Error(),
+			"stack": logging.Stack(err),
+		})
+		return
+	} else if !held {
+		mint.Log(ctx, logging.LvWarn, "Lost task lease before saving", nil)
+		return
 	}
 
 	db.Commit(ctx)
+}
 
-	if d.Model.Status == mint.TkStPending {
-		go func() {
-			a.AppendAndSchedule(ctx, d)
-		}()
-	} else {
-		go func() {
-			time.Sleep(100 * time.Millisecond)
-			a.LockAndSchedule(a.Ctx)
-		}()
+// work claims and executes tasks and then periodic tasks until none is
+// eligible, then waits to be woken up or for the poll interval to elapse.
+func (a *Async) work() {
+	for {
+		a.tick()
+
+		for {
+			m, err := a.claim()
+			if err != nil {
+				mint.Log(a.Ctx, logging.LvError, "Error claiming task",
+					logging.Fields{
+						"error": err.Error(),
+						"stack": logging.Stack(err),
+					})
+				break
+			}
+			if m == nil {
+				break
+			}
+			a.RunOne(m)
+		}
+
+		for {
+			s, err := a.claimSchedule()
+			if err != nil {
+				mint.Log(a.Ctx, logging.LvError, "Error claiming schedule",
+					logging.Fields{
+						"error": err.Error(),
+						"stack": logging.Stack(err),
+					})
+				break
+			}
+			if s == nil {
+				break
+			}
+			a.RunPeriodic(s)
+		}
+
+		select {
+		case <-a.wakeup:
+		case <-time.After(a.Poll):
+		}
 	}
 }
 
-// Run should be called from a go routine to execute task as a worker. Multiple
-// worker can be run concurrently.
+// Run should be called from a go routine to execute tasks. It schedules the
+// registered periodic tasks, starts the configured number of concurrent
+// workers and never returns.
 func (a *Async) Run() {
-	go func() {
-		for {
-			time.Sleep(10 * time.Second)
-			a.LockAndSchedule(a.Ctx)
-		}
-	}()
-	for d := range a.Scheduled {
-		a.RunOne(d)
+	if err := a.schedule(); err != nil {
+		mint.Log(a.Ctx, logging.LvError, "Error scheduling periodic tasks",
+			logging.Fields{
+				"error": err.Error(),
+				"stack": logging.Stack(err),
+			})
 	}
+	for i := 1; i < a.Workers; i++ {
+		go a.work()
+	}
+	a.work()
 }
 
 // ContextKey is the type of the key used with context to carry contextual
@@ -318,23 +504,56 @@ func Queue(
 	return async.Queue(ctx, t)
 }
 
-// TestRunOne runs one task off of the list of pending tasks, In tests we don't
-// have any worker so we use this ot run tasks syncrhonously as needed.
+// TestRunOne claims and runs one eligible task, In tests we don't have any
+// worker so we use this ot run tasks syncrhonously as needed. It counts as an
+// iteration of the scheduler loop.
 func TestRunOne(
 	ctx context.Context,
 ) {
 	a := Get(ctx)
-	var d Deadline
-
-	a.mutex.Lock()
+	a.tick()
 
-	if len(a.Pending) == 0 {
-		a.mutex.Unlock()
+	m, err := a.claim()
+	if err != nil {
+		mint.Log(ctx, logging.LvError, "Error claiming task", logging.Fields{
+			"error": err.Error(),
+			"stack": logging.Stack(err),
+		})
+		return
+	}
+	if m == nil {
 		return
 	}
-	d, a.Pending = a.Pending[len(a.Pending)-1], a.Pending[:len(a.Pending)-1]
 
-	a.mutex.Unlock()
+	a.RunOne(m)
+}
 
-	a.RunOne(d)
+// TestRunDue claims and runs eligible tasks until none is left and returns
+// the number of tasks run. Along with a fake clock (see clock.Fake) it lets
+// tests run the tasks due at a given time deterministically.
+func TestRunDue(
+	ctx context.Context,
+) int {
+	a := Get(ctx)
+
+	count := 0
+	for {
+		a.tick()
+
+		m, err := a.claim()
+		if err != nil {
+			mint.Log(ctx, logging.LvError, "Error claiming task",
+				logging.Fields{
+					"error": err.Error(),
+					"stack": logging.Stack(err),
+				})
+			return count
+		}
+		if m == nil {
+			return count
+		}
+
+		a.RunOne(m)
+		count++
+	}
 }
@@ -0,0 +1,250 @@
+package async
+
+import (
+	"context"
+	"time"
+
+	"github.com/spolu/settle/lib/clock"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/lib/metrics"
+	"github.com/spolu/settle/lib/trace"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+// Periodic is the interface for a periodic task. Periodic tasks are executed
+// for each mint host served by the process according to their recurrence.
+// Their schedule is persisted so that it survives restarts, and executions
+// are leased so that a periodic task runs on only one worker at a time.
+// Failed executions are not retried, the next execution is scheduled as
+// usual.
+type Periodic interface {
+	// Name is the name of the periodic task.
+	Name() mint.TkName
+
+	// Recurrence computes the execution times of the periodic task.
+	Recurrence() Recurrence
+
+	// Timeout is the deadline of each execution of the periodic task.
+	Timeout() time.Duration
+
+	// Execute runs the periodic task for the provided scheduled time.
+	Execute(ctx context.Context, scheduled time.Time) error
+}
+
+// PeriodicRegistrar is used to register periodic tasks within the module.
+var PeriodicRegistrar = map[mint.TkName]Periodic{}
+
+// unscheduledDelay is the delay after which a schedule whose periodic task is
+// not registered (or whose recurrence does not match any time) is checked
+// again.
+const unscheduledDelay = 24 * time.Hour
+
+var periodicExecutions = metrics.NewCounter(
+	"settle_mint_periodic_executions_total",
+	"Number of periodic task executions by task name.",
+	"task")
+
+var periodicFailures = metrics.NewCounter(
+	"settle_mint_periodic_failures_total",
+	"Number of failed periodic task executions by task name.",
+	"task")
+
+// schedule creates the schedule of each registered periodic task for each
+// mint host served by the process if it does not exist yet. The first
+// execution is scheduled from the current time.
+func (a *Async) schedule() error {
+	for _, host := range mint.GetHosts(a.Ctx) {
+		for name, p := range PeriodicRegistrar {
+			err := func() error {
+				ctx := db.Begin(mint.WithHost(a.Ctx, host), "mint")
+				defer db.LoggedRollback(ctx)
+
+				s, err := model.LoadScheduleByName(ctx, name)
+				if err != nil {
+					return errors.Trace(err)
+				} else if s != nil {
+					return nil
+				}
+
+				s, err = model.CreateSchedule(ctx,
+					name, p.Recurrence().Next(clock.Now(ctx)))
+				if err != nil {
+					switch errors.Cause(err).(type) {
+					case model.ErrUniqueConstraintViolation:
+						// Scheduled concurrently by another process.
+						return nil
+					}
+					return errors.Trace(err)
+				}
+
+				db.Commit(ctx)
+
+				mint.Log(ctx, logging.LvInfo, "Scheduled periodic task",
+					logging.Fields{
+						"task": s.Name,
+						"next": s.Next,
+					})
+
+				return nil
+			}()
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+	return nil
+}
+
+// claimSchedule attempts to lease a schedule whose next execution is due. It
+// returns nil if there is no periodic task to execute.
+func (a *Async) claimSchedule() (*model.Schedule, error) {
+	ctx := db.Begin(a.Ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	now := clock.Now(a.Ctx)
+	s, err := model.ClaimSchedule(ctx, a.Owner, now, now.Add(a.Lease))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	db.Commit(ctx)
+
+	return s, nil
+}
+
+// RunPeriodic executes a periodic task whose schedule is leased by this
+// process, schedules its next execution and releases the schedule. The task
+// is executed for the mint host of the schedule within a new trace.
+func (a *Async) RunPeriodic(
+	s *model.Schedule,
+) {
+	hostCtx := logging.WithFields(
+		trace.With(mint.WithHost(a.Ctx, s.Mint), trace.New()),
+		logging.Fields{
+			"periodic": string(s.Name),
+		})
+	start := time.Now()
+
+	p, ok := PeriodicRegistrar[s.Name]
+	if !ok {
+		mint.Log(hostCtx, logging.LvWarn, "Unregistered periodic task", nil)
+		s.Next = clock.Now(hostCtx).Add(unscheduledDelay)
+		a.releaseSchedule(hostCtx, s)
+		return
+	}
+
+	mint.Log(hostCtx, logging.LvInfo, "Executing periodic task",
+		logging.Fields{
+			"scheduled": s.Next,
+		})
+
+	timeout := p.Timeout()
+	if timeout <= 0 {
+		timeout = DefaultTaskTimeout
+	}
+
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go a.renew(hostCtx, s, done, stopped)
+	execCtx, cancel := context.WithTimeout(With(hostCtx, a), timeout)
+	err := p.Execute(execCtx, s.Next)
+	cancel()
+	close(done)
+	<-stopped
+	periodicExecutions.Inc(string(s.Name))
+
+	now := clock.Now(hostCtx)
+	s.Last = &now
+	s.LastError = nil
+	if err != nil {
+		lastError := err.Error()
+		if len(lastError) > maxLastErrorLength {
+			lastError = lastError[:maxLastErrorLength]
+		}
+		s.LastError = &lastError
+		periodicFailures.Inc(string(s.Name))
+
+		mint.Log(hostCtx, logging.LvError, "Error executing periodic task",
+			logging.Fields{
+				"duration": time.Now().Sub(start).Seconds(),
+				"error":    err.Error(),
+				"stack":    logging.Stack(err),
+			})
+	} else {
+		mint.Log(hostCtx, logging.LvInfo,
+			"Successfuly executed periodic task", logging.Fields{
+				"duration": time.Now().Sub(start).Seconds(),
+			})
+	}
+
+	// Executions missed while no process was running are not caught up, the
+	// next execution is scheduled from the current time.
+	s.Next = p.Recurrence().Next(now)
+	if s.Next.IsZero() {
+		mint.Log(hostCtx, logging.LvError,
+			"Periodic task recurrence has no next execution", nil)
+		s.Next = now.Add(unscheduledDelay)
+	}
+
+	a.releaseSchedule(hostCtx, s)
+}
+
+// releaseSchedule saves the schedule and releases its lease.
+func (a *Async) releaseSchedule(
+	ctx context.Context,
+	s *model.Schedule,
+) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	held, err := s.Release(ctx, a.Owner)
+	if err != nil {
+		mint.Log(ctx, logging.LvError, "Error saving schedule", logging.Fields{
+			"error": err.Error(),
+			"stack": logging.Stack(err),
+		})
+		return
+	} else if !held {
+		mint.Log(ctx, logging.LvWarn, "Lost schedule lease before saving", nil)
+		return
+	}
+
+	db.Commit(ctx)
+}
+
+// TestRunPeriodic schedules the registered periodic tasks, then claims and
+// runs one due periodic task. In tests we don't have any worker so we use
+// this to run periodic tasks synchronously as needed. It counts as an
+// iteration of the scheduler loop.
+func TestRunPeriodic(
+	ctx context.Context,
+) {
+	a := Get(ctx)
+	a.tick()
+
+	if err := a.schedule(); err != nil {
+		mint.Log(ctx, logging.LvError, "Error scheduling periodic tasks",
+			logging.Fields{
+				"error": err.Error(),
+				"stack": logging.Stack(err),
+			})
+		return
+	}
+
+	s, err := a.claimSchedule()
+	if err != nil {
+		mint.Log(ctx, logging.LvError, "Error claiming schedule",
+			logging.Fields{
+				"error": err.Error(),
+				"stack": logging.Stack(err),
+			})
+		return
+	}
+	if s == nil {
+		return
+	}
+
+	a.RunPeriodic(s)
+}
@@ -0,0 +1,112 @@
+package async
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff is the curve followed by the intervals between the retries of a
+// task.
+type Backoff string
+
+const (
+	// BkExponential doubles the interval between retries after each retry.
+	BkExponential Backoff = "exponential"
+	// BkLinear keeps the interval between retries constant.
+	BkLinear Backoff = "linear"
+)
+
+// DefaultTaskTimeout is the deadline of a task execution if its retry policy
+// does not specify one.
+const DefaultTaskTimeout = 1 * time.Minute
+
+// maxBackoffShift caps the exponent of exponential backoffs to avoid
+// overflowing durations.
+const maxBackoffShift = 32
+
+// RetryPolicy declares when a task is executed, how it is retried and when it
+// is given up on.
+type RetryPolicy struct {
+	// Delay is the delay between the creation of the task and its first
+	// execution.
+	Delay time.Duration
+	// Backoff is the curve followed by the intervals between retries.
+	Backoff Backoff
+	// Interval is the interval before the first retry.
+	Interval time.Duration
+	// Jitter is the fraction of the interval before a retry that is randomly
+	// added to its deadline, so that tasks failing together are not retried
+	// together.
+	Jitter float64
+
+	// MaxRetries caps the total number of retries.
+	MaxRetries uint
+	// MaxAge is the age after which a task is marked as failed instead of
+	// being retried. No max age is enforced if it is zero.
+	MaxAge time.Duration
+
+	// Timeout is the deadline of each execution of the task. DefaultTaskTimeout
+	// is used if it is zero.
+	Timeout time.Duration
+}
+
+// interval returns the interval between the provided retry and the previous
+// execution (the interval of the first execution is zero).
+func (p RetryPolicy) interval(
+	retry uint,
+) time.Duration {
+	if retry == 0 {
+		return 0
+	}
+	switch p.Backoff {
+	case BkExponential:
+		shift := retry - 1
+		if shift > maxBackoffShift {
+			shift = maxBackoffShift
+		}
+		return p.Interval * time.Duration(uint64(1)<<shift)
+	default:
+		return p.Interval
+	}
+}
+
+// DeadlineForRetry returns the deadline for the provided retry count of a task
+// created at the provided time.
+func (p RetryPolicy) DeadlineForRetry(
+	created time.Time,
+	retry uint,
+) time.Time {
+	deadline := created.Add(p.Delay)
+	for r := uint(1); r <= retry; r++ {
+		deadline = deadline.Add(p.interval(r))
+	}
+	if p.Jitter > 0 && retry > 0 {
+		jitter := rand.Float64() * p.Jitter * float64(p.interval(retry))
+		deadline = deadline.Add(time.Duration(jitter))
+	}
+	return deadline
+}
+
+// Exhausted returns whether a task created at the provided time that failed
+// retry times (including its last execution) should be given up on.
+func (p RetryPolicy) Exhausted(
+	created time.Time,
+	retry uint,
+	now time.Time,
+) bool {
+	if retry > p.MaxRetries {
+		return true
+	}
+	if p.MaxAge > 0 && now.Sub(created) > p.MaxAge {
+		return true
+	}
+	return false
+}
+
+// ExecutionTimeout returns the deadline of each execution of the task.
+func (p RetryPolicy) ExecutionTimeout() time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return DefaultTaskTimeout
+}
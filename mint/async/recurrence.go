@@ -0,0 +1,209 @@
+package async
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spolu/settle/lib/errors"
+)
+
+// Recurrence computes the execution times of a periodic task.
+type Recurrence interface {
+	// Next returns the first execution time strictly after the provided
+	// time.
+	Next(after time.Time) time.Time
+}
+
+// every is a recurrence with a fixed interval.
+type every time.Duration
+
+// Every returns a recurrence executing a periodic task at a fixed interval.
+func Every(
+	interval time.Duration,
+) Recurrence {
+	if interval <= 0 {
+		panic(errors.Newf("Invalid recurrence interval: %s", interval))
+	}
+	return every(interval)
+}
+
+// Next implements Recurrence.
+func (e every) Next(
+	after time.Time,
+) time.Time {
+	return after.Add(time.Duration(e))
+}
+
+// cronField is the set of values matched by a field of a cron expression.
+type cronField map[int]bool
+
+// cron is a recurrence defined by a cron expression, evaluated in UTC.
+type cron struct {
+	minute cronField
+	hour   cronField
+	dom    cronField
+	month  cronField
+	dow    cronField
+
+	// Whether the day of month and day of week fields are restricted (not
+	// `*`). If both are, a day matches if either matches.
+	domStar bool
+	dowStar bool
+}
+
+// maxCronYears bounds the search for the next execution time of cron
+// expressions that never match (such as `0 0 31 2 *`).
+const maxCronYears = 5
+
+// parseCronField parses a comma separated list of `*`, values, ranges
+// (`a-b`) and steps (`*/n`, `a-b/n`) within [min, max].
+func parseCronField(
+	field string,
+	min int,
+	max int,
+) (cronField, error) {
+	values := cronField{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i != -1 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s < 1 {
+				return nil, errors.Trace(errors.Newf(
+					"Invalid cron step: %s", part))
+			}
+			step = s
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			l, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, errors.Trace(errors.Newf(
+					"Invalid cron value: %s", part))
+			}
+			lo, hi = l, l
+			if len(bounds) == 2 {
+				h, err := strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, errors.Trace(errors.Newf(
+						"Invalid cron range: %s", part))
+				}
+				hi = h
+			} else if step != 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, errors.Trace(errors.Newf(
+				"Cron value out of range [%d, %d]: %s", min, max, part))
+		}
+
+		for v := lo; v <= hi; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// ParseCron parses a standard five fields cron expression (minute, hour, day
+// of month, month, day of week) into a recurrence. Fields support `*`, lists,
+// ranges and steps. Days of week range from 0 (Sunday) to 7 (Sunday).
+// Expressions are evaluated in UTC.
+func ParseCron(
+	expr string,
+) (Recurrence, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.Trace(errors.Newf(
+			"Invalid cron expression (expected 5 fields): %s", expr))
+	}
+
+	c := &cron{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if c.dow[7] {
+		c.dow[0] = true
+	}
+
+	return c, nil
+}
+
+// MustParseCron parses a cron expression and panics if it is invalid. It is
+// meant to be used when registering periodic tasks.
+func MustParseCron(
+	expr string,
+) Recurrence {
+	r, err := ParseCron(expr)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// matchDay returns whether the day of t matches the expression.
+func (c *cron) matchDay(
+	t time.Time,
+) bool {
+	dom := c.dom[t.Day()]
+	dow := c.dow[int(t.Weekday())]
+	switch {
+	case c.domStar && c.dowStar:
+		return true
+	case c.domStar:
+		return dow
+	case c.dowStar:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Next implements Recurrence. It returns the zero time if the expression does
+// not match any time in the next maxCronYears years.
+func (c *cron) Next(
+	after time.Time,
+) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxCronYears, 0, 0)
+
+	for t.Before(limit) {
+		if !c.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.hour[t.Hour()] {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !c.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
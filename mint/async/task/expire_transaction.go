@@ -6,6 +6,7 @@ import (
 
 	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/mint"
 	"github.com/spolu/settle/mint/async"
 	"github.com/spolu/settle/mint/lib/plan"
@@ -14,11 +15,12 @@ import (
 
 const (
 	// TkExpireTransaction expires a transaction
-	TkExpireTransaction mint.TkName = "ExpireTransaction"
+	TkExpireTransaction = mint.TkExpireTransaction
 )
 
 func init() {
-	async.Registrar[TkExpireTransaction] = NewExpireTransaction
+	async.Registrar[TkExpireTransaction] =
+		async.WithoutPayload(NewExpireTransaction)
 }
 
 // ExpireTransaction is in charge of attempting to cancel the transcation (if
@@ -55,17 +57,23 @@ func (t *ExpireTransaction) Subject() string {
 	return t.id
 }
 
-// MaxRetries returns the max retries for the task.
-func (t *ExpireTransaction) MaxRetries() uint {
-	return 8
+// Payload returns the task payload.
+func (t *ExpireTransaction) Payload() interface{} {
+	return nil
 }
 
-// DeadlineForRetry returns the deadline for the provided retry count.
-func (t *ExpireTransaction) DeadlineForRetry(
-	retry uint,
-) time.Time {
+// Policy returns the retry policy of the task. The first execution happens
+// mint.TransactionExpiryMs after creation and retries are spaced by the same
+// interval.
+func (t *ExpireTransaction) Policy() async.RetryPolicy {
 	expiry := time.Duration(mint.TransactionExpiryMs) * time.Millisecond
-	return t.Created().Add(expiry + time.Duration(retry)*expiry)
+	return async.RetryPolicy{
+		Delay:      expiry,
+		Backoff:    async.BkLinear,
+		Interval:   expiry,
+		MaxRetries: 8,
+		Timeout:    1 * time.Minute,
+	}
 }
 
 // Execute idempotently runs the task to completion or errors.
@@ -92,9 +100,11 @@ func (t *ExpireTransaction) Execute(
 	db.Commit(ctx)
 
 	if tx.Status == mint.TxStSettled {
-		mint.Logf(ctx,
-			"Skipping settled transaction expiry: transaction=%s status=%s",
-			tx.ID(), tx.Status)
+		mint.Log(ctx, logging.LvInfo, "Skipping settled transaction expiry",
+			logging.Fields{
+				"transaction": tx.ID(),
+				"status":      tx.Status,
+			})
 		return nil
 	}
 
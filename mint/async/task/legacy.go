@@ -0,0 +1,25 @@
+package task
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/spolu/settle/lib/errors"
+)
+
+// parseLegacySubject parses the subject of propagation tasks queued before
+// their hop was moved to their payload, formatted as "id|hop".
+func parseLegacySubject(
+	subject string,
+) (string, int8, error) {
+	ss := strings.Split(subject, "|")
+	if len(ss) != 2 {
+		return "", 0, errors.Trace(
+			errors.Newf("Invalid subject: %s", subject))
+	}
+	h, err := strconv.ParseInt(ss[1], 10, 8)
+	if err != nil {
+		return "", 0, errors.Trace(err)
+	}
+	return ss[0], int8(h), nil
+}
@@ -0,0 +1,20 @@
+package task
+
+import (
+	"time"
+
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/async"
+)
+
+// propagationPolicy is the retry policy of propagation tasks. They are
+// executed immediately and retried with an exponential backoff for about three
+// days.
+var propagationPolicy = async.RetryPolicy{
+	Backoff:    async.BkExponential,
+	Interval:   mint.PropagationRetryInterval,
+	Jitter:     0.1,
+	MaxRetries: 18,
+	MaxAge:     7 * 24 * time.Hour,
+	Timeout:    1 * time.Minute,
+}
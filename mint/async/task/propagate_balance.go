@@ -17,7 +17,8 @@ const (
 )
 
 func init() {
-	async.Registrar[TkPropagateBalance] = NewPropagateBalance
+	async.Registrar[TkPropagateBalance] =
+		async.WithoutPayload(NewPropagateBalance)
 }
 
 // PropagateBalance is in charge of propagating the balance to the holder mint
@@ -54,16 +55,14 @@ func (t *PropagateBalance) Subject() string {
 	return t.id
 }
 
-// MaxRetries returns the max retries for the task.
-func (t *PropagateBalance) MaxRetries() uint {
-	return 18
+// Payload returns the task payload.
+func (t *PropagateBalance) Payload() interface{} {
+	return nil
 }
 
-// DeadlineForRetry returns the deadline for the provided retry count.
-func (t *PropagateBalance) DeadlineForRetry(
-	retry uint,
-) time.Time {
-	return t.Created().Add((1<<retry - 1) * time.Second)
+// Policy returns the retry policy of the task.
+func (t *PropagateBalance) Policy() async.RetryPolicy {
+	return propagationPolicy
 }
 
 // Execute idempotently runs the task to completion or errors.
@@ -2,12 +2,12 @@ package task
 
 import (
 	"context"
-	"strconv"
-	"strings"
+	"encoding/json"
 	"time"
 
 	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/mint"
 	"github.com/spolu/settle/mint/async"
 	"github.com/spolu/settle/mint/lib/plan"
@@ -20,7 +20,7 @@ const (
 )
 
 func init() {
-	async.Registrar[TkPropagateCancellation] = NewPropagateCancellation
+	async.Registrar[TkPropagateCancellation] = loadPropagateCancellation
 }
 
 // PropagateCancellation is in charge of propagating the cancellation of a
@@ -31,29 +31,50 @@ type PropagateCancellation struct {
 	hop     int8
 }
 
-// NewPropagateCancellation constructs and initializes the task.
+// propagateCancellationPayload is the payload of the task.
+type propagateCancellationPayload struct {
+	Hop *int8 `json:"hop"`
+}
+
+// NewPropagateCancellation constructs and initializes the task to propagate the
+// cancellation of the transaction with the provided ID from the provided hop.
 func NewPropagateCancellation(
 	ctx context.Context,
 	created time.Time,
-	subject string,
+	id string,
+	hop int8,
 ) async.Task {
-	ss := strings.Split(subject, "|")
-	if len(ss) != 2 {
-		panic(errors.Newf("Invalid subject: %s", subject))
-	}
-	h, err := strconv.ParseInt(ss[1], 10, 8)
-	if err != nil {
-		panic(err)
-	}
-	hop := int8(h)
-
 	return &PropagateCancellation{
 		created: created,
-		id:      ss[0],
+		id:      id,
 		hop:     hop,
 	}
 }
 
+// loadPropagateCancellation reconstructs the task from its subject and payload.
+func loadPropagateCancellation(
+	ctx context.Context,
+	created time.Time,
+	subject string,
+	payload []byte,
+) (async.Task, error) {
+	var p propagateCancellationPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if p.Hop == nil {
+		// Tasks queued by previous versions have their hop in their subject.
+		id, hop, err := parseLegacySubject(subject)
+		if err != nil {
+			return nil, errors.Trace(errors.Newf(
+				"Missing hop in payload: %s", string(payload)))
+		}
+		return NewPropagateCancellation(ctx, created, id, hop), nil
+	}
+
+	return NewPropagateCancellation(ctx, created, subject, *p.Hop), nil
+}
+
 // Name returns the task name.
 func (t *PropagateCancellation) Name() mint.TkName {
 	return TkPropagateCancellation
@@ -69,16 +90,16 @@ func (t *PropagateCancellation) Subject() string {
 	return t.id
 }
 
-// MaxRetries returns the max retries for the task.
-func (t *PropagateCancellation) MaxRetries() uint {
-	return 18
+// Payload returns the task payload.
+func (t *PropagateCancellation) Payload() interface{} {
+	return propagateCancellationPayload{
+		Hop: &t.hop,
+	}
 }
 
-// DeadlineForRetry returns the deadline for the provided retry count.
-func (t *PropagateCancellation) DeadlineForRetry(
-	retry uint,
-) time.Time {
-	return t.Created().Add((1<<retry - 1) * time.Second)
+// Policy returns the retry policy of the task.
+func (t *PropagateCancellation) Policy() async.RetryPolicy {
+	return propagationPolicy
 }
 
 // Execute idempotently runs the task to completion or errors.
@@ -113,9 +134,11 @@ func (t *PropagateCancellation) Execute(
 	if int(t.hop)-1 >= 0 {
 		m := plan.Hops[t.hop-1].Mint
 
-		mint.Logf(ctx,
-			"Propagating cancellation: transaction=%s hop=%d mint=%s",
-			tx.ID(), t.hop, m)
+		mint.Log(ctx, logging.LvInfo, "Propagating cancellation", logging.Fields{
+			"transaction": tx.ID(),
+			"hop":         t.hop,
+			"mint":        m,
+		})
 
 		_, err := client.CancelTransaction(ctx, tx.ID(), t.hop-1, m)
 		if err != nil {
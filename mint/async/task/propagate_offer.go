@@ -17,7 +17,8 @@ const (
 )
 
 func init() {
-	async.Registrar[TkPropagateOffer] = NewPropagateOffer
+	async.Registrar[TkPropagateOffer] =
+		async.WithoutPayload(NewPropagateOffer)
 }
 
 // PropagateOffer is in charge of propagating the offer to all required mints
@@ -55,16 +56,14 @@ func (t *PropagateOffer) Subject() string {
 	return t.id
 }
 
-// MaxRetries returns the max retries for the task.
-func (t *PropagateOffer) MaxRetries() uint {
-	return 18
+// Payload returns the task payload.
+func (t *PropagateOffer) Payload() interface{} {
+	return nil
 }
 
-// DeadlineForRetry returns the deadline for the provided retry count.
-func (t *PropagateOffer) DeadlineForRetry(
-	retry uint,
-) time.Time {
-	return t.Created().Add((1<<retry - 1) * time.Second)
+// Policy returns the retry policy of the task.
+func (t *PropagateOffer) Policy() async.RetryPolicy {
+	return propagationPolicy
 }
 
 // Execute idempotently runs the task to completion or errors.
@@ -17,7 +17,8 @@ const (
 )
 
 func init() {
-	async.Registrar[TkPropagateOperation] = NewPropagateOperation
+	async.Registrar[TkPropagateOperation] =
+		async.WithoutPayload(NewPropagateOperation)
 }
 
 // PropagateOperation is in charge of propagating the operation to all required
@@ -55,16 +56,14 @@ func (t *PropagateOperation) Subject() string {
 	return t.id
 }
 
-// MaxRetries returns the max retries for the task.
-func (t *PropagateOperation) MaxRetries() uint {
-	return 18
+// Payload returns the task payload.
+func (t *PropagateOperation) Payload() interface{} {
+	return nil
 }
 
-// DeadlineForRetry returns the deadline for the provided retry count.
-func (t *PropagateOperation) DeadlineForRetry(
-	retry uint,
-) time.Time {
-	return t.Created().Add((1<<retry - 1) * time.Second)
+// Policy returns the retry policy of the task.
+func (t *PropagateOperation) Policy() async.RetryPolicy {
+	return propagationPolicy
 }
 
 // Execute idempotently runs the task to completion or errors.
@@ -2,12 +2,12 @@ package task
 
 import (
 	"context"
-	"strconv"
-	"strings"
+	"encoding/json"
 	"time"
 
 	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/mint"
 	"github.com/spolu/settle/mint/async"
 	"github.com/spolu/settle/mint/lib/plan"
@@ -20,7 +20,7 @@ const (
 )
 
 func init() {
-	async.Registrar[TkPropagateSettlement] = NewPropagateSettlement
+	async.Registrar[TkPropagateSettlement] = loadPropagateSettlement
 }
 
 // PropagateSettlement is in charge of propagating the settlement of a
@@ -31,29 +31,50 @@ type PropagateSettlement struct {
 	hop     int8
 }
 
-// NewPropagateSettlement constructs and initializes the task.
+// propagateSettlementPayload is the payload of the task.
+type propagateSettlementPayload struct {
+	Hop *int8 `json:"hop"`
+}
+
+// NewPropagateSettlement constructs and initializes the task to propagate the
+// settlement of the transaction with the provided ID from the provided hop.
 func NewPropagateSettlement(
 	ctx context.Context,
 	created time.Time,
-	subject string,
+	id string,
+	hop int8,
 ) async.Task {
-	ss := strings.Split(subject, "|")
-	if len(ss) != 2 {
-		panic(errors.Newf("Invalid subject: %s", subject))
-	}
-	h, err := strconv.ParseInt(ss[1], 10, 8)
-	if err != nil {
-		panic(err)
-	}
-	hop := int8(h)
-
 	return &PropagateSettlement{
 		created: created,
-		id:      ss[0],
+		id:      id,
 		hop:     hop,
 	}
 }
 
+// loadPropagateSettlement reconstructs the task from its subject and payload.
+func loadPropagateSettlement(
+	ctx context.Context,
+	created time.Time,
+	subject string,
+	payload []byte,
+) (async.Task, error) {
+	var p propagateSettlementPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if p.Hop == nil {
+		// Tasks queued by previous versions have their hop in their subject.
+		id, hop, err := parseLegacySubject(subject)
+		if err != nil {
+			return nil, errors.Trace(errors.Newf(
+				"Missing hop in payload: %s", string(payload)))
+		}
+		return NewPropagateSettlement(ctx, created, id, hop), nil
+	}
+
+	return NewPropagateSettlement(ctx, created, subject, *p.Hop), nil
+}
+
 // Name returns the task name.
 func (t *PropagateSettlement) Name() mint.TkName {
 	return TkPropagateSettlement
@@ -69,16 +90,16 @@ func (t *PropagateSettlement) Subject() string {
 	return t.id
 }
 
-// MaxRetries returns the max retries for the task.
-func (t *PropagateSettlement) MaxRetries() uint {
-	return 18
+// Payload returns the task payload.
+func (t *PropagateSettlement) Payload() interface{} {
+	return propagateSettlementPayload{
+		Hop: &t.hop,
+	}
 }
 
-// DeadlineForRetry returns the deadline for the provided retry count.
-func (t *PropagateSettlement) DeadlineForRetry(
-	retry uint,
-) time.Time {
-	return t.Created().Add((1<<retry - 1) * time.Second)
+// Policy returns the retry policy of the task.
+func (t *PropagateSettlement) Policy() async.RetryPolicy {
+	return propagationPolicy
 }
 
 // Execute idempotently runs the task to completion or errors.
@@ -122,9 +143,11 @@ func (t *PropagateSettlement) Execute(
 	if int(t.hop)-1 >= 0 {
 		m := plan.Hops[t.hop-1].Mint
 
-		mint.Logf(ctx,
-			"Propagating settlement: transaction=%s hop=%d mint=%s",
-			tx.ID(), t.hop, m)
+		mint.Log(ctx, logging.LvInfo, "Propagating settlement", logging.Fields{
+			"transaction": tx.ID(),
+			"hop":         t.hop,
+			"mint":        m,
+		})
 
 		hop := t.hop - 1
 		_, err := client.SettleTransaction(ctx, tx.ID(), &hop, tx.Secret, &m)
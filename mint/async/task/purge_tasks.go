@@ -0,0 +1,78 @@
+package task
+
+import (
+	"context"
+	"time"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/async"
+	"github.com/spolu/settle/mint/model"
+)
+
+const (
+	// TkPurgeTasks deletes old completed tasks.
+	TkPurgeTasks mint.TkName = "PurgeTasks"
+)
+
+// TaskRetention is the duration for which succeeded and canceled tasks are
+// kept after their creation.
+const TaskRetention = 7 * 24 * time.Hour
+
+func init() {
+	async.PeriodicRegistrar[TkPurgeTasks] = &PurgeTasks{}
+}
+
+// PurgeTasks is a periodic task in charge of deleting succeeded and canceled
+// tasks older than TaskRetention. Failed tasks are kept until they are
+// requeued through the admin API (and purged once they succeed or are
+// canceled).
+type PurgeTasks struct{}
+
+// Name returns the periodic task name.
+func (p *PurgeTasks) Name() mint.TkName {
+	return TkPurgeTasks
+}
+
+// Recurrence returns the recurrence of the periodic task.
+func (p *PurgeTasks) Recurrence() async.Recurrence {
+	return async.Every(1 * time.Hour)
+}
+
+// Timeout returns the deadline of each execution.
+func (p *PurgeTasks) Timeout() time.Duration {
+	return 5 * time.Minute
+}
+
+// Execute deletes the tasks created before TaskRetention prior to the
+// scheduled time.
+func (p *PurgeTasks) Execute(
+	ctx context.Context,
+	scheduled time.Time,
+) error {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	before := scheduled.Add(-TaskRetention)
+	total := int64(0)
+	for _, status := range []mint.TkStatus{
+		mint.TkStSucceeded, mint.TkStCanceled,
+	} {
+		count, err := model.DeleteTasksCreatedBefore(ctx, status, before)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		total += count
+	}
+
+	db.Commit(ctx)
+
+	mint.Log(ctx, logging.LvInfo, "Purged tasks", logging.Fields{
+		"count":  total,
+		"before": before,
+	})
+
+	return nil
+}
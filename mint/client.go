@@ -4,18 +4,186 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/spolu/settle/lib/breaker"
 	"github.com/spolu/settle/lib/client"
 	"github.com/spolu/settle/lib/env"
 	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/lib/metrics"
 	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/lib/trace"
 )
 
+var clientRequests = metrics.NewCounter(
+	"settle_mint_client_requests_total",
+	"Number of outbound requests to other mints by peer host and response "+
+		"status (error if no response was received).",
+	"peer", "status")
+
+var clientErrors = metrics.NewCounter(
+	"settle_mint_client_errors_total",
+	"Number of outbound requests to other mints that failed to get a "+
+		"response or got a server error, by peer host.",
+	"peer")
+
+var clientDuration = metrics.NewHistogram(
+	"settle_mint_client_request_duration_seconds",
+	"Latency of outbound requests to other mints by peer host.",
+	metrics.DefaultBuckets, "peer")
+
+// MaxPeerLabels is the maximum number of peer hosts labeling the metrics of
+// the client. Other peers are labeled PeerLabelOther.
+const MaxPeerLabels = 256
+
+// PeerLabelOther labels the metrics of the peers that are not known.
+const PeerLabelOther = "other"
+
+// peerLabels records the known peers of the mint, labeling its metrics: the
+// first MaxPeerLabels peer hosts that responded to the mint without a server
+// error (so that arbitrary hosts the mint is made to contact don't grow the
+// cardinality of its metrics).
+var peerLabels = struct {
+	mutex *sync.Mutex
+	known map[string]bool
+}{
+	mutex: &sync.Mutex{},
+	known: map[string]bool{},
+}
+
+// knowPeer records a peer host as known if there is room left.
+func knowPeer(
+	host string,
+) {
+	peerLabels.mutex.Lock()
+	defer peerLabels.mutex.Unlock()
+
+	if len(peerLabels.known) < MaxPeerLabels {
+		peerLabels.known[host] = true
+	}
+}
+
+// peerLabel returns the metrics label of a peer host: the host itself if it
+// is known, PeerLabelOther otherwise.
+func peerLabel(
+	host string,
+) string {
+	peerLabels.mutex.Lock()
+	defer peerLabels.mutex.Unlock()
+
+	if peerLabels.known[host] {
+		return host
+	}
+	return PeerLabelOther
+}
+
+// instrumentedTransport records metrics for outbound requests to other mints.
+type instrumentedTransport struct {
+	http.RoundTripper
+}
+
+const (
+	// RecentPeerWindow is the window during which peer APIs contacted by the
+	// mint are recorded (see RecentPeers).
+	RecentPeerWindow = time.Hour
+	// MaxContactedPeers is the maximum number of peer APIs recorded as
+	// contacted by the mint. The least recently contacted ones are evicted
+	// beyond it.
+	MaxContactedPeers = 1024
+)
+
+// contacted records the last time each peer API (by scheme and host) was
+// contacted by the mint, during the last RecentPeerWindow.
+var contacted = struct {
+	mutex *sync.Mutex
+	all   map[string]time.Time
+}{
+	mutex: &sync.Mutex{},
+	all:   map[string]time.Time{},
+}
+
+// RecentPeers returns the base URLs of the peer APIs contacted by the mint
+// since the provided time.
+func RecentPeers(
+	since time.Time,
+) []string {
+	contacted.mutex.Lock()
+	defer contacted.mutex.Unlock()
+
+	peers := []string{}
+	for base, t := range contacted.all {
+		if !t.Before(since) {
+			peers = append(peers, base)
+		}
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+// contact records that a peer API was contacted, evicting the peers not
+// contacted during the last RecentPeerWindow and the least recently contacted
+// ones beyond MaxContactedPeers.
+func contact(
+	base string,
+	now time.Time,
+) {
+	contacted.mutex.Lock()
+	defer contacted.mutex.Unlock()
+
+	contacted.all[base] = now
+	if len(contacted.all) <= MaxContactedPeers {
+		return
+	}
+
+	oldest := ""
+	for b, t := range contacted.all {
+		if now.Sub(t) > RecentPeerWindow {
+			delete(contacted.all, b)
+		} else if oldest == "" || t.Before(contacted.all[oldest]) {
+			oldest = b
+		}
+	}
+	if len(contacted.all) > MaxContactedPeers {
+		delete(contacted.all, oldest)
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t instrumentedTransport) RoundTrip(
+	req *http.Request,
+) (*http.Response, error) {
+	contact(req.URL.Scheme+"://"+req.URL.Host, time.Now())
+
+	start := time.Now()
+	r, err := t.RoundTripper.RoundTrip(req)
+	if err == nil && r.StatusCode < http.StatusInternalServerError {
+		knowPeer(req.URL.Host)
+	}
+	peer := peerLabel(req.URL.Host)
+
+	clientDuration.Observe(time.Now().Sub(start).Seconds(), peer)
+	if err != nil {
+		clientRequests.Inc(peer, "error")
+		clientErrors.Inc(peer)
+		return r, err
+	}
+	clientRequests.Inc(peer, fmt.Sprintf("%d", r.StatusCode))
+	if r.StatusCode >= http.StatusInternalServerError {
+		clientErrors.Inc(peer)
+	}
+	return r, nil
+}
+
 // Client expose an interface to perform queries on remote mints.
 type Client struct {
 	httpClient *http.Client
@@ -25,7 +193,13 @@ type Client struct {
 func (c *Client) Init(
 	ctx context.Context,
 ) error {
-	c.httpClient = client.Default(ctx)
+	httpClient := *client.Default(ctx)
+	transport := httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	httpClient.Transport = instrumentedTransport{transport}
+	c.httpClient = &httpClient
 	return nil
 }
 
@@ -41,9 +215,17 @@ var DefaultScheme = map[env.Environment]string{
 	env.QA:         "http",
 }
 
+// Possible host: ias.edu:8989
+var hostRegexpStr = "([a-zA-Z0-9-]+\\.[a-zA-Z0-9-.]+(:[0-9]{1,5}){0,1})"
+
 // Possible address: von.neumann@ias.edu:8989
 var addressRegexpStr = "([a-zA-Z0-9-_.]{1,256})(\\+[a-zA-Z0-9-_.]+){0,1}@" +
-	"([a-zA-Z0-9-]+\\.[a-zA-Z0-9-.]+(:[0-9]{1,5}){0,1})"
+	hostRegexpStr
+
+// HostRegexp is used to validate mint hosts.
+var HostRegexp = regexp.MustCompile(
+	"^" + hostRegexpStr + "$",
+)
 
 // AssetNameRegexp is used to validate and parse asset names.
 var AssetNameRegexp = regexp.MustCompile(
@@ -169,393 +351,794 @@ func FullMintURL(
 	return &url
 }
 
-// RetrieveBalance retrieves an balance given its ID by extracting the mint and
-// retrieving it from there.
-func (c *Client) RetrieveBalance(
+const (
+	// DiscoveryTTL is the duration for which the discovery documents of mints
+	// are cached by the client.
+	DiscoveryTTL = 10 * time.Minute
+	// MaxCachedMints is the maximum number of discovery documents cached by
+	// the client. Expired documents are evicted when it is reached, then the
+	// ones expiring first.
+	MaxCachedMints = 1024
+)
+
+// discovery caches the discovery documents of mints by host. A nil document is
+// cached for mints that don't serve one.
+var discovery = struct {
+	mutex *sync.Mutex
+	all   map[string]discovered
+}{
+	mutex: &sync.Mutex{},
+	all:   map[string]discovered{},
+}
+
+type discovered struct {
+	expiry time.Time
+	mint   *MintResource
+}
+
+// RetrieveMint retrieves the discovery document of the mint at the provided
+// host. Documents are cached for DiscoveryTTL. It returns nil if the mint does
+// not serve a discovery document.
+func (c *Client) RetrieveMint(
 	ctx context.Context,
-	id string,
-) (*BalanceResource, error) {
-	owner, _, err := NormalizedOwnerAndTokenFromID(ctx, id)
+	host string,
+) (*MintResource, error) {
+	discovery.mutex.Lock()
+	d, ok := discovery.all[host]
+	discovery.mutex.Unlock()
+	if ok && time.Now().Before(d.expiry) {
+		return d.mint, nil
+	}
+
+	status, raw, err := c.send(ctx, call{
+		host:       host,
+		method:     "GET",
+		url:        FullMintURL(ctx, host, DiscoveryPath, url.Values{}),
+		idempotent: true,
+	})
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	_, host, err := UsernameAndMintHostFromAddress(ctx, owner)
-	if err != nil {
-		return nil, errors.Trace(err)
+
+	var m *MintResource
+	switch {
+	case status == http.StatusOK:
+		if raw == nil {
+			return nil, errors.Trace(errors.Newf(
+				"Invalid discovery document for %s", host))
+		}
+		m = &MintResource{}
+		if err := raw.Extract("mint", m); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if err := checkDiscovered(host, m); err != nil {
+			return nil, errors.Trace(err)
+		}
+	case status >= http.StatusBadRequest && status < 500 &&
+		status != http.StatusTooManyRequests:
+		// Mints predating discovery documents are contacted at their host.
+		// They reply with a client error (generally 400 username_invalid as
+		// the discovery path matches their user routes). Throttled requests
+		// are transient and not cached.
+	default:
+		return nil, errors.Trace(errors.Newf(
+			"Unexpected status retrieving the discovery document of %s: %d",
+			host, status))
+	}
+
+	discovery.mutex.Lock()
+	if _, ok := discovery.all[host]; !ok &&
+		len(discovery.all) >= MaxCachedMints {
+		evictDiscovered(time.Now())
+	}
+	discovery.all[host] = discovered{time.Now().Add(DiscoveryTTL), m}
+	discovery.mutex.Unlock()
+
+	return m, nil
+}
+
+// evictDiscovered removes the expired discovery documents from the cache, or
+// the document expiring first if none is. It must be called with the
+// discovery mutex held.
+func evictDiscovered(
+	now time.Time,
+) {
+	first := ""
+	for host, d := range discovery.all {
+		if !now.Before(d.expiry) {
+			delete(discovery.all, host)
+		} else if first == "" || d.expiry.Before(discovery.all[first].expiry) {
+			first = host
+		}
+	}
+	if len(discovery.all) >= MaxCachedMints {
+		delete(discovery.all, first)
+	}
+}
+
+// checkDiscovered checks that the API advertised by the discovery document of
+// the mint at the provided host is served by that host or one of its
+// subdomains, so that a mint can't redirect its peers to arbitrary servers.
+func checkDiscovered(
+	host string,
+	m *MintResource,
+) error {
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+	if m.Host != hostname && !strings.HasSuffix(m.Host, "."+hostname) {
+		return errors.Trace(errors.Newf(
+			"Invalid discovery document for %s: host %s is not within %s",
+			host, m.Host, hostname))
 	}
+	if m.Port <= 0 || m.Port > 65535 {
+		return errors.Trace(errors.Newf(
+			"Invalid discovery document for %s: port %d", host, m.Port))
+	}
+	if m.Scheme != "http" && m.Scheme != "https" {
+		return errors.Trace(errors.Newf(
+			"Invalid discovery document for %s: scheme %s", host, m.Scheme))
+	}
+	return nil
+}
 
-	req, err := http.NewRequest("GET",
-		FullMintURL(ctx,
-			host, fmt.Sprintf("/balances/%s", id), url.Values{}).String(), nil)
+// Ping checks that the peer API at the provided base URL (as returned by
+// RecentPeers) is reachable and not failing.
+func (c *Client) Ping(
+	ctx context.Context,
+	base string,
+) error {
+	req, err := http.NewRequest("GET", base+DiscoveryPath, nil)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return errors.Trace(err)
 	}
 	req.Header.Add("Mint-Protocol-Version", ProtocolVersion)
+	trace.SetHeader(ctx, req)
+	req = req.WithContext(ctx)
 	r, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return errors.Trace(err)
 	}
 	defer r.Body.Close()
 
-	var raw svc.Resp
-	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+	if r.StatusCode >= http.StatusInternalServerError {
+		return errors.Trace(errors.Newf(
+			"Unexpected status pinging %s: %d", base, r.StatusCode))
+	}
+	return nil
+}
+
+// MintURL constructs a fully qualified URL to contact the API of the mint at
+// the provided host, as advertised by its discovery document if it serves one
+// and defaulting to FullMintURL otherwise. It errors if the mint does not
+// support the current protocol version.
+func (c *Client) MintURL(
+	ctx context.Context,
+	host string,
+	path string,
+	query url.Values,
+) (*url.URL, error) {
+	m, err := c.RetrieveMint(ctx, host)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if m == nil {
+		return FullMintURL(ctx, host, path, query), nil
+	}
 
-	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusCreated {
-		var e errors.ConcreteUserError
-		err = raw.Extract("error", &e)
-		if err != nil {
-			return nil, errors.Trace(err)
+	supported := false
+	for _, v := range m.Versions {
+		if v == ProtocolVersion {
+			supported = true
 		}
-		return nil, errors.Trace(ErrMintClient{
-			r.StatusCode, e.ErrCode, e.ErrMessage,
-		})
+	}
+	if !supported {
+		return nil, errors.Trace(errors.Newf(
+			"Mint %s does not support protocol version %s (supported: %s)",
+			host, ProtocolVersion, strings.Join(m.Versions, ", ")))
 	}
 
-	var balance BalanceResource
-	if err := raw.Extract("balance", &balance); err != nil {
-		return nil, errors.Trace(err)
+	url := url.URL{
+		Scheme:   m.Scheme,
+		Host:     fmt.Sprintf("%s:%d", m.Host, m.Port),
+		Path:     path,
+		RawQuery: query.Encode(),
 	}
+	return &url, nil
+}
 
-	return &balance, nil
+const (
+	// CallTimeout is the maximum duration of a call to another mint, retries
+	// included, unless the context it is made from has an earlier deadline.
+	CallTimeout = 30 * time.Second
+	// AttemptTimeout is the maximum duration of each attempt of a call.
+	AttemptTimeout = 10 * time.Second
+	// MaxAttempts is the maximum number of attempts of idempotent calls.
+	MaxAttempts = 3
+	// RetryBackoff is the delay before the first retry of a call, doubled
+	// for each subsequent retry.
+	RetryBackoff = 200 * time.Millisecond
+	// BreakerThreshold is the number of consecutive failed attempts after
+	// which the circuit breaker of a mint opens.
+	BreakerThreshold = 5
+	// BreakerCooldown is the duration for which calls to a mint fail fast
+	// once its circuit breaker opened.
+	BreakerCooldown = 30 * time.Second
+)
+
+// breakers are the circuit breakers of the mints contacted by this process,
+// by host. Failures are network errors and server errors.
+var breakers = breaker.New(BreakerThreshold, BreakerCooldown)
+
+var breakerOpen = metrics.NewGauge(
+	"settle_mint_client_breaker_open",
+	"Whether the circuit breaker of a known peer host is open or half open "+
+		"(1) or closed (0).",
+	"peer")
+
+// setBreakerOpen updates the breakerOpen gauge of a peer host. The state of
+// the breakers of unknown peers is not reported (as they share their label).
+func setBreakerOpen(
+	host string,
+) {
+	peer := peerLabel(host)
+	if peer == PeerLabelOther {
+		return
+	}
+	if breakers.State(host) == breaker.Closed {
+		breakerOpen.Set(0, peer)
+	} else {
+		breakerOpen.Set(1, peer)
+	}
 }
 
-// RetrieveOffer retrieves an offer given its ID by extracting the mint and
-// retrieving it from there.
-func (c *Client) RetrieveOffer(
+// BreakerStatuses returns the status of the circuit breakers of the mints
+// that recently failed.
+func BreakerStatuses() []breaker.Status {
+	return breakers.Statuses()
+}
+
+// ResetBreaker closes the circuit breaker of the mint at the provided host.
+func ResetBreaker(
+	host string,
+) {
+	breakers.Reset(host)
+	setBreakerOpen(host)
+}
+
+// call is a call to another mint.
+type call struct {
+	host       string // Host of the mint, keying its circuit breaker.
+	method     string
+	url        *url.URL
+	body       url.Values // Form body of POST calls.
+	idempotent bool       // Whether the call can be retried.
+}
+
+// send sends a call with a deadline derived from the context, retrying
+// idempotent calls on network and server errors. It fails fast if the circuit
+// breaker of the mint is open. It returns the status of the response and its
+// decoded body (nil if it could not be decoded).
+func (c *Client) send(
 	ctx context.Context,
-	id string,
-) (*OfferResource, error) {
-	owner, _, err := NormalizedOwnerAndTokenFromID(ctx, id)
-	if err != nil {
-		return nil, errors.Trace(err)
+	cl call,
+) (int, svc.Resp, error) {
+	ctx, cancel := context.WithTimeout(ctx, CallTimeout)
+	defer cancel()
+
+	attempts := 1
+	if cl.idempotent {
+		attempts = MaxAttempts
 	}
-	_, host, err := UsernameAndMintHostFromAddress(ctx, owner)
+
+	var status int
+	var raw svc.Resp
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(RetryBackoff << uint(i-1)):
+			case <-ctx.Done():
+				return 0, nil, errors.Trace(ctx.Err())
+			}
+		}
+
+		if err := breakers.Allow(cl.host, time.Now()); err != nil {
+			return 0, nil, errors.Trace(err)
+		}
+
+		status, raw, err = c.attempt(ctx, cl)
+		if ctx.Err() != nil {
+			// The call was canceled or timed out on our side, which says
+			// nothing about the health of the peer. Release it so that a
+			// trial call of a half open circuit is not left in flight.
+			breakers.Release(cl.host, time.Now())
+			setBreakerOpen(cl.host)
+			return 0, nil, errors.Trace(ctx.Err())
+		}
+		failed := err != nil || status >= http.StatusInternalServerError
+
+		if failed {
+			breakers.Failure(cl.host, time.Now())
+		} else {
+			breakers.Success(cl.host, time.Now())
+			knowPeer(cl.host)
+		}
+		setBreakerOpen(cl.host)
+
+		if !failed {
+			return status, raw, nil
+		}
+
+		fields := logging.Fields{
+			"peer":    cl.host,
+			"method":  cl.method,
+			"path":    cl.url.Path,
+			"attempt": i + 1,
+			"status":  status,
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+		}
+		Log(ctx, logging.LvWarn, "Failed call attempt", fields)
+	}
+
 	if err != nil {
-		return nil, errors.Trace(err)
+		return 0, nil, errors.Trace(err)
 	}
+	// Server errors of the last attempt are returned to the caller.
+	return status, raw, nil
+}
+
+// attempt performs one attempt of a call.
+func (c *Client) attempt(
+	ctx context.Context,
+	cl call,
+) (int, svc.Resp, error) {
+	ctx, cancel := context.WithTimeout(ctx, AttemptTimeout)
+	defer cancel()
 
-	req, err := http.NewRequest("GET",
-		FullMintURL(ctx,
-			host, fmt.Sprintf("/offers/%s", id), url.Values{}).String(), nil)
+	var body io.Reader
+	if cl.body != nil {
+		body = strings.NewReader(cl.body.Encode())
+	}
+	req, err := http.NewRequest(cl.method, cl.url.String(), body)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return 0, nil, errors.Trace(err)
+	}
+	if cl.body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	}
 	req.Header.Add("Mint-Protocol-Version", ProtocolVersion)
+	trace.SetHeader(ctx, req)
+	req = req.WithContext(ctx)
 	r, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return 0, nil, errors.Trace(err)
 	}
 	defer r.Body.Close()
 
 	var raw svc.Resp
 	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
-		return nil, errors.Trace(err)
+		if r.StatusCode == http.StatusOK || r.StatusCode == http.StatusCreated {
+			return 0, nil, errors.Trace(err)
+		}
+		raw = nil
+	}
+
+	return r.StatusCode, raw, nil
+}
+
+// do sends a call to the API of the mint at the provided host and extracts
+// the resource returned under key into v. Error responses are returned as
+// ErrMintClient.
+func (c *Client) do(
+	ctx context.Context,
+	host string,
+	method string,
+	path string,
+	body url.Values,
+	idempotent bool,
+	key string,
+	v interface{},
+) error {
+	u, err := c.MintURL(ctx, host, path, url.Values{})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if method == "POST" && body == nil {
+		body = url.Values{}
 	}
 
-	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusCreated {
+	status, raw, err := c.send(ctx, call{
+		host:       host,
+		method:     method,
+		url:        u,
+		body:       body,
+		idempotent: idempotent,
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if status != http.StatusOK && status != http.StatusCreated {
 		var e errors.ConcreteUserError
-		err = raw.Extract("error", &e)
-		if err != nil {
-			return nil, errors.Trace(err)
+		if raw == nil {
+			return errors.Trace(ErrMintClient{
+				status, "", http.StatusText(status),
+			})
 		}
-		return nil, errors.Trace(ErrMintClient{
-			r.StatusCode, e.ErrCode, e.ErrMessage,
+		if err := raw.Extract("error", &e); err != nil {
+			return errors.Trace(err)
+		}
+		return errors.Trace(ErrMintClient{
+			status, e.ErrCode, e.ErrMessage,
 		})
 	}
 
-	var offer OfferResource
-	if err := raw.Extract("offer", &offer); err != nil {
-		return nil, errors.Trace(err)
+	if err := raw.Extract(key, v); err != nil {
+		return errors.Trace(err)
 	}
-
-	return &offer, nil
+	return nil
 }
 
-// RetrieveOperation retrieves an operation given its ID by extracting the mint
-// and retrieving it from there.
-func (c *Client) RetrieveOperation(
+// hostFromID returns the host of the canonical mint of an object given its
+// ID.
+func hostFromID(
 	ctx context.Context,
 	id string,
-) (*OperationResource, error) {
+) (string, error) {
 	owner, _, err := NormalizedOwnerAndTokenFromID(ctx, id)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return "", errors.Trace(err)
 	}
 	_, host, err := UsernameAndMintHostFromAddress(ctx, owner)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return "", errors.Trace(err)
 	}
+	return host, nil
+}
 
-	req, err := http.NewRequest("GET",
-		FullMintURL(ctx,
-			host, fmt.Sprintf("/operations/%s", id), url.Values{}).String(), nil)
+// RetrieveBalance retrieves an balance given its ID by extracting the mint and
+// retrieving it from there.
+func (c *Client) RetrieveBalance(
+	ctx context.Context,
+	id string,
+) (*BalanceResource, error) {
+	host, err := hostFromID(ctx, id)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	req.Header.Add("Mint-Protocol-Version", ProtocolVersion)
-	r, err := c.httpClient.Do(req)
+
+	var balance BalanceResource
+	err = c.do(ctx, host, "GET", fmt.Sprintf("/balances/%s", id), nil,
+		true, "balance", &balance)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	defer r.Body.Close()
 
-	var raw svc.Resp
-	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
-		return nil, errors.Trace(err)
-	}
+	return &balance, nil
+}
 
-	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusCreated {
-		var e errors.ConcreteUserError
-		err = raw.Extract("error", &e)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		return nil, errors.Trace(ErrMintClient{
-			r.StatusCode, e.ErrCode, e.ErrMessage,
-		})
+// RetrieveOffer retrieves an offer given its ID by extracting the mint and
+// retrieving it from there.
+func (c *Client) RetrieveOffer(
+	ctx context.Context,
+	id string,
+) (*OfferResource, error) {
+	host, err := hostFromID(ctx, id)
+	if err != nil {
+		return nil, errors.Trace(err)
 	}
 
-	var operation OperationResource
-	if err := raw.Extract("operation", &operation); err != nil {
+	var offer OfferResource
+	err = c.do(ctx, host, "GET", fmt.Sprintf("/offers/%s", id), nil,
+		true, "offer", &offer)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
-	return &operation, nil
+	return &offer, nil
 }
 
-// RetrieveTransaction retrieves a transaction given its ID by extracting the
-// mint and retrieving it from there. If host is specified, it attempts to
-// retrrieve the transaction from this host instead of the canonical host.
-func (c *Client) RetrieveTransaction(
+// RetrieveAsset retrieves an asset given its name by extracting the mint and
+// retrieving it from there.
+func (c *Client) RetrieveAsset(
 	ctx context.Context,
-	id string,
-	mint *string,
-) (*TransactionResource, error) {
-	if mint == nil {
-		owner, _, err := NormalizedOwnerAndTokenFromID(ctx, id)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		_, host, err := UsernameAndMintHostFromAddress(ctx, owner)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		mint = &host
-	}
-
-	req, err := http.NewRequest("GET",
-		FullMintURL(ctx,
-			*mint, fmt.Sprintf("/transactions/%s", id), url.Values{}).String(), nil)
+	name string,
+) (*AssetResource, error) {
+	a, err := AssetResourceFromName(ctx, name)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	req.Header.Add("Mint-Protocol-Version", ProtocolVersion)
-	r, err := c.httpClient.Do(req)
+	_, host, err := UsernameAndMintHostFromAddress(ctx, a.Owner)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	defer r.Body.Close()
 
-	var raw svc.Resp
-	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+	var asset AssetResource
+	err = c.do(ctx, host, "GET", fmt.Sprintf("/assets/%s", name), nil,
+		true, "asset", &asset)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
-	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusCreated {
-		var e errors.ConcreteUserError
-		err = raw.Extract("error", &e)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		return nil, errors.Trace(ErrMintClient{
-			r.StatusCode, e.ErrCode, e.ErrMessage,
-		})
-	}
+	return &asset, nil
+}
 
-	var transaction TransactionResource
-	if err := raw.Extract("transaction", &transaction); err != nil {
-		return nil, errors.Trace(err)
-	}
+const (
+	// OfferCacheTTL is the duration for which offers retrieved with
+	// LookupOffer are cached by the client. Cached offers are invalidated
+	// when the offer is propagated to the mint.
+	OfferCacheTTL = 30 * time.Second
+	// AssetCacheTTL is the duration for which assets retrieved with
+	// LookupAsset are cached by the client. Assets are immutable so they are
+	// cached for longer than offers.
+	AssetCacheTTL = 10 * time.Minute
+	// MaxCachedEntries is the maximum number of offers and of assets cached
+	// by the client. Expired entries are evicted when it is reached, then the
+	// ones expiring first.
+	MaxCachedEntries = 4096
+)
 
-	return &transaction, nil
+var cacheLookups = metrics.NewCounter(
+	"settle_mint_client_cache_lookups_total",
+	"Number of offer and asset lookups by kind and result (hit or miss).",
+	"kind", "result")
+
+// cache caches the offers and assets of remote mints by ID and name. It is
+// shared by all the clients of the mint so that the plans computed when
+// creating, settling and canceling a transaction retrieve the offers of the
+// path only once.
+var cache = struct {
+	mutex  *sync.Mutex
+	offers map[string]cachedOffer
+	assets map[string]cachedAsset
+}{
+	mutex:  &sync.Mutex{},
+	offers: map[string]cachedOffer{},
+	assets: map[string]cachedAsset{},
 }
 
-// PropagateBalance propagates an balance to the specified mint.
-func (c *Client) PropagateBalance(
+type cachedOffer struct {
+	expiry time.Time
+	offer  OfferResource
+}
+
+type cachedAsset struct {
+	expiry time.Time
+	asset  AssetResource
+}
+
+// LookupOffer retrieves an offer given its ID, using the cached version if it
+// was retrieved less than OfferCacheTTL ago. Callers that must see the
+// current state of the offer should use RetrieveOffer instead.
+func (c *Client) LookupOffer(
 	ctx context.Context,
 	id string,
-	mint string,
-) (*BalanceResource, error) {
-	req, err := http.NewRequest("POST",
-		FullMintURL(ctx, mint,
-			fmt.Sprintf("/balances/%s", id), url.Values{}).String(), nil)
-	if err != nil {
-		return nil, errors.Trace(err)
+) (*OfferResource, error) {
+	cache.mutex.Lock()
+	o, ok := cache.offers[id]
+	cache.mutex.Unlock()
+	if ok && time.Now().Before(o.expiry) {
+		cacheLookups.Inc("offer", "hit")
+		return &o.offer, nil
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Mint-Protocol-Version", ProtocolVersion)
-	r, err := c.httpClient.Do(req)
+	cacheLookups.Inc("offer", "miss")
+
+	offer, err := c.RetrieveOffer(ctx, id)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	defer r.Body.Close()
+	// Only cache offers that are what we asked for.
+	if offer.ID != id {
+		return nil, errors.Trace(errors.Newf(
+			"Unexpected offer id: %s expected %s", offer.ID, id))
+	}
 
-	var raw svc.Resp
-	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
-		return nil, errors.Trace(err)
+	cache.mutex.Lock()
+	if _, ok := cache.offers[id]; !ok && len(cache.offers) >= MaxCachedEntries {
+		evictOffers(time.Now())
 	}
+	cache.offers[id] = cachedOffer{time.Now().Add(OfferCacheTTL), *offer}
+	cache.mutex.Unlock()
 
-	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusCreated {
-		var e errors.ConcreteUserError
-		err = raw.Extract("error", &e)
-		if err != nil {
-			return nil, errors.Trace(err)
+	return offer, nil
+}
+
+// evictOffers removes the expired offers from the cache, or the offer expiring
+// first if none is. It must be called with the cache mutex held.
+func evictOffers(
+	now time.Time,
+) {
+	first := ""
+	for id, o := range cache.offers {
+		if !now.Before(o.expiry) {
+			delete(cache.offers, id)
+		} else if first == "" || o.expiry.Before(cache.offers[first].expiry) {
+			first = id
 		}
-		return nil, errors.Trace(ErrMintClient{
-			r.StatusCode, e.ErrCode, e.ErrMessage,
-		})
 	}
+	if len(cache.offers) >= MaxCachedEntries {
+		delete(cache.offers, first)
+	}
+}
 
-	var balance BalanceResource
-	if err := raw.Extract("balance", &balance); err != nil {
-		return nil, errors.Trace(err)
+// evictAssets removes the expired assets from the cache, or the asset expiring
+// first if none is. It must be called with the cache mutex held.
+func evictAssets(
+	now time.Time,
+) {
+	first := ""
+	for name, a := range cache.assets {
+		if !now.Before(a.expiry) {
+			delete(cache.assets, name)
+		} else if first == "" || a.expiry.Before(cache.assets[first].expiry) {
+			first = name
+		}
 	}
+	if len(cache.assets) >= MaxCachedEntries {
+		delete(cache.assets, first)
+	}
+}
 
-	return &balance, nil
+// InvalidateOffer removes an offer from the cache. It is called when the
+// offer is propagated to the mint or when it fails validation.
+func InvalidateOffer(
+	id string,
+) {
+	cache.mutex.Lock()
+	delete(cache.offers, id)
+	cache.mutex.Unlock()
 }
 
-// PropagateOffer propagates an offer to the specified mint.
-func (c *Client) PropagateOffer(
+// LookupAsset retrieves an asset given its name, using the cached version if
+// it was retrieved less than AssetCacheTTL ago.
+func (c *Client) LookupAsset(
 	ctx context.Context,
-	id string,
-	mint string,
-) (*OfferResource, error) {
-	req, err := http.NewRequest("POST",
-		FullMintURL(ctx, mint,
-			fmt.Sprintf("/offers/%s", id), url.Values{}).String(), nil)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Mint-Protocol-Version", ProtocolVersion)
-	r, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, errors.Trace(err)
+	name string,
+) (*AssetResource, error) {
+	cache.mutex.Lock()
+	a, ok := cache.assets[name]
+	cache.mutex.Unlock()
+	if ok && time.Now().Before(a.expiry) {
+		cacheLookups.Inc("asset", "hit")
+		return &a.asset, nil
 	}
-	defer r.Body.Close()
+	cacheLookups.Inc("asset", "miss")
 
-	var raw svc.Resp
-	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+	asset, err := c.RetrieveAsset(ctx, name)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
-
-	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusCreated {
-		var e errors.ConcreteUserError
-		err = raw.Extract("error", &e)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		return nil, errors.Trace(ErrMintClient{
-			r.StatusCode, e.ErrCode, e.ErrMessage,
-		})
+	if asset.Name != name {
+		return nil, errors.Trace(errors.Newf(
+			"Unexpected asset name: %s expected %s", asset.Name, name))
 	}
 
-	var offer OfferResource
-	if err := raw.Extract("offer", &offer); err != nil {
-		return nil, errors.Trace(err)
+	cache.mutex.Lock()
+	if _, ok := cache.assets[name]; !ok &&
+		len(cache.assets) >= MaxCachedEntries {
+		evictAssets(time.Now())
 	}
+	cache.assets[name] = cachedAsset{time.Now().Add(AssetCacheTTL), *asset}
+	cache.mutex.Unlock()
 
-	return &offer, nil
+	return asset, nil
 }
 
-// PropagateOperation propagates an operation to the specified mint.
-func (c *Client) PropagateOperation(
+// RetrieveOperation retrieves an operation given its ID by extracting the mint
+// and retrieving it from there.
+func (c *Client) RetrieveOperation(
 	ctx context.Context,
 	id string,
-	mint string,
 ) (*OperationResource, error) {
-	req, err := http.NewRequest("POST",
-		FullMintURL(ctx, mint,
-			fmt.Sprintf("/operations/%s", id), url.Values{}).String(), nil)
+	host, err := hostFromID(ctx, id)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Mint-Protocol-Version", ProtocolVersion)
-	r, err := c.httpClient.Do(req)
+
+	var operation OperationResource
+	err = c.do(ctx, host, "GET", fmt.Sprintf("/operations/%s", id), nil,
+		true, "operation", &operation)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	defer r.Body.Close()
 
-	var raw svc.Resp
-	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
-		return nil, errors.Trace(err)
-	}
+	return &operation, nil
+}
 
-	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusCreated {
-		var e errors.ConcreteUserError
-		err = raw.Extract("error", &e)
+// RetrieveTransaction retrieves a transaction given its ID by extracting the
+// mint and retrieving it from there. If host is specified, it attempts to
+// retrrieve the transaction from this host instead of the canonical host.
+func (c *Client) RetrieveTransaction(
+	ctx context.Context,
+	id string,
+	mint *string,
+) (*TransactionResource, error) {
+	if mint == nil {
+		host, err := hostFromID(ctx, id)
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
-		return nil, errors.Trace(ErrMintClient{
-			r.StatusCode, e.ErrCode, e.ErrMessage,
-		})
+		mint = &host
 	}
 
-	var operation OperationResource
-	if err := raw.Extract("operation", &operation); err != nil {
+	var transaction TransactionResource
+	err := c.do(ctx, *mint, "GET", fmt.Sprintf("/transactions/%s", id), nil,
+		true, "transaction", &transaction)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
-	return &operation, nil
+	return &transaction, nil
 }
 
-// PropagateTransaction propagates a transaction to the specified mint.
-func (c *Client) PropagateTransaction(
+// PropagateBalance propagates an balance to the specified mint.
+func (c *Client) PropagateBalance(
 	ctx context.Context,
 	id string,
-	hop int8,
 	mint string,
-) (*TransactionResource, error) {
-	req, err := http.NewRequest("POST",
-		FullMintURL(ctx, mint,
-			fmt.Sprintf("/transactions/%s", id), url.Values{}).String(),
-		strings.NewReader(url.Values{
-			"hop": {fmt.Sprintf("%d", hop)},
-		}.Encode()))
+) (*BalanceResource, error) {
+	var balance BalanceResource
+	err := c.do(ctx, mint, "POST", fmt.Sprintf("/balances/%s", id), nil,
+		true, "balance", &balance)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Mint-Protocol-Version", ProtocolVersion)
-	r, err := c.httpClient.Do(req)
+
+	return &balance, nil
+}
+
+// PropagateOffer propagates an offer to the specified mint.
+func (c *Client) PropagateOffer(
+	ctx context.Context,
+	id string,
+	mint string,
+) (*OfferResource, error) {
+	var offer OfferResource
+	err := c.do(ctx, mint, "POST", fmt.Sprintf("/offers/%s", id), nil,
+		true, "offer", &offer)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	defer r.Body.Close()
 
-	var raw svc.Resp
-	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+	return &offer, nil
+}
+
+// PropagateOperation propagates an operation to the specified mint.
+func (c *Client) PropagateOperation(
+	ctx context.Context,
+	id string,
+	mint string,
+) (*OperationResource, error) {
+	var operation OperationResource
+	err := c.do(ctx, mint, "POST", fmt.Sprintf("/operations/%s", id), nil,
+		true, "operation", &operation)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
-	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusCreated {
-		var e errors.ConcreteUserError
-		err = raw.Extract("error", &e)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		return nil, errors.Trace(ErrMintClient{
-			r.StatusCode, e.ErrCode, e.ErrMessage,
-		})
+	return &operation, nil
+}
+
+// PropagateTransaction propagates a transaction to the specified mint. It is
+// not retried as it reserves funds on the mint.
+func (c *Client) PropagateTransaction(
+	ctx context.Context,
+	id string,
+	hop int8,
+	mint string,
+) (*TransactionResource, error) {
+	body := url.Values{
+		"hop": []string{fmt.Sprintf("%d", hop)},
 	}
 
 	var transaction TransactionResource
-	if err := raw.Extract("transaction", &transaction); err != nil {
+	err := c.do(ctx, mint, "POST", fmt.Sprintf("/transactions/%s", id), body,
+		false, "transaction", &transaction)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -573,11 +1156,7 @@ func (c *Client) SettleTransaction(
 	mint *string,
 ) (*TransactionResource, error) {
 	if mint == nil {
-		owner, _, err := NormalizedOwnerAndTokenFromID(ctx, id)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		_, host, err := UsernameAndMintHostFromAddress(ctx, owner)
+		host, err := hostFromID(ctx, id)
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
@@ -592,39 +1171,11 @@ func (c *Client) SettleTransaction(
 		body["secret"] = []string{*secret}
 	}
 
-	req, err := http.NewRequest("POST",
-		FullMintURL(ctx, *mint,
-			fmt.Sprintf("/transactions/%s/settle", id), url.Values{}).String(),
-		strings.NewReader(body.Encode()))
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Mint-Protocol-Version", ProtocolVersion)
-	r, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-	defer r.Body.Close()
-
-	var raw svc.Resp
-	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusCreated {
-		var e errors.ConcreteUserError
-		err = raw.Extract("error", &e)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		return nil, errors.Trace(ErrMintClient{
-			r.StatusCode, e.ErrCode, e.ErrMessage,
-		})
-	}
-
 	var transaction TransactionResource
-	if err := raw.Extract("transaction", &transaction); err != nil {
+	err := c.do(ctx, *mint, "POST",
+		fmt.Sprintf("/transactions/%s/settle", id), body,
+		false, "transaction", &transaction)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -642,39 +1193,12 @@ func (c *Client) CancelTransaction(
 	body := url.Values{
 		"hop": []string{fmt.Sprintf("%d", hop)},
 	}
-	req, err := http.NewRequest("POST",
-		FullMintURL(ctx, mint,
-			fmt.Sprintf("/transactions/%s/cancel", id), url.Values{}).String(),
-		strings.NewReader(body.Encode()))
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Mint-Protocol-Version", ProtocolVersion)
-	r, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-	defer r.Body.Close()
-
-	var raw svc.Resp
-	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusCreated {
-		var e errors.ConcreteUserError
-		err = raw.Extract("error", &e)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		return nil, errors.Trace(ErrMintClient{
-			r.StatusCode, e.ErrCode, e.ErrMessage,
-		})
-	}
 
 	var transaction TransactionResource
-	if err := raw.Extract("transaction", &transaction); err != nil {
+	err := c.do(ctx, mint, "POST",
+		fmt.Sprintf("/transactions/%s/cancel", id), body,
+		false, "transaction", &transaction)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -3,12 +3,20 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log"
+	"os"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/mint"
 	"github.com/spolu/settle/mint/app"
+	"github.com/spolu/settle/mint/async"
 	"github.com/spolu/settle/mint/model"
 )
 
@@ -19,28 +27,76 @@ var dsnFlag string
 
 var hstFlag string
 var prtFlag string
+var wrkFlag string
+var admFlag string
+var rtlFlag string
+var ppfFlag string
+var pubFlag string
+var pbkFlag string
 
 var usrFlag string
 var pasFlag string
 
+var filFlag string
+
+var tskFlag string
+var tsoFlag string
+var tssFlag string
+var tsnFlag string
+var tsjFlag string
+
+var lvlFlag string
+var lfmFlag string
+
 func init() {
 	flag.StringVar(&actFlag, "action",
-		"run", "The action to perform (run, create_user), default: run")
+		"run", "The action to perform (run, create_user, export, import, tasks), default: run")
 
 	flag.StringVar(&envFlag, "env",
 		"qa", "The environment to run in (qa, production), default: qa")
 	flag.StringVar(&dsnFlag, "db_dsn",
 		"", "The DSN of the database to use, default: sqlite3://~/.mint/mint-$env.db")
 	flag.StringVar(&hstFlag, "host",
-		"", "The externally accessible host name of this mint, default: none (required for production)")
+		"", "The externally accessible host name of this mint, or a comma separated list of host names to serve several mints from this process (create_user, export and import act on the first one), default: none (required for production)")
 	flag.StringVar(&prtFlag, "port",
 		"", "The port on which the mint will listen, default: 2406 in qa and 2407 in production")
+	flag.StringVar(&wrkFlag, "workers",
+		"1", "The number of concurrent workers executing asynchronous tasks, default: 1")
+	flag.StringVar(&admFlag, "admin_secret",
+		"", "The secret to pass as bearer token to access the admin API (/admin/*), default: none (admin API disabled)")
+	flag.StringVar(&rtlFlag, "rate_limits",
+		"", "A comma separated list of class=rate overriding the default rate limits, classes being user, peer, expensive and login (for example user=600/m,expensive=60/m,login=off), default: user=600/m,peer=1200/m,expensive=60/m,login=10/m")
+	flag.StringVar(&ppfFlag, "peer_policy",
+		"", "The path to a JSON file listing the peer mints to allow or block and their exposure caps, default: none (all peers allowed)")
+	flag.StringVar(&pubFlag, "public_url",
+		"", "The base URL of the mint API advertised to clients and peers at /.well-known/settle-mint if it is not served at the host of the mint, as a comma separated list of host=url when serving several hosts (for example ias.edu=https://api.ias.edu) or a single URL otherwise (for example https://api.ias.edu), default: none (inferred from each host)")
+	flag.StringVar(&pbkFlag, "public_keys",
+		"", "A comma separated list of public keys advertised at /.well-known/settle-mint (for example certificate pins), default: none")
 
 	flag.StringVar(&usrFlag, "username",
 		"foo", "The user name of the user for the create_user action")
 	flag.StringVar(&pasFlag, "password",
 		"bar", "The password of the user for the create_user action")
 
+	flag.StringVar(&filFlag, "file",
+		"", "The archive file for the export and import actions, default: stdout for export, stdin for import")
+
+	flag.StringVar(&tskFlag, "task",
+		"", "The id of the task to operate on for the tasks action, default: none (list tasks)")
+	flag.StringVar(&tsoFlag, "task_op",
+		"", "The operation to perform on the task for the tasks action (requeue, cancel, run)")
+	flag.StringVar(&tssFlag, "task_status",
+		"", "Only list tasks with this status for the tasks action (pending, succeeded, failed, canceled), default: any")
+	flag.StringVar(&tsnFlag, "task_name",
+		"", "Only list tasks with this name for the tasks action, default: any")
+	flag.StringVar(&tsjFlag, "task_subject",
+		"", "Only list tasks with this subject for the tasks action, default: any")
+
+	flag.StringVar(&lvlFlag, "log_level",
+		"info", "The minimum level of logged entries (debug, info, warn, error), default: info")
+	flag.StringVar(&lfmFlag, "log_format",
+		"text", "The format of logged entries (text, json), default: text")
+
 	if fl := log.Flags(); fl&log.Ltime != 0 {
 		log.SetFlags(fl | log.Lmicroseconds)
 	}
@@ -51,16 +107,28 @@ func main() {
 		flag.Parse()
 	}
 
+	level, err := logging.ParseLevel(lvlFlag)
+	if err != nil {
+		log.Fatal(errors.Details(err))
+	}
+	format, err := logging.ParseFormat(lfmFlag)
+	if err != nil {
+		log.Fatal(errors.Details(err))
+	}
+	logging.Configure(level, format)
+
 	ctx, err := app.BackgroundContextFromFlags(
 		envFlag,
 		dsnFlag,
 		hstFlag, prtFlag,
+		wrkFlag, admFlag, rtlFlag, ppfFlag,
+		pubFlag, pbkFlag,
 	)
 	if err != nil {
 		log.Fatal(errors.Details(err))
 	}
 
-	validActions := []string{"run", "create_user"}
+	validActions := []string{"run", "create_user", "export", "import", "tasks"}
 	switch actFlag {
 	case "run":
 		mux, err := app.Build(ctx)
@@ -73,6 +141,16 @@ func main() {
 		}
 	case "create_user":
 		CreateUser(ctx, usrFlag, pasFlag)
+	case "export":
+		Export(ctx, filFlag)
+	case "import":
+		Import(ctx, filFlag)
+	case "tasks":
+		if tskFlag == "" {
+			ListTasks(ctx, mint.TkStatus(tssFlag), mint.TkName(tsnFlag), tsjFlag)
+		} else {
+			OperateTask(ctx, tskFlag, tsoFlag)
+		}
 	default:
 		log.Fatalf("Invalid action `%s`, valid actions are: %s",
 			actFlag, strings.Join(validActions, ", "))
@@ -108,3 +186,129 @@ func CreateUser(
 		}
 	}
 }
+
+// Export is a convenience function exposed on the command line to dump the
+// content of the mint DB to an archive. It can be run while the mint is
+// running as the export happens within a consistent snapshot of the DB.
+func Export(
+	ctx context.Context,
+	path string,
+) {
+	var w io.Writer = os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			log.Fatal(errors.Details(err))
+		}
+		defer f.Close()
+		w = f
+	}
+
+	ctx = db.BeginSnapshot(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	counts, err := model.ExportArchive(ctx, w)
+	if err != nil {
+		log.Fatal(errors.Details(err))
+	}
+	logging.Logf(ctx, "Exported archive: counts=%v", counts)
+}
+
+// Import is a convenience function exposed on the command line to restore an
+// archive produced by Export into an empty mint DB.
+func Import(
+	ctx context.Context,
+	path string,
+) {
+	var r io.Reader = os.Stdin
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(errors.Details(err))
+		}
+		defer f.Close()
+		r = f
+	}
+
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	counts, err := model.ImportArchive(ctx, r)
+	if err != nil {
+		log.Fatal(errors.Details(err))
+	}
+
+	db.Commit(ctx)
+	logging.Logf(ctx, "Imported archive: counts=%v", counts)
+}
+
+// printTask prints a task on one line.
+func printTask(
+	w io.Writer,
+	t *model.Task,
+) {
+	lastError := ""
+	if t.LastError != nil {
+		lastError = *t.LastError
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%q\n",
+		t.Token, t.Name, t.Status, t.Retry,
+		t.Deadline.Format(time.RFC3339), t.Subject, lastError)
+}
+
+// ListTasks is a convenience function exposed on the command line to list the
+// async tasks of the mint filtered by status, name and subject.
+func ListTasks(
+	ctx context.Context,
+	status mint.TkStatus,
+	name mint.TkName,
+	subject string,
+) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	tasks, err := model.LoadTaskList(ctx,
+		time.Now(), 1000, status, name, subject)
+	if err != nil {
+		log.Fatal(errors.Details(err))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tNAME\tSTATUS\tRETRY\tDEADLINE\tSUBJECT\tLAST ERROR\n")
+	for _, t := range tasks {
+		t := t
+		printTask(w, &t)
+	}
+	w.Flush()
+}
+
+// OperateTask is a convenience function exposed on the command line to
+// requeue a failed task, cancel a pending one or run one immediately.
+func OperateTask(
+	ctx context.Context,
+	token string,
+	op string,
+) {
+	a := async.Get(ctx)
+
+	var t *model.Task
+	var err error
+	switch op {
+	case "requeue":
+		t, err = a.RequeueTask(ctx, token)
+	case "cancel":
+		t, err = a.CancelTask(ctx, token)
+	case "run":
+		t, err = a.RunTask(ctx, token)
+	default:
+		log.Fatalf("Invalid task operation `%s`, valid operations are: "+
+			"requeue, cancel, run", op)
+	}
+	if err != nil {
+		log.Fatal(errors.Details(err))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	printTask(w, t)
+	w.Flush()
+}
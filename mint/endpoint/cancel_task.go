@@ -0,0 +1,69 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint/async"
+	"github.com/spolu/settle/mint/model"
+	"goji.io/pat"
+)
+
+const (
+	// EndPtCancelTask cancels a pending task.
+	EndPtCancelTask EndPtName = "CancelTask"
+)
+
+func init() {
+	registrar[EndPtCancelTask] = NewCancelTask
+}
+
+// CancelTask cancels a pending task (admin only).
+type CancelTask struct {
+	Token string
+}
+
+// NewCancelTask constructs and initialiezes the endpoint.
+func NewCancelTask(
+	r *http.Request,
+) (Endpoint, error) {
+	return &CancelTask{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *CancelTask) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	if err := ValidateAdmin(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	// Validate task.
+	token, err := ValidateTask(ctx, pat.Param(r, "task"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Token = *token
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *CancelTask) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	task, err := async.Get(ctx).CancelTask(ctx, e.Token)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"task": format.JSONPtr(model.NewTaskResource(ctx, task)),
+	}, nil
+}
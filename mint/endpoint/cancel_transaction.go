@@ -5,11 +5,12 @@ import (
 	"fmt"
 	"math/big"
 	"net/http"
-	"time"
 
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/lib/ptr"
 	"github.com/spolu/settle/lib/svc"
 	"github.com/spolu/settle/mint"
@@ -93,6 +94,8 @@ func (e *CancelTransaction) Validate(
 func (e *CancelTransaction) Execute(
 	ctx context.Context,
 ) (*int, *svc.Resp, error) {
+	ctx = logging.WithFields(ctx, logging.Fields{"transaction": e.ID})
+
 	switch authentication.Get(ctx).Status {
 	case authentication.AutStSkipped:
 		return e.ExecutePropagated(ctx)
@@ -109,7 +112,9 @@ func (e *CancelTransaction) Execute(
 func (e *CancelTransaction) ExecuteAuthenticated(
 	ctx context.Context,
 ) (*int, *svc.Resp, error) {
-	ctx = db.Begin(ctx, "mint")
+	oCtx := ctx
+
+	ctx = db.Begin(oCtx, "mint")
 	defer db.LoggedRollback(ctx)
 
 	// We load the transaction even if it has been propagated as the only node
@@ -182,51 +187,30 @@ func (e *CancelTransaction) ExecuteAuthenticated(
 		))
 	}
 
-	// Cancel will idempotently cancel the transaction on all hops that are
-	// involving this mint.
-	err = e.Cancel(ctx)
-	if err != nil {
-		return nil, nil, errors.Trace(errors.NewUserErrorf(err,
-			402, "cancellation_failed",
-			"The cancellation execution failed for the transaction: %s", e.ID,
-		))
-	}
-
-	// We mark the transaction as cancelled if the hop of this is the minimal
-	// one for this mint. Cancelation checks only use operations and crossings
-	// so the status of a transaction is mostly indicative, but we want to mark
-	// it as cancelled only after it is cancelled at all hops.
-	if e.Hop == *minHop {
-		e.Tx.Status = mint.TxStCanceled
-		err = e.Tx.Save(ctx)
-		if err != nil {
-			return nil, nil, errors.Trace(err) // 500
-		}
-	}
+	db.Commit(ctx)
 
-	ops, err := model.LoadCanonicalOperationsByTransaction(ctx, e.ID)
+	ops, crs, err := e.ExecuteCancel(oCtx, *minHop)
 	if err != nil {
-		return nil, nil, errors.Trace(err) // 500
+		return nil, nil, errors.Trace(err)
 	}
+	tx = e.Tx
 
-	crs, err := model.LoadCanonicalCrossingsByTransaction(ctx, e.ID)
-	if err != nil {
-		return nil, nil, errors.Trace(err) // 500
+	if e.Hop == *minHop {
+		observeTransaction(e.Tx.Status, e.Tx.Propagation)
 	}
 
-	db.Commit(ctx)
-
 	err = e.Propagate(ctx)
 	if err != nil {
 		// If cancellation propagation failed we log it and trigger an
 		// asyncrhonous one. In any case the node before us will check on us
 		// before attempting to settle as well.
-		mint.Logf(ctx,
-			"Cancellation propagation failed: transaction=%s hop=%d error=%s",
-			e.ID, e.Hop, err.Error())
-		err = async.Queue(ctx,
-			task.NewPropagateCancellation(ctx,
-				time.Now(), fmt.Sprintf("%s|%d", e.ID, e.Hop)))
+		mint.Log(ctx, logging.LvWarn, "Cancellation propagation failed",
+			logging.Fields{
+				"hop":   e.Hop,
+				"error": err.Error(),
+			})
+		err = async.Queue(oCtx,
+			task.NewPropagateCancellation(ctx, clock.Now(ctx), e.ID, e.Hop))
 		if err != nil {
 			return nil, nil, errors.Trace(err) // 500
 		}
@@ -244,7 +228,9 @@ func (e *CancelTransaction) ExecuteAuthenticated(
 func (e *CancelTransaction) ExecutePropagated(
 	ctx context.Context,
 ) (*int, *svc.Resp, error) {
-	ctx = db.Begin(ctx, "mint")
+	oCtx := ctx
+
+	ctx = db.Begin(oCtx, "mint")
 	defer db.LoggedRollback(ctx)
 
 	tx, err := model.LoadTransactionByID(ctx, e.ID)
@@ -307,52 +293,29 @@ func (e *CancelTransaction) ExecutePropagated(
 		))
 	}
 
-	// Cancel will idempotently cancel the transaction on all hops that are
-	// involving this mint.
-	err = e.Cancel(ctx)
-	if err != nil {
-		return nil, nil, errors.Trace(errors.NewUserErrorf(err,
-			402, "cancellation_failed",
-			"The cancellation execution failed for the transaction: %s", e.ID,
-		))
-	}
-
-	// We mark the transaction as cancelled if the hop of this is the minimal
-	// one for this mint. Cancelation checks only use operations and crossings
-	// so the status of a transaction is mostly indicative, but we want to mark
-	// it as cancelled only after it is cancelled at all hops.
-	if e.Hop == *minHop {
-		e.Tx.Status = mint.TxStCanceled
-		err = e.Tx.Save(ctx)
-		if err != nil {
-			return nil, nil, errors.Trace(err) // 500
-		}
-	}
+	db.Commit(ctx)
 
-	ops, err := model.LoadCanonicalOperationsByTransaction(ctx, e.ID)
+	ops, crs, err := e.ExecuteCancel(oCtx, *minHop)
 	if err != nil {
-		return nil, nil, errors.Trace(err) // 500
+		return nil, nil, errors.Trace(err)
 	}
-
-	crs, err := model.LoadCanonicalCrossingsByTransaction(ctx, e.ID)
-	if err != nil {
-		return nil, nil, errors.Trace(err) // 500
+	tx = e.Tx
+	if e.Hop == *minHop {
+		observeTransaction(e.Tx.Status, e.Tx.Propagation)
 	}
 
-	// Commit the transaction as well as operations and crossings as canceled..
-	db.Commit(ctx)
-
 	err = e.Propagate(ctx)
 	if err != nil {
 		// If cancellation propagation failed we log it and trigger an
 		// asyncrhonous one. In any case the node before us will check on us
 		// before attempting to settle as well.
-		mint.Logf(ctx,
-			"Cancellation propagation failed: transaction=%s hop=%d error=%s",
-			e.ID, e.Hop, err.Error())
-		err = async.Queue(ctx,
-			task.NewPropagateCancellation(ctx,
-				time.Now(), fmt.Sprintf("%s|%d", e.ID, e.Hop)))
+		mint.Log(ctx, logging.LvWarn, "Cancellation propagation failed",
+			logging.Fields{
+				"hop":   e.Hop,
+				"error": err.Error(),
+			})
+		err = async.Queue(oCtx,
+			task.NewPropagateCancellation(ctx, clock.Now(ctx), e.ID, e.Hop))
 		if err != nil {
 			return nil, nil, errors.Trace(err) // 500
 		}
@@ -365,6 +328,74 @@ func (e *CancelTransaction) ExecutePropagated(
 	}, nil
 }
 
+// ExecuteCancel idempotently cancels the transaction at the current hop and
+// marks it as canceled if this is the minimal hop for this mint. It runs in a
+// DB transaction that only locks the rows it updates (the transaction, the
+// crossed offer and the balances involved), re-run from scratch on
+// serialization failures.
+func (e *CancelTransaction) ExecuteCancel(
+	ctx context.Context,
+	minHop int8,
+) ([]*model.Operation, []*model.Crossing, error) {
+	var ops []*model.Operation
+	var crs []*model.Crossing
+	err := db.Retry(ctx, "mint", func(ctx context.Context) error {
+		tx, err := model.LockTransactionByID(ctx, e.ID)
+		if err != nil || tx == nil {
+			return errors.Trace(err) // 500
+		}
+		e.Tx = tx
+
+		// The transaction may have been settled since it was last loaded.
+		if e.Tx.Status == mint.TxStSettled {
+			return errors.Trace(errors.NewUserErrorf(nil,
+				402, "cancellation_failed",
+				"The transaction you are trying to cancel is settled: %s.",
+				e.ID,
+			))
+		}
+
+		// Cancel will idempotently cancel the transaction on all hops that
+		// are involving this mint.
+		err = e.Cancel(ctx)
+		if err != nil {
+			return errors.Trace(errors.NewUserErrorf(err,
+				402, "cancellation_failed",
+				"The cancellation execution failed for the transaction: %s",
+				e.ID,
+			))
+		}
+
+		// We mark the transaction as cancelled if the hop of this is the
+		// minimal one for this mint. Cancelation checks only use operations
+		// and crossings so the status of a transaction is mostly indicative,
+		// but we want to mark it as cancelled only after it is cancelled at
+		// all hops.
+		if e.Hop == minHop {
+			e.Tx.Status = mint.TxStCanceled
+			err = e.Tx.Save(ctx)
+			if err != nil {
+				return errors.Trace(err) // 500
+			}
+		}
+
+		ops, err = model.LoadCanonicalOperationsByTransaction(ctx, e.ID)
+		if err != nil {
+			return errors.Trace(err) // 500
+		}
+
+		crs, err = model.LoadCanonicalCrossingsByTransaction(ctx, e.ID)
+		if err != nil {
+			return errors.Trace(err) // 500
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	return ops, crs, nil
+}
+
 // Cancel cancels idempotently the underlying operations and crossings at the
 // current hop.
 func (e *CancelTransaction) Cancel(
@@ -377,8 +408,9 @@ func (e *CancelTransaction) Cancel(
 	}
 
 	h := e.Plan.Hops[e.Hop]
-	mint.Logf(ctx,
-		"Executing cancellation plan: transaction=%s hop=%d", e.ID, e.Hop)
+	mint.Log(ctx, logging.LvInfo, "Executing cancellation plan", logging.Fields{
+		"hop": e.Hop,
+	})
 
 	// Cancel the OpAction (should always be defined)
 	if h.OpAction != nil {
@@ -393,13 +425,7 @@ func (e *CancelTransaction) Cancel(
 		}
 
 		if op.Status == mint.TxStCanceled {
-			mint.Logf(ctx,
-				"Skipped operation: id=%s[%s] created=%q propagation=%s "+
-					"asset=%s source=%s destination=%s amount=%s "+
-					"status=%s transaction=%s",
-				op.Owner, op.Token, op.Created, op.Propagation, op.Asset,
-				op.Source, op.Destination, (*big.Int)(&op.Amount).String(),
-				op.Status, *op.Transaction)
+			mint.Log(ctx, logging.LvInfo, "Skipped operation", operationFields(op))
 
 		} else {
 			a := h.OpAction
@@ -417,7 +443,7 @@ func (e *CancelTransaction) Cancel(
 			// issued on the fly).
 			var srcBalance *model.Balance
 			if asset.Owner != op.Source {
-				srcBalance, err = model.LoadCanonicalBalanceByAssetHolder(ctx,
+				srcBalance, err = model.LockCanonicalBalanceByAssetHolder(ctx,
 					op.Asset, op.Source)
 				if err != nil {
 					return errors.Trace(err)
@@ -443,7 +469,8 @@ func (e *CancelTransaction) Cancel(
 				}
 
 				err = async.Queue(ctx,
-					task.NewPropagateBalance(ctx, time.Now(), srcBalance.ID()))
+					task.NewPropagateBalance(ctx,
+						clock.Now(ctx), srcBalance.ID()))
 				if err != nil {
 					return errors.Trace(err)
 				}
@@ -455,13 +482,7 @@ func (e *CancelTransaction) Cancel(
 				return errors.Trace(err)
 			}
 
-			mint.Logf(ctx,
-				"Canceled operation: id=%s[%s] created=%q propagation=%s "+
-					"asset=%s source=%s destination=%s amount=%s "+
-					"status=%s transaction=%s",
-				op.Owner, op.Token, op.Created, op.Propagation, op.Asset,
-				op.Source, op.Destination, (*big.Int)(&op.Amount).String(),
-				op.Status, *op.Transaction)
+			mint.Log(ctx, logging.LvInfo, "Canceled operation", operationFields(op))
 		}
 	}
 
@@ -477,15 +498,11 @@ func (e *CancelTransaction) Cancel(
 		}
 
 		if cr.Status == mint.TxStSettled {
-			mint.Logf(ctx,
-				"Skipped crossing: id=%s[%s] created=%q offer=%s amount=%s "+
-					"status=%s transaction=%s",
-				cr.Owner, cr.Token, cr.Created, cr.Offer,
-				(*big.Int)(&cr.Amount).String(), cr.Status, cr.Transaction)
+			mint.Log(ctx, logging.LvInfo, "Skipped crossing", crossingFields(cr))
 		} else {
 			a := h.CrAction
 
-			offer, err := model.LoadCanonicalOfferByID(ctx, *a.CrossingOffer)
+			offer, err := model.LockCanonicalOfferByID(ctx, *a.CrossingOffer)
 			if err != nil {
 				return errors.Trace(err)
 			} else if offer == nil {
@@ -515,7 +532,7 @@ func (e *CancelTransaction) Cancel(
 			}
 
 			err = async.Queue(ctx,
-				task.NewPropagateOffer(ctx, time.Now(), offer.ID()))
+				task.NewPropagateOffer(ctx, clock.Now(ctx), offer.ID()))
 			if err != nil {
 				return errors.Trace(err)
 			}
@@ -526,11 +543,7 @@ func (e *CancelTransaction) Cancel(
 				return errors.Trace(err)
 			}
 
-			mint.Logf(ctx,
-				"Canceled crossing: id=%s[%s] created=%q offer=%s amount=%s "+
-					"status=%s transaction=%s",
-				cr.Owner, cr.Token, cr.Created, cr.Offer,
-				(*big.Int)(&cr.Amount).String(), cr.Status, cr.Transaction)
+			mint.Log(ctx, logging.LvInfo, "Canceled crossing", crossingFields(cr))
 		}
 	}
 
@@ -545,9 +558,10 @@ func (e *CancelTransaction) Propagate(
 	if int(e.Hop)-1 >= 0 {
 		m := e.Plan.Hops[e.Hop-1].Mint
 
-		mint.Logf(ctx,
-			"Propagating cancellation: transaction=%s hop=%d mint=%s",
-			e.ID, e.Hop, m)
+		mint.Log(ctx, logging.LvInfo, "Propagating cancellation", logging.Fields{
+			"hop":  e.Hop,
+			"mint": m,
+		})
 
 		_, err := e.Client.CancelTransaction(ctx, e.ID, e.Hop-1, m)
 		if err != nil {
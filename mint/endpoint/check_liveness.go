@@ -0,0 +1,47 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+)
+
+const (
+	// EndPtCheckLiveness checks that the mint process is alive.
+	EndPtCheckLiveness EndPtName = "CheckLiveness"
+)
+
+func init() {
+	registrar[EndPtCheckLiveness] = NewCheckLiveness
+}
+
+// CheckLiveness checks that the mint process is alive (able to serve
+// requests), without checking its dependencies. It is not authenticated.
+type CheckLiveness struct{}
+
+// NewCheckLiveness constructs and initialiezes the endpoint.
+func NewCheckLiveness(
+	r *http.Request,
+) (Endpoint, error) {
+	return &CheckLiveness{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *CheckLiveness) Validate(
+	r *http.Request,
+) error {
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *CheckLiveness) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"status": format.JSONPtr(mint.HlStOK),
+	}, nil
+}
@@ -0,0 +1,67 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/lib/health"
+)
+
+const (
+	// EndPtCheckReadiness checks that the mint process is ready to serve
+	// requests.
+	EndPtCheckReadiness EndPtName = "CheckReadiness"
+)
+
+func init() {
+	registrar[EndPtCheckReadiness] = NewCheckReadiness
+}
+
+// CheckReadiness checks that the dependencies of the mint process (DB and
+// async workers) are healthy, and optionally the reachability of its recent
+// peers. It returns a 503 if any dependency is unhealthy. It is not
+// authenticated, except when checking peers which requires the admin secret
+// (see authentication.Middleware).
+type CheckReadiness struct {
+	Peers bool
+}
+
+// NewCheckReadiness constructs and initialiezes the endpoint.
+func NewCheckReadiness(
+	r *http.Request,
+) (Endpoint, error) {
+	return &CheckReadiness{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *CheckReadiness) Validate(
+	r *http.Request,
+) error {
+	e.Peers = r.URL.Query().Get("peers") == "true"
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *CheckReadiness) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	h, err := health.Ready(ctx, e.Peers)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	status := http.StatusOK
+	if h.Status != mint.HlStOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	return ptr.Int(status), &svc.Resp{
+		"health": format.JSONPtr(*h),
+	}, nil
+}
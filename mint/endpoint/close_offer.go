@@ -4,8 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"time"
 
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/format"
@@ -81,7 +81,7 @@ func (e *CloseOffer) Execute(
 	ctx = db.Begin(ctx, "mint")
 	defer db.LoggedRollback(ctx)
 
-	offer, err := model.LoadCanonicalOfferByOwnerToken(ctx, e.Owner, e.Token)
+	offer, err := model.LockCanonicalOfferByOwnerToken(ctx, e.Owner, e.Token)
 	if err != nil {
 		return nil, nil, errors.Trace(err) // 500
 	} else if offer == nil {
@@ -99,7 +99,8 @@ func (e *CloseOffer) Execute(
 		return nil, nil, errors.Trace(err) // 500
 	}
 
-	err = async.Queue(ctx, task.NewPropagateOffer(ctx, time.Now(), offer.ID()))
+	err = async.Queue(ctx,
+		task.NewPropagateOffer(ctx, clock.Now(ctx), offer.ID()))
 	if err != nil {
 		return nil, nil, errors.Trace(err) // 500
 	}
@@ -0,0 +1,136 @@
+package endpoint
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/lib/authentication"
+	"github.com/spolu/settle/mint/model"
+)
+
+const (
+	// EndPtCreateAPIKey creates a new API key.
+	EndPtCreateAPIKey EndPtName = "CreateAPIKey"
+)
+
+func init() {
+	registrar[EndPtCreateAPIKey] = NewCreateAPIKey
+}
+
+// CreateAPIKey controls the creation of new API keys.
+type CreateAPIKey struct {
+	Owner         string
+	Name          string
+	Scopes        []mint.KyScope
+	PayLimit      *big.Int
+	PayLimitAsset *string
+	Expiry        *time.Time
+}
+
+// NewCreateAPIKey constructs and initialiezes the endpoint.
+func NewCreateAPIKey(
+	r *http.Request,
+) (Endpoint, error) {
+	return &CreateAPIKey{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *CreateAPIKey) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	e.Owner = authentication.Get(ctx).User.Token
+
+	name := r.PostFormValue("name")
+	if len(name) == 0 || len(name) > 256 {
+		return errors.Trace(errors.NewUserErrorf(nil,
+			400, "name_invalid",
+			"The API key name you provided is invalid: %s. Names must be "+
+				"between 1 and 256 characters long.",
+			name,
+		))
+	}
+	e.Name = name
+
+	// Validate scopes.
+	if r.PostForm == nil {
+		err := r.ParseMultipartForm(defaultMaxMemory)
+		if err != nil {
+			return errors.Trace(err) // 500
+		}
+	}
+	scopes, err := ValidateKeyScopes(ctx, r.PostForm["scopes[]"])
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Scopes = scopes
+
+	// Validate pay limit. Pay limits are bound to the asset they are
+	// expressed in.
+	if payLimit := r.PostFormValue("pay_limit"); payLimit != "" {
+		amount, err := ValidateAmount(ctx, payLimit)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		e.PayLimit = amount
+
+		asset, err := ValidateAsset(ctx, r.PostFormValue("pay_limit_asset"))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		e.PayLimitAsset = &asset.Name
+	}
+
+	// Validate expiry.
+	expiry, err := ValidateExpiry(ctx, r.PostFormValue("expiry"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Expiry = expiry
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *CreateAPIKey) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	var payLimit *model.Amount
+	if e.PayLimit != nil {
+		payLimit = (*model.Amount)(e.PayLimit)
+	}
+
+	key, secret, err := model.CreateAPIKey(ctx,
+		e.Owner,
+		e.Name,
+		e.Scopes,
+		payLimit,
+		e.PayLimitAsset,
+		e.Expiry,
+	)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	db.Commit(ctx)
+
+	r := model.NewAPIKeyResource(ctx, key)
+	credential := key.Token + "." + secret
+	r.Secret = &credential
+
+	return ptr.Int(http.StatusCreated), &svc.Resp{
+		"api_key": format.JSONPtr(r),
+	}, nil
+}
@@ -5,11 +5,12 @@ import (
 	"fmt"
 	"math/big"
 	"net/http"
-	"time"
 
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/lib/ptr"
 	"github.com/spolu/settle/lib/svc"
 	"github.com/spolu/settle/mint"
@@ -147,17 +148,9 @@ func (e *CreateOffer) Execute(
 		return nil, nil, errors.Trace(err) // 500
 	}
 
-	mint.Logf(ctx,
-		"Created offer: id=%s[%s] created=%q propagation=%s "+
-			"base_asset=%s quote_asset=%s base_price=%s quote_price=%s "+
-			"amount=%s status=%s remainder=%s",
-		of.Owner, of.Token, of.Created, of.Propagation, of.BaseAsset,
-		of.QuoteAsset,
-		(*big.Int)(&of.BasePrice).String(), (*big.Int)(&of.QuotePrice),
-		(*big.Int)(&of.Amount).String(), of.Status,
-		(*big.Int)(&of.Remainder).String())
-
-	err = async.Queue(ctx, task.NewPropagateOffer(ctx, time.Now(), of.ID()))
+	mint.Log(ctx, logging.LvInfo, "Created offer", offerFields(of))
+
+	err = async.Queue(ctx, task.NewPropagateOffer(ctx, clock.Now(ctx), of.ID()))
 	if err != nil {
 		return nil, nil, errors.Trace(err) // 500
 	}
@@ -9,15 +9,18 @@ import (
 
 	"goji.io/pat"
 
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/lib/ptr"
 	"github.com/spolu/settle/lib/svc"
 	"github.com/spolu/settle/mint"
 	"github.com/spolu/settle/mint/async"
 	"github.com/spolu/settle/mint/async/task"
 	"github.com/spolu/settle/mint/lib/authentication"
+	"github.com/spolu/settle/mint/lib/peering"
 	"github.com/spolu/settle/mint/lib/plan"
 	"github.com/spolu/settle/mint/model"
 )
@@ -82,6 +85,12 @@ func (e *CreateTransaction) Validate(
 		e.ID = *id
 		e.Owner = *owner
 
+		// Reject transactions from mints blocked by the peer policy before
+		// retrieving them.
+		if err := peering.CheckAddress(ctx, e.Owner); err != nil {
+			return errors.Trace(err)
+		}
+
 		// Validate hop.
 		hop, err := ValidateHop(ctx, r.PostFormValue("hop"))
 		if err != nil {
@@ -110,6 +119,21 @@ func (e *CreateTransaction) Validate(
 		}
 		e.Amount = *amount
 
+		// API keys with a pay limit can only pay in the asset it is
+		// expressed in (the limit itself is enforced upon creation, see
+		// checkPayLimit).
+		key := authentication.Get(ctx).APIKey
+		if key != nil && key.PayLimit != nil {
+			if key.PayLimitAsset == nil || *key.PayLimitAsset != e.QuoteAsset {
+				return errors.Trace(errors.NewUserErrorf(nil,
+					403, "pay_limit_asset_invalid",
+					"The API key you are using can only pay in the asset of "+
+						"its pay limit: %s.",
+					ptr.DerefStr(key.PayLimitAsset, "none"),
+				))
+			}
+		}
+
 		// Validate destination.
 		dstAddress, err := mint.NormalizedAddress(ctx, r.PostFormValue("destination"))
 		if err != nil {
@@ -163,6 +187,14 @@ func (e *CreateTransaction) ExecuteCanonical(
 	ctx = db.Begin(oCtx, "mint")
 	defer db.LoggedRollback(ctx)
 
+	var apiKey *string
+	if key := authentication.Get(ctx).APIKey; key != nil {
+		apiKey = &key.Token
+		if err := e.checkPayLimit(ctx, key.Token); err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+	}
+
 	// Create canonical transaction locally.
 	tx, err := model.CreateCanonicalTransaction(ctx,
 		e.Owner,
@@ -172,6 +204,7 @@ func (e *CreateTransaction) ExecuteCanonical(
 		e.Destination,
 		model.OfPath(e.Path),
 		mint.TxStPending,
+		apiKey,
 	)
 	if err != nil {
 		return nil, nil, errors.Trace(err) // 500
@@ -179,6 +212,18 @@ func (e *CreateTransaction) ExecuteCanonical(
 	e.Tx = tx
 	e.ID = e.Tx.ID()
 
+	// Expire the transaction if it is neither settled nor canceled by then.
+	err = async.Queue(ctx, task.NewExpireTransaction(ctx, clock.Now(ctx), e.ID))
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	// Surface peer policy rejections to the user before computing the plan
+	// (which would fail on them as well).
+	if err := plan.CheckPeers(ctx, e.Tx); err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
 	pl, err := plan.Compute(ctx, e.Client, e.Tx, false)
 	if err != nil {
 		return nil, nil, errors.Trace(errors.NewUserErrorf(err,
@@ -215,46 +260,50 @@ func (e *CreateTransaction) ExecuteCanonical(
 		))
 	}
 
-	ctx = db.Begin(oCtx, "mint")
-	defer db.LoggedRollback(ctx)
-
-	// Reload the transaction post propagation.
-	tx, err = model.LoadTransactionByID(ctx, e.ID)
-	if err != nil || tx == nil {
-		return nil, nil, errors.Trace(err) // 500
-	}
-	e.Tx = tx
+	// Mark the transaction as reserved in a DB transaction re-run from
+	// scratch on serialization failures.
+	var ops []*model.Operation
+	var crs []*model.Crossing
+	err = db.Retry(oCtx, "mint", func(ctx context.Context) error {
+		// Reload the transaction post propagation.
+		tx, err = model.LockTransactionByID(ctx, e.ID)
+		if err != nil || tx == nil {
+			return errors.Trace(err) // 500
+		}
+		e.Tx = tx
 
-	switch e.Tx.Status {
-	case mint.TxStPending:
-		// Mark the transaction as reserved.
-		e.Tx.Status = mint.TxStReserved
-	case mint.TxStReserved:
-		// No-op as the transaction was already marked as reserved during
-		// propagation.
-	default:
-		return nil, nil, errors.Newf(
-			"Unexpected transaction status %s: %s", e.Tx.Status, e.ID) // 500
-	}
+		switch e.Tx.Status {
+		case mint.TxStPending:
+			// Mark the transaction as reserved.
+			e.Tx.Status = mint.TxStReserved
+		case mint.TxStReserved:
+			// No-op as the transaction was already marked as reserved during
+			// propagation.
+		default:
+			return errors.Newf(
+				"Unexpected transaction status %s: %s", e.Tx.Status, e.ID) // 500
+		}
 
-	err = e.Tx.Save(ctx)
-	if err != nil {
-		return nil, nil, errors.Trace(err) // 500
-	}
+		err = e.Tx.Save(ctx)
+		if err != nil {
+			return errors.Trace(err) // 500
+		}
 
-	ops, err := model.LoadCanonicalOperationsByTransaction(ctx, e.ID)
-	if err != nil {
-		return nil, nil, errors.Trace(err) // 500
-	}
+		ops, err = model.LoadCanonicalOperationsByTransaction(ctx, e.ID)
+		if err != nil {
+			return errors.Trace(err) // 500
+		}
 
-	crs, err := model.LoadCanonicalCrossingsByTransaction(ctx, e.ID)
+		crs, err = model.LoadCanonicalCrossingsByTransaction(ctx, e.ID)
+		if err != nil {
+			return errors.Trace(err) // 500
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, nil, errors.Trace(err) // 500
+		return nil, nil, errors.Trace(err)
 	}
 
-	// Commit the transaction in reserved state.
-	db.Commit(ctx)
-
 	return ptr.Int(http.StatusCreated), &svc.Resp{
 		"transaction": format.JSONPtr(model.NewTransactionResource(ctx,
 			tx, ops, crs,
@@ -262,6 +311,39 @@ func (e *CreateTransaction) ExecuteCanonical(
 	}, nil
 }
 
+// checkPayLimit checks that the transaction does not make the total of the
+// transactions created with the API key exceed its pay limit if any. The key
+// is locked until the end of the current transaction so that concurrent
+// transactions of the key are checked serially.
+func (e *CreateTransaction) checkPayLimit(
+	ctx context.Context,
+	token string,
+) error {
+	key, err := model.LockAPIKeyByToken(ctx, token)
+	if err != nil || key == nil {
+		return errors.Trace(err) // 500
+	}
+	if key.PayLimit == nil {
+		return nil
+	}
+
+	spent, err := model.SpentByAPIKey(ctx, key.Token)
+	if err != nil {
+		return errors.Trace(err) // 500
+	}
+	total := new(big.Int).Add(spent, &e.Amount)
+	if total.Cmp((*big.Int)(key.PayLimit)) > 0 {
+		return errors.Trace(errors.NewUserErrorf(nil,
+			403, "pay_limit_exceeded",
+			"The transaction would exceed the pay limit of the API key you "+
+				"are using: %s spent, %s requested, %s limit.",
+			spent.String(), e.Amount.String(),
+			(*big.Int)(key.PayLimit).String(),
+		))
+	}
+	return nil
+}
+
 // ExecutePropagated executes the creation of a propagated transaction
 // (involved mint).
 func (e *CreateTransaction) ExecutePropagated(
@@ -331,6 +413,14 @@ func (e *CreateTransaction) ExecutePropagated(
 			return nil, nil, errors.Trace(err) // 500
 		}
 		e.Tx = tx
+
+		// Expire the transaction if it is neither settled nor canceled by
+		// then.
+		err = async.Queue(ctx,
+			task.NewExpireTransaction(ctx, clock.Now(ctx), e.ID))
+		if err != nil {
+			return nil, nil, errors.Trace(err) // 500
+		}
 	}
 
 	pl, err := plan.Compute(ctx, e.Client, e.Tx, false)
@@ -377,56 +467,62 @@ func (e *CreateTransaction) ExecutePropagated(
 		}
 	}
 
-	ctx = db.Begin(oCtx, "mint")
-	defer db.LoggedRollback(ctx)
-
-	// Reload the transaction post propagation.
-	tx, err = model.LoadTransactionByID(ctx, e.ID)
-	if err != nil || tx == nil {
-		return nil, nil, errors.Trace(err) // 500
-	}
-	e.Tx = tx
+	// Execute the plan as well as the transaction status change in a DB
+	// transaction that only locks the rows it updates (the transaction, the
+	// crossed offer and the balances involved), re-run from scratch on
+	// serialization failures.
+	var ops []*model.Operation
+	var crs []*model.Crossing
+	err = db.Retry(oCtx, "mint", func(ctx context.Context) error {
+		// Reload the transaction post propagation.
+		tx, err = model.LockTransactionByID(ctx, e.ID)
+		if err != nil || tx == nil {
+			return errors.Trace(err) // 500
+		}
+		e.Tx = tx
 
-	// Idempotently execute plan for the transaction.
-	err = e.ExecutePlan(ctx)
-	if err != nil {
-		return nil, nil, errors.Trace(errors.NewUserErrorf(err,
-			402, "transaction_failed",
-			"The plan execution failed at hop %d for transaction: %s",
-			e.Hop, e.ID,
-		))
-	}
+		// Idempotently execute plan for the transaction.
+		err = e.ExecutePlan(ctx)
+		if err != nil {
+			return errors.Trace(errors.NewUserErrorf(err,
+				402, "transaction_failed",
+				"The plan execution failed at hop %d for transaction: %s",
+				e.Hop, e.ID,
+			))
+		}
 
-	switch e.Tx.Status {
-	case mint.TxStPending:
-		// Mark the transaction as reserved.
-		e.Tx.Status = mint.TxStReserved
-	case mint.TxStReserved:
-		// No-op as the transaction was already marked as reserved during
-		// propagation.
-	default:
-		return nil, nil, errors.Newf(
-			"Unexpected transaction status %s: %s", e.Tx.Status, e.ID) // 500
-	}
+		switch e.Tx.Status {
+		case mint.TxStPending:
+			// Mark the transaction as reserved.
+			e.Tx.Status = mint.TxStReserved
+		case mint.TxStReserved:
+			// No-op as the transaction was already marked as reserved during
+			// propagation.
+		default:
+			return errors.Newf(
+				"Unexpected transaction status %s: %s", e.Tx.Status, e.ID) // 500
+		}
 
-	err = e.Tx.Save(ctx)
-	if err != nil {
-		return nil, nil, errors.Trace(err) // 500
-	}
+		err = e.Tx.Save(ctx)
+		if err != nil {
+			return errors.Trace(err) // 500
+		}
 
-	ops, err := model.LoadCanonicalOperationsByTransaction(ctx, e.ID)
-	if err != nil {
-		return nil, nil, errors.Trace(err) // 500
-	}
+		ops, err = model.LoadCanonicalOperationsByTransaction(ctx, e.ID)
+		if err != nil {
+			return errors.Trace(err) // 500
+		}
 
-	crs, err := model.LoadCanonicalCrossingsByTransaction(ctx, e.ID)
+		crs, err = model.LoadCanonicalCrossingsByTransaction(ctx, e.ID)
+		if err != nil {
+			return errors.Trace(err) // 500
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, nil, errors.Trace(err) // 500
+		return nil, nil, errors.Trace(err)
 	}
 
-	// Commit the plan execution as well as the transaction status change.
-	db.Commit(ctx)
-
 	return ptr.Int(http.StatusCreated), &svc.Resp{
 		"transaction": format.JSONPtr(model.NewTransactionResource(ctx,
 			tx, ops, crs,
@@ -448,8 +544,10 @@ func (e *CreateTransaction) ExecutePlan(
 	}
 
 	h := e.Plan.Hops[e.Hop]
-	mint.Logf(ctx,
-		"Executing transaction plan: transaction=%s hop=%d", e.ID, e.Hop)
+	mint.Log(ctx, logging.LvInfo, "Executing transaction plan", logging.Fields{
+		"transaction": e.ID,
+		"hop":         e.Hop,
+	})
 
 	// Execute the OpAction (should always be defined)
 	if h.OpAction != nil {
@@ -459,13 +557,7 @@ func (e *CreateTransaction) ExecutePlan(
 			return errors.Trace(err)
 		}
 		if op != nil {
-			mint.Logf(ctx,
-				"Skipped operation: id=%s[%s] created=%q propagation=%s "+
-					"asset=%s source=%s destination=%s amount=%s "+
-					"status=%s transaction=%s",
-				op.Owner, op.Token, op.Created, op.Propagation, op.Asset,
-				op.Source, op.Destination, (*big.Int)(&op.Amount).String(),
-				op.Status, *op.Transaction)
+			mint.Log(ctx, logging.LvInfo, "Skipped operation", operationFields(op))
 		} else {
 			a := h.OpAction
 
@@ -479,7 +571,7 @@ func (e *CreateTransaction) ExecutePlan(
 
 			var srcBalance *model.Balance
 			if a.OperationSource != nil && asset.Owner != *a.OperationSource {
-				srcBalance, err = model.LoadCanonicalBalanceByAssetHolder(ctx,
+				srcBalance, err = model.LockCanonicalBalanceByAssetHolder(ctx,
 					*a.OperationAsset, *a.OperationSource)
 				if err != nil {
 					return errors.Trace(err)
@@ -494,13 +586,20 @@ func (e *CreateTransaction) ExecutePlan(
 			if a.OperationDestination != nil &&
 				asset.Owner != *a.OperationDestination {
 				dstBalance, err =
-					model.LoadOrCreateCanonicalBalanceByAssetHolder(ctx,
+					model.LockOrCreateCanonicalBalanceByAssetHolder(ctx,
 						asset.Owner, *a.OperationAsset, *a.OperationDestination)
 				if err != nil {
 					return errors.Trace(err)
 				}
 			}
 
+			// Enforce the exposure cap to the mint of the destination.
+			err = peering.CheckExposure(ctx,
+				*a.OperationAsset, *a.OperationDestination, a.Amount)
+			if err != nil {
+				return errors.Trace(err)
+			}
+
 			op, err := model.CreateCanonicalOperation(ctx,
 				a.Owner,
 				*a.OperationAsset,
@@ -558,19 +657,14 @@ func (e *CreateTransaction) ExecutePlan(
 				}
 
 				err = async.Queue(ctx,
-					task.NewPropagateBalance(ctx, time.Now(), srcBalance.ID()))
+					task.NewPropagateBalance(ctx,
+						clock.Now(ctx), srcBalance.ID()))
 				if err != nil {
 					return errors.Trace(err)
 				}
 			}
 
-			mint.Logf(ctx,
-				"Reserved operation: id=%s[%s] created=%q propagation=%s "+
-					"asset=%s source=%s destination=%s amount=%s "+
-					"status=%s transaction=%s",
-				op.Owner, op.Token, op.Created, op.Propagation, op.Asset,
-				op.Source, op.Destination, (*big.Int)(&op.Amount).String(),
-				op.Status, *op.Transaction)
+			mint.Log(ctx, logging.LvInfo, "Reserved operation", operationFields(op))
 		}
 	}
 
@@ -581,15 +675,11 @@ func (e *CreateTransaction) ExecutePlan(
 			return errors.Trace(err)
 		}
 		if cr != nil {
-			mint.Logf(ctx,
-				"Skipped crossing: id=%s[%s] created=%q offer=%s amount=%s "+
-					"status=%s transaction=%s",
-				cr.Owner, cr.Token, cr.Created, cr.Offer,
-				(*big.Int)(&cr.Amount).String(), cr.Status, cr.Transaction)
+			mint.Log(ctx, logging.LvInfo, "Skipped crossing", crossingFields(cr))
 		} else {
 			a := h.CrAction
 
-			offer, err := model.LoadCanonicalOfferByID(ctx, *a.CrossingOffer)
+			offer, err := model.LockCanonicalOfferByID(ctx, *a.CrossingOffer)
 			if err != nil {
 				return errors.Trace(err)
 			} else if offer == nil {
@@ -636,16 +726,12 @@ func (e *CreateTransaction) ExecutePlan(
 			}
 
 			err = async.Queue(ctx,
-				task.NewPropagateOffer(ctx, time.Now(), offer.ID()))
+				task.NewPropagateOffer(ctx, clock.Now(ctx), offer.ID()))
 			if err != nil {
 				return errors.Trace(err)
 			}
 
-			mint.Logf(ctx,
-				"Reserved crossing: id=%s[%s] created=%q offer=%s amount=%s "+
-					"status=%s transaction=%s",
-				cr.Owner, cr.Token, cr.Created, cr.Offer,
-				(*big.Int)(&cr.Amount).String(), cr.Status, cr.Transaction)
+			mint.Log(ctx, logging.LvInfo, "Reserved crossing", crossingFields(cr))
 		}
 	}
 
@@ -660,9 +746,11 @@ func (e *CreateTransaction) Propagate(
 	if int(e.Hop)-1 >= 0 {
 		m := e.Plan.Hops[e.Hop-1].Mint
 
-		mint.Logf(ctx,
-			"Propagating transaction: transaction=%s hop=%d mint=%s",
-			e.ID, e.Hop-1, m)
+		mint.Log(ctx, logging.LvInfo, "Propagating transaction", logging.Fields{
+			"transaction": e.ID,
+			"hop":         e.Hop - 1,
+			"mint":        m,
+		})
 
 		txn, err := e.Client.PropagateTransaction(ctx, e.ID, e.Hop-1, m)
 		if err != nil {
@@ -0,0 +1,93 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint/model"
+)
+
+const (
+	// EndPtCreateUser creates a new user.
+	EndPtCreateUser EndPtName = "CreateUser"
+)
+
+func init() {
+	registrar[EndPtCreateUser] = NewCreateUser
+}
+
+// CreateUser controls the creation of new users (admin only).
+type CreateUser struct {
+	Username string
+	Password string
+}
+
+// NewCreateUser constructs and initialiezes the endpoint.
+func NewCreateUser(
+	r *http.Request,
+) (Endpoint, error) {
+	return &CreateUser{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *CreateUser) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	if err := ValidateAdmin(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	// Validate username.
+	username, err := ValidateUsername(ctx, r.PostFormValue("username"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Username = *username
+
+	// Validate password.
+	password, err := ValidatePassword(ctx, r.PostFormValue("password"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Password = *password
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *CreateUser) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	user, err := model.CreateUser(ctx,
+		e.Username,
+		e.Password,
+	)
+	if err != nil {
+		switch err := errors.Cause(err).(type) {
+		case model.ErrUniqueConstraintViolation:
+			return nil, nil, errors.Trace(errors.NewUserErrorf(err,
+				400, "username_taken",
+				"A user already exists with username: %s.",
+				e.Username,
+			))
+		default:
+			return nil, nil, errors.Trace(err) // 500
+		}
+	}
+
+	db.Commit(ctx)
+
+	return ptr.Int(http.StatusCreated), &svc.Resp{
+		"user": format.JSONPtr(model.NewUserResource(ctx, user)),
+	}, nil
+}
@@ -0,0 +1,85 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint/model"
+	"goji.io/pat"
+)
+
+const (
+	// EndPtDeletePeer deletes the policy set for a peer mint.
+	EndPtDeletePeer EndPtName = "DeletePeer"
+)
+
+func init() {
+	registrar[EndPtDeletePeer] = NewDeletePeer
+}
+
+// DeletePeer deletes the policy set for a peer mint with the admin API,
+// reverting it to the peer policy file of the mint (admin only).
+type DeletePeer struct {
+	Host string
+}
+
+// NewDeletePeer constructs and initialiezes the endpoint.
+func NewDeletePeer(
+	r *http.Request,
+) (Endpoint, error) {
+	return &DeletePeer{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *DeletePeer) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	if err := ValidateAdmin(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	// Validate peer.
+	host, err := ValidatePeer(ctx, pat.Param(r, "peer"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Host = *host
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *DeletePeer) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	peer, err := model.LoadPeerByHost(ctx, e.Host)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	} else if peer == nil {
+		return nil, nil, errors.Trace(errors.NewUserErrorf(nil,
+			404, "peer_not_found",
+			"The peer you are trying to delete does not exist: %s.",
+			e.Host,
+		))
+	}
+
+	if err := peer.Delete(ctx); err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	db.Commit(ctx)
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"peer": format.JSONPtr(model.NewPeerResource(ctx, peer)),
+	}, nil
+}
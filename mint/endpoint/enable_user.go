@@ -0,0 +1,87 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint/model"
+	"goji.io/pat"
+)
+
+const (
+	// EndPtEnableUser re-enables a disabled user.
+	EndPtEnableUser EndPtName = "EnableUser"
+)
+
+func init() {
+	registrar[EndPtEnableUser] = NewEnableUser
+}
+
+// EnableUser re-enables a disabled user (admin only).
+type EnableUser struct {
+	Username string
+}
+
+// NewEnableUser constructs and initialiezes the endpoint.
+func NewEnableUser(
+	r *http.Request,
+) (Endpoint, error) {
+	return &EnableUser{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *EnableUser) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	if err := ValidateAdmin(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	// Validate username.
+	username, err := ValidateUsername(ctx, pat.Param(r, "username"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Username = *username
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *EnableUser) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	user, err := model.LoadUserByUsername(ctx, e.Username)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	} else if user == nil {
+		return nil, nil, errors.Trace(errors.NewUserErrorf(nil,
+			404, "user_not_found",
+			"The user you are trying to enable does not exist: %s.",
+			e.Username,
+		))
+	}
+
+	if user.Disabled != nil {
+		user.Disabled = nil
+		if err := user.Save(ctx); err != nil {
+			return nil, nil, errors.Trace(err) // 500
+		}
+	}
+
+	db.Commit(ctx)
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"user": format.JSONPtr(model.NewUserResource(ctx, user)),
+	}, nil
+}
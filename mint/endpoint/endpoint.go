@@ -2,11 +2,18 @@ package endpoint
 
 import (
 	"context"
+	"fmt"
+	"math/big"
 	"net/http"
+	"time"
 
 	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/lib/metrics"
 	"github.com/spolu/settle/lib/respond"
 	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
 )
 
 const (
@@ -16,6 +23,96 @@ const (
 // EndPtName reprensents an endpoint name.
 type EndPtName string
 
+var requestsTotal = metrics.NewCounter(
+	"settle_mint_requests_total",
+	"Number of requests by endpoint and response status.",
+	"endpoint", "status")
+
+var requestDuration = metrics.NewHistogram(
+	"settle_mint_request_duration_seconds",
+	"Latency of requests by endpoint.",
+	metrics.DefaultBuckets, "endpoint")
+
+var transactionsTotal = metrics.NewCounter(
+	"settle_mint_transactions_total",
+	"Number of transactions reaching a final status by status and "+
+		"propagation type.",
+	"status", "propagation")
+
+// observeTransaction records a transaction reaching a final status.
+func observeTransaction(
+	status mint.TxStatus,
+	propagation mint.PgType,
+) {
+	transactionsTotal.Inc(string(status), string(propagation))
+}
+
+// operationFields returns the logging fields describing an operation.
+func operationFields(
+	op *model.Operation,
+) logging.Fields {
+	fields := logging.Fields{
+		"operation":   op.ID(),
+		"created":     op.Created,
+		"propagation": op.Propagation,
+		"asset":       op.Asset,
+		"source":      op.Source,
+		"destination": op.Destination,
+		"amount":      (*big.Int)(&op.Amount).String(),
+		"status":      op.Status,
+	}
+	if op.Transaction != nil {
+		fields["transaction"] = *op.Transaction
+	}
+	return fields
+}
+
+// crossingFields returns the logging fields describing a crossing.
+func crossingFields(
+	cr *model.Crossing,
+) logging.Fields {
+	return logging.Fields{
+		"crossing":    cr.ID(),
+		"created":     cr.Created,
+		"offer":       cr.Offer,
+		"amount":      (*big.Int)(&cr.Amount).String(),
+		"status":      cr.Status,
+		"transaction": cr.Transaction,
+	}
+}
+
+// offerFields returns the logging fields describing an offer.
+func offerFields(
+	of *model.Offer,
+) logging.Fields {
+	return logging.Fields{
+		"offer":       of.ID(),
+		"created":     of.Created,
+		"propagation": of.Propagation,
+		"base_asset":  of.BaseAsset,
+		"quote_asset": of.QuoteAsset,
+		"base_price":  (*big.Int)(&of.BasePrice).String(),
+		"quote_price": (*big.Int)(&of.QuotePrice).String(),
+		"amount":      (*big.Int)(&of.Amount).String(),
+		"status":      of.Status,
+		"remainder":   (*big.Int)(&of.Remainder).String(),
+	}
+}
+
+// balanceFields returns the logging fields describing a balance.
+func balanceFields(
+	bal *model.Balance,
+) logging.Fields {
+	return logging.Fields{
+		"balance":     bal.ID(),
+		"created":     bal.Created,
+		"propagation": bal.Propagation,
+		"asset":       bal.Asset,
+		"holder":      bal.Holder,
+		"value":       (*big.Int)(&bal.Value).String(),
+	}
+}
+
 // registrar is used to register endpoints within the module.
 var registrar = map[EndPtName](func(*http.Request) (Endpoint, error)){}
 
@@ -41,25 +138,47 @@ func HandlerFor(
 		w http.ResponseWriter,
 		r *http.Request,
 	) {
-		ctx := r.Context()
+		ctx := logging.WithFields(r.Context(), logging.Fields{
+			"endpoint": string(name),
+		})
+		r = r.WithContext(ctx)
+		start := time.Now()
+
+		// Helper closure to record the request metrics.
+		observe := func(status int) {
+			requestsTotal.Inc(string(name), fmt.Sprintf("%d", status))
+			requestDuration.Observe(
+				time.Now().Sub(start).Seconds(), string(name))
+		}
+		// Helper closure to respond with an error and record the request
+		// metrics.
+		fail := func(err error) {
+			status := http.StatusInternalServerError
+			if e := errors.ExtractUserError(err); e != nil {
+				status = e.Status()
+			}
+			respond.Error(ctx, w, err)
+			observe(status)
+		}
 
 		endpt, err := registrar[name](r)
 		if err != nil {
-			respond.Error(ctx, w, errors.Trace(err))
+			fail(errors.Trace(err))
 			return
 		}
 
 		err = endpt.Validate(r)
 		if err != nil {
-			respond.Error(ctx, w, errors.Trace(err))
+			fail(errors.Trace(err))
 			return
 		}
 
-		status, resp, err := endpt.Execute(r.Context())
+		status, resp, err := endpt.Execute(ctx)
 		if err != nil {
-			respond.Error(ctx, w, errors.Trace(err))
+			fail(errors.Trace(err))
 			return
 		}
 		respond.Respond(ctx, w, *status, nil, *resp)
+		observe(*status)
 	}
 }
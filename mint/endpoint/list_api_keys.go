@@ -0,0 +1,79 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/lib/authentication"
+	"github.com/spolu/settle/mint/model"
+)
+
+const (
+	// EndPtListAPIKeys lists the API keys of the authenticated user.
+	EndPtListAPIKeys EndPtName = "ListAPIKeys"
+)
+
+func init() {
+	registrar[EndPtListAPIKeys] = NewListAPIKeys
+}
+
+// ListAPIKeys returns a list of API keys.
+type ListAPIKeys struct {
+	ListEndpoint
+	Owner string
+}
+
+// NewListAPIKeys constructs and initialiezes the endpoint.
+func NewListAPIKeys(
+	r *http.Request,
+) (Endpoint, error) {
+	return &ListAPIKeys{
+		ListEndpoint: ListEndpoint{},
+	}, nil
+}
+
+// Validate validates the input parameters.
+func (e *ListAPIKeys) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	e.Owner = authentication.Get(ctx).User.Token
+
+	return e.ListEndpoint.Validate(r)
+}
+
+// Execute executes the endpoint.
+func (e *ListAPIKeys) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	keys, err := model.LoadAPIKeyListByOwner(ctx,
+		e.ListEndpoint.CreatedBefore,
+		e.ListEndpoint.Limit,
+		e.Owner,
+	)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	db.Commit(ctx)
+
+	l := []mint.APIKeyResource{}
+	for _, k := range keys {
+		k := k
+		l = append(l, model.NewAPIKeyResource(ctx, &k))
+	}
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"api_keys": format.JSONPtr(l),
+	}, nil
+}
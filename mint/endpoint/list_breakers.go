@@ -0,0 +1,68 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+)
+
+const (
+	// EndPtListBreakers lists the circuit breakers of the mint client.
+	EndPtListBreakers EndPtName = "ListBreakers"
+)
+
+func init() {
+	registrar[EndPtListBreakers] = NewListBreakers
+}
+
+// ListBreakers returns the state of the circuit breakers of the client of the
+// mint process for the peers that recently failed (admin only).
+type ListBreakers struct{}
+
+// NewListBreakers constructs and initialiezes the endpoint.
+func NewListBreakers(
+	r *http.Request,
+) (Endpoint, error) {
+	return &ListBreakers{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *ListBreakers) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	if err := ValidateAdmin(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *ListBreakers) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	l := []mint.BreakerResource{}
+	for _, s := range mint.BreakerStatuses() {
+		b := mint.BreakerResource{
+			Host:     s.Key,
+			State:    string(s.State),
+			Failures: s.Failures,
+		}
+		if !s.Opened.IsZero() {
+			opened := s.Opened.UnixNano() / mint.TimeResolutionNs
+			b.Opened = &opened
+		}
+		l = append(l, b)
+	}
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"breakers": format.JSONPtr(l),
+	}, nil
+}
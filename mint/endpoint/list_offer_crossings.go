@@ -0,0 +1,117 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"goji.io/pat"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/lib/authentication"
+	"github.com/spolu/settle/mint/model"
+)
+
+const (
+	// EndPtListOfferCrossings lists the crossings of an offer.
+	EndPtListOfferCrossings EndPtName = "ListOfferCrossings"
+)
+
+func init() {
+	registrar[EndPtListOfferCrossings] = NewListOfferCrossings
+}
+
+// ListOfferCrossings returns the list of crossings of an offer of the
+// authenticated user, that is the amounts of the offer reserved, settled or
+// canceled by transactions.
+type ListOfferCrossings struct {
+	ListEndpoint
+	ID    string
+	Owner string
+	Token string
+}
+
+// NewListOfferCrossings constructs and initialiezes the endpoint.
+func NewListOfferCrossings(
+	r *http.Request,
+) (Endpoint, error) {
+	return &ListOfferCrossings{
+		ListEndpoint: ListEndpoint{},
+	}, nil
+}
+
+// Validate validates the input parameters.
+func (e *ListOfferCrossings) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	e.Owner = fmt.Sprintf("%s@%s",
+		authentication.Get(ctx).User.Username, mint.GetHost(ctx))
+
+	// Validate id.
+	_, owner, token, err := ValidateID(ctx, pat.Param(r, "offer"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.ID = fmt.Sprintf("%s[%s]", *owner, *token)
+	e.Token = *token
+
+	// Validate that the authenticated owner owns the offer.
+	if e.Owner != *owner {
+		return errors.Trace(errors.NewUserErrorf(nil,
+			400, "not_authorized",
+			"You can only list the crossings of an offer that is owned by "+
+				"the account you are currently authenticated with: %s. The "+
+				"requested offer is owned by: %s.",
+			e.Owner, *owner,
+		))
+	}
+
+	return e.ListEndpoint.Validate(r)
+}
+
+// Execute executes the endpoint.
+func (e *ListOfferCrossings) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	offer, err := model.LoadCanonicalOfferByOwnerToken(ctx, e.Owner, e.Token)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	} else if offer == nil {
+		return nil, nil, errors.Trace(errors.NewUserErrorf(nil,
+			404, "offer_not_found",
+			"The offer whose crossings you are trying to list does not "+
+				"exist: %s.",
+			e.ID,
+		))
+	}
+
+	crossings, err := model.LoadCrossingListByOffer(ctx,
+		e.ListEndpoint.CreatedBefore,
+		e.ListEndpoint.Limit,
+		e.ID,
+	)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	db.Commit(ctx)
+
+	l := []mint.CrossingResource{}
+	for _, c := range crossings {
+		l = append(l, model.NewCrossingResource(ctx, c))
+	}
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"crossings": format.JSONPtr(l),
+	}, nil
+}
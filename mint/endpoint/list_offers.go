@@ -0,0 +1,82 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/lib/authentication"
+	"github.com/spolu/settle/mint/model"
+)
+
+const (
+	// EndPtListOffers lists the offers of the authenticated user.
+	EndPtListOffers EndPtName = "ListOffers"
+)
+
+func init() {
+	registrar[EndPtListOffers] = NewListOffers
+}
+
+// ListOffers returns the list of canonical offers of the authenticated user
+// (whether active, closed or consumed).
+type ListOffers struct {
+	ListEndpoint
+	Owner string
+}
+
+// NewListOffers constructs and initialiezes the endpoint.
+func NewListOffers(
+	r *http.Request,
+) (Endpoint, error) {
+	return &ListOffers{
+		ListEndpoint: ListEndpoint{},
+	}, nil
+}
+
+// Validate validates the input parameters.
+func (e *ListOffers) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	e.Owner = fmt.Sprintf("%s@%s",
+		authentication.Get(ctx).User.Username, mint.GetHost(ctx))
+
+	return e.ListEndpoint.Validate(r)
+}
+
+// Execute executes the endpoint.
+func (e *ListOffers) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	offers, err := model.LoadCanonicalOfferListByOwner(ctx,
+		e.ListEndpoint.CreatedBefore,
+		e.ListEndpoint.Limit,
+		e.Owner,
+	)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	db.Commit(ctx)
+
+	l := []mint.OfferResource{}
+	for _, o := range offers {
+		o := o
+		l = append(l, model.NewOfferResource(ctx, &o))
+	}
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"offers": format.JSONPtr(l),
+	}, nil
+}
@@ -0,0 +1,66 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint/lib/peering"
+)
+
+const (
+	// EndPtListPeers lists the peer policy of the mint.
+	EndPtListPeers EndPtName = "ListPeers"
+)
+
+func init() {
+	registrar[EndPtListPeers] = NewListPeers
+}
+
+// ListPeers returns the default peer policy of the mint along with the peers
+// set in its policy file or with the admin API (admin only).
+type ListPeers struct{}
+
+// NewListPeers constructs and initialiezes the endpoint.
+func NewListPeers(
+	r *http.Request,
+) (Endpoint, error) {
+	return &ListPeers{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *ListPeers) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	if err := ValidateAdmin(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *ListPeers) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	policy, peers, err := peering.List(ctx)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	db.Commit(ctx)
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"default": format.JSONPtr(policy),
+		"peers":   format.JSONPtr(peers),
+	}, nil
+}
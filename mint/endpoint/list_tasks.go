@@ -0,0 +1,95 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+const (
+	// EndPtListTasks lists async tasks.
+	EndPtListTasks EndPtName = "ListTasks"
+)
+
+func init() {
+	registrar[EndPtListTasks] = NewListTasks
+}
+
+// ListTasks returns a list of async tasks filtered by status, name and
+// subject (admin only).
+type ListTasks struct {
+	ListEndpoint
+	Status  mint.TkStatus
+	Name    mint.TkName
+	Subject string
+}
+
+// NewListTasks constructs and initialiezes the endpoint.
+func NewListTasks(
+	r *http.Request,
+) (Endpoint, error) {
+	return &ListTasks{
+		ListEndpoint: ListEndpoint{},
+	}, nil
+}
+
+// Validate validates the input parameters.
+func (e *ListTasks) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	if err := ValidateAdmin(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	// Validate status.
+	status, err := ValidateTaskStatus(ctx, r.URL.Query().Get("status"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Status = *status
+
+	e.Name = mint.TkName(r.URL.Query().Get("name"))
+	e.Subject = r.URL.Query().Get("subject")
+
+	return e.ListEndpoint.Validate(r)
+}
+
+// Execute executes the endpoint.
+func (e *ListTasks) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	tasks, err := model.LoadTaskList(ctx,
+		e.ListEndpoint.CreatedBefore,
+		e.ListEndpoint.Limit,
+		e.Status,
+		e.Name,
+		e.Subject,
+	)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	db.Commit(ctx)
+
+	l := []mint.TaskResource{}
+	for _, t := range tasks {
+		t := t
+		l = append(l, model.NewTaskResource(ctx, &t))
+	}
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"tasks": format.JSONPtr(l),
+	}, nil
+}
@@ -0,0 +1,78 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+const (
+	// EndPtListUsers lists the users of the mint.
+	EndPtListUsers EndPtName = "ListUsers"
+)
+
+func init() {
+	registrar[EndPtListUsers] = NewListUsers
+}
+
+// ListUsers returns a list of users (admin only).
+type ListUsers struct {
+	ListEndpoint
+}
+
+// NewListUsers constructs and initialiezes the endpoint.
+func NewListUsers(
+	r *http.Request,
+) (Endpoint, error) {
+	return &ListUsers{
+		ListEndpoint: ListEndpoint{},
+	}, nil
+}
+
+// Validate validates the input parameters.
+func (e *ListUsers) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	if err := ValidateAdmin(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	return e.ListEndpoint.Validate(r)
+}
+
+// Execute executes the endpoint.
+func (e *ListUsers) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	users, err := model.LoadUserList(ctx,
+		e.ListEndpoint.CreatedBefore,
+		e.ListEndpoint.Limit,
+	)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	db.Commit(ctx)
+
+	l := []mint.UserResource{}
+	for _, u := range users {
+		u := u
+		l = append(l, model.NewUserResource(ctx, &u))
+	}
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"users": format.JSONPtr(l),
+	}, nil
+}
@@ -2,7 +2,6 @@ package endpoint
 
 import (
 	"context"
-	"math/big"
 	"net/http"
 	"time"
 
@@ -11,9 +10,11 @@ import (
 	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/lib/ptr"
 	"github.com/spolu/settle/lib/svc"
 	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/lib/peering"
 	"github.com/spolu/settle/mint/model"
 )
 
@@ -67,6 +68,12 @@ func (e *PropagateBalance) Validate(
 	e.Owner = *owner
 	e.Token = *token
 
+	// Reject propagations from mints blocked by the peer policy before
+	// retrieving the balance.
+	if err := peering.CheckAddress(ctx, e.Owner); err != nil {
+		return errors.Trace(err)
+	}
+
 	return nil
 }
 
@@ -181,11 +188,7 @@ func (e *PropagateBalance) Execute(
 			return nil, nil, errors.Trace(err) // 500
 		}
 
-		mint.Logf(ctx,
-			"Propagated balance: id=%s[%s] created=%q propagation=%s "+
-				"asset=%s holder=%s value=%s",
-			bal.Owner, bal.Token, bal.Created, bal.Propagation, bal.Asset,
-			bal.Holder, (*big.Int)(&bal.Value).String())
+		mint.Log(ctx, logging.LvInfo, "Propagated balance", balanceFields(bal))
 	}
 
 	db.Commit(ctx)
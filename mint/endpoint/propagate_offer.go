@@ -2,7 +2,6 @@ package endpoint
 
 import (
 	"context"
-	"math/big"
 	"net/http"
 	"time"
 
@@ -11,9 +10,11 @@ import (
 	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/lib/ptr"
 	"github.com/spolu/settle/lib/svc"
 	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/lib/peering"
 	"github.com/spolu/settle/mint/model"
 )
 
@@ -67,6 +68,12 @@ func (e *PropagateOffer) Validate(
 	e.Owner = *owner
 	e.Token = *token
 
+	// Reject propagations from mints blocked by the peer policy before
+	// retrieving the offer.
+	if err := peering.CheckAddress(ctx, e.Owner); err != nil {
+		return errors.Trace(err)
+	}
+
 	return nil
 }
 
@@ -74,6 +81,10 @@ func (e *PropagateOffer) Validate(
 func (e *PropagateOffer) Execute(
 	ctx context.Context,
 ) (*int, *svc.Resp, error) {
+	// The offer changed on its canonical mint, so drop any cached version
+	// before retrieving it.
+	mint.InvalidateOffer(e.ID)
+
 	offer, err := e.Client.RetrieveOffer(ctx, e.ID)
 	if err != nil {
 		return nil, nil, errors.Trace(errors.NewUserErrorf(err,
@@ -216,14 +227,7 @@ func (e *PropagateOffer) Execute(
 			return nil, nil, errors.Trace(err) // 500
 		}
 
-		mint.Logf(ctx,
-			"Propagated offer: id=%s[%s] created=%q propagation=%s "+
-				"base_asset=%s quote_asset=%s base_price=%s quote_price=%s "+
-				"amount=%s status=%s remainder=%s",
-			of.Owner, of.Token, of.Created, of.Propagation, of.BaseAsset,
-			of.QuoteAsset, of.BasePrice, of.QuotePrice,
-			(*big.Int)(&of.Amount).String(), of.Status,
-			(*big.Int)(&of.Remainder).String())
+		mint.Log(ctx, logging.LvInfo, "Propagated offer", offerFields(of))
 	}
 
 	db.Commit(ctx)
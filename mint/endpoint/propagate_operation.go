@@ -2,7 +2,6 @@ package endpoint
 
 import (
 	"context"
-	"math/big"
 	"net/http"
 	"time"
 
@@ -11,9 +10,11 @@ import (
 	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/lib/ptr"
 	"github.com/spolu/settle/lib/svc"
 	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/lib/peering"
 	"github.com/spolu/settle/mint/model"
 )
 
@@ -68,6 +69,12 @@ func (e *PropagateOperation) Validate(
 	e.Owner = *owner
 	e.Token = *token
 
+	// Reject propagations from mints blocked by the peer policy before
+	// retrieving the operation.
+	if err := peering.CheckAddress(ctx, e.Owner); err != nil {
+		return errors.Trace(err)
+	}
+
 	return nil
 }
 
@@ -210,13 +217,7 @@ func (e *PropagateOperation) ExecutePropagated(
 			return nil, nil, errors.Trace(err) // 500
 		}
 
-		mint.Logf(ctx,
-			"Propagated operation: id=%s[%s] created=%q propagation=%s "+
-				"asset=%s source=%s destination=%s amount=%s "+
-				"status=%s transaction=%s",
-			op.Owner, op.Token, op.Created, op.Propagation, op.Asset,
-			op.Source, op.Destination, (*big.Int)(&op.Amount).String(),
-			op.Status, *op.Transaction)
+		mint.Log(ctx, logging.LvInfo, "Propagated operation", operationFields(op))
 	}
 
 	db.Commit(ctx)
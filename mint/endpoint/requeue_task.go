@@ -0,0 +1,70 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint/async"
+	"github.com/spolu/settle/mint/model"
+	"goji.io/pat"
+)
+
+const (
+	// EndPtRequeueTask requeues a failed or canceled task.
+	EndPtRequeueTask EndPtName = "RequeueTask"
+)
+
+func init() {
+	registrar[EndPtRequeueTask] = NewRequeueTask
+}
+
+// RequeueTask requeues a failed or canceled task for immediate
+// execution (admin only).
+type RequeueTask struct {
+	Token string
+}
+
+// NewRequeueTask constructs and initialiezes the endpoint.
+func NewRequeueTask(
+	r *http.Request,
+) (Endpoint, error) {
+	return &RequeueTask{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *RequeueTask) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	if err := ValidateAdmin(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	// Validate task.
+	token, err := ValidateTask(ctx, pat.Param(r, "task"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Token = *token
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *RequeueTask) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	task, err := async.Get(ctx).RequeueTask(ctx, e.Token)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"task": format.JSONPtr(model.NewTaskResource(ctx, task)),
+	}, nil
+}
@@ -0,0 +1,96 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint/model"
+	"goji.io/pat"
+)
+
+const (
+	// EndPtResetUserPassword resets the password of a user.
+	EndPtResetUserPassword EndPtName = "ResetUserPassword"
+)
+
+func init() {
+	registrar[EndPtResetUserPassword] = NewResetUserPassword
+}
+
+// ResetUserPassword sets a new password for a user (admin only).
+type ResetUserPassword struct {
+	Username string
+	Password string
+}
+
+// NewResetUserPassword constructs and initialiezes the endpoint.
+func NewResetUserPassword(
+	r *http.Request,
+) (Endpoint, error) {
+	return &ResetUserPassword{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *ResetUserPassword) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	if err := ValidateAdmin(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	// Validate username.
+	username, err := ValidateUsername(ctx, pat.Param(r, "username"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Username = *username
+
+	// Validate password.
+	password, err := ValidatePassword(ctx, r.PostFormValue("password"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Password = *password
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *ResetUserPassword) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	user, err := model.LoadUserByUsername(ctx, e.Username)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	} else if user == nil {
+		return nil, nil, errors.Trace(errors.NewUserErrorf(nil,
+			404, "user_not_found",
+			"The user whose password you are trying to reset does not "+
+				"exist: %s.",
+			e.Username,
+		))
+	}
+
+	if err := user.UpdatePassword(ctx, e.Password); err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+	if err := user.Save(ctx); err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	db.Commit(ctx)
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"user": format.JSONPtr(model.NewUserResource(ctx, user)),
+	}, nil
+}
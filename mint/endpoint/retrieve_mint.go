@@ -0,0 +1,83 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+)
+
+const (
+	// EndPtRetrieveMint retrieves the discovery document of the mint.
+	EndPtRetrieveMint EndPtName = "RetrieveMint"
+)
+
+func init() {
+	registrar[EndPtRetrieveMint] = NewRetrieveMint
+}
+
+// RetrieveMint retrieves the discovery document of the mint. It is not
+// authenticated and is used by clients and peers to find out how to contact
+// the mint and what it supports.
+type RetrieveMint struct{}
+
+// NewRetrieveMint constructs and initialiezes the endpoint.
+func NewRetrieveMint(
+	r *http.Request,
+) (Endpoint, error) {
+	return &RetrieveMint{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *RetrieveMint) Validate(
+	r *http.Request,
+) error {
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *RetrieveMint) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	u, err := mint.GetPublicURL(ctx)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	var port int64 = 80
+	if u.Scheme == "https" {
+		port = 443
+	}
+	if u.Port() != "" {
+		port, err = strconv.ParseInt(u.Port(), 10, 64)
+		if err != nil {
+			return nil, nil, errors.Trace(err) // 500
+		}
+	}
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"mint": format.JSONPtr(mint.MintResource{
+			ID:         mint.GetHost(ctx),
+			Versions:   []string{mint.ProtocolVersion},
+			Scheme:     u.Scheme,
+			Host:       u.Hostname(),
+			Port:       port,
+			PublicKeys: mint.GetPublicKeys(ctx),
+			Limits: mint.MintLimitsResource{
+				TransactionExpiry: mint.TransactionExpiryMs,
+				MaxPathLength:     mint.MaxPathLength,
+			},
+			FeePolicy: mint.FePlNone,
+			Features: []mint.MtFeature{
+				mint.MtFtAPIKeys,
+				mint.MtFtPeerPolicy,
+				mint.MtFtTransactionCancel,
+			},
+		}),
+	}, nil
+}
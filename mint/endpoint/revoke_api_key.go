@@ -0,0 +1,90 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/clock"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint/lib/authentication"
+	"github.com/spolu/settle/mint/model"
+	"goji.io/pat"
+)
+
+const (
+	// EndPtRevokeAPIKey revokes an API key.
+	EndPtRevokeAPIKey EndPtName = "RevokeAPIKey"
+)
+
+func init() {
+	registrar[EndPtRevokeAPIKey] = NewRevokeAPIKey
+}
+
+// RevokeAPIKey revokes an API key of the authenticated user. Revocation is
+// immediate and irreversible.
+type RevokeAPIKey struct {
+	Owner string
+	Token string
+}
+
+// NewRevokeAPIKey constructs and initialiezes the endpoint.
+func NewRevokeAPIKey(
+	r *http.Request,
+) (Endpoint, error) {
+	return &RevokeAPIKey{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *RevokeAPIKey) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	e.Owner = authentication.Get(ctx).User.Token
+
+	// Validate key.
+	token, err := ValidateKey(ctx, pat.Param(r, "key"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Token = *token
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *RevokeAPIKey) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	key, err := model.LoadAPIKeyByToken(ctx, e.Token)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	} else if key == nil || key.Owner != e.Owner {
+		return nil, nil, errors.Trace(errors.NewUserErrorf(nil,
+			404, "key_not_found",
+			"The API key you are trying to revoke does not exist: %s.",
+			e.Token,
+		))
+	}
+
+	if key.Revoked == nil {
+		revoked := clock.Now(ctx).UTC()
+		key.Revoked = &revoked
+		if err := key.Save(ctx); err != nil {
+			return nil, nil, errors.Trace(err) // 500
+		}
+	}
+
+	db.Commit(ctx)
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"api_key": format.JSONPtr(model.NewAPIKeyResource(ctx, key)),
+	}, nil
+}
@@ -0,0 +1,70 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint/async"
+	"github.com/spolu/settle/mint/model"
+	"goji.io/pat"
+)
+
+const (
+	// EndPtRunTask runs a task immediately.
+	EndPtRunTask EndPtName = "RunTask"
+)
+
+func init() {
+	registrar[EndPtRunTask] = NewRunTask
+}
+
+// RunTask runs a pending or failed task immediately and
+// synchronously (admin only).
+type RunTask struct {
+	Token string
+}
+
+// NewRunTask constructs and initialiezes the endpoint.
+func NewRunTask(
+	r *http.Request,
+) (Endpoint, error) {
+	return &RunTask{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *RunTask) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	if err := ValidateAdmin(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	// Validate task.
+	token, err := ValidateTask(ctx, pat.Param(r, "task"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Token = *token
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *RunTask) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	task, err := async.Get(ctx).RunTask(ctx, e.Token)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"task": format.JSONPtr(model.NewTaskResource(ctx, task)),
+	}, nil
+}
@@ -6,13 +6,14 @@ import (
 	"fmt"
 	"math/big"
 	"net/http"
-	"time"
 
 	"golang.org/x/crypto/scrypt"
 
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/lib/ptr"
 	"github.com/spolu/settle/lib/svc"
 	"github.com/spolu/settle/mint"
@@ -104,6 +105,8 @@ func (e *SettleTransaction) Validate(
 func (e *SettleTransaction) Execute(
 	ctx context.Context,
 ) (*int, *svc.Resp, error) {
+	ctx = logging.WithFields(ctx, logging.Fields{"transaction": e.ID})
+
 	switch authentication.Get(ctx).Status {
 	case authentication.AutStSkipped:
 		return e.ExecutePropagated(ctx)
@@ -185,6 +188,7 @@ func (e *SettleTransaction) ExecuteCanonical(
 	}
 
 	db.Commit(ctx)
+	observeTransaction(e.Tx.Status, e.Tx.Propagation)
 
 	// At the canonical mint the settlement propagation starts from a virtual
 	// Hop which is the length of the plan hops plus one.
@@ -194,12 +198,13 @@ func (e *SettleTransaction) ExecuteCanonical(
 	err = e.Propagate(ctx)
 	if err != nil {
 		// If propagation failed we log it and trigger an asyncrhonous one.
-		mint.Logf(ctx,
-			"Settlement propagation failed: transaction=%s hop=%d error=%s",
-			e.ID, e.Hop, err.Error())
-		err = async.Queue(ctx,
-			task.NewPropagateSettlement(ctx,
-				time.Now(), fmt.Sprintf("%s|%d", e.ID, e.Hop)))
+		mint.Log(ctx, logging.LvWarn, "Settlement propagation failed",
+			logging.Fields{
+				"hop":   e.Hop,
+				"error": err.Error(),
+			})
+		err = async.Queue(oCtx,
+			task.NewPropagateSettlement(ctx, clock.Now(ctx), e.ID, e.Hop))
 		if err != nil {
 			return nil, nil, errors.Trace(err) // 500
 		}
@@ -292,9 +297,11 @@ func (e *SettleTransaction) ExecutePropagated(
 		_, err := e.Client.CancelTransaction(ctx,
 			e.ID, e.Hop, mint.GetHost(ctx))
 		if err != nil {
-			mint.Logf(ctx,
-				"Opportunistic cancellation failed: transaction=%s hop=%d error=%s",
-				e.ID, e.Hop, err.Error())
+			mint.Log(ctx, logging.LvWarn, "Opportunistic cancellation failed",
+				logging.Fields{
+					"hop":   e.Hop,
+					"error": err.Error(),
+				})
 		}
 
 		// Reopen a DB transaction and reload the transaction, hopefully
@@ -347,48 +354,77 @@ func (e *SettleTransaction) ExecutePropagated(
 	}
 	e.Plan = pl
 
-	// Settle will idempotently (at specified hop) settle the
-	// transaction (generally called on highest hop first). Subsequent calls (on
-	// same hop will be no-ops).
-	err = e.Settle(ctx)
-	if err != nil {
-		return nil, nil, errors.Trace(errors.NewUserErrorf(err,
-			402, "settlement_failed",
-			"The settlement execution failed for the transaction: %s", e.ID,
-		))
-	}
+	db.Commit(ctx)
 
-	// Mark the transaction as settled (if there's a loop we'll call settle on
-	// the other hop even if marked as settled) and store the secret.
-	e.Tx.Status = mint.TxStSettled
-	e.Tx.Secret = &e.Secret
-	err = e.Tx.Save(ctx)
-	if err != nil {
-		return nil, nil, errors.Trace(err) // 500
-	}
+	// Settle the transaction as well as operations and crossings in a DB
+	// transaction that only locks the rows it updates, re-run from scratch on
+	// serialization failures.
+	var ops []*model.Operation
+	var crs []*model.Crossing
+	err = db.Retry(oCtx, "mint", func(ctx context.Context) error {
+		tx, err = model.LockTransactionByID(ctx, e.ID)
+		if err != nil || tx == nil {
+			return errors.Trace(err) // 500
+		}
+		e.Tx = tx
 
-	ops, err := model.LoadCanonicalOperationsByTransaction(ctx, e.ID)
-	if err != nil {
-		return nil, nil, errors.Trace(err) // 500
-	}
+		// The transaction may have been canceled since it was last loaded.
+		if e.Tx.Status == mint.TxStCanceled {
+			return errors.Trace(errors.NewUserErrorf(nil,
+				402, "settlement_failed",
+				"The transaction you are trying to settle is canceled: %s.",
+				e.ID,
+			))
+		}
 
-	crs, err := model.LoadCanonicalCrossingsByTransaction(ctx, e.ID)
+		// Settle will idempotently (at specified hop) settle the
+		// transaction (generally called on highest hop first). Subsequent
+		// calls (on same hop will be no-ops).
+		err = e.Settle(ctx)
+		if err != nil {
+			return errors.Trace(errors.NewUserErrorf(err,
+				402, "settlement_failed",
+				"The settlement execution failed for the transaction: %s",
+				e.ID,
+			))
+		}
+
+		// Mark the transaction as settled (if there's a loop we'll call
+		// settle on the other hop even if marked as settled) and store the
+		// secret.
+		e.Tx.Status = mint.TxStSettled
+		e.Tx.Secret = &e.Secret
+		err = e.Tx.Save(ctx)
+		if err != nil {
+			return errors.Trace(err) // 500
+		}
+
+		ops, err = model.LoadCanonicalOperationsByTransaction(ctx, e.ID)
+		if err != nil {
+			return errors.Trace(err) // 500
+		}
+
+		crs, err = model.LoadCanonicalCrossingsByTransaction(ctx, e.ID)
+		if err != nil {
+			return errors.Trace(err) // 500
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, nil, errors.Trace(err) // 500
+		return nil, nil, errors.Trace(err)
 	}
-
-	// Commit the transaction as well as operations and crossings as settled.
-	db.Commit(ctx)
+	observeTransaction(e.Tx.Status, e.Tx.Propagation)
 
 	err = e.Propagate(ctx)
 	if err != nil {
 		// If propagation failed we log it and trigger an asyncrhonous one.
-		mint.Logf(ctx,
-			"Settlement propagation failed: transaction=%s hop=%d error=%s",
-			e.ID, e.Hop, err.Error())
-		err = async.Queue(ctx,
-			task.NewPropagateSettlement(ctx,
-				time.Now(), fmt.Sprintf("%s|%d", e.ID, e.Hop)))
+		mint.Log(ctx, logging.LvWarn, "Settlement propagation failed",
+			logging.Fields{
+				"hop":   e.Hop,
+				"error": err.Error(),
+			})
+		err = async.Queue(oCtx,
+			task.NewPropagateSettlement(ctx, clock.Now(ctx), e.ID, e.Hop))
 		if err != nil {
 			return nil, nil, errors.Trace(err) // 500
 		}
@@ -413,8 +449,9 @@ func (e *SettleTransaction) Settle(
 	}
 
 	h := e.Plan.Hops[e.Hop]
-	mint.Logf(ctx,
-		"Executing settlement plan: transaction=%s hop=%d", e.ID, e.Hop)
+	mint.Log(ctx, logging.LvInfo, "Executing settlement plan", logging.Fields{
+		"hop": e.Hop,
+	})
 
 	// Settle the OpAction (should always be defined)
 	if h.OpAction != nil {
@@ -429,13 +466,7 @@ func (e *SettleTransaction) Settle(
 		}
 
 		if op.Status == mint.TxStSettled {
-			mint.Logf(ctx,
-				"Skipped operation: id=%s[%s] created=%q propagation=%s "+
-					"asset=%s source=%s destination=%s amount=%s "+
-					"status=%s transaction=%s",
-				op.Owner, op.Token, op.Created, op.Propagation, op.Asset,
-				op.Source, op.Destination, (*big.Int)(&op.Amount).String(),
-				op.Status, *op.Transaction)
+			mint.Log(ctx, logging.LvInfo, "Skipped operation", operationFields(op))
 
 		} else {
 			a := h.OpAction
@@ -452,7 +483,7 @@ func (e *SettleTransaction) Settle(
 			if a.OperationDestination != nil &&
 				asset.Owner != *a.OperationDestination {
 				dstBalance, err =
-					model.LoadOrCreateCanonicalBalanceByAssetHolder(ctx,
+					model.LockOrCreateCanonicalBalanceByAssetHolder(ctx,
 						asset.Owner, *a.OperationAsset, *a.OperationDestination)
 				if err != nil {
 					return errors.Trace(err)
@@ -476,7 +507,8 @@ func (e *SettleTransaction) Settle(
 				}
 
 				err = async.Queue(ctx,
-					task.NewPropagateBalance(ctx, time.Now(), dstBalance.ID()))
+					task.NewPropagateBalance(ctx,
+						clock.Now(ctx), dstBalance.ID()))
 				if err != nil {
 					return errors.Trace(err)
 				}
@@ -488,17 +520,11 @@ func (e *SettleTransaction) Settle(
 				return errors.Trace(err)
 			}
 
-			mint.Logf(ctx,
-				"Settled operation: id=%s[%s] created=%q propagation=%s "+
-					"asset=%s source=%s destination=%s amount=%s "+
-					"status=%s transaction=%s",
-				op.Owner, op.Token, op.Created, op.Propagation, op.Asset,
-				op.Source, op.Destination, (*big.Int)(&op.Amount).String(),
-				op.Status, *op.Transaction)
+			mint.Log(ctx, logging.LvInfo, "Settled operation", operationFields(op))
 
 			opID := op.ID()
 			err = async.Queue(ctx,
-				task.NewPropagateOperation(ctx, time.Now(), opID))
+				task.NewPropagateOperation(ctx, clock.Now(ctx), opID))
 			if err != nil {
 				return errors.Trace(err)
 			}
@@ -517,11 +543,7 @@ func (e *SettleTransaction) Settle(
 		}
 
 		if cr.Status == mint.TxStSettled {
-			mint.Logf(ctx,
-				"Skipped crossing: id=%s[%s] created=%q offer=%s amount=%s "+
-					"status=%s transaction=%s",
-				cr.Owner, cr.Token, cr.Created, cr.Offer,
-				(*big.Int)(&cr.Amount).String(), cr.Status, cr.Transaction)
+			mint.Log(ctx, logging.LvInfo, "Skipped crossing", crossingFields(cr))
 		} else {
 			cr.Status = mint.TxStSettled
 			err = cr.Save(ctx)
@@ -529,11 +551,7 @@ func (e *SettleTransaction) Settle(
 				return errors.Trace(err)
 			}
 
-			mint.Logf(ctx,
-				"Settled crossing: id=%s[%s] created=%q offer=%s amount=%s "+
-					"status=%s transaction=%s",
-				cr.Owner, cr.Token, cr.Created, cr.Offer,
-				(*big.Int)(&cr.Amount).String(), cr.Status, cr.Transaction)
+			mint.Log(ctx, logging.LvInfo, "Settled crossing", crossingFields(cr))
 		}
 	}
 
@@ -548,9 +566,10 @@ func (e *SettleTransaction) Propagate(
 	if int(e.Hop)-1 >= 0 {
 		m := e.Plan.Hops[e.Hop-1].Mint
 
-		mint.Logf(ctx,
-			"Propagating settlement: transaction=%s hop=%d mint=%s",
-			e.ID, e.Hop, m)
+		mint.Log(ctx, logging.LvInfo, "Propagating settlement", logging.Fields{
+			"hop":  e.Hop,
+			"mint": m,
+		})
 
 		hop := e.Hop - 1
 		_, err := e.Client.SettleTransaction(ctx, e.ID, &hop, &e.Secret, &m)
@@ -0,0 +1,113 @@
+package endpoint
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/ptr"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+	"goji.io/pat"
+)
+
+const (
+	// EndPtUpdatePeer sets the policy applied to a peer mint.
+	EndPtUpdatePeer EndPtName = "UpdatePeer"
+)
+
+func init() {
+	registrar[EndPtUpdatePeer] = NewUpdatePeer
+}
+
+// UpdatePeer sets the policy and optional exposure cap applied to a peer mint,
+// overriding the peer policy file of the mint (admin only).
+type UpdatePeer struct {
+	Host        string
+	Policy      mint.PrPolicy
+	ExposureCap *big.Int
+}
+
+// NewUpdatePeer constructs and initialiezes the endpoint.
+func NewUpdatePeer(
+	r *http.Request,
+) (Endpoint, error) {
+	return &UpdatePeer{}, nil
+}
+
+// Validate validates the input parameters.
+func (e *UpdatePeer) Validate(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	if err := ValidateAdmin(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	// Validate peer.
+	host, err := ValidatePeer(ctx, pat.Param(r, "peer"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Host = *host
+
+	// Validate policy.
+	policy, err := ValidatePeerPolicy(ctx, r.PostFormValue("policy"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.Policy = *policy
+
+	// Validate exposure cap.
+	if exposureCap := r.PostFormValue("exposure_cap"); exposureCap != "" {
+		amount, err := ValidateAmount(ctx, exposureCap)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		e.ExposureCap = amount
+	}
+
+	return nil
+}
+
+// Execute executes the endpoint.
+func (e *UpdatePeer) Execute(
+	ctx context.Context,
+) (*int, *svc.Resp, error) {
+	ctx = db.Begin(ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	var exposureCap *model.Amount
+	if e.ExposureCap != nil {
+		exposureCap = (*model.Amount)(e.ExposureCap)
+	}
+
+	peer, err := model.LoadPeerByHost(ctx, e.Host)
+	if err != nil {
+		return nil, nil, errors.Trace(err) // 500
+	}
+
+	if peer == nil {
+		peer, err = model.CreatePeer(ctx, e.Host, e.Policy, exposureCap)
+		if err != nil {
+			return nil, nil, errors.Trace(err) // 500
+		}
+	} else {
+		peer.Policy = e.Policy
+		peer.ExposureCap = exposureCap
+		if err := peer.Save(ctx); err != nil {
+			return nil, nil, errors.Trace(err) // 500
+		}
+	}
+
+	db.Commit(ctx)
+
+	return ptr.Int(http.StatusOK), &svc.Resp{
+		"peer": format.JSONPtr(model.NewPeerResource(ctx, peer)),
+	}, nil
+}
@@ -7,8 +7,11 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/lib/authentication"
+	"github.com/spolu/settle/mint/lib/peering"
 	"github.com/spolu/settle/mint/model"
 )
 
@@ -16,6 +19,18 @@ import (
 var PriceRegexp = regexp.MustCompile(
 	"^([0-9]+)\\/([0-9]+)$")
 
+// TaskRegexp is used to validate task ids.
+var TaskRegexp = regexp.MustCompile(
+	"^task_[a-zA-Z0-9_]+$")
+
+// KeyRegexp is used to validate API key ids.
+var KeyRegexp = regexp.MustCompile(
+	"^key_[a-zA-Z0-9_]+$")
+
+// UsernameRegexp is used to validate usernames.
+var UsernameRegexp = regexp.MustCompile(
+	"^[a-zA-Z0-9-_.]{1,256}$")
+
 // ValidateAsset vlaidates an asset name.
 func ValidateAsset(
 	ctx context.Context,
@@ -120,6 +135,15 @@ func ValidatePath(
 	ctx context.Context,
 	path []string,
 ) ([]string, error) {
+	if len(path) > mint.MaxPathLength {
+		return nil, errors.Trace(errors.NewUserErrorf(nil,
+			400, "path_invalid",
+			"The path you provided is too long: %d offers. Paths can have "+
+				"at most %d offers.",
+			len(path), mint.MaxPathLength,
+		))
+	}
+
 	for _, offer := range path {
 		_, _, err := mint.NormalizedOwnerAndTokenFromID(ctx, offer)
 		if err != nil {
@@ -194,7 +218,7 @@ func ValidateCreatedBefore(
 	createdBefore string,
 ) (*time.Time, error) {
 	if createdBefore == "" {
-		t := time.Now()
+		t := clock.Now(ctx)
 		return &t, nil
 	}
 
@@ -259,3 +283,204 @@ func ValidatePropagation(
 
 	return &p, nil
 }
+
+// ValidateAdmin validates that the request was authenticated with the admin
+// secret.
+func ValidateAdmin(
+	ctx context.Context,
+) error {
+	if authentication.Get(ctx).Status != authentication.AutStAdmin {
+		return errors.Trace(errors.NewUserErrorf(nil,
+			403, "not_authorized",
+			"This endpoint is only accessible with the admin secret.",
+		))
+	}
+
+	return nil
+}
+
+// ValidateTaskStatus validates a task status filter (empty matches any
+// status).
+func ValidateTaskStatus(
+	ctx context.Context,
+	status string,
+) (*mint.TkStatus, error) {
+	s := mint.TkStatus(status)
+	switch s {
+	case "", mint.TkStPending, mint.TkStSucceeded, mint.TkStFailed,
+		mint.TkStCanceled:
+	default:
+		return nil, errors.Trace(errors.NewUserErrorf(nil,
+			400, "status_invalid",
+			"The task status you provided is invalid: %s. It can be "+
+				"pending, succeeded, failed or canceled.",
+			status,
+		))
+	}
+
+	return &s, nil
+}
+
+// ValidateTask validates a task id.
+func ValidateTask(
+	ctx context.Context,
+	task string,
+) (*string, error) {
+	if !TaskRegexp.MatchString(task) {
+		return nil, errors.Trace(errors.NewUserErrorf(nil,
+			400, "task_invalid",
+			"The task id you provided is invalid: %s.",
+			task,
+		))
+	}
+
+	return &task, nil
+}
+
+// ValidateKey validates an API key id.
+func ValidateKey(
+	ctx context.Context,
+	key string,
+) (*string, error) {
+	if !KeyRegexp.MatchString(key) {
+		return nil, errors.Trace(errors.NewUserErrorf(nil,
+			400, "key_invalid",
+			"The API key id you provided is invalid: %s.",
+			key,
+		))
+	}
+
+	return &key, nil
+}
+
+// ValidateKeyScopes validates a list of API key scopes.
+func ValidateKeyScopes(
+	ctx context.Context,
+	scopes []string,
+) ([]mint.KyScope, error) {
+	if len(scopes) == 0 {
+		return nil, errors.Trace(errors.NewUserErrorf(nil,
+			400, "scopes_invalid",
+			"You must provide at least one scope in `scopes[]`.",
+		))
+	}
+	s := []mint.KyScope{}
+	seen := map[mint.KyScope]bool{}
+	for _, scope := range scopes {
+		sc := mint.KyScope(scope)
+		switch sc {
+		case mint.KyScRead, mint.KyScOffers, mint.KyScPay, mint.KyScSettle:
+		default:
+			return nil, errors.Trace(errors.NewUserErrorf(nil,
+				400, "scopes_invalid",
+				"The scope you provided in `scopes[]` is invalid: %s. It can "+
+					"be read, offers, pay or settle.",
+				scope,
+			))
+		}
+		if !seen[sc] {
+			seen[sc] = true
+			s = append(s, sc)
+		}
+	}
+
+	return s, nil
+}
+
+// ValidateExpiry validates an optional expiry in the future (empty means no
+// expiry).
+func ValidateExpiry(
+	ctx context.Context,
+	expiry string,
+) (*time.Time, error) {
+	if expiry == "" {
+		return nil, nil
+	}
+
+	e, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || e < 0 {
+		return nil, errors.Trace(errors.NewUserErrorf(err,
+			400, "expiry_invalid",
+			"The expiry you provided is invalid: %s. Expiries must be "+
+				"positive integers representing a unix time in milliseconds.",
+			expiry,
+		))
+	}
+	converted := time.Unix(0, e*mint.TimeResolutionNs)
+	if !converted.After(clock.Now(ctx)) {
+		return nil, errors.Trace(errors.NewUserErrorf(nil,
+			400, "expiry_invalid",
+			"The expiry you provided is in the past: %s.",
+			expiry,
+		))
+	}
+
+	return &converted, nil
+}
+
+// ValidateUsername validates a username.
+func ValidateUsername(
+	ctx context.Context,
+	username string,
+) (*string, error) {
+	if !UsernameRegexp.MatchString(username) {
+		return nil, errors.Trace(errors.NewUserErrorf(nil,
+			400, "username_invalid",
+			"The username you provided is invalid: %s. Usernames can use "+
+				"alphanumeric, `-`, `_` and `.` characters only.",
+			username,
+		))
+	}
+
+	return &username, nil
+}
+
+// ValidatePassword validates a new password.
+func ValidatePassword(
+	ctx context.Context,
+	password string,
+) (*string, error) {
+	if len(password) < 8 || len(password) > 256 {
+		return nil, errors.Trace(errors.NewUserErrorf(nil,
+			400, "password_invalid",
+			"The password you provided is invalid. Passwords must be "+
+				"between 8 and 256 characters long.",
+		))
+	}
+
+	return &password, nil
+}
+
+// ValidatePeer validates a peer mint host.
+func ValidatePeer(
+	ctx context.Context,
+	host string,
+) (*string, error) {
+	if !mint.HostRegexp.MatchString(host) {
+		return nil, errors.Trace(errors.NewUserErrorf(nil,
+			400, "peer_invalid",
+			"The peer mint host you provided is invalid: %s.",
+			host,
+		))
+	}
+
+	return &host, nil
+}
+
+// ValidatePeerPolicy validates a peer policy.
+func ValidatePeerPolicy(
+	ctx context.Context,
+	policy string,
+) (*mint.PrPolicy, error) {
+	p := mint.PrPolicy(policy)
+	if err := peering.ValidatePolicy(p); err != nil {
+		return nil, errors.Trace(errors.NewUserErrorf(err,
+			400, "policy_invalid",
+			"The peer policy you provided is invalid: %s. Valid peer "+
+				"policies are: allow, block.",
+			policy,
+		))
+	}
+
+	return &p, nil
+}
@@ -3,13 +3,17 @@ package mint
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"strings"
 
 	"github.com/spolu/settle/lib/env"
+	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/logging"
 )
 
 const (
-	// EnvCfgHost is the env config key for the mint host.
+	// EnvCfgHost is the env config key for the mint hosts. It is a comma
+	// separated list of hosts, the first one being the primary host.
 	EnvCfgHost env.ConfigKey = "host"
 	// EnvCfgPort is the port on which to run the mint.
 	EnvCfgPort env.ConfigKey = "port"
@@ -17,13 +21,70 @@ const (
 	EnvCfgKeyFile env.ConfigKey = "key_file"
 	// EnvCfgCrtFile is the production certificate file.
 	EnvCfgCrtFile env.ConfigKey = "crt_file"
+	// EnvCfgWorkers is the number of concurrent async workers to run.
+	EnvCfgWorkers env.ConfigKey = "workers"
+	// EnvCfgAdminSecret is the secret granting access to the admin API. The
+	// admin API is disabled if it is empty.
+	EnvCfgAdminSecret env.ConfigKey = "admin_secret"
+	// EnvCfgRateLimits overrides the default rate limits of the mint. It is a
+	// comma separated list of `class=rate` (see throttling.ParseLimits).
+	EnvCfgRateLimits env.ConfigKey = "rate_limits"
+	// EnvCfgPeerPolicy is the path to the JSON peer policy file of the mint
+	// (see peering.ParsePolicy). All peers are allowed if it is empty.
+	EnvCfgPeerPolicy env.ConfigKey = "peer_policy"
+	// EnvCfgPublicURL is the base URL of the mint API advertised in its
+	// discovery document. It is a comma separated list of `host=url` (for
+	// example ias.edu=https://api.ias.edu), or a single URL if only one host
+	// is served. It defaults to the URL inferred from the mint host.
+	EnvCfgPublicURL env.ConfigKey = "public_url"
+	// EnvCfgPublicKeys is a comma separated list of public keys advertised in
+	// the discovery document of the mint (for example certificate pins).
+	EnvCfgPublicKeys env.ConfigKey = "public_keys"
 )
 
-// GetHost retrieves the current mint host from the given contest.
+// ContextKey is the type of the key used with context to carry contextual
+// mint state.
+type ContextKey string
+
+const (
+	// hostKey the context.Context key to store the current mint host.
+	hostKey ContextKey = "mint.host"
+)
+
+// WithHost stores the mint host a request or a task is executed for in the
+// provided context.
+func WithHost(
+	ctx context.Context,
+	host string,
+) context.Context {
+	return context.WithValue(ctx, hostKey, host)
+}
+
+// GetHost retrieves the current mint host from the given contest. It is the
+// host set with WithHost if any, and the primary host otherwise.
 func GetHost(
 	ctx context.Context,
 ) string {
-	return env.Get(ctx).Config[EnvCfgHost]
+	if host, ok := ctx.Value(hostKey).(string); ok {
+		return host
+	}
+	return GetHosts(ctx)[0]
+}
+
+// GetHosts retrieves all the hosts served by the mint process.
+func GetHosts(
+	ctx context.Context,
+) []string {
+	hosts := []string{}
+	for _, h := range strings.Split(env.Get(ctx).Config[EnvCfgHost], ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	if len(hosts) == 0 {
+		return []string{""}
+	}
+	return hosts
 }
 
 // GetPort retrieves the current mint port from the given contest.
@@ -33,11 +94,112 @@ func GetPort(
 	return env.Get(ctx).Config[EnvCfgPort]
 }
 
-// Logf shells out to logging.Logf adding the mint host as prefix.
+// GetPublicURL retrieves the base URL of the mint API for the current mint
+// host from the given context.
+func GetPublicURL(
+	ctx context.Context,
+) (*url.URL, error) {
+	urls, err := parsePublicURLs(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if u, ok := urls[GetHost(ctx)]; ok {
+		return u, nil
+	}
+	return FullMintURL(ctx, GetHost(ctx), "", url.Values{}), nil
+}
+
+// parsePublicURLs parses the public URLs configured for the hosts served by
+// the mint process, by host.
+func parsePublicURLs(
+	ctx context.Context,
+) (map[string]*url.URL, error) {
+	hosts := GetHosts(ctx)
+	urls := map[string]*url.URL{}
+	for _, e := range strings.Split(env.Get(ctx).Config[EnvCfgPublicURL], ",") {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+		host, raw := "", e
+		if i := strings.Index(e, "="); i != -1 {
+			host, raw = strings.TrimSpace(e[:i]), strings.TrimSpace(e[i+1:])
+		} else if len(hosts) == 1 {
+			host = hosts[0]
+		} else {
+			return nil, errors.Trace(errors.Newf(
+				"Invalid public URL `%s`, it must have the form "+
+					"host=url when serving several hosts", e))
+		}
+		if !served(hosts, host) {
+			return nil, errors.Trace(errors.Newf(
+				"Invalid public URL `%s`, host %s is not served", e, host))
+		}
+		if _, ok := urls[host]; ok {
+			return nil, errors.Trace(errors.Newf(
+				"Invalid public URL `%s`, duplicate host %s", e, host))
+		}
+
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" ||
+			(u.Path != "" && u.Path != "/") {
+			return nil, errors.Trace(errors.Newf(
+				"Invalid public URL `%s`, it must have the form "+
+					"https://api.ias.edu[:port]", raw))
+		}
+		urls[host] = u
+	}
+	return urls, nil
+}
+
+// served returns whether host is one of the provided hosts.
+func served(
+	hosts []string,
+	host string,
+) bool {
+	for _, h := range hosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPublicKeys retrieves the public keys advertised by the mint from the
+// given context.
+func GetPublicKeys(
+	ctx context.Context,
+) []string {
+	keys := []string{}
+	for _, k := range strings.Split(env.Get(ctx).Config[EnvCfgPublicKeys], ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Logf shells out to logging.Logf adding the mint host as field.
 func Logf(
 	ctx context.Context,
 	format string,
 	v ...interface{},
 ) {
-	logging.Logf(ctx, fmt.Sprintf("[%s] ", GetHost(ctx))+format, v...)
+	Log(ctx, logging.LvInfo, fmt.Sprintf(format, v...), nil)
+}
+
+// Log shells out to logging.Entry adding the mint host as field.
+func Log(
+	ctx context.Context,
+	level logging.Level,
+	msg string,
+	fields logging.Fields,
+) {
+	all := logging.Fields{"host": GetHost(ctx)}
+	for k, v := range fields {
+		all[k] = v
+	}
+	logging.Entry(ctx, level, msg, all)
 }
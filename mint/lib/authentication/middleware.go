@@ -2,12 +2,19 @@ package authentication
 
 import (
 	"context"
+	"crypto/subtle"
 	"net/http"
 	"regexp"
+	"strings"
+	"time"
 
+	"github.com/spolu/settle/lib/clock"
+	"github.com/spolu/settle/lib/env"
 	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/lib/respond"
 	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/lib/throttling"
 	"github.com/spolu/settle/mint/model"
 )
 
@@ -30,13 +37,17 @@ const (
 	AutStSkipped AutStatus = "skipped"
 	// AutStFailed indicates a failed authentication.
 	AutStFailed AutStatus = "failed"
+	// AutStAdmin indicates a successful authentication with the admin
+	// secret.
+	AutStAdmin AutStatus = "admin"
 )
 
 // Status stores the authentication information, the status and authenticated
-// user if applicable.
+// user if applicable, along with the API key used to authenticate if any.
 type Status struct {
 	Status AutStatus
 	User   *model.User
+	APIKey *model.APIKey
 }
 
 // With stores the authentication information in a new context.
@@ -81,6 +92,175 @@ var SkipList = []*SkipRule{
 
 	&SkipRule{"GET", regexp.MustCompile("^/assets/[a-zA-Z0-9_\\+:@\\.\\[\\]]+$")},
 	&SkipRule{"GET", regexp.MustCompile("^/assets/[a-zA-Z0-9_\\+:@\\.\\[\\]]+/offers$")},
+
+	&SkipRule{"GET", regexp.MustCompile("^/.well-known/settle-mint$")},
+
+	&SkipRule{"GET", regexp.MustCompile("^/healthz$")},
+	&SkipRule{"GET", regexp.MustCompile("^/readyz$")},
+}
+
+// ScopeRule grants access to an endpoint to the API keys with a given scope.
+type ScopeRule struct {
+	Method  string
+	Pattern *regexp.Regexp
+	Scope   mint.KyScope
+}
+
+// ScopeList is the list of endpoints accessible with API keys. Requests
+// authenticated with an API key to endpoints not covered by the scopes of the
+// key are rejected (or unauthenticated if part of the SkipList).
+var ScopeList = []*ScopeRule{
+	&ScopeRule{"GET", regexp.MustCompile("^/assets$"), mint.KyScRead},
+	&ScopeRule{"GET", regexp.MustCompile("^/assets/[a-zA-Z0-9_\\+:@\\.\\[\\]]+$"), mint.KyScRead},
+	&ScopeRule{"GET", regexp.MustCompile("^/assets/[a-zA-Z0-9_\\+:@\\.\\[\\]]+/balances$"), mint.KyScRead},
+	&ScopeRule{"GET", regexp.MustCompile("^/assets/[a-zA-Z0-9_\\+:@\\.\\[\\]]+/offers$"), mint.KyScRead},
+	&ScopeRule{"GET", regexp.MustCompile("^/balances$"), mint.KyScRead},
+	&ScopeRule{"GET", regexp.MustCompile("^/balances/[a-zA-Z0-9_\\+:@\\.\\[\\]]+$"), mint.KyScRead},
+	&ScopeRule{"GET", regexp.MustCompile("^/offers$"), mint.KyScRead},
+	&ScopeRule{"GET", regexp.MustCompile("^/offers/[a-zA-Z0-9_\\+:@\\.\\[\\]]+$"), mint.KyScRead},
+	&ScopeRule{"GET", regexp.MustCompile("^/offers/[a-zA-Z0-9_\\+:@\\.\\[\\]]+/crossings$"), mint.KyScRead},
+	&ScopeRule{"GET", regexp.MustCompile("^/operations/[a-zA-Z0-9_\\+:@\\.\\[\\]]+$"), mint.KyScRead},
+	&ScopeRule{"GET", regexp.MustCompile("^/transactions/[a-zA-Z0-9_\\+:@\\.\\[\\]]+$"), mint.KyScRead},
+
+	&ScopeRule{"POST", regexp.MustCompile("^/offers$"), mint.KyScOffers},
+	&ScopeRule{"POST", regexp.MustCompile("^/offers/[a-zA-Z0-9_\\+:@\\.\\[\\]]+/close$"), mint.KyScOffers},
+
+	&ScopeRule{"POST", regexp.MustCompile("^/transactions$"), mint.KyScPay},
+
+	&ScopeRule{"POST", regexp.MustCompile("^/transactions/[a-zA-Z0-9_\\+:@\\.\\[\\]]+/settle$"), mint.KyScSettle},
+	&ScopeRule{"POST", regexp.MustCompile("^/transactions/[a-zA-Z0-9_\\+:@\\.\\[\\]]+/cancel$"), mint.KyScSettle},
+}
+
+// APIKeyPrefix is the prefix of API key bearer credentials, of the form
+// `<key_token>.<secret>`.
+const APIKeyPrefix = "Bearer key_"
+
+// AdminPattern matches the paths of the admin API. Admin API requests are
+// authenticated with the admin secret passed as bearer token instead of user
+// credentials.
+var AdminPattern = regexp.MustCompile("^/admin/")
+
+// requiresAdmin returns whether a request must be authenticated with the admin
+// secret: admin API requests, metrics and readiness checks of the
+// reachability of peers (which have the mint contact each of its recent
+// peers).
+func requiresAdmin(
+	r *http.Request,
+) bool {
+	if AdminPattern.MatchString(r.URL.Path) {
+		return true
+	}
+	if r.Method == "GET" && r.URL.Path == "/metrics" {
+		return true
+	}
+	return r.Method == "GET" && r.URL.Path == "/readyz" &&
+		r.URL.Query().Get("peers") == "true"
+}
+
+// authenticateAdmin authenticates an admin API request against the admin
+// secret of the mint.
+func authenticateAdmin(
+	r *http.Request,
+) error {
+	ctx := r.Context()
+
+	secret := env.Get(ctx).Config[mint.EnvCfgAdminSecret]
+	if secret == "" {
+		return errors.Trace(errors.NewUserErrorf(nil,
+			403, "admin_disabled",
+			"The admin API is disabled on this mint (no admin secret "+
+				"configured).",
+		))
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") || subtle.ConstantTimeCompare(
+		[]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(secret)) != 1 {
+		return errors.Trace(errors.NewUserErrorf(nil,
+			401, "admin_secret_invalid",
+			"The admin secret you provided is invalid.",
+		))
+	}
+
+	return nil
+}
+
+// checkUser checks that an authenticated user is allowed to use the mint.
+func checkUser(
+	user *model.User,
+) error {
+	if user.Disabled != nil {
+		return errors.Trace(errors.NewUserErrorf(nil,
+			403, "user_disabled",
+			"The user you are trying to authenticate with is disabled: %s.",
+			user.Username,
+		))
+	}
+	return nil
+}
+
+// authenticateAPIKey authenticates a request with an API key bearer
+// credential, returning the key and its owner. Failed attempts are throttled
+// per key, in which case the time to wait before retrying is returned.
+func authenticateAPIKey(
+	r *http.Request,
+) (*model.APIKey, *model.User, time.Duration, error) {
+	ctx := r.Context()
+
+	credential := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	ss := strings.SplitN(credential, ".", 2)
+	if len(ss) != 2 {
+		return nil, nil, 0, errors.Trace(errors.NewUserErrorf(nil,
+			401, "api_key_invalid",
+			"The API key you provided is invalid. API keys must be passed "+
+				"as bearer tokens of the form `key_*.secret`.",
+		))
+	}
+	token, secret := ss[0], ss[1]
+
+	loginKey := "login:" + token
+	if wait, err := throttling.Check(ctx, throttling.ThClLogin,
+		loginKey); err != nil {
+		return nil, nil, wait, errors.Trace(err)
+	}
+
+	key, err := model.LoadAPIKeyByToken(ctx, token)
+	if err != nil {
+		return nil, nil, 0, errors.Trace(err)
+	}
+	if key == nil || key.CheckSecret(secret) != nil {
+		throttling.Allow(ctx, throttling.ThClLogin, loginKey)
+		return nil, nil, 0, errors.Trace(errors.NewUserErrorf(nil,
+			401, "api_key_invalid",
+			"The API key you provided is invalid: %s.", token,
+		))
+	}
+
+	if key.Revoked != nil {
+		return nil, nil, 0, errors.Trace(errors.NewUserErrorf(nil,
+			401, "api_key_revoked",
+			"The API key you provided was revoked: %s.", token,
+		))
+	}
+	if !key.Active(clock.Now(ctx)) {
+		return nil, nil, 0, errors.Trace(errors.NewUserErrorf(nil,
+			401, "api_key_expired",
+			"The API key you provided is expired: %s.", token,
+		))
+	}
+
+	user, err := model.LoadUserByToken(ctx, key.Owner)
+	if err != nil {
+		return nil, nil, 0, errors.Trace(err)
+	} else if user == nil {
+		return nil, nil, 0, errors.Trace(errors.NewUserErrorf(nil,
+			401, "api_key_invalid",
+			"The API key you provided is not associated with any existing "+
+				"user: %s.", token,
+		))
+	}
+
+	return key, user, 0, nil
 }
 
 // ServeHTTP handles incoming HTTP requests and attempt to authenticate them.
@@ -89,7 +269,42 @@ func (m middleware) ServeHTTP(
 	r *http.Request,
 ) {
 	ctx := r.Context()
-	withStatus := With(ctx, Status{AutStFailed, nil})
+	withStatus := With(ctx, Status{AutStFailed, nil, nil})
+
+	if requiresAdmin(r) {
+		// Failed admin authentication attempts are throttled per client IP
+		// address to protect the admin secret from brute force.
+		adminKey := "admin:" + throttling.ClientIP(r)
+		if wait, err := throttling.Check(ctx, throttling.ThClLogin,
+			adminKey); err != nil {
+			mint.Log(ctx, logging.LvWarn, "Authentication", logging.Fields{
+				"status": AutStFailed,
+				"admin":  true,
+				"wait":   wait.Seconds(),
+			})
+			if wait > 0 {
+				throttling.SetRetryAfter(w, wait)
+			}
+			respond.Error(withStatus, w, errors.Trace(err))
+			return
+		}
+		if err := authenticateAdmin(r); err != nil {
+			throttling.Allow(ctx, throttling.ThClLogin, adminKey)
+			mint.Log(ctx, logging.LvWarn, "Authentication", logging.Fields{
+				"status": AutStFailed,
+				"admin":  true,
+			})
+			respond.Error(withStatus, w, errors.Trace(err))
+			return
+		}
+		withStatus = throttling.With(
+			With(ctx, Status{AutStAdmin, nil, nil}), throttling.Subject{})
+		mint.Log(ctx, logging.LvInfo, "Authentication", logging.Fields{
+			"status": AutStAdmin,
+		})
+		m.Handler.ServeHTTP(w, r.WithContext(withStatus))
+		return
+	}
 
 	username, password, _ := r.BasicAuth()
 	skip := false
@@ -103,17 +318,109 @@ func (m middleware) ServeHTTP(
 	// authentication error.
 	failedAuth := func(err error) {
 		if skip {
-			withStatus = With(ctx, Status{AutStSkipped, nil})
-			mint.Logf(ctx,
-				"Authentication: status=%q username=%q",
-				Get(withStatus).Status, username)
+			withStatus = With(ctx, Status{AutStSkipped, nil, nil})
+			mint.Log(ctx, logging.LvInfo, "Authentication", logging.Fields{
+				"status":   Get(withStatus).Status,
+				"username": username,
+			})
 			m.Handler.ServeHTTP(w, r.WithContext(withStatus))
 		} else {
-			withStatus = With(ctx, Status{AutStFailed, nil})
-			mint.Logf(ctx,
-				"Authentication: status=%q username=%q",
-				Get(withStatus).Status, username)
+			withStatus = With(ctx, Status{AutStFailed, nil, nil})
+			mint.Log(ctx, logging.LvWarn, "Authentication", logging.Fields{
+				"status":   Get(withStatus).Status,
+				"username": username,
+			})
+			respond.Error(withStatus, w, errors.Trace(err))
+		}
+	}
+
+	// Helper closure to store the authenticated user and API key (if any) in
+	// the context and serve the request.
+	succeededAuth := func(user *model.User, key *model.APIKey) {
+		withStatus = logging.WithFields(
+			throttling.With(
+				With(ctx, Status{AutStSucceeded, user, key}),
+				throttling.Subject{
+					Class: throttling.ThClUser,
+					Key:   "user:" + user.Token,
+				}),
+			logging.Fields{"user": user.Token})
+		fields := logging.Fields{
+			"status":   Get(withStatus).Status,
+			"username": user.Username,
+		}
+		if key != nil {
+			fields["api_key"] = key.Token
+		}
+		mint.Log(withStatus, logging.LvInfo, "Authentication", fields)
+
+		m.Handler.ServeHTTP(w, r.WithContext(withStatus))
+	}
+
+	if strings.HasPrefix(r.Header.Get("Authorization"), APIKeyPrefix) {
+		key, user, wait, err := authenticateAPIKey(r)
+		if err != nil {
+			if wait > 0 {
+				mint.Log(ctx, logging.LvWarn, "Authentication", logging.Fields{
+					"status": AutStFailed,
+					"wait":   wait.Seconds(),
+				})
+				throttling.SetRetryAfter(w, wait)
+				respond.Error(withStatus, w, errors.Trace(err))
+				return
+			}
+			failedAuth(errors.Trace(err))
+			return
+		}
+
+		if err := checkUser(user); err != nil {
+			failedAuth(errors.Trace(err))
+			return
+		}
+
+		scoped := false
+		for _, s := range ScopeList {
+			if s.Method == r.Method && s.Pattern.MatchString(r.URL.Path) &&
+				key.HasScope(s.Scope) {
+				scoped = true
+			}
+		}
+		if !scoped {
+			failedAuth(errors.Trace(errors.NewUserErrorf(nil,
+				403, "api_key_scope_insufficient",
+				"The API key you provided is not granted access to this "+
+					"endpoint: %s %s.", r.Method, r.URL.Path,
+			)))
+			return
+		}
+
+		succeededAuth(user, key)
+		return
+	}
+
+	// Failed authentication attempts are throttled per username and client
+	// IP address. Once the budget of a username is exhausted for an address,
+	// its requests from that address are rejected without checking their
+	// credentials (so that the user can still authenticate from elsewhere).
+	loginKey := "login:" + username + "|" + throttling.ClientIP(r)
+	if username != "" {
+		if wait, err := throttling.Check(ctx, throttling.ThClLogin,
+			loginKey); err != nil {
+			mint.Log(ctx, logging.LvWarn, "Authentication", logging.Fields{
+				"status":   AutStFailed,
+				"username": username,
+				"wait":     wait.Seconds(),
+			})
+			if wait > 0 {
+				throttling.SetRetryAfter(w, wait)
+			}
 			respond.Error(withStatus, w, errors.Trace(err))
+			return
+		}
+	}
+	failedLogin := func() {
+		if username != "" {
+			throttling.Allow(ctx, throttling.ThClLogin, loginKey)
 		}
 	}
 
@@ -122,6 +429,7 @@ func (m middleware) ServeHTTP(
 		failedAuth(errors.Trace(err))
 		return
 	} else if user == nil {
+		failedLogin()
 		failedAuth(errors.Trace(errors.NewUserErrorf(err,
 			400, "username_invalid",
 			"The username you are trying to authenticate with is not "+
@@ -131,19 +439,19 @@ func (m middleware) ServeHTTP(
 	}
 
 	if err := user.CheckPassword(ctx, password); err != nil {
+		failedLogin()
 		failedAuth(errors.Trace(errors.NewUserErrorf(err,
 			400, "password_invalid", "The password you provided is invalid.",
 		)))
 		return
 	}
 
-	withStatus = With(ctx, Status{AutStSucceeded, user})
-	mint.Logf(ctx,
-		"Authentication: status=%q user=%q username=%q",
-		Get(withStatus).Status, Get(withStatus).User.Token,
-		username)
+	if err := checkUser(user); err != nil {
+		failedAuth(errors.Trace(err))
+		return
+	}
 
-	m.Handler.ServeHTTP(w, r.WithContext(withStatus))
+	succeededAuth(user, nil)
 }
 
 // Middleware that authenticates API requests.
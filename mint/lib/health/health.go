@@ -0,0 +1,217 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/clock"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/token"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/async"
+	"github.com/spolu/settle/mint/model"
+)
+
+const (
+	// CheckTimeout is the maximum duration of each readiness check.
+	CheckTimeout = 2 * time.Second
+	// PeersTimeout is the maximum duration of the reachability checks of all
+	// the recent peers, which are run concurrently.
+	PeersTimeout = 5 * time.Second
+	// MaxTickAge is the maximum age of the last iteration of the scheduler
+	// loop of the async workers for the mint to be ready.
+	MaxTickAge = time.Minute
+	// MaxTaskOverdue is the maximum time for which a task can be eligible for
+	// execution without being claimed by a worker for the mint to be ready.
+	MaxTaskOverdue = 5 * time.Minute
+	// RecentPeerWindow is the window during which peers must have been
+	// contacted to be checked for reachability.
+	RecentPeerWindow = mint.RecentPeerWindow
+)
+
+// run executes a check with a timeout and returns its result.
+func run(
+	ctx context.Context,
+	name string,
+	check func(context.Context) error,
+) mint.HealthCheckResource {
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+
+	r := mint.HealthCheckResource{
+		Name:     name,
+		Status:   mint.HlStOK,
+		Duration: time.Now().Sub(start).Nanoseconds() / mint.TimeResolutionNs,
+	}
+	if err != nil {
+		r.Status = mint.HlStFailed
+		r.Detail = errors.Cause(err).Error()
+	}
+	return r
+}
+
+// CheckDB checks that the DB is reachable and accepts writes, by inserting a
+// health check in a transaction that is rolled back. It returns as soon as ctx
+// expires, even if the DB does not respond.
+func CheckDB(
+	ctx context.Context,
+) error {
+	mintDB := db.GetDB(ctx, "mint")
+	if mintDB == nil {
+		// The mint runs on a backend (see db.WithBackend) which is always
+		// reachable.
+		ctx = db.Begin(ctx, "mint")
+		defer db.LoggedRollback(ctx)
+		if _, err := model.CreateHealthCheck(ctx); err != nil {
+			return errors.Trace(err)
+		}
+		return nil
+	}
+	if err := mintDB.PingContext(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	// Transactions of the DB driver do not take a context, the write is run
+	// in the background and rolled back whenever it completes.
+	done := make(chan error, 1)
+	go func() {
+		done <- writeHealthCheck(ctx, mintDB)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return errors.Trace(ctx.Err())
+	case err := <-done:
+		return errors.Trace(err)
+	}
+}
+
+// writeHealthCheck inserts a health check in a transaction that is rolled
+// back.
+func writeHealthCheck(
+	ctx context.Context,
+	mintDB *sqlx.DB,
+) error {
+	tx, err := mintDB.Beginx()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer tx.Rollback()
+
+	ctx = db.WithTransaction(ctx, db.Transaction{
+		Tx:      tx,
+		Token:   token.New("tx"),
+		Tag:     "mint",
+		Started: time.Now(),
+	})
+	if _, err := model.CreateHealthCheck(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+// CheckWorkers checks that the async workers of the process are running.
+func CheckWorkers(
+	ctx context.Context,
+) error {
+	last := async.Get(ctx).LastTick()
+	if last.IsZero() {
+		return errors.Trace(errors.Newf("Async workers are not running"))
+	}
+	if age := time.Now().Sub(last); age > MaxTickAge {
+		return errors.Trace(errors.Newf(
+			"Last scheduler tick is too old: %s", age))
+	}
+	return nil
+}
+
+// CheckTasks checks that no task is left unclaimed for too long after its
+// deadline.
+func CheckTasks(
+	ctx context.Context,
+) error {
+	now := clock.Now(ctx)
+	task, err := model.LoadOldestOverdueTask(ctx, now)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if task == nil {
+		return nil
+	}
+	if overdue := now.Sub(task.Deadline); overdue > MaxTaskOverdue {
+		return errors.Trace(errors.Newf(
+			"Oldest overdue task is overdue for too long: task=%s overdue=%s",
+			task.Token, overdue))
+	}
+	return nil
+}
+
+// CheckPeers checks concurrently the reachability of the peers contacted by
+// the mint during the RecentPeerWindow, within PeersTimeout overall.
+func CheckPeers(
+	ctx context.Context,
+) ([]mint.HealthCheckResource, error) {
+	client := &mint.Client{}
+	if err := client.Init(ctx); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, PeersTimeout)
+	defer cancel()
+
+	peers := mint.RecentPeers(time.Now().Add(-RecentPeerWindow))
+	checks := make([]mint.HealthCheckResource, len(peers))
+
+	wg := sync.WaitGroup{}
+	for i, base := range peers {
+		i, base := i, base
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checks[i] = run(ctx, base, func(ctx context.Context) error {
+				return client.Ping(ctx, base)
+			})
+		}()
+	}
+	wg.Wait()
+
+	return checks, nil
+}
+
+// Ready runs the readiness checks of the process, and the reachability
+// checks of its recent peers if requested.
+func Ready(
+	ctx context.Context,
+	peers bool,
+) (*mint.HealthResource, error) {
+	h := mint.HealthResource{
+		Status: mint.HlStOK,
+		Checks: []mint.HealthCheckResource{
+			run(ctx, "db", CheckDB),
+			run(ctx, "workers", CheckWorkers),
+			run(ctx, "tasks", CheckTasks),
+		},
+	}
+	for _, c := range h.Checks {
+		if c.Status != mint.HlStOK {
+			h.Status = mint.HlStFailed
+		}
+	}
+
+	if peers {
+		checks, err := CheckPeers(ctx)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		h.Peers = checks
+	}
+
+	return &h, nil
+}
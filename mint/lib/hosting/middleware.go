@@ -0,0 +1,90 @@
+package hosting
+
+import (
+	"net"
+	"net/http"
+	"regexp"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/lib/respond"
+	"github.com/spolu/settle/mint"
+)
+
+type middleware struct {
+	http.Handler
+}
+
+// ProcessList is the list of endpoints that concern the mint process rather
+// than one of its hosts. They are served whatever the host they are addressed
+// to (for example by an orchestrator probing the process by IP address).
+var ProcessList = []*regexp.Regexp{
+	regexp.MustCompile("^/healthz$"),
+	regexp.MustCompile("^/readyz$"),
+}
+
+// Select returns the mint host a request is addressed to among the hosts
+// served by this mint, based on the Host header or the SNI server name. If the
+// mint serves only one host, it is always selected.
+func Select(
+	r *http.Request,
+) (string, bool) {
+	hosts := mint.GetHosts(r.Context())
+	if len(hosts) == 1 {
+		return hosts[0], true
+	}
+
+	candidates := []string{r.Host}
+	if h, _, err := net.SplitHostPort(r.Host); err == nil {
+		candidates = append(candidates, h)
+	}
+	if r.TLS != nil && r.TLS.ServerName != "" {
+		candidates = append(candidates, r.TLS.ServerName)
+	}
+
+	for _, c := range candidates {
+		for _, h := range hosts {
+			if c == h {
+				return h, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ServeHTTP handles incoming HTTP requests and scopes them to the mint host
+// they are addressed to.
+func (m middleware) ServeHTTP(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	host, ok := Select(r)
+	if !ok {
+		for _, p := range ProcessList {
+			if p.MatchString(r.URL.Path) {
+				host, ok = mint.GetHosts(r.Context())[0], true
+			}
+		}
+	}
+	if !ok {
+		respond.Error(r.Context(), w, errors.Trace(errors.NewUserErrorf(nil,
+			404, "host_unknown",
+			"The host you are trying to reach is not served by this "+
+				"mint: %s.", r.Host,
+		)))
+		return
+	}
+
+	ctx := logging.WithFields(mint.WithHost(r.Context(), host),
+		logging.Fields{"host": host})
+	mint.Log(ctx, logging.LvDebug, "Hosting", logging.Fields{
+		"request_host": r.Host,
+	})
+
+	m.Handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// Middleware that selects the mint host of API requests.
+func Middleware(h http.Handler) http.Handler {
+	return middleware{h}
+}
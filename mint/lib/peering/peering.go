@@ -0,0 +1,348 @@
+package peering
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spolu/settle/lib/env"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/lib/metrics"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+// Peer is the policy applied to a peer mint.
+type Peer struct {
+	Policy mint.PrPolicy `json:"policy"`
+	// ExposureCap is the maximum amount (in base units of each asset) of
+	// reserved operations to users of the peer, per asset, if any.
+	ExposureCap *big.Int `json:"exposure_cap"`
+}
+
+// Policy is the peer policy of a mint as read from the file configured with
+// mint.EnvCfgPeerPolicy. Peers that are not listed are subject to the default
+// policy (so that a default `block` policy turns the list of peers into an
+// allow-list).
+type Policy struct {
+	Default mint.PrPolicy   `json:"default"`
+	Peers   map[string]Peer `json:"peers"`
+}
+
+// DefaultPolicy is the policy of mints with no peer policy file, allowing all
+// peers.
+var DefaultPolicy = Policy{
+	Default: mint.PrPlAllow,
+	Peers:   map[string]Peer{},
+}
+
+var rejectedRequests = metrics.NewCounter(
+	"settle_mint_peer_rejections_total",
+	"Number of interactions with peer mints rejected by the peer policy by "+
+		"reason.",
+	"reason")
+
+// ValidatePolicy validates a peer policy value.
+func ValidatePolicy(
+	policy mint.PrPolicy,
+) error {
+	switch policy {
+	case mint.PrPlAllow, mint.PrPlBlock:
+		return nil
+	}
+	return errors.Trace(errors.Newf(
+		"Invalid peer policy `%s`, valid policies are: allow, block", policy))
+}
+
+// ParsePolicy parses and validates a JSON peer policy, for example:
+//
+//	{
+//	  "default": "allow",
+//	  "peers": {
+//	    "spam.example.com": { "policy": "block" },
+//	    "ias.edu": { "policy": "allow", "exposure_cap": 100000 }
+//	  }
+//	}
+func ParsePolicy(
+	raw []byte,
+) (*Policy, error) {
+	p := Policy{}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if p.Default == "" {
+		p.Default = mint.PrPlAllow
+	}
+	if err := ValidatePolicy(p.Default); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if p.Peers == nil {
+		p.Peers = map[string]Peer{}
+	}
+	for host, peer := range p.Peers {
+		if !mint.HostRegexp.MatchString(host) {
+			return nil, errors.Trace(errors.Newf(
+				"Invalid peer host: %s", host))
+		}
+		if err := ValidatePolicy(peer.Policy); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if peer.ExposureCap != nil && peer.ExposureCap.Sign() < 0 {
+			return nil, errors.Trace(errors.Newf(
+				"Invalid exposure cap for peer %s: %s",
+				host, peer.ExposureCap.String()))
+		}
+	}
+	return &p, nil
+}
+
+// files caches the policy files by path, along with their modification time
+// so that they are reloaded when edited.
+var files = struct {
+	mutex *sync.Mutex
+	all   map[string]cachedFile
+}{
+	mutex: &sync.Mutex{},
+	all:   map[string]cachedFile{},
+}
+
+type cachedFile struct {
+	modified time.Time
+	policy   *Policy
+}
+
+// LoadFile reads and parses the peer policy file at the provided path. It
+// returns the DefaultPolicy if the path is empty.
+func LoadFile(
+	path string,
+) (*Policy, error) {
+	if path == "" {
+		return &DefaultPolicy, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	files.mutex.Lock()
+	defer files.mutex.Unlock()
+
+	if f, ok := files.all[path]; ok && f.modified.Equal(info.ModTime()) {
+		return f.policy, nil
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	p, err := ParsePolicy(raw)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	files.all[path] = cachedFile{info.ModTime(), p}
+
+	return p, nil
+}
+
+// Lookup returns the policy applied to the peer mint host and whether it
+// comes from the `file` or the `admin` API (empty if the default policy
+// applies). Peers set with the admin API take precedence over the file.
+func Lookup(
+	ctx context.Context,
+	host string,
+) (*Peer, string, error) {
+	peer, err := model.LoadPeerByHost(ctx, host)
+	if err != nil {
+		return nil, "", errors.Trace(err)
+	}
+	if peer != nil {
+		p := &Peer{Policy: peer.Policy}
+		if peer.ExposureCap != nil {
+			p.ExposureCap = (*big.Int)(peer.ExposureCap)
+		}
+		return p, "admin", nil
+	}
+
+	policy, err := LoadFile(env.Get(ctx).Config[mint.EnvCfgPeerPolicy])
+	if err != nil {
+		return nil, "", errors.Trace(err)
+	}
+	if p, ok := policy.Peers[host]; ok {
+		return &p, "file", nil
+	}
+
+	return &Peer{Policy: policy.Default}, "", nil
+}
+
+// List returns the default policy and the peers of the mint, from both the
+// policy file and the admin API.
+func List(
+	ctx context.Context,
+) (mint.PrPolicy, []mint.PeerResource, error) {
+	policy, err := LoadFile(env.Get(ctx).Config[mint.EnvCfgPeerPolicy])
+	if err != nil {
+		return "", nil, errors.Trace(err)
+	}
+	peers, err := model.LoadPeerList(ctx)
+	if err != nil {
+		return "", nil, errors.Trace(err)
+	}
+
+	all := map[string]mint.PeerResource{}
+	for host, p := range policy.Peers {
+		all[host] = mint.PeerResource{
+			Host:        host,
+			Source:      "file",
+			Policy:      p.Policy,
+			ExposureCap: p.ExposureCap,
+		}
+	}
+	for _, p := range peers {
+		p := p
+		all[p.Host] = model.NewPeerResource(ctx, &p)
+	}
+
+	l := []mint.PeerResource{}
+	for _, r := range all {
+		l = append(l, r)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Host < l[j].Host })
+
+	return policy.Default, l, nil
+}
+
+// isLocal returns whether the host is served by the current mint process.
+func isLocal(
+	ctx context.Context,
+	host string,
+) bool {
+	for _, h := range mint.GetHosts(ctx) {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns a 403 user error if the peer mint host is blocked by the peer
+// policy of the mint. Hosts served by the current process are always
+// allowed.
+func Check(
+	ctx context.Context,
+	host string,
+) error {
+	if isLocal(ctx, host) {
+		return nil
+	}
+	peer, _, err := Lookup(ctx, host)
+	if err != nil {
+		return errors.Trace(err) // 500
+	}
+	if peer.Policy == mint.PrPlBlock {
+		rejectedRequests.Inc("blocked")
+		mint.Log(ctx, logging.LvWarn, "Rejected blocked peer", logging.Fields{
+			"peer": host,
+		})
+		return errors.Trace(errors.NewUserErrorf(nil,
+			403, "peer_blocked",
+			"This mint does not interact with the mint: %s.", host,
+		))
+	}
+	return nil
+}
+
+// CheckAddress is similar to Check for the mint of the provided address.
+func CheckAddress(
+	ctx context.Context,
+	address string,
+) error {
+	_, host, err := mint.UsernameAndMintHostFromAddress(ctx, address)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(Check(ctx, host))
+}
+
+// Exposure computes the current exposure of the mint to a peer in an asset,
+// that is the sum of the amounts of its reserved canonical operations in that
+// asset to users of the peer.
+func Exposure(
+	ctx context.Context,
+	host string,
+	asset string,
+) (*big.Int, error) {
+	ops, err := model.LoadCanonicalReservedOperationsByDestinationHost(ctx,
+		host)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	exposure := big.NewInt(0)
+	for _, op := range ops {
+		if op.Asset == asset {
+			exposure.Add(exposure, (*big.Int)(&op.Amount))
+		}
+	}
+	return exposure, nil
+}
+
+// CheckExposure returns a 403 user error if reserving an operation of the
+// provided amount of asset to the destination address would exceed the
+// exposure cap of its mint if any. It must be called within the DB
+// transaction reserving the operation: the asset is locked so that concurrent
+// reservations can't both fit under the cap.
+func CheckExposure(
+	ctx context.Context,
+	asset string,
+	destination string,
+	amount *big.Int,
+) error {
+	_, host, err := mint.UsernameAndMintHostFromAddress(ctx, destination)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if isLocal(ctx, host) {
+		return nil
+	}
+	peer, _, err := Lookup(ctx, host)
+	if err != nil {
+		return errors.Trace(err) // 500
+	}
+	if peer.ExposureCap == nil {
+		return nil
+	}
+
+	if a, err := model.LockCanonicalAssetByName(ctx, asset); err != nil {
+		return errors.Trace(err) // 500
+	} else if a == nil {
+		return errors.Trace(errors.Newf("Asset not found: %s", asset)) // 500
+	}
+
+	exposure, err := Exposure(ctx, host, asset)
+	if err != nil {
+		return errors.Trace(err) // 500
+	}
+	if new(big.Int).Add(exposure, amount).Cmp(peer.ExposureCap) > 0 {
+		rejectedRequests.Inc("exposure")
+		mint.Log(ctx, logging.LvWarn, "Rejected peer exposure", logging.Fields{
+			"peer":     host,
+			"asset":    asset,
+			"exposure": exposure.String(),
+			"amount":   amount.String(),
+			"cap":      peer.ExposureCap.String(),
+		})
+		return errors.Trace(errors.NewUserErrorf(nil,
+			403, "peer_exposure_exceeded",
+			"The operation would exceed the exposure cap of this mint to "+
+				"the mint %s in %s (%s).",
+			host, asset, peer.ExposureCap.String(),
+		))
+	}
+	return nil
+}
@@ -7,7 +7,9 @@ import (
 	"regexp"
 
 	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/lib/peering"
 	"github.com/spolu/settle/mint/model"
 	"golang.org/x/sync/errgroup"
 )
@@ -55,12 +57,24 @@ type TxPlan struct {
 }
 
 // Compute retrieves the offers of the path and compute the transaction plan.
+// The plans of pending transactions (being created) are refused if they
+// involve mints blocked by the peer policy of the current mint.
+//
+// Offers and their base assets are looked up through the client cache, except
+// for the offers of the current mint while the transaction is pending, as
+// this is the mint that is about to reserve a crossing on them.
 func Compute(
 	ctx context.Context,
 	client *mint.Client,
 	tx *model.Transaction,
 	shallow bool,
 ) (*TxPlan, error) {
+	if tx.Status == mint.TxStPending {
+		if err := CheckPeers(ctx, tx); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
 	g, ctx := errgroup.WithContext(ctx)
 	offers := make([]mint.OfferResource, len(tx.Path))
 
@@ -68,18 +82,28 @@ func Compute(
 		i, id := i, id
 		g.Go(func() error {
 			if !shallow {
-				offer, err := client.RetrieveOffer(ctx, id)
+				owner, _, err := mint.NormalizedOwnerAndTokenFromID(ctx, id)
+				if err != nil {
+					return errors.Trace(err)
+				}
+				_, host, err := mint.UsernameAndMintHostFromAddress(ctx, owner)
 				if err != nil {
 					return errors.Trace(err)
 				}
 
-				// Validate that the offer owner owns the base asset (enforced by
-				// offer creation but good defense in depth to validate here).
-				pair, err := mint.AssetResourcesFromPair(ctx, offer.Pair)
-				if offer.Owner != pair[0].Owner {
-					return errors.Newf(
-						"Offer/BaseAsset owner mismatch at offer %s: %s expected "+
-							"%s.", offer.ID, pair[0].Owner, offer.Owner)
+				var offer *mint.OfferResource
+				if tx.Status == mint.TxStPending && host == mint.GetHost(ctx) {
+					offer, err = client.RetrieveOffer(ctx, id)
+				} else {
+					offer, err = client.LookupOffer(ctx, id)
+				}
+				if err != nil {
+					return errors.Trace(err)
+				}
+
+				if err := validateOffer(ctx, client, offer); err != nil {
+					mint.InvalidateOffer(id)
+					return errors.Trace(err)
 				}
 
 				offers[i] = *offer
@@ -258,29 +282,90 @@ func Compute(
 		plan.Hops[hop-1].OpAction.Amount = amount
 	}
 
-	logLine := fmt.Sprintf("Transaction plan for %s:", plan.Transaction)
+	hops := []string{}
 	for i, h := range plan.Hops {
-		logLine += fmt.Sprintf("\n  [%d] mint=%s", i, h.Mint)
+		hop := fmt.Sprintf("[%d] mint=%s", i, h.Mint)
 		if h.OpAction != nil {
 			a := h.OpAction
-			logLine += fmt.Sprintf("\n    [%s] amount=%s asset=%s "+
-				"source=%s destination=%s ",
+			hop += fmt.Sprintf(" [%s] amount=%s asset=%s "+
+				"source=%s destination=%s",
 				a.Type, a.Amount.String(), *a.OperationAsset,
 				*a.OperationSource, *a.OperationDestination)
 		}
 		if h.CrAction != nil {
 			a := h.CrAction
-			logLine += fmt.Sprintf(
-				"\n    [%s ] amount=%s offer=%s pair=%s price=%s",
+			hop += fmt.Sprintf(
+				" [%s] amount=%s offer=%s pair=%s price=%s",
 				a.Type, a.Amount.String(), *a.CrossingOffer,
 				offers[i-offset-1].Pair, offers[i-offset-1].Price)
 		}
+		hops = append(hops, hop)
 	}
-	mint.Logf(ctx, logLine)
+	mint.Log(ctx, logging.LvInfo, "Transaction plan", logging.Fields{
+		"transaction": plan.Transaction,
+		"hops":        hops,
+	})
 
 	return &plan, nil
 }
 
+// validateOffer validates that the offer owner owns the base asset and that
+// it exists (enforced by offer creation but good defense in depth to validate
+// here).
+func validateOffer(
+	ctx context.Context,
+	client *mint.Client,
+	offer *mint.OfferResource,
+) error {
+	pair, err := mint.AssetResourcesFromPair(ctx, offer.Pair)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if offer.Owner != pair[0].Owner {
+		return errors.Newf(
+			"Offer/BaseAsset owner mismatch at offer %s: %s expected "+
+				"%s.", offer.ID, pair[0].Owner, offer.Owner)
+	}
+
+	if _, err := client.LookupAsset(ctx, pair[0].Name); err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+// CheckPeers checks the mints involved in a transaction against the peer
+// policy of the current mint, before any offer is retrieved from them. Plans
+// of already reserved transactions are not checked so that they can still be
+// settled or canceled after a peer gets blocked.
+func CheckPeers(
+	ctx context.Context,
+	tx *model.Transaction,
+) error {
+	addresses := []string{tx.Owner, tx.Destination}
+
+	bAsset, err := mint.AssetResourceFromName(ctx, tx.BaseAsset)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	addresses = append(addresses, bAsset.Owner)
+
+	for _, id := range tx.Path {
+		owner, _, err := mint.NormalizedOwnerAndTokenFromID(ctx, id)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		addresses = append(addresses, owner)
+	}
+
+	for _, address := range addresses {
+		if err := peering.CheckAddress(ctx, address); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
 // Check checks that the plan was properly executed at the specified hop by
 // retrieving the transaction ont that mint and checking the actions against
 // the advertised operations and crossings.
@@ -0,0 +1,259 @@
+package throttling
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/lib/respond"
+	"github.com/spolu/settle/mint"
+)
+
+type middleware struct {
+	http.Handler
+}
+
+// Rule matches requests by method and path.
+type Rule struct {
+	Method  string
+	Pattern *regexp.Regexp
+}
+
+// ExpensiveList is the list of endpoints subject to the ThClExpensive rate
+// limit.
+var ExpensiveList = []*Rule{
+	&Rule{"POST", regexp.MustCompile("^/transactions$")},
+	&Rule{"POST", regexp.MustCompile("^/transactions/[a-zA-Z0-9_\\+:@\\.\\[\\]]+$")},
+	&Rule{"POST", regexp.MustCompile("^/transactions/[a-zA-Z0-9_\\+:@\\.\\[\\]]+/settle$")},
+	&Rule{"POST", regexp.MustCompile("^/transactions/[a-zA-Z0-9_\\+:@\\.\\[\\]]+/cancel$")},
+}
+
+// PropagationList is the list of endpoints receiving the propagation of an
+// object by the mint it originates from, whose ID is the first submatch of
+// their pattern.
+var PropagationList = []*Rule{
+	&Rule{"POST", regexp.MustCompile("^/offers/([a-zA-Z0-9_\\+:@\\.\\[\\]]+)$")},
+	&Rule{"POST", regexp.MustCompile("^/operations/([a-zA-Z0-9_\\+:@\\.\\[\\]]+)$")},
+	&Rule{"POST", regexp.MustCompile("^/balances/([a-zA-Z0-9_\\+:@\\.\\[\\]]+)$")},
+	&Rule{"POST", regexp.MustCompile("^/transactions/([a-zA-Z0-9_\\+:@\\.\\[\\]]+)$")},
+	&Rule{"POST", regexp.MustCompile("^/transactions/([a-zA-Z0-9_\\+:@\\.\\[\\]]+)/settle$")},
+	&Rule{"POST", regexp.MustCompile("^/transactions/([a-zA-Z0-9_\\+:@\\.\\[\\]]+)/cancel$")},
+}
+
+// ClientIP returns the IP address of the client of a request.
+func ClientIP(
+	r *http.Request,
+) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// Peer returns the key identifying the calling peer of an unauthenticated
+// request. Propagation requests are keyed by the mint host the propagated
+// object originates from if the request comes from one of the addresses of
+// that host (so that a client can't consume the budget of another mint by
+// claiming its host), other requests by their client IP address.
+func Peer(
+	r *http.Request,
+) string {
+	ip := ClientIP(r)
+	for _, rule := range PropagationList {
+		if rule.Method != r.Method {
+			continue
+		}
+		m := rule.Pattern.FindStringSubmatch(r.URL.Path)
+		if len(m) == 0 {
+			continue
+		}
+		if host, ok := propagatingHost(r, m[1], ip); ok {
+			return "mint:" + host
+		}
+		break
+	}
+	return "ip:" + ip
+}
+
+// propagatingHost returns the mint host the object with the provided ID
+// originates from if ip is one of the addresses of that host.
+func propagatingHost(
+	r *http.Request,
+	id string,
+	ip string,
+) (string, bool) {
+	ctx := r.Context()
+	owner, _, err := mint.NormalizedOwnerAndTokenFromID(ctx, id)
+	if err != nil {
+		return "", false
+	}
+	_, host, err := mint.UsernameAndMintHostFromAddress(ctx, owner)
+	if err != nil {
+		return "", false
+	}
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+	for _, a := range lookupHost(ctx, hostname) {
+		if a == ip {
+			return host, true
+		}
+	}
+	return "", false
+}
+
+const (
+	// LookupTimeout is the maximum duration of the resolution of the host of
+	// a propagating mint.
+	LookupTimeout = 2 * time.Second
+	// LookupTTL is the duration for which the resolved addresses of a host
+	// (or the failure to resolve them) are cached.
+	LookupTTL = 5 * time.Minute
+	// MaxCachedLookups is the maximum number of hosts whose resolved addresses
+	// are cached.
+	MaxCachedLookups = 4096
+	// MaxPendingLookups is the maximum number of concurrent host resolutions.
+	// Propagation requests received while it is reached are keyed by their
+	// client IP address.
+	MaxPendingLookups = 16
+)
+
+type cachedLookup struct {
+	expiry time.Time
+	addrs  []string
+}
+
+// lookups caches the resolved addresses of the hosts of propagating mints.
+// Propagation requests are unauthenticated and resolved before any budget is
+// consumed, so that resolutions are cached, bounded in duration and in
+// number.
+var lookups = struct {
+	mutex   *sync.Mutex
+	hosts   map[string]cachedLookup
+	pending chan struct{}
+}{
+	mutex:   &sync.Mutex{},
+	hosts:   map[string]cachedLookup{},
+	pending: make(chan struct{}, MaxPendingLookups),
+}
+
+// lookupHost returns the addresses of hostname, from the cache if possible.
+// It returns nil if hostname can't be resolved or if too many resolutions are
+// already pending.
+func lookupHost(
+	ctx context.Context,
+	hostname string,
+) []string {
+	now := time.Now()
+
+	lookups.mutex.Lock()
+	l, ok := lookups.hosts[hostname]
+	lookups.mutex.Unlock()
+	if ok && now.Before(l.expiry) {
+		return l.addrs
+	}
+
+	select {
+	case lookups.pending <- struct{}{}:
+		defer func() { <-lookups.pending }()
+	default:
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, LookupTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, hostname)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Don't cache resolutions interrupted by the timeout or the
+			// client.
+			return nil
+		}
+		addrs = nil
+	}
+
+	lookups.mutex.Lock()
+	if _, ok := lookups.hosts[hostname]; !ok &&
+		len(lookups.hosts) >= MaxCachedLookups {
+		evictLookups(now)
+	}
+	lookups.hosts[hostname] = cachedLookup{now.Add(LookupTTL), addrs}
+	lookups.mutex.Unlock()
+
+	return addrs
+}
+
+// evictLookups removes the expired lookups from the cache, or the lookup
+// expiring first if none is. It must be called with the lookups mutex held.
+func evictLookups(
+	now time.Time,
+) {
+	first := ""
+	for h, l := range lookups.hosts {
+		if !now.Before(l.expiry) {
+			delete(lookups.hosts, h)
+		} else if first == "" || l.expiry.Before(lookups.hosts[first].expiry) {
+			first = h
+		}
+	}
+	if len(lookups.hosts) >= MaxCachedLookups {
+		delete(lookups.hosts, first)
+	}
+}
+
+// ServeHTTP handles incoming HTTP requests and rejects them if they exceed
+// the rate limits of their user (if authenticated) or calling peer.
+func (m middleware) ServeHTTP(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+
+	subject, ok := Get(ctx)
+	if !ok {
+		subject = Subject{ThClPeer, "peer:" + Peer(r)}
+	}
+	if subject.Class == "" {
+		m.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	classes := []ThClass{subject.Class}
+	for _, rule := range ExpensiveList {
+		if rule.Method == r.Method && rule.Pattern.MatchString(r.URL.Path) {
+			classes = append(classes, ThClExpensive)
+			break
+		}
+	}
+
+	for _, c := range classes {
+		wait, err := Allow(ctx, c, subject.Key)
+		if err != nil {
+			mint.Log(ctx, logging.LvWarn, "Throttled request", logging.Fields{
+				"class": c,
+				"key":   subject.Key,
+				"wait":  wait.Seconds(),
+			})
+			if wait > 0 {
+				SetRetryAfter(w, wait)
+			}
+			respond.Error(ctx, w, errors.Trace(err))
+			return
+		}
+	}
+
+	m.Handler.ServeHTTP(w, r)
+}
+
+// Middleware that rate limits API requests. It must be installed after the
+// authentication middleware.
+func Middleware(h http.Handler) http.Handler {
+	return middleware{h}
+}
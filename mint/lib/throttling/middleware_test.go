@@ -0,0 +1,50 @@
+package throttling
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupHost(
+	t *testing.T,
+) {
+	ctx := context.Background()
+
+	// Cached resolutions are served without resolving the host.
+	lookups.mutex.Lock()
+	lookups.hosts["mint.example.invalid"] = cachedLookup{
+		time.Now().Add(time.Minute), []string{"10.0.0.1"},
+	}
+	lookups.mutex.Unlock()
+	assert.Equal(t, []string{"10.0.0.1"},
+		lookupHost(ctx, "mint.example.invalid"))
+
+	// Hosts are not resolved while too many resolutions are pending.
+	for i := 0; i < MaxPendingLookups; i++ {
+		lookups.pending <- struct{}{}
+	}
+	assert.Nil(t, lookupHost(ctx, "other.example.invalid"))
+	for i := 0; i < MaxPendingLookups; i++ {
+		<-lookups.pending
+	}
+
+	// The cache is bounded.
+	lookups.mutex.Lock()
+	for i := 0; len(lookups.hosts) < MaxCachedLookups; i++ {
+		lookups.hosts[fmt.Sprintf("%d.example.invalid", i)] = cachedLookup{
+			time.Now().Add(time.Minute), nil,
+		}
+	}
+	lookups.mutex.Unlock()
+	lookupHost(ctx, "localhost")
+
+	lookups.mutex.Lock()
+	assert.Equal(t, MaxCachedLookups, len(lookups.hosts))
+	_, ok := lookups.hosts["localhost"]
+	lookups.mutex.Unlock()
+	assert.True(t, ok)
+}
@@ -0,0 +1,225 @@
+package throttling
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spolu/settle/lib/env"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/metrics"
+	"github.com/spolu/settle/lib/ratelimit"
+	"github.com/spolu/settle/mint"
+)
+
+// ThClass is a class of requests sharing the same rate limit.
+type ThClass string
+
+const (
+	// ThClUser limits the requests of an authenticated user.
+	ThClUser ThClass = "user"
+	// ThClPeer limits the unauthenticated requests (mostly propagation
+	// requests from other mints) of a calling peer.
+	ThClPeer ThClass = "peer"
+	// ThClExpensive limits the requests to expensive endpoints (transaction
+	// creation, settlement and cancellation) in addition to ThClUser or
+	// ThClPeer.
+	ThClExpensive ThClass = "expensive"
+	// ThClLogin limits the failed authentication attempts per username and
+	// client IP address, and the failed admin authentication attempts per
+	// client IP address.
+	ThClLogin ThClass = "login"
+)
+
+// DefaultLimits are the rate limits applied to each class of requests unless
+// overridden with mint.EnvCfgRateLimits.
+var DefaultLimits = map[ThClass]ratelimit.Rate{
+	ThClUser:      ratelimit.Rate{Count: 600, Period: time.Minute},
+	ThClPeer:      ratelimit.Rate{Count: 1200, Period: time.Minute},
+	ThClExpensive: ratelimit.Rate{Count: 60, Period: time.Minute},
+	ThClLogin:     ratelimit.Rate{Count: 10, Period: time.Minute},
+}
+
+// Subject identifies the budget a request is accounted against. Requests
+// with an empty class are exempted from rate limits.
+type Subject struct {
+	Class ThClass
+	Key   string
+}
+
+// ContextKey is the type of the key used with context to carry contextual
+// throttling information.
+type ContextKey string
+
+const (
+	// subjectKey the context.Context key to store the throttling subject.
+	subjectKey ContextKey = "throttling.subject"
+)
+
+// With stores the throttling subject of a request in a new context. It is
+// set by the authentication middleware, requests without subject being
+// accounted against the budget of their calling peer.
+func With(
+	ctx context.Context,
+	subject Subject,
+) context.Context {
+	return context.WithValue(ctx, subjectKey, subject)
+}
+
+// Get retrieves the throttling subject from the context if any.
+func Get(
+	ctx context.Context,
+) (Subject, bool) {
+	subject, ok := ctx.Value(subjectKey).(Subject)
+	return subject, ok
+}
+
+var throttledRequests = metrics.NewCounter(
+	"settle_mint_throttled_requests_total",
+	"Number of requests rejected by rate limits by class.",
+	"class")
+
+// ParseLimits parses a comma separated list of `class=rate` overriding the
+// default limits (for example `user=100/m,login=off`).
+func ParseLimits(
+	s string,
+) (map[ThClass]ratelimit.Rate, error) {
+	limits := map[ThClass]ratelimit.Rate{}
+	for c, r := range DefaultLimits {
+		limits[c] = r
+	}
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Trace(errors.Newf(
+				"Invalid rate limit: %s", part))
+		}
+		class := ThClass(strings.TrimSpace(kv[0]))
+		if _, ok := DefaultLimits[class]; !ok {
+			return nil, errors.Trace(errors.Newf(
+				"Unknown rate limit class: %s", class))
+		}
+		rate, err := ratelimit.ParseRate(kv[1])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		limits[class] = rate
+	}
+	return limits, nil
+}
+
+// limiters holds the limiters of the process, by class and rate so that a
+// change of configuration results in a new limiter, along with the limits
+// parsed from the last configuration seen.
+var limiters = struct {
+	mutex  *sync.Mutex
+	all    map[string]*ratelimit.Limiter
+	config string
+	limits map[ThClass]ratelimit.Rate
+}{
+	mutex: &sync.Mutex{},
+	all:   map[string]*ratelimit.Limiter{},
+}
+
+// limiterFor returns the limiter of the class as configured in the context.
+func limiterFor(
+	ctx context.Context,
+	class ThClass,
+) (*ratelimit.Limiter, error) {
+	config := env.Get(ctx).Config[mint.EnvCfgRateLimits]
+
+	limiters.mutex.Lock()
+	defer limiters.mutex.Unlock()
+
+	if limiters.limits == nil || limiters.config != config {
+		limits, err := ParseLimits(config)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		limiters.config = config
+		limiters.limits = limits
+	}
+	rate := limiters.limits[class]
+
+	key := fmt.Sprintf("%s|%s", class, rate)
+	l, ok := limiters.all[key]
+	if !ok {
+		l = ratelimit.NewLimiter(rate)
+		limiters.all[key] = l
+	}
+	return l, nil
+}
+
+// throttled returns the user error returned when a request is throttled.
+func throttled(
+	class ThClass,
+	rate ratelimit.Rate,
+	wait time.Duration,
+) error {
+	throttledRequests.Inc(string(class))
+	return errors.Trace(errors.NewUserErrorf(nil,
+		429, "rate_limited",
+		"You have exceeded the %s rate limit of this mint (%s). Retry in "+
+			"%d seconds.",
+		class, rate, RetryAfter(wait),
+	))
+}
+
+// RetryAfter returns the number of seconds to wait before retrying as
+// returned in the Retry-After header of throttled requests.
+func RetryAfter(
+	wait time.Duration,
+) int {
+	return int(math.Ceil(wait.Seconds()))
+}
+
+// Allow consumes one request of the budget of the key for the class. Keys
+// are scoped to the current mint host. It returns a 429 user error along
+// with the time to wait before retrying if the budget is exhausted.
+func Allow(
+	ctx context.Context,
+	class ThClass,
+	key string,
+) (time.Duration, error) {
+	l, err := limiterFor(ctx, class)
+	if err != nil {
+		return 0, errors.Trace(err) // 500
+	}
+	ok, wait := l.Allow(mint.GetHost(ctx)+"|"+key, time.Now())
+	if !ok {
+		return wait, throttled(class, l.Rate(), wait)
+	}
+	return 0, nil
+}
+
+// Check is similar to Allow but does not consume the budget of the key.
+func Check(
+	ctx context.Context,
+	class ThClass,
+	key string,
+) (time.Duration, error) {
+	l, err := limiterFor(ctx, class)
+	if err != nil {
+		return 0, errors.Trace(err) // 500
+	}
+	ok, wait := l.Check(mint.GetHost(ctx)+"|"+key, time.Now())
+	if !ok {
+		return wait, throttled(class, l.Rate(), wait)
+	}
+	return 0, nil
+}
+
+// SetRetryAfter sets the Retry-After header of a throttled response.
+func SetRetryAfter(
+	w http.ResponseWriter,
+	wait time.Duration,
+) {
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", RetryAfter(wait)))
+}
@@ -0,0 +1,217 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql/driver"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/spolu/settle/lib/clock"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/token"
+	"github.com/spolu/settle/mint"
+)
+
+// KeyScopes is a list of API key scopes and implements sql.Scanner and
+// driver.Valuer to be stored as a comma separated list.
+type KeyScopes []mint.KyScope
+
+// Value implements driver.Valuer.
+func (s KeyScopes) Value() (value driver.Value, err error) {
+	scopes := []string{}
+	for _, sc := range s {
+		scopes = append(scopes, string(sc))
+	}
+	return strings.Join(scopes, ","), nil
+}
+
+// Scan implements sql.Scanner.
+func (s *KeyScopes) Scan(src interface{}) error {
+	str := ""
+	switch src := src.(type) {
+	case []byte:
+		str = string(src)
+	case string:
+		str = src
+	default:
+		return errors.Newf("Incompatible type for KeyScopes with value: %q", src)
+	}
+	*s = KeyScopes{}
+	if len(str) > 0 {
+		for _, sc := range strings.Split(str, ",") {
+			*s = append(*s, mint.KyScope(sc))
+		}
+	}
+
+	return nil
+}
+
+// APIKey represents an API key granting a subset of the rights of its owner.
+// API keys are authenticated with a bearer credential of the form
+// `<token>.<secret>`, only the hash of the secret being stored.
+type APIKey struct {
+	Mint    string // Host of the mint the object belongs to.
+	Owner   string // Token of the user owning the key.
+	Token   string
+	Created time.Time
+
+	Name          string
+	SecretHash    string    `db:"secret_hash"`
+	Scopes        KeyScopes // Scopes granted to the key.
+	PayLimit      *Amount   `db:"pay_limit"`       // Maximum total spent.
+	PayLimitAsset *string   `db:"pay_limit_asset"` // Asset of the pay limit.
+	Expiry        *time.Time
+	Revoked       *time.Time
+}
+
+// NewAPIKeyResource generates a new resource.
+func NewAPIKeyResource(
+	ctx context.Context,
+	key *APIKey,
+) mint.APIKeyResource {
+	r := mint.APIKeyResource{
+		ID:      key.Token,
+		Created: key.Created.UnixNano() / mint.TimeResolutionNs,
+		Name:    key.Name,
+		Scopes:  []mint.KyScope(key.Scopes),
+	}
+	if key.PayLimit != nil {
+		r.PayLimit = (*big.Int)(key.PayLimit)
+		r.PayLimitAsset = key.PayLimitAsset
+	}
+	if key.Expiry != nil {
+		expiry := key.Expiry.UnixNano() / mint.TimeResolutionNs
+		r.Expiry = &expiry
+	}
+	if key.Revoked != nil {
+		revoked := key.Revoked.UnixNano() / mint.TimeResolutionNs
+		r.Revoked = &revoked
+	}
+	return r
+}
+
+// hashAPIKeySecret returns the hex encoded hash of an API key secret. Secrets
+// are random so a plain hash is sufficient to store them.
+func hashAPIKeySecret(
+	secret string,
+) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+// CreateAPIKey creates and stores a new APIKey for the provided user token. It
+// returns the secret of the key which is not stored and can't be retrieved
+// afterwards.
+func CreateAPIKey(
+	ctx context.Context,
+	owner string,
+	name string,
+	scopes []mint.KyScope,
+	payLimit *Amount,
+	payLimitAsset *string,
+	expiry *time.Time,
+) (*APIKey, string, error) {
+	secret := token.RandStr() + token.RandStr()
+
+	key := APIKey{
+		Mint:    mint.GetHost(ctx),
+		Owner:   owner,
+		Token:   token.New("key"),
+		Created: clock.Now(ctx).UTC(),
+
+		Name:          name,
+		SecretHash:    hashAPIKeySecret(secret),
+		Scopes:        KeyScopes(scopes),
+		PayLimit:      payLimit,
+		PayLimitAsset: payLimitAsset,
+	}
+	if expiry != nil {
+		e := expiry.UTC()
+		key.Expiry = &e
+	}
+
+	if err := getStore(ctx).APIKeys().Create(ctx, &key); err != nil {
+		return nil, "", errors.Trace(err)
+	}
+
+	return &key, secret, nil
+}
+
+// Save updates the object database representation with the in-memory values.
+// Only the revocation of a key can be updated.
+func (k *APIKey) Save(
+	ctx context.Context,
+) error {
+	return getStore(ctx).APIKeys().Save(ctx, k)
+}
+
+// LoadAPIKeyByToken attempts to load the API key with the given token.
+func LoadAPIKeyByToken(
+	ctx context.Context,
+	token string,
+) (*APIKey, error) {
+	return getStore(ctx).APIKeys().LoadByToken(ctx, token, false)
+}
+
+// LockAPIKeyByToken attempts to load the API key with the given token, locking
+// it until the end of the current transaction. Transactions created with a
+// key with a pay limit lock it first so that its limit is checked serially.
+func LockAPIKeyByToken(
+	ctx context.Context,
+	token string,
+) (*APIKey, error) {
+	return getStore(ctx).APIKeys().LoadByToken(ctx, token, true)
+}
+
+// LoadAPIKeyListByOwner loads the API keys of the provided user token.
+func LoadAPIKeyListByOwner(
+	ctx context.Context,
+	createdBefore time.Time,
+	limit uint,
+	owner string,
+) ([]APIKey, error) {
+	return getStore(ctx).APIKeys().ListByOwner(ctx,
+		owner, createdBefore, limit)
+}
+
+// CheckSecret checks if the provided secret matches the secret hash of the
+// key.
+func (k *APIKey) CheckSecret(
+	secret string,
+) error {
+	if subtle.ConstantTimeCompare(
+		[]byte(hashAPIKeySecret(secret)), []byte(k.SecretHash)) != 1 {
+		return errors.Newf("Secret mismatch")
+	}
+	return nil
+}
+
+// Active returns whether the key is neither revoked nor expired at the
+// provided time.
+func (k *APIKey) Active(
+	now time.Time,
+) bool {
+	if k.Revoked != nil {
+		return false
+	}
+	if k.Expiry != nil && !now.Before(*k.Expiry) {
+		return false
+	}
+	return true
+}
+
+// HasScope returns whether the key was granted the provided scope.
+func (k *APIKey) HasScope(
+	scope mint.KyScope,
+) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
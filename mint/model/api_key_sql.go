@@ -0,0 +1,129 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+)
+
+// sqlAPIKeys is the SQL APIKeyRepository.
+type sqlAPIKeys struct{}
+
+func (r sqlAPIKeys) Create(
+	ctx context.Context,
+	key *APIKey,
+) error {
+	ext := db.Ext(ctx, "mint")
+	if _, err := sqlx.NamedExec(ext, `
+INSERT INTO api_keys
+  (mint, owner, token, created, name, secret_hash, scopes, pay_limit,
+   pay_limit_asset, expiry, revoked)
+VALUES
+  (:mint, :owner, :token, :created, :name, :secret_hash, :scopes,
+   :pay_limit, :pay_limit_asset, :expiry, :revoked)
+`, key); err != nil {
+		return errors.Trace(sqlInsertError(err))
+	}
+
+	return nil
+}
+
+func (r sqlAPIKeys) Save(
+	ctx context.Context,
+	key *APIKey,
+) error {
+	ext := db.Ext(ctx, "mint")
+	_, err := sqlx.NamedExec(ext, `
+UPDATE api_keys
+SET revoked = :revoked
+WHERE mint = :mint
+  AND token = :token
+`, key)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+func (r sqlAPIKeys) LoadByToken(
+	ctx context.Context,
+	token string,
+	lock bool,
+) (*APIKey, error) {
+	key := APIKey{
+		Mint:  mint.GetHost(ctx),
+		Token: token,
+	}
+
+	forUpdate := ""
+	if lock {
+		forUpdate = db.ForUpdate(ctx, "mint")
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM api_keys
+WHERE mint = :mint
+  AND token = :token
+`+forUpdate, key); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		rows.Close()
+		return nil, nil
+	} else if err := rows.StructScan(&key); err != nil {
+		rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &key, nil
+}
+
+func (r sqlAPIKeys) ListByOwner(
+	ctx context.Context,
+	owner string,
+	createdBefore time.Time,
+	limit uint,
+) ([]APIKey, error) {
+	query := map[string]interface{}{
+		"mint":           mint.GetHost(ctx),
+		"owner":          owner,
+		"created_before": createdBefore.UTC(),
+		"limit":          limit,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM api_keys
+WHERE mint = :mint
+  AND owner = :owner
+  AND created < :created_before
+ORDER BY created DESC
+LIMIT :limit
+`, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	keys := []APIKey{}
+
+	defer rows.Close()
+	for rows.Next() {
+		k := APIKey{}
+		err := rows.StructScan(&k)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,333 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/clock"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/mint"
+)
+
+const (
+	// ArchiveFormat identifies mint archives.
+	ArchiveFormat string = "settle-mint-archive"
+	// ArchiveVersion is the current version of the archive format. Importers
+	// refuse archives with a different version, except version 1 archives
+	// which are upgraded (see ImportArchive).
+	ArchiveVersion int = 2
+)
+
+// ArEntryType is the type of an archive entry.
+type ArEntryType string
+
+const (
+	// ArEnTpHeader is the first entry of an archive.
+	ArEnTpHeader ArEntryType = "header"
+	// ArEnTpRecord is an entry holding one row of one table.
+	ArEnTpRecord ArEntryType = "record"
+	// ArEnTpTrailer is the last entry of an archive, used to detect truncated
+	// archives.
+	ArEnTpTrailer ArEntryType = "trailer"
+)
+
+// ArchiveEntry is one line of a mint archive. Archives are JSONL files
+// starting with a header, followed by one record per row of each table and
+// terminated by a trailer with the number of records exported per table.
+// Records are dialect independent so that an archive exported from a sqlite3
+// DB can be imported in a postgres one (and vice versa).
+type ArchiveEntry struct {
+	Type ArEntryType `json:"type"`
+
+	// Header.
+	Format  string   `json:"format,omitempty"`
+	Version int      `json:"version,omitempty"`
+	Created int64    `json:"created,omitempty"`
+	Host    string   `json:"host,omitempty"` // Version 1 only.
+	Hosts   []string `json:"hosts,omitempty"`
+	Tables  []string `json:"tables,omitempty"`
+
+	// Record.
+	Table  string           `json:"table,omitempty"`
+	Record *json.RawMessage `json:"record,omitempty"`
+
+	// Trailer.
+	Counts map[string]int `json:"counts,omitempty"`
+}
+
+// archiveTable describes how to dump and restore a table.
+type archiveTable struct {
+	Name   string
+	Order  string
+	New    func() interface{}
+	Insert string
+}
+
+// archiveTables is the list of tables part of an archive, in restoration
+// order.
+var archiveTables = []archiveTable{
+	{"users", "mint, created, token",
+		func() interface{} { return &User{} }, `
+INSERT INTO users
+  (mint, token, created, username, password_hash, disabled)
+VALUES
+  (:mint, :token, :created, :username, :password_hash, :disabled)
+`},
+	{"assets", "mint, created, owner, token",
+		func() interface{} { return &Asset{} }, `
+INSERT INTO assets
+  (mint, owner, token, created, propagation, code, scale)
+VALUES
+  (:mint, :owner, :token, :created, :propagation, :code, :scale)
+`},
+	{"operations", "mint, created, owner, token",
+		func() interface{} { return &Operation{} }, `
+INSERT INTO operations
+  (mint, owner, token, created, propagation, asset, source, destination,
+   amount, status, txn, hop)
+VALUES
+  (:mint, :owner, :token, :created, :propagation, :asset, :source,
+   :destination, :amount, :status, :txn, :hop)
+`},
+	{"balances", "mint, created, owner, token",
+		func() interface{} { return &Balance{} }, `
+INSERT INTO balances
+  (mint, owner, token, created, propagation, asset, holder, value)
+VALUES
+  (:mint, :owner, :token, :created, :propagation, :asset, :holder, :value)
+`},
+	{"offers", "mint, created, owner, token",
+		func() interface{} { return &Offer{} }, `
+INSERT INTO offers
+  (mint, owner, token, created, propagation, base_asset, quote_asset,
+   base_price, quote_price, amount, status, remainder)
+VALUES
+  (:mint, :owner, :token, :created, :propagation, :base_asset, :quote_asset,
+   :base_price, :quote_price, :amount, :status, :remainder)
+`},
+	{"transactions", "mint, created, owner, token",
+		func() interface{} { return &Transaction{} }, `
+INSERT INTO transactions
+  (mint, owner, token, created, propagation, base_asset, quote_asset,
+   amount, destination, path, status, lock, secret, trace, api_key)
+VALUES
+  (:mint, :owner, :token, :created, :propagation, :base_asset, :quote_asset,
+   :amount, :destination, :path, :status, :lock, :secret, :trace, :api_key)
+`},
+	{"crossings", "mint, created, owner, token",
+		func() interface{} { return &Crossing{} }, `
+INSERT INTO crossings
+  (mint, owner, token, created, propagation, offer, amount, status, txn,
+   hop)
+VALUES
+  (:mint, :owner, :token, :created, :propagation, :offer, :amount, :status,
+   :txn, :hop)
+`},
+	{"tasks", "mint, created, token",
+		func() interface{} { return &Task{} }, `
+INSERT INTO tasks
+  (mint, token, created, name, subject, payload, status, retry, deadline,
+   requeued, lease_owner, lease_expiry, last_error, trace)
+VALUES
+  (:mint, :token, :created, :name, :subject, :payload, :status, :retry,
+   :deadline, :requeued, :lease_owner, :lease_expiry, :last_error, :trace)
+`},
+	{"schedules", "mint, created, name",
+		func() interface{} { return &Schedule{} }, `
+INSERT INTO schedules
+  (mint, name, created, next, last, last_error, lease_owner, lease_expiry)
+VALUES
+  (:mint, :name, :created, :next, :last, :last_error, :lease_owner,
+   :lease_expiry)
+`},
+	{"api_keys", "mint, created, token",
+		func() interface{} { return &APIKey{} }, `
+INSERT INTO api_keys
+  (mint, owner, token, created, name, secret_hash, scopes, pay_limit,
+   pay_limit_asset, expiry, revoked)
+VALUES
+  (:mint, :owner, :token, :created, :name, :secret_hash, :scopes,
+   :pay_limit, :pay_limit_asset, :expiry, :revoked)
+`},
+	{"peers", "mint, created, host",
+		func() interface{} { return &Peer{} }, `
+INSERT INTO peers
+  (mint, host, created, policy, exposure_cap)
+VALUES
+  (:mint, :host, :created, :policy, :exposure_cap)
+`},
+}
+
+// ExportArchive writes an archive of all the mint tables to w. It must be
+// called within a snapshot transaction (see db.BeginSnapshot) for the archive
+// to be consistent while the mint is running. It returns the number of
+// records exported per table.
+func ExportArchive(
+	ctx context.Context,
+	w io.Writer,
+) (map[string]int, error) {
+	enc := json.NewEncoder(w)
+
+	tables := []string{}
+	for _, t := range archiveTables {
+		tables = append(tables, t.Name)
+	}
+	err := enc.Encode(ArchiveEntry{
+		Type:    ArEnTpHeader,
+		Format:  ArchiveFormat,
+		Version: ArchiveVersion,
+		Created: clock.Now(ctx).UnixNano() / mint.TimeResolutionNs,
+		Hosts:   mint.GetHosts(ctx),
+		Tables:  tables,
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	ext := db.Ext(ctx, "mint")
+	counts := map[string]int{}
+	for _, t := range archiveTables {
+		counts[t.Name] = 0
+		err := func() error {
+			rows, err := ext.Queryx(fmt.Sprintf(
+				"SELECT * FROM %s ORDER BY %s", t.Name, t.Order))
+			if err != nil {
+				return errors.Trace(err)
+			}
+			defer rows.Close()
+			for rows.Next() {
+				r := t.New()
+				if err := rows.StructScan(r); err != nil {
+					return errors.Trace(err)
+				}
+				err = enc.Encode(ArchiveEntry{
+					Type:   ArEnTpRecord,
+					Table:  t.Name,
+					Record: format.JSONPtr(r),
+				})
+				if err != nil {
+					return errors.Trace(err)
+				}
+				counts[t.Name]++
+			}
+			return errors.Trace(rows.Err())
+		}()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	err = enc.Encode(ArchiveEntry{
+		Type:   ArEnTpTrailer,
+		Counts: counts,
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return counts, nil
+}
+
+// ImportArchive restores an archive read from r. It must be called within a
+// transaction and refuses to restore into a DB that is not empty. It returns
+// the number of records imported per table.
+func ImportArchive(
+	ctx context.Context,
+	r io.Reader,
+) (map[string]int, error) {
+	ext := db.Ext(ctx, "mint")
+
+	tables := map[string]archiveTable{}
+	for _, t := range archiveTables {
+		var count int
+		if err := sqlx.Get(ext, &count, fmt.Sprintf(
+			"SELECT COUNT(*) FROM %s", t.Name)); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if count > 0 {
+			return nil, errors.Trace(errors.Newf(
+				"Table %s is not empty (%d rows), archives can only be "+
+					"imported in an empty DB.", t.Name, count))
+		}
+		tables[t.Name] = t
+	}
+
+	dec := json.NewDecoder(r)
+
+	var header ArchiveEntry
+	if err := dec.Decode(&header); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if header.Type != ArEnTpHeader || header.Format != ArchiveFormat {
+		return nil, errors.Trace(errors.Newf(
+			"Invalid archive: missing %s header", ArchiveFormat))
+	}
+	// Version 1 archives were exported by mints serving a single host, their
+	// records are not scoped by mint and are restored for that host.
+	upgrade := ""
+	switch header.Version {
+	case ArchiveVersion:
+	case 1:
+		if header.Host == "" {
+			return nil, errors.Trace(errors.Newf(
+				"Invalid archive: missing host in version 1 header"))
+		}
+		upgrade = header.Host
+	default:
+		return nil, errors.Trace(errors.Newf(
+			"Unsupported archive version: %d expected %d",
+			header.Version, ArchiveVersion))
+	}
+
+	counts := map[string]int{}
+	for {
+		var entry ArchiveEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			return nil, errors.Trace(errors.Newf(
+				"Invalid archive: missing trailer (truncated archive?)"))
+		} else if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		switch entry.Type {
+		case ArEnTpRecord:
+			t, ok := tables[entry.Table]
+			if !ok || entry.Record == nil {
+				return nil, errors.Trace(errors.Newf(
+					"Invalid archive record for table: %s", entry.Table))
+			}
+			rec := t.New()
+			if err := json.Unmarshal(*entry.Record, rec); err != nil {
+				return nil, errors.Trace(err)
+			}
+			if upgrade != "" {
+				reflect.ValueOf(rec).Elem().
+					FieldByName("Mint").SetString(upgrade)
+			}
+			if _, err := sqlx.NamedExec(ext, t.Insert, rec); err != nil {
+				return nil, errors.Trace(err)
+			}
+			counts[t.Name]++
+
+		case ArEnTpTrailer:
+			for name, count := range entry.Counts {
+				if counts[name] != count {
+					return nil, errors.Trace(errors.Newf(
+						"Invalid archive: %d records imported for table %s, "+
+							"%d expected", counts[name], name, count))
+				}
+			}
+			return counts, nil
+
+		default:
+			return nil, errors.Trace(errors.Newf(
+				"Invalid archive entry type: %s", entry.Type))
+		}
+	}
+}
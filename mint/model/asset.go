@@ -6,10 +6,7 @@ import (
 	"regexp"
 	"time"
 
-	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
-	sqlite3 "github.com/mattn/go-sqlite3"
-	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/token"
 	"github.com/spolu/settle/mint"
@@ -27,6 +24,7 @@ var AssetCodeRegexp = regexp.MustCompile("^[A-Z0-9\\-]{1,64}$")
 
 // Asset represents an asset object. Asset are created by users (issuer).
 type Asset struct {
+	Mint        string // Host of the mint the object belongs to.
 	Owner       string
 	Token       string
 	Created     time.Time
@@ -64,32 +62,17 @@ func CreateCanonicalAsset(
 	scale int8,
 ) (*Asset, error) {
 	asset := Asset{
+		Mint:        mint.GetHost(ctx),
 		Owner:       owner,
 		Token:       token.New("asset"),
-		Created:     time.Now().UTC(),
+		Created:     clock.Now(ctx).UTC(),
 		Propagation: mint.PgTpCanonical,
 
 		Code:  code,
 		Scale: scale,
 	}
 
-	ext := db.Ext(ctx, "mint")
-	if _, err := sqlx.NamedExec(ext, `
-INSERT INTO assets
-  (owner, token, created, propagation, code, scale)
-VALUES
-  (:owner, :token, :created, :propagation, :code, :scale)
-`, asset); err != nil {
-		switch err := err.(type) {
-		case *pq.Error:
-			if err.Code.Name() == "unique_violation" {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		case sqlite3.Error:
-			if err.ExtendedCode == sqlite3.ErrConstraintUnique {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		}
+	if err := getStore(ctx).Assets().Create(ctx, &asset); err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -104,33 +87,8 @@ func LoadCanonicalAssetByOwnerCodeScale(
 	code string,
 	scale int8,
 ) (*Asset, error) {
-	asset := Asset{
-		Owner:       owner,
-		Code:        code,
-		Scale:       scale,
-		Propagation: mint.PgTpCanonical,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM assets
-WHERE owner = :owner
-  AND code = :code
-  AND scale = :scale
-  AND propagation = :propagation
-`, asset); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&asset); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	return &asset, nil
+	return getStore(ctx).Assets().LoadByOwnerCodeScale(ctx,
+		owner, code, scale, mint.PgTpCanonical, false)
 }
 
 // LoadCanonicalAssetByName attempts to load an asset by its name.
@@ -147,6 +105,20 @@ func LoadCanonicalAssetByName(
 		r.Owner, r.Code, r.Scale)
 }
 
+// LockCanonicalAssetByName attempts to load and lock an asset by its name.
+func LockCanonicalAssetByName(
+	ctx context.Context,
+	name string,
+) (*Asset, error) {
+	r, err := mint.AssetResourceFromName(ctx, name)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return getStore(ctx).Assets().LoadByOwnerCodeScale(ctx,
+		r.Owner, r.Code, r.Scale, mint.PgTpCanonical, true)
+}
+
 // LoadAssetListByOwner loads an asset list by owner.
 func LoadAssetListByOwner(
 	ctx context.Context,
@@ -154,36 +126,6 @@ func LoadAssetListByOwner(
 	limit uint,
 	owner string,
 ) ([]Asset, error) {
-	query := map[string]interface{}{
-		"owner":          owner,
-		"created_before": createdBefore.UTC(),
-		"limit":          limit,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM assets
-WHERE owner = :owner
-AND created < :created_before
-ORDER BY created DESC
-LIMIT :limit
-`, query)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	assets := []Asset{}
-
-	defer rows.Close()
-	for rows.Next() {
-		a := Asset{}
-		err := rows.StructScan(&a)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		assets = append(assets, a)
-	}
-
-	return assets, nil
+	return getStore(ctx).Assets().ListByOwner(ctx,
+		owner, createdBefore, limit)
 }
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+)
+
+// sqlAssets is the SQL AssetRepository.
+type sqlAssets struct{}
+
+func (r sqlAssets) Create(
+	ctx context.Context,
+	asset *Asset,
+) error {
+	ext := db.Ext(ctx, "mint")
+	if _, err := sqlx.NamedExec(ext, `
+INSERT INTO assets
+  (mint, owner, token, created, propagation, code, scale)
+VALUES
+  (:mint, :owner, :token, :created, :propagation, :code, :scale)
+`, asset); err != nil {
+		return errors.Trace(sqlInsertError(err))
+	}
+
+	return nil
+}
+
+func (r sqlAssets) LoadByOwnerCodeScale(
+	ctx context.Context,
+	owner string,
+	code string,
+	scale int8,
+	propagation mint.PgType,
+	lock bool,
+) (*Asset, error) {
+	asset := Asset{
+		Mint:        mint.GetHost(ctx),
+		Owner:       owner,
+		Code:        code,
+		Scale:       scale,
+		Propagation: propagation,
+	}
+
+	forUpdate := ""
+	if lock {
+		forUpdate = db.ForUpdate(ctx, "mint")
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM assets
+WHERE mint = :mint
+  AND owner = :owner
+  AND code = :code
+  AND scale = :scale
+  AND propagation = :propagation
+`+forUpdate, asset); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		return nil, nil
+	} else if err := rows.StructScan(&asset); err != nil {
+		defer rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &asset, nil
+}
+
+func (r sqlAssets) ListByOwner(
+	ctx context.Context,
+	owner string,
+	createdBefore time.Time,
+	limit uint,
+) ([]Asset, error) {
+	query := map[string]interface{}{
+		"mint":           mint.GetHost(ctx),
+		"owner":          owner,
+		"created_before": createdBefore.UTC(),
+		"limit":          limit,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM assets
+WHERE mint = :mint
+  AND owner = :owner
+AND created < :created_before
+ORDER BY created DESC
+LIMIT :limit
+`, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	assets := []Asset{}
+
+	defer rows.Close()
+	for rows.Next() {
+		a := Asset{}
+		err := rows.StructScan(&a)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		assets = append(assets, a)
+	}
+
+	return assets, nil
+}
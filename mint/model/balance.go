@@ -6,10 +6,7 @@ import (
 	"math/big"
 	"time"
 
-	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
-	sqlite3 "github.com/mattn/go-sqlite3"
-	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/token"
 	"github.com/spolu/settle/mint"
@@ -22,6 +19,7 @@ import (
 // - Propagated balances are indicatively stored on the mints of the balances's
 //   holders.
 type Balance struct {
+	Mint        string // Host of the mint the object belongs to.
 	Owner       string
 	Token       string
 	Created     time.Time
@@ -59,9 +57,10 @@ func CreateCanonicalBalance(
 	value Amount,
 ) (*Balance, error) {
 	balance := Balance{
+		Mint:        mint.GetHost(ctx),
 		Owner:       owner,
 		Token:       token.New("balance"),
-		Created:     time.Now().UTC(),
+		Created:     clock.Now(ctx).UTC(),
 		Propagation: mint.PgTpCanonical,
 
 		Asset:  asset,
@@ -69,23 +68,7 @@ func CreateCanonicalBalance(
 		Value:  value,
 	}
 
-	ext := db.Ext(ctx, "mint")
-	if _, err := sqlx.NamedExec(ext, `
-INSERT INTO balances
-  (owner, token, created, propagation, asset, holder, value)
-VALUES
-  (:owner, :token, :created, :propagation, :asset, :holder, :value)
-`, balance); err != nil {
-		switch err := err.(type) {
-		case *pq.Error:
-			if err.Code.Name() == "unique_violation" {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		case sqlite3.Error:
-			if err.ExtendedCode == sqlite3.ErrConstraintUnique {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		}
+	if err := getStore(ctx).Balances().Create(ctx, &balance); err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -103,6 +86,7 @@ func CreatePropagatedBalance(
 	value Amount,
 ) (*Balance, error) {
 	balance := Balance{
+		Mint:        mint.GetHost(ctx),
 		Owner:       owner,
 		Token:       token,
 		Created:     created.UTC(),
@@ -113,23 +97,7 @@ func CreatePropagatedBalance(
 		Value:  value,
 	}
 
-	ext := db.Ext(ctx, "mint")
-	if _, err := sqlx.NamedExec(ext, `
-INSERT INTO balances
-  (owner, token, created, propagation, asset, holder, value)
-VALUES
-  (:owner, :token, :created, :propagation, :asset, :holder, :value)
-`, balance); err != nil {
-		switch err := err.(type) {
-		case *pq.Error:
-			if err.Code.Name() == "unique_violation" {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		case sqlite3.Error:
-			if err.ExtendedCode == sqlite3.ErrConstraintUnique {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		}
+	if err := getStore(ctx).Balances().Create(ctx, &balance); err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -145,18 +113,7 @@ func (b *Balance) ID() string {
 func (b *Balance) Save(
 	ctx context.Context,
 ) error {
-	ext := db.Ext(ctx, "mint")
-	_, err := sqlx.NamedExec(ext, `
-UPDATE balances
-SET value = :value
-WHERE owner = :owner
-  AND token = :token
-`, b)
-	if err != nil {
-		return errors.Trace(err)
-	}
-
-	return nil
+	return getStore(ctx).Balances().Save(ctx, b)
 }
 
 // LoadCanonicalBalanceByAssetHolder attempts to load a balance for the given
@@ -166,30 +123,20 @@ func LoadCanonicalBalanceByAssetHolder(
 	asset string,
 	holder string,
 ) (*Balance, error) {
-	balance := Balance{
-		Asset:       asset,
-		Holder:      holder,
-		Propagation: mint.PgTpCanonical,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM balances
-WHERE asset = :asset
-  AND holder = :holder
-`, balance); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&balance); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
-		return nil, errors.Trace(err)
-	}
+	return getStore(ctx).Balances().LoadByAssetHolder(ctx,
+		asset, holder, false)
+}
 
-	return &balance, nil
+// LockCanonicalBalanceByAssetHolder attempts to load a balance for the given
+// holder address and asset name, locking it until the end of the current
+// transaction.
+func LockCanonicalBalanceByAssetHolder(
+	ctx context.Context,
+	asset string,
+	holder string,
+) (*Balance, error) {
+	return getStore(ctx).Balances().LoadByAssetHolder(ctx,
+		asset, holder, true)
 }
 
 // LoadOrCreateCanonicalBalanceByAssetHolder loads an existing balance for the
@@ -214,6 +161,29 @@ func LoadOrCreateCanonicalBalanceByAssetHolder(
 	return balance, nil
 }
 
+// LockOrCreateCanonicalBalanceByAssetHolder locks an existing balance for the
+// specified asset and holder or creates one (with a 0 value) if it does not
+// exist. Concurrent creations of the same balance fail on a unique violation
+// which is a serialization failure (see db.Retry).
+func LockOrCreateCanonicalBalanceByAssetHolder(
+	ctx context.Context,
+	owner string,
+	asset string,
+	holder string,
+) (*Balance, error) {
+	balance, err := LockCanonicalBalanceByAssetHolder(ctx, asset, holder)
+	if err != nil {
+		return nil, errors.Trace(err)
+	} else if balance == nil {
+		balance, err = CreateCanonicalBalance(ctx,
+			owner, asset, holder, Amount{})
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return balance, nil
+}
+
 // LoadCanonicalBalanceByOwnerToken attempts to load a canonical balance for
 // the given owner and token.
 func LoadCanonicalBalanceByOwnerToken(
@@ -221,31 +191,8 @@ func LoadCanonicalBalanceByOwnerToken(
 	owner string,
 	token string,
 ) (*Balance, error) {
-	balance := Balance{
-		Owner:       owner,
-		Token:       token,
-		Propagation: mint.PgTpCanonical,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM balances
-WHERE owner = :owner
-  AND token = :token
-  AND propagation = :propagation
-`, balance); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&balance); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	return &balance, nil
+	return getStore(ctx).Balances().LoadByOwnerToken(ctx,
+		owner, token, mint.PgTpCanonical)
 }
 
 // LoadCanonicalBalanceByID attempts to load the canonical balanceffer for the
@@ -268,31 +215,8 @@ func LoadPropagatedBalanceByOwnerToken(
 	owner string,
 	token string,
 ) (*Balance, error) {
-	balance := Balance{
-		Owner:       owner,
-		Token:       token,
-		Propagation: mint.PgTpPropagated,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM balances
-WHERE owner = :owner
-  AND token = :token
-  AND propagation = :propagation
-`, balance); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&balance); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	return &balance, nil
+	return getStore(ctx).Balances().LoadByOwnerToken(ctx,
+		owner, token, mint.PgTpPropagated)
 }
 
 // LoadBalanceListByHolder loads a balance list by holder.
@@ -302,39 +226,8 @@ func LoadBalanceListByHolder(
 	limit uint,
 	holder string,
 ) ([]Balance, error) {
-	query := map[string]interface{}{
-		"holder":         holder,
-		"created_before": createdBefore.UTC(),
-		"limit":          limit,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM balances
-WHERE holder = :holder
-AND created < :created_before
-ORDER BY created DESC
-LIMIT :limit
-`, query)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	balances := []Balance{}
-
-	defer rows.Close()
-	for rows.Next() {
-		b := Balance{}
-		err := rows.StructScan(&b)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-
-		balances = append(balances, b)
-	}
-
-	return balances, nil
+	return getStore(ctx).Balances().ListByHolder(ctx,
+		holder, createdBefore, limit)
 }
 
 // LoadBalanceListByAsset loads a balance list by asset.
@@ -344,37 +237,6 @@ func LoadBalanceListByAsset(
 	limit uint,
 	asset string,
 ) ([]Balance, error) {
-	query := map[string]interface{}{
-		"asset":          asset,
-		"created_before": createdBefore.UTC(),
-		"limit":          limit,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM balances
-WHERE asset = :asset
-AND created < :created_before
-ORDER BY created DESC
-LIMIT :limit
-`, query)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	balances := []Balance{}
-
-	defer rows.Close()
-	for rows.Next() {
-		b := Balance{}
-		err := rows.StructScan(&b)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-
-		balances = append(balances, b)
-	}
-
-	return balances, nil
+	return getStore(ctx).Balances().ListByAsset(ctx,
+		asset, createdBefore, limit)
 }
@@ -0,0 +1,186 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+)
+
+// sqlBalances is the SQL BalanceRepository.
+type sqlBalances struct{}
+
+func (r sqlBalances) Create(
+	ctx context.Context,
+	balance *Balance,
+) error {
+	ext := db.Ext(ctx, "mint")
+	if _, err := sqlx.NamedExec(ext, `
+INSERT INTO balances
+  (mint, owner, token, created, propagation, asset, holder, value)
+VALUES
+  (:mint, :owner, :token, :created, :propagation, :asset, :holder, :value)
+`, balance); err != nil {
+		return errors.Trace(sqlInsertError(err))
+	}
+
+	return nil
+}
+
+func (r sqlBalances) Save(
+	ctx context.Context,
+	balance *Balance,
+) error {
+	ext := db.Ext(ctx, "mint")
+	_, err := sqlx.NamedExec(ext, `
+UPDATE balances
+SET value = :value
+WHERE mint = :mint
+  AND owner = :owner
+  AND token = :token
+`, balance)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+func (r sqlBalances) LoadByAssetHolder(
+	ctx context.Context,
+	asset string,
+	holder string,
+	lock bool,
+) (*Balance, error) {
+	balance := Balance{
+		Mint:   mint.GetHost(ctx),
+		Asset:  asset,
+		Holder: holder,
+	}
+
+	forUpdate := ""
+	if lock {
+		forUpdate = db.ForUpdate(ctx, "mint")
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM balances
+WHERE mint = :mint
+  AND asset = :asset
+  AND holder = :holder
+`+forUpdate, balance); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		return nil, nil
+	} else if err := rows.StructScan(&balance); err != nil {
+		defer rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &balance, nil
+}
+
+func (r sqlBalances) LoadByOwnerToken(
+	ctx context.Context,
+	owner string,
+	token string,
+	propagation mint.PgType,
+) (*Balance, error) {
+	balance := Balance{
+		Mint:        mint.GetHost(ctx),
+		Owner:       owner,
+		Token:       token,
+		Propagation: propagation,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM balances
+WHERE mint = :mint
+  AND owner = :owner
+  AND token = :token
+  AND propagation = :propagation
+`, balance); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		return nil, nil
+	} else if err := rows.StructScan(&balance); err != nil {
+		defer rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &balance, nil
+}
+
+func (r sqlBalances) ListByHolder(
+	ctx context.Context,
+	holder string,
+	createdBefore time.Time,
+	limit uint,
+) ([]Balance, error) {
+	return r.list(ctx, "holder", holder, createdBefore, limit)
+}
+
+func (r sqlBalances) ListByAsset(
+	ctx context.Context,
+	asset string,
+	createdBefore time.Time,
+	limit uint,
+) ([]Balance, error) {
+	return r.list(ctx, "asset", asset, createdBefore, limit)
+}
+
+// list loads a balance list filtered on the provided column.
+func (r sqlBalances) list(
+	ctx context.Context,
+	column string,
+	value string,
+	createdBefore time.Time,
+	limit uint,
+) ([]Balance, error) {
+	query := map[string]interface{}{
+		"mint":           mint.GetHost(ctx),
+		"value":          value,
+		"created_before": createdBefore.UTC(),
+		"limit":          limit,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM balances
+WHERE mint = :mint
+  AND `+column+` = :value
+  AND created < :created_before
+ORDER BY created DESC
+LIMIT :limit
+`, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	balances := []Balance{}
+
+	defer rows.Close()
+	for rows.Next() {
+		b := Balance{}
+		err := rows.StructScan(&b)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		balances = append(balances, b)
+	}
+
+	return balances, nil
+}
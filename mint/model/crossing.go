@@ -6,10 +6,7 @@ import (
 	"math/big"
 	"time"
 
-	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
-	sqlite3 "github.com/mattn/go-sqlite3"
-	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/token"
 	"github.com/spolu/settle/mint"
@@ -18,6 +15,7 @@ import (
 // Crossing represents a transaction crossing an offer and consuming some of
 // its amount. Crossings are not propagated.
 type Crossing struct {
+	Mint        string // Host of the mint the object belongs to.
 	Owner       string
 	Token       string
 	Created     time.Time
@@ -61,9 +59,10 @@ func CreateCanonicalCrossing(
 	hop int8,
 ) (*Crossing, error) {
 	crossing := Crossing{
+		Mint:        mint.GetHost(ctx),
 		Owner:       owner,
 		Token:       token.New("crossing"),
-		Created:     time.Now().UTC(),
+		Created:     clock.Now(ctx).UTC(),
 		Propagation: mint.PgTpCanonical,
 
 		Offer:  offer,
@@ -74,23 +73,7 @@ func CreateCanonicalCrossing(
 		Hop:         hop,
 	}
 
-	ext := db.Ext(ctx, "mint")
-	if _, err := sqlx.NamedExec(ext, `
-INSERT INTO crossings
-  (owner, token, created, propagation, offer, amount, status, txn, hop)
-VALUES
-  (:owner, :token, :created, :propagation, :offer, :amount, :status, :txn, :hop)
-`, crossing); err != nil {
-		switch err := err.(type) {
-		case *pq.Error:
-			if err.Code.Name() == "unique_violation" {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		case sqlite3.Error:
-			if err.ExtendedCode == sqlite3.ErrConstraintUnique {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		}
+	if err := getStore(ctx).Crossings().Create(ctx, &crossing); err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -106,18 +89,7 @@ func (c *Crossing) ID() string {
 func (c *Crossing) Save(
 	ctx context.Context,
 ) error {
-	ext := db.Ext(ctx, "mint")
-	_, err := sqlx.NamedExec(ext, `
-UPDATE crossings
-SET status = :status
-WHERE owner = :owner
-  AND token = :token
-`, c)
-	if err != nil {
-		return errors.Trace(err)
-	}
-
-	return nil
+	return getStore(ctx).Crossings().Save(ctx, c)
 }
 
 // LoadCanonicalCrossingByTransactionHop attempts to load the crossing for the
@@ -127,31 +99,8 @@ func LoadCanonicalCrossingByTransactionHop(
 	transaction string,
 	hop int8,
 ) (*Crossing, error) {
-	crossing := Crossing{
-		Transaction: transaction,
-		Hop:         hop,
-		Propagation: mint.PgTpCanonical,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM crossings
-WHERE txn = :txn
-  AND hop = :hop
-  AND propagation = :propagation
-`, crossing); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&crossing); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	return &crossing, nil
+	return getStore(ctx).Crossings().LoadByTransactionHop(ctx,
+		transaction, hop, mint.PgTpCanonical)
 }
 
 // LoadCanonicalCrossingsByTransaction loads all crossings that are associated
@@ -160,33 +109,17 @@ func LoadCanonicalCrossingsByTransaction(
 	ctx context.Context,
 	transaction string,
 ) ([]*Crossing, error) {
-	query := map[string]interface{}{
-		"txn":         transaction,
-		"propagation": mint.PgTpCanonical,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM crossings
-WHERE txn = :txn
-  AND propagation = :propagation
-`, query)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	crossings := []*Crossing{}
-
-	defer rows.Close()
-	for rows.Next() {
-		cr := Crossing{}
-		err := rows.StructScan(&cr)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		crossings = append(crossings, &cr)
-	}
+	return getStore(ctx).Crossings().ListByTransaction(ctx,
+		transaction, mint.PgTpCanonical)
+}
 
-	return crossings, nil
+// LoadCrossingListByOffer loads the list of crossings of the specified offer.
+func LoadCrossingListByOffer(
+	ctx context.Context,
+	createdBefore time.Time,
+	limit uint,
+	offer string,
+) ([]*Crossing, error) {
+	return getStore(ctx).Crossings().ListByOffer(ctx,
+		offer, createdBefore, limit)
 }
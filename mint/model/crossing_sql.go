@@ -0,0 +1,167 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+)
+
+// sqlCrossings is the SQL CrossingRepository.
+type sqlCrossings struct{}
+
+func (r sqlCrossings) Create(
+	ctx context.Context,
+	crossing *Crossing,
+) error {
+	ext := db.Ext(ctx, "mint")
+	if _, err := sqlx.NamedExec(ext, `
+INSERT INTO crossings
+  (mint, owner, token, created, propagation, offer, amount, status, txn,
+   hop)
+VALUES
+  (:mint, :owner, :token, :created, :propagation, :offer, :amount, :status,
+   :txn, :hop)
+`, crossing); err != nil {
+		return errors.Trace(sqlInsertError(err))
+	}
+
+	return nil
+}
+
+func (r sqlCrossings) Save(
+	ctx context.Context,
+	crossing *Crossing,
+) error {
+	ext := db.Ext(ctx, "mint")
+	_, err := sqlx.NamedExec(ext, `
+UPDATE crossings
+SET status = :status
+WHERE mint = :mint
+  AND owner = :owner
+  AND token = :token
+`, crossing)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+func (r sqlCrossings) LoadByTransactionHop(
+	ctx context.Context,
+	transaction string,
+	hop int8,
+	propagation mint.PgType,
+) (*Crossing, error) {
+	crossing := Crossing{
+		Mint:        mint.GetHost(ctx),
+		Transaction: transaction,
+		Hop:         hop,
+		Propagation: propagation,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM crossings
+WHERE mint = :mint
+  AND txn = :txn
+  AND hop = :hop
+  AND propagation = :propagation
+`, crossing); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		return nil, nil
+	} else if err := rows.StructScan(&crossing); err != nil {
+		defer rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &crossing, nil
+}
+
+func (r sqlCrossings) ListByTransaction(
+	ctx context.Context,
+	transaction string,
+	propagation mint.PgType,
+) ([]*Crossing, error) {
+	query := map[string]interface{}{
+		"mint":        mint.GetHost(ctx),
+		"txn":         transaction,
+		"propagation": propagation,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM crossings
+WHERE mint = :mint
+  AND txn = :txn
+  AND propagation = :propagation
+`, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	crossings := []*Crossing{}
+
+	defer rows.Close()
+	for rows.Next() {
+		cr := Crossing{}
+		err := rows.StructScan(&cr)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		crossings = append(crossings, &cr)
+	}
+
+	return crossings, nil
+}
+
+func (r sqlCrossings) ListByOffer(
+	ctx context.Context,
+	offer string,
+	createdBefore time.Time,
+	limit uint,
+) ([]*Crossing, error) {
+	query := map[string]interface{}{
+		"mint":           mint.GetHost(ctx),
+		"offer":          offer,
+		"created_before": createdBefore.UTC(),
+		"limit":          limit,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM crossings
+WHERE mint = :mint
+  AND offer = :offer
+  AND created < :created_before
+ORDER BY created DESC
+LIMIT :limit
+`, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	crossings := []*Crossing{}
+
+	defer rows.Close()
+	for rows.Next() {
+		cr := Crossing{}
+		err := rows.StructScan(&cr)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		crossings = append(crossings, &cr)
+	}
+
+	return crossings, nil
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/spolu/settle/lib/clock"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/token"
+	"github.com/spolu/settle/mint"
+)
+
+// HealthCheck is a row written by readiness checks to verify that the DB
+// accepts writes. Readiness checks roll back their transaction so that health
+// checks are never actually stored.
+type HealthCheck struct {
+	Mint    string
+	Token   string
+	Created time.Time
+}
+
+// CreateHealthCheck creates and stores a new HealthCheck.
+func CreateHealthCheck(
+	ctx context.Context,
+) (*HealthCheck, error) {
+	check := HealthCheck{
+		Mint:    mint.GetHost(ctx),
+		Token:   token.New("health"),
+		Created: clock.Now(ctx).UTC(),
+	}
+
+	if err := getStore(ctx).HealthChecks().Create(ctx, &check); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &check, nil
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+)
+
+// sqlHealthChecks is the SQL HealthCheckRepository.
+type sqlHealthChecks struct{}
+
+func (r sqlHealthChecks) Create(
+	ctx context.Context,
+	check *HealthCheck,
+) error {
+	ext := db.Ext(ctx, "mint")
+	if _, err := sqlx.NamedExec(ext, `
+INSERT INTO health_checks
+  (mint, token, created)
+VALUES
+  (:mint, :token, :created)
+`, check); err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+// apiKeys is the in-memory model.APIKeyRepository.
+type apiKeys struct {
+	store *Store
+}
+
+func (r apiKeys) Create(
+	ctx context.Context,
+	key *model.APIKey,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		if _, ok := t.apiKeys[key.Token]; ok {
+			return uniqueViolation("api_keys_pkey")
+		}
+		t.apiKeys[key.Token] = cloneAPIKey(key)
+		return nil
+	})
+}
+
+func (r apiKeys) Save(
+	ctx context.Context,
+	key *model.APIKey,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		if k, ok := t.apiKeys[key.Token]; ok && k.Mint == key.Mint {
+			k = cloneAPIKey(k)
+			k.Revoked = cloneTime(key.Revoked)
+			t.apiKeys[key.Token] = k
+		}
+		return nil
+	})
+}
+
+func (r apiKeys) LoadByToken(
+	ctx context.Context,
+	token string,
+	lock bool,
+) (*model.APIKey, error) {
+	var key *model.APIKey
+	err := r.store.load(ctx, lock, func(t *tables) error {
+		k, ok := t.apiKeys[token]
+		if ok && k.Mint == mint.GetHost(ctx) {
+			key = cloneAPIKey(k)
+		}
+		return nil
+	})
+	return key, err
+}
+
+func (r apiKeys) ListByOwner(
+	ctx context.Context,
+	owner string,
+	createdBefore time.Time,
+	limit uint,
+) ([]model.APIKey, error) {
+	list := []model.APIKey{}
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, k := range t.apiKeys {
+			if k.Mint == mint.GetHost(ctx) && k.Owner == owner &&
+				k.Created.Before(createdBefore) {
+				list = append(list, *cloneAPIKey(k))
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return newer(list[i].Created, list[i].Token,
+			list[j].Created, list[j].Token)
+	})
+	if uint(len(list)) > limit {
+		list = list[:limit]
+	}
+	return list, err
+}
+
+// cloneAPIKey returns a copy of the API key.
+func cloneAPIKey(
+	key *model.APIKey,
+) *model.APIKey {
+	c := *key
+	c.Scopes = append(model.KeyScopes{}, key.Scopes...)
+	c.PayLimit = cloneAmountPtr(key.PayLimit)
+	c.PayLimitAsset = cloneString(key.PayLimitAsset)
+	c.Expiry = cloneTime(key.Expiry)
+	c.Revoked = cloneTime(key.Revoked)
+	return &c
+}
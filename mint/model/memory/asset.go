@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+// assets is the in-memory model.AssetRepository.
+type assets struct {
+	store *Store
+}
+
+func (r assets) Create(
+	ctx context.Context,
+	asset *model.Asset,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		k := key{asset.Mint, asset.Owner, asset.Token}
+		if _, ok := t.assets[k]; ok {
+			return uniqueViolation("assets_pkey")
+		}
+		for _, a := range t.assets {
+			if a.Mint == asset.Mint && a.Owner == asset.Owner &&
+				a.Code == asset.Code && a.Scale == asset.Scale {
+				return uniqueViolation("assets_owner_code_scale_u")
+			}
+		}
+		t.assets[k] = cloneAsset(asset)
+		return nil
+	})
+}
+
+func (r assets) LoadByOwnerCodeScale(
+	ctx context.Context,
+	owner string,
+	code string,
+	scale int8,
+	propagation mint.PgType,
+	lock bool,
+) (*model.Asset, error) {
+	var asset *model.Asset
+	err := r.store.load(ctx, lock, func(t *tables) error {
+		for _, a := range t.assets {
+			if a.Mint == mint.GetHost(ctx) && a.Owner == owner &&
+				a.Code == code && a.Scale == scale &&
+				a.Propagation == propagation {
+				asset = cloneAsset(a)
+			}
+		}
+		return nil
+	})
+	return asset, err
+}
+
+func (r assets) ListByOwner(
+	ctx context.Context,
+	owner string,
+	createdBefore time.Time,
+	limit uint,
+) ([]model.Asset, error) {
+	list := []model.Asset{}
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, a := range t.assets {
+			if a.Mint == mint.GetHost(ctx) && a.Owner == owner &&
+				a.Created.Before(createdBefore) {
+				list = append(list, *cloneAsset(a))
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return newer(list[i].Created, list[i].Token,
+			list[j].Created, list[j].Token)
+	})
+	if uint(len(list)) > limit {
+		list = list[:limit]
+	}
+	return list, err
+}
+
+// cloneAsset returns a copy of the asset.
+func cloneAsset(
+	asset *model.Asset,
+) *model.Asset {
+	c := *asset
+	return &c
+}
@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+// balances is the in-memory model.BalanceRepository.
+type balances struct {
+	store *Store
+}
+
+func (r balances) Create(
+	ctx context.Context,
+	balance *model.Balance,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		k := key{balance.Mint, balance.Owner, balance.Token}
+		if _, ok := t.balances[k]; ok {
+			return uniqueViolation("balances_pkey")
+		}
+		for _, b := range t.balances {
+			if b.Mint == balance.Mint && b.Asset == balance.Asset &&
+				b.Holder == balance.Holder {
+				return uniqueViolation("balances_asset_holder_u")
+			}
+		}
+		t.balances[k] = cloneBalance(balance)
+		return nil
+	})
+}
+
+func (r balances) Save(
+	ctx context.Context,
+	balance *model.Balance,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		k := key{balance.Mint, balance.Owner, balance.Token}
+		if b, ok := t.balances[k]; ok {
+			b = cloneBalance(b)
+			b.Value = cloneAmount(balance.Value)
+			t.balances[k] = b
+		}
+		return nil
+	})
+}
+
+func (r balances) LoadByAssetHolder(
+	ctx context.Context,
+	asset string,
+	holder string,
+	lock bool,
+) (*model.Balance, error) {
+	var balance *model.Balance
+	err := r.store.load(ctx, lock, func(t *tables) error {
+		for _, b := range t.balances {
+			if b.Mint == mint.GetHost(ctx) && b.Asset == asset &&
+				b.Holder == holder {
+				balance = cloneBalance(b)
+			}
+		}
+		return nil
+	})
+	return balance, err
+}
+
+func (r balances) LoadByOwnerToken(
+	ctx context.Context,
+	owner string,
+	token string,
+	propagation mint.PgType,
+) (*model.Balance, error) {
+	var balance *model.Balance
+	err := r.store.view(ctx, func(t *tables) error {
+		b, ok := t.balances[key{mint.GetHost(ctx), owner, token}]
+		if ok && b.Propagation == propagation {
+			balance = cloneBalance(b)
+		}
+		return nil
+	})
+	return balance, err
+}
+
+func (r balances) ListByHolder(
+	ctx context.Context,
+	holder string,
+	createdBefore time.Time,
+	limit uint,
+) ([]model.Balance, error) {
+	return r.list(ctx, func(b *model.Balance) bool {
+		return b.Holder == holder
+	}, createdBefore, limit)
+}
+
+func (r balances) ListByAsset(
+	ctx context.Context,
+	asset string,
+	createdBefore time.Time,
+	limit uint,
+) ([]model.Balance, error) {
+	return r.list(ctx, func(b *model.Balance) bool {
+		return b.Asset == asset
+	}, createdBefore, limit)
+}
+
+// list loads a balance list filtered by match.
+func (r balances) list(
+	ctx context.Context,
+	match func(b *model.Balance) bool,
+	createdBefore time.Time,
+	limit uint,
+) ([]model.Balance, error) {
+	list := []model.Balance{}
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, b := range t.balances {
+			if b.Mint == mint.GetHost(ctx) && match(b) &&
+				b.Created.Before(createdBefore) {
+				list = append(list, *cloneBalance(b))
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return newer(list[i].Created, list[i].Token,
+			list[j].Created, list[j].Token)
+	})
+	if uint(len(list)) > limit {
+		list = list[:limit]
+	}
+	return list, err
+}
+
+// cloneBalance returns a copy of the balance.
+func cloneBalance(
+	balance *model.Balance,
+) *model.Balance {
+	c := *balance
+	c.Value = cloneAmount(balance.Value)
+	return &c
+}
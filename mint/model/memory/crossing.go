@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+// crossings is the in-memory model.CrossingRepository.
+type crossings struct {
+	store *Store
+}
+
+func (r crossings) Create(
+	ctx context.Context,
+	crossing *model.Crossing,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		k := key{crossing.Mint, crossing.Owner, crossing.Token}
+		if _, ok := t.crossings[k]; ok {
+			return uniqueViolation("crossings_pkey")
+		}
+		t.crossings[k] = cloneCrossing(crossing)
+		return nil
+	})
+}
+
+func (r crossings) Save(
+	ctx context.Context,
+	crossing *model.Crossing,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		k := key{crossing.Mint, crossing.Owner, crossing.Token}
+		if c, ok := t.crossings[k]; ok {
+			c = cloneCrossing(c)
+			c.Status = crossing.Status
+			t.crossings[k] = c
+		}
+		return nil
+	})
+}
+
+func (r crossings) LoadByTransactionHop(
+	ctx context.Context,
+	transaction string,
+	hop int8,
+	propagation mint.PgType,
+) (*model.Crossing, error) {
+	var crossing *model.Crossing
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, c := range t.crossings {
+			if c.Mint == mint.GetHost(ctx) && c.Transaction == transaction &&
+				c.Hop == hop && c.Propagation == propagation {
+				crossing = cloneCrossing(c)
+			}
+		}
+		return nil
+	})
+	return crossing, err
+}
+
+func (r crossings) ListByTransaction(
+	ctx context.Context,
+	transaction string,
+	propagation mint.PgType,
+) ([]*model.Crossing, error) {
+	list := []*model.Crossing{}
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, c := range t.crossings {
+			if c.Mint == mint.GetHost(ctx) && c.Transaction == transaction &&
+				c.Propagation == propagation {
+				list = append(list, cloneCrossing(c))
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return newer(list[j].Created, list[j].Token,
+			list[i].Created, list[i].Token)
+	})
+	return list, err
+}
+
+func (r crossings) ListByOffer(
+	ctx context.Context,
+	offer string,
+	createdBefore time.Time,
+	limit uint,
+) ([]*model.Crossing, error) {
+	list := []*model.Crossing{}
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, c := range t.crossings {
+			if c.Mint == mint.GetHost(ctx) && c.Offer == offer &&
+				c.Created.Before(createdBefore) {
+				list = append(list, cloneCrossing(c))
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return newer(list[i].Created, list[i].Token,
+			list[j].Created, list[j].Token)
+	})
+	if uint(len(list)) > limit {
+		list = list[:limit]
+	}
+	return list, err
+}
+
+// cloneCrossing returns a copy of the crossing.
+func cloneCrossing(
+	crossing *model.Crossing,
+) *model.Crossing {
+	c := *crossing
+	c.Amount = cloneAmount(crossing.Amount)
+	return &c
+}
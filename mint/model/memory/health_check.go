@@ -0,0 +1,26 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/spolu/settle/mint/model"
+)
+
+// healthChecks is the in-memory model.HealthCheckRepository.
+type healthChecks struct {
+	store *Store
+}
+
+func (r healthChecks) Create(
+	ctx context.Context,
+	check *model.HealthCheck,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		if _, ok := t.healthChecks[check.Token]; ok {
+			return uniqueViolation("health_checks_pkey")
+		}
+		c := *check
+		t.healthChecks[check.Token] = &c
+		return nil
+	})
+}
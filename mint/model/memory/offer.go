@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+// offers is the in-memory model.OfferRepository.
+type offers struct {
+	store *Store
+}
+
+func (r offers) Create(
+	ctx context.Context,
+	offer *model.Offer,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		k := key{offer.Mint, offer.Owner, offer.Token}
+		if _, ok := t.offers[k]; ok {
+			return uniqueViolation("offers_pkey")
+		}
+		t.offers[k] = cloneOffer(offer)
+		return nil
+	})
+}
+
+func (r offers) Save(
+	ctx context.Context,
+	offer *model.Offer,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		k := key{offer.Mint, offer.Owner, offer.Token}
+		if o, ok := t.offers[k]; ok {
+			o = cloneOffer(o)
+			o.Status = offer.Status
+			o.Remainder = cloneAmount(offer.Remainder)
+			t.offers[k] = o
+		}
+		return nil
+	})
+}
+
+func (r offers) LoadByOwnerToken(
+	ctx context.Context,
+	owner string,
+	token string,
+	propagation mint.PgType,
+	lock bool,
+) (*model.Offer, error) {
+	var offer *model.Offer
+	err := r.store.load(ctx, lock, func(t *tables) error {
+		o, ok := t.offers[key{mint.GetHost(ctx), owner, token}]
+		if ok && o.Propagation == propagation {
+			offer = cloneOffer(o)
+		}
+		return nil
+	})
+	return offer, err
+}
+
+func (r offers) ListByBaseAsset(
+	ctx context.Context,
+	asset string,
+	createdBefore time.Time,
+	limit uint,
+) ([]model.Offer, error) {
+	return r.list(ctx, func(o *model.Offer) bool {
+		return o.BaseAsset == asset
+	}, createdBefore, limit)
+}
+
+func (r offers) ListByQuoteAsset(
+	ctx context.Context,
+	asset string,
+	createdBefore time.Time,
+	limit uint,
+) ([]model.Offer, error) {
+	return r.list(ctx, func(o *model.Offer) bool {
+		return o.QuoteAsset == asset
+	}, createdBefore, limit)
+}
+
+func (r offers) ListByOwner(
+	ctx context.Context,
+	owner string,
+	propagation mint.PgType,
+	createdBefore time.Time,
+	limit uint,
+) ([]model.Offer, error) {
+	return r.list(ctx, func(o *model.Offer) bool {
+		return o.Owner == owner && o.Propagation == propagation
+	}, createdBefore, limit)
+}
+
+// list loads an offer list filtered by match.
+func (r offers) list(
+	ctx context.Context,
+	match func(o *model.Offer) bool,
+	createdBefore time.Time,
+	limit uint,
+) ([]model.Offer, error) {
+	list := []model.Offer{}
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, o := range t.offers {
+			if o.Mint == mint.GetHost(ctx) && match(o) &&
+				o.Created.Before(createdBefore) {
+				list = append(list, *cloneOffer(o))
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return newer(list[i].Created, list[i].Token,
+			list[j].Created, list[j].Token)
+	})
+	if uint(len(list)) > limit {
+		list = list[:limit]
+	}
+	return list, err
+}
+
+// cloneOffer returns a copy of the offer.
+func cloneOffer(
+	offer *model.Offer,
+) *model.Offer {
+	c := *offer
+	c.BasePrice = cloneAmount(offer.BasePrice)
+	c.QuotePrice = cloneAmount(offer.QuotePrice)
+	c.Amount = cloneAmount(offer.Amount)
+	c.Remainder = cloneAmount(offer.Remainder)
+	return &c
+}
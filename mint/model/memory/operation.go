@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+// operations is the in-memory model.OperationRepository.
+type operations struct {
+	store *Store
+}
+
+func (r operations) Create(
+	ctx context.Context,
+	operation *model.Operation,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		k := key{operation.Mint, operation.Owner, operation.Token}
+		if _, ok := t.operations[k]; ok {
+			return uniqueViolation("operations_pkey")
+		}
+		t.operations[k] = cloneOperation(operation)
+		return nil
+	})
+}
+
+func (r operations) Save(
+	ctx context.Context,
+	operation *model.Operation,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		k := key{operation.Mint, operation.Owner, operation.Token}
+		if o, ok := t.operations[k]; ok {
+			o = cloneOperation(o)
+			o.Status = operation.Status
+			t.operations[k] = o
+		}
+		return nil
+	})
+}
+
+func (r operations) LoadByOwnerToken(
+	ctx context.Context,
+	owner string,
+	token string,
+	propagation mint.PgType,
+) (*model.Operation, error) {
+	var operation *model.Operation
+	err := r.store.view(ctx, func(t *tables) error {
+		o, ok := t.operations[key{mint.GetHost(ctx), owner, token}]
+		if ok && o.Propagation == propagation {
+			operation = cloneOperation(o)
+		}
+		return nil
+	})
+	return operation, err
+}
+
+func (r operations) LoadByTransactionHop(
+	ctx context.Context,
+	transaction string,
+	hop int8,
+) (*model.Operation, error) {
+	var operation *model.Operation
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, o := range t.operations {
+			if o.Mint == mint.GetHost(ctx) &&
+				o.Transaction != nil && *o.Transaction == transaction &&
+				o.Hop != nil && *o.Hop == hop {
+				operation = cloneOperation(o)
+			}
+		}
+		return nil
+	})
+	return operation, err
+}
+
+func (r operations) ListByTransaction(
+	ctx context.Context,
+	transaction string,
+	propagation mint.PgType,
+) ([]*model.Operation, error) {
+	return r.list(ctx, func(o *model.Operation) bool {
+		return o.Transaction != nil && *o.Transaction == transaction &&
+			o.Propagation == propagation
+	})
+}
+
+func (r operations) ListByDestinationHost(
+	ctx context.Context,
+	host string,
+	propagation mint.PgType,
+	status mint.TxStatus,
+) ([]*model.Operation, error) {
+	return r.list(ctx, func(o *model.Operation) bool {
+		return strings.HasSuffix(o.Destination, "@"+host) &&
+			o.Propagation == propagation && o.Status == status
+	})
+}
+
+// list loads the operations matching match, from the least to the most
+// recently created.
+func (r operations) list(
+	ctx context.Context,
+	match func(o *model.Operation) bool,
+) ([]*model.Operation, error) {
+	list := []*model.Operation{}
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, o := range t.operations {
+			if o.Mint == mint.GetHost(ctx) && match(o) {
+				list = append(list, cloneOperation(o))
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return newer(list[j].Created, list[j].Token,
+			list[i].Created, list[i].Token)
+	})
+	return list, err
+}
+
+// cloneOperation returns a copy of the operation.
+func cloneOperation(
+	operation *model.Operation,
+) *model.Operation {
+	c := *operation
+	c.Amount = cloneAmount(operation.Amount)
+	c.Transaction = cloneString(operation.Transaction)
+	if operation.Hop != nil {
+		hop := *operation.Hop
+		c.Hop = &hop
+	}
+	return &c
+}
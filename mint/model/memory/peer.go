@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+// peers is the in-memory model.PeerRepository.
+type peers struct {
+	store *Store
+}
+
+func (r peers) Create(
+	ctx context.Context,
+	peer *model.Peer,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		k := key{Mint: peer.Mint, Token: peer.Host}
+		if _, ok := t.peers[k]; ok {
+			return uniqueViolation("peers_pkey")
+		}
+		t.peers[k] = clonePeer(peer)
+		return nil
+	})
+}
+
+func (r peers) Save(
+	ctx context.Context,
+	peer *model.Peer,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		k := key{Mint: peer.Mint, Token: peer.Host}
+		if p, ok := t.peers[k]; ok {
+			p = clonePeer(p)
+			p.Policy = peer.Policy
+			p.ExposureCap = cloneAmountPtr(peer.ExposureCap)
+			t.peers[k] = p
+		}
+		return nil
+	})
+}
+
+func (r peers) Delete(
+	ctx context.Context,
+	peer *model.Peer,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		delete(t.peers, key{Mint: peer.Mint, Token: peer.Host})
+		return nil
+	})
+}
+
+func (r peers) LoadByHost(
+	ctx context.Context,
+	host string,
+) (*model.Peer, error) {
+	var peer *model.Peer
+	err := r.store.view(ctx, func(t *tables) error {
+		if p, ok := t.peers[key{Mint: mint.GetHost(ctx), Token: host}]; ok {
+			peer = clonePeer(p)
+		}
+		return nil
+	})
+	return peer, err
+}
+
+func (r peers) List(
+	ctx context.Context,
+) ([]model.Peer, error) {
+	list := []model.Peer{}
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, p := range t.peers {
+			if p.Mint == mint.GetHost(ctx) {
+				list = append(list, *clonePeer(p))
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Host < list[j].Host })
+	return list, err
+}
+
+// clonePeer returns a copy of the peer.
+func clonePeer(
+	peer *model.Peer,
+) *model.Peer {
+	c := *peer
+	c.ExposureCap = cloneAmountPtr(peer.ExposureCap)
+	return &c
+}
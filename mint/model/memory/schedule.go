@@ -0,0 +1,161 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+// schedules is the in-memory model.ScheduleRepository.
+type schedules struct {
+	store *Store
+}
+
+// scheduleKey returns the key of the schedule in the tables.
+func scheduleKey(
+	schedule *model.Schedule,
+) key {
+	return key{Mint: schedule.Mint, Token: string(schedule.Name)}
+}
+
+func (r schedules) Create(
+	ctx context.Context,
+	schedule *model.Schedule,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		if _, ok := t.schedules[scheduleKey(schedule)]; ok {
+			return uniqueViolation("schedules_pkey")
+		}
+		t.schedules[scheduleKey(schedule)] = cloneSchedule(schedule)
+		return nil
+	})
+}
+
+func (r schedules) LoadByName(
+	ctx context.Context,
+	name mint.TkName,
+) (*model.Schedule, error) {
+	var schedule *model.Schedule
+	err := r.store.view(ctx, func(t *tables) error {
+		k := key{Mint: mint.GetHost(ctx), Token: string(name)}
+		if s, ok := t.schedules[k]; ok {
+			schedule = cloneSchedule(s)
+		}
+		return nil
+	})
+	return schedule, err
+}
+
+func (r schedules) Claim(
+	ctx context.Context,
+	hosts []string,
+	owner string,
+	now time.Time,
+	expiry time.Time,
+) (*model.Schedule, error) {
+	var schedule *model.Schedule
+	err := r.store.update(ctx, func(t *tables) error {
+		list := []*model.Schedule{}
+		for _, s := range t.schedules {
+			if served(hosts, s.Mint) && !s.Next.After(now) &&
+				(s.LeaseExpiry == nil || s.LeaseExpiry.Before(now)) {
+				list = append(list, s)
+			}
+		}
+		if len(list) == 0 {
+			return nil
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if !list[i].Next.Equal(list[j].Next) {
+				return list[i].Next.Before(list[j].Next)
+			}
+			if list[i].Mint != list[j].Mint {
+				return list[i].Mint < list[j].Mint
+			}
+			return list[i].Name < list[j].Name
+		})
+		schedule = cloneSchedule(list[0])
+		e := expiry.UTC()
+		schedule.LeaseOwner = &owner
+		schedule.LeaseExpiry = &e
+		t.schedules[scheduleKey(schedule)] = cloneSchedule(schedule)
+		return nil
+	})
+	return schedule, err
+}
+
+// leased returns the schedule stored in the tables if it is leased by owner.
+func leased(
+	t *tables,
+	schedule *model.Schedule,
+	owner string,
+) (*model.Schedule, bool) {
+	s, ok := t.schedules[scheduleKey(schedule)]
+	if !ok || s.LeaseOwner == nil || *s.LeaseOwner != owner {
+		return nil, false
+	}
+	return cloneSchedule(s), true
+}
+
+func (r schedules) RenewLease(
+	ctx context.Context,
+	schedule *model.Schedule,
+	owner string,
+	expiry time.Time,
+) (bool, error) {
+	renewed := false
+	err := r.store.update(ctx, func(t *tables) error {
+		s, ok := leased(t, schedule, owner)
+		if !ok {
+			return nil
+		}
+		e := expiry.UTC()
+		s.LeaseExpiry = &e
+		t.schedules[scheduleKey(s)] = s
+		renewed = true
+		return nil
+	})
+	return renewed, err
+}
+
+func (r schedules) Release(
+	ctx context.Context,
+	schedule *model.Schedule,
+	owner string,
+) (bool, error) {
+	released := false
+	err := r.store.update(ctx, func(t *tables) error {
+		s, ok := leased(t, schedule, owner)
+		if !ok {
+			return nil
+		}
+		s.Next = schedule.Next.UTC()
+		s.Last = nil
+		if schedule.Last != nil {
+			l := schedule.Last.UTC()
+			s.Last = &l
+		}
+		s.LastError = cloneString(schedule.LastError)
+		s.LeaseOwner = nil
+		s.LeaseExpiry = nil
+		t.schedules[scheduleKey(s)] = s
+		released = true
+		return nil
+	})
+	return released, err
+}
+
+// cloneSchedule returns a copy of the schedule.
+func cloneSchedule(
+	schedule *model.Schedule,
+) *model.Schedule {
+	c := *schedule
+	c.Last = cloneTime(schedule.Last)
+	c.LastError = cloneString(schedule.LastError)
+	c.LeaseOwner = cloneString(schedule.LeaseOwner)
+	c.LeaseExpiry = cloneTime(schedule.LeaseExpiry)
+	return &c
+}
@@ -0,0 +1,426 @@
+// Package memory implements an in-memory model.Store. It lets tests and
+// simulations run mints without a SQL database:
+//
+//	ctx = db.WithBackend(ctx, "mint", memory.NewStore(ctx))
+//
+// Transactions behave like sqlite3 ones. They read a consistent snapshot of
+// the store and writes are serialized: the first write (or locking read) of a
+// transaction waits for the concurrent writing transaction to end and fails
+// with a db.ErrSerializationFailure if another transaction committed since
+// its first read. Writes are only visible to other transactions once
+// committed. Model functions called outside of a transaction run in their own
+// transaction.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/mint/model"
+)
+
+// BusyTimeout is the maximum time a transaction waits for the concurrent
+// writing transaction to end before failing, as for sqlite3.
+const BusyTimeout = 5 * time.Second
+
+// key is the primary key of objects owned by a user.
+type key struct {
+	Mint  string
+	Owner string
+	Token string
+}
+
+// tables is a version of the content of the store. Committed tables are never
+// modified and objects stored in tables are never modified either (updates
+// replace them with a modified copy).
+type tables struct {
+	assets       map[key]*model.Asset
+	balances     map[key]*model.Balance
+	offers       map[key]*model.Offer
+	operations   map[key]*model.Operation
+	transactions map[key]*model.Transaction
+	crossings    map[key]*model.Crossing
+	tasks        map[string]*model.Task        // By token.
+	users        map[string]*model.User        // By token.
+	apiKeys      map[string]*model.APIKey      // By token.
+	peers        map[key]*model.Peer           // By mint and host.
+	schedules    map[key]*model.Schedule       // By mint and name.
+	healthChecks map[string]*model.HealthCheck // By token.
+}
+
+// copy returns a copy of the tables that can be modified.
+func (t *tables) copy() *tables {
+	c := &tables{
+		assets:       map[key]*model.Asset{},
+		balances:     map[key]*model.Balance{},
+		offers:       map[key]*model.Offer{},
+		operations:   map[key]*model.Operation{},
+		transactions: map[key]*model.Transaction{},
+		crossings:    map[key]*model.Crossing{},
+		tasks:        map[string]*model.Task{},
+		users:        map[string]*model.User{},
+		apiKeys:      map[string]*model.APIKey{},
+		peers:        map[key]*model.Peer{},
+		schedules:    map[key]*model.Schedule{},
+		healthChecks: map[string]*model.HealthCheck{},
+	}
+	for k, v := range t.assets {
+		c.assets[k] = v
+	}
+	for k, v := range t.balances {
+		c.balances[k] = v
+	}
+	for k, v := range t.offers {
+		c.offers[k] = v
+	}
+	for k, v := range t.operations {
+		c.operations[k] = v
+	}
+	for k, v := range t.transactions {
+		c.transactions[k] = v
+	}
+	for k, v := range t.crossings {
+		c.crossings[k] = v
+	}
+	for k, v := range t.tasks {
+		c.tasks[k] = v
+	}
+	for k, v := range t.users {
+		c.users[k] = v
+	}
+	for k, v := range t.apiKeys {
+		c.apiKeys[k] = v
+	}
+	for k, v := range t.peers {
+		c.peers[k] = v
+	}
+	for k, v := range t.schedules {
+		c.schedules[k] = v
+	}
+	for k, v := range t.healthChecks {
+		c.healthChecks[k] = v
+	}
+	return c
+}
+
+// Store is an in-memory model.Store and db.Backend.
+type Store struct {
+	mutex     *sync.Mutex
+	committed *tables
+
+	// writer is held by the transaction writing to the store if any.
+	writer chan struct{}
+}
+
+// NewStore returns a new empty in-memory store.
+func NewStore(
+	ctx context.Context,
+) *Store {
+	logging.Logf(ctx, "Opened memory store\n")
+
+	return &Store{
+		mutex:     &sync.Mutex{},
+		committed: (&tables{}).copy(),
+		writer:    make(chan struct{}, 1),
+	}
+}
+
+// Begin implements db.Backend.
+func (s *Store) Begin() (db.BackendTx, error) {
+	return &transaction{store: s}, nil
+}
+
+// transaction is a transaction on the store.
+type transaction struct {
+	store *Store
+	done  bool
+
+	// snapshot is the committed version of the tables as of the first read
+	// of the transaction.
+	snapshot *tables
+	// tables is the private copy of the tables modified by the transaction
+	// once it holds the writer.
+	tables *tables
+}
+
+// read returns the tables the transaction reads from.
+func (t *transaction) read() *tables {
+	if t.tables != nil {
+		return t.tables
+	}
+	if t.snapshot == nil {
+		t.store.mutex.Lock()
+		t.snapshot = t.store.committed
+		t.store.mutex.Unlock()
+	}
+	return t.snapshot
+}
+
+// write acquires the writer for the transaction and returns the tables it
+// writes to.
+func (t *transaction) write() (*tables, error) {
+	if t.tables != nil {
+		return t.tables, nil
+	}
+
+	select {
+	case t.store.writer <- struct{}{}:
+	case <-time.After(BusyTimeout):
+		return nil, errors.Trace(db.ErrSerializationFailure{
+			Reason: "memory store busy",
+		})
+	}
+
+	t.store.mutex.Lock()
+	committed := t.store.committed
+	t.store.mutex.Unlock()
+
+	if t.snapshot != nil && t.snapshot != committed {
+		<-t.store.writer
+		return nil, errors.Trace(db.ErrSerializationFailure{
+			Reason: "concurrent transaction committed",
+		})
+	}
+	t.tables = committed.copy()
+
+	return t.tables, nil
+}
+
+// Commit implements db.BackendTx.
+func (t *transaction) Commit() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+
+	if t.tables != nil {
+		t.store.mutex.Lock()
+		t.store.committed = t.tables
+		t.store.mutex.Unlock()
+		<-t.store.writer
+	}
+	return nil
+}
+
+// Rollback implements db.BackendTx.
+func (t *transaction) Rollback() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+
+	if t.tables != nil {
+		<-t.store.writer
+	}
+	return nil
+}
+
+// current returns the transaction of the context if it is a transaction on
+// the store, or a new transaction otherwise along with true.
+func (s *Store) current(
+	ctx context.Context,
+) (*transaction, bool) {
+	if t, ok := db.GetBackendTx(ctx).(*transaction); ok && t.store == s {
+		return t, false
+	}
+	return &transaction{store: s}, true
+}
+
+// view runs fn on the tables read by the current transaction.
+func (s *Store) view(
+	ctx context.Context,
+	fn func(t *tables) error,
+) error {
+	tx, auto := s.current(ctx)
+	if tx.done {
+		return errors.Trace(sql.ErrTxDone)
+	}
+	if auto {
+		defer tx.Rollback()
+	}
+	return fn(tx.read())
+}
+
+// update runs fn on the tables written by the current transaction. fn must
+// check that it can be applied before modifying the tables.
+func (s *Store) update(
+	ctx context.Context,
+	fn func(t *tables) error,
+) error {
+	tx, auto := s.current(ctx)
+	if tx.done {
+		return errors.Trace(sql.ErrTxDone)
+	}
+	tables, err := tx.write()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := fn(tables); err != nil {
+		if auto {
+			tx.Rollback()
+		}
+		return err
+	}
+	if auto {
+		return tx.Commit()
+	}
+	return nil
+}
+
+// load runs fn on the tables read by the current transaction, acquiring the
+// writer first if lock is true so that the objects loaded cannot be modified
+// by concurrent transactions (as with SELECT ... FOR UPDATE).
+func (s *Store) load(
+	ctx context.Context,
+	lock bool,
+	fn func(t *tables) error,
+) error {
+	if lock {
+		return s.update(ctx, fn)
+	}
+	return s.view(ctx, fn)
+}
+
+// served returns whether host is one of the provided hosts.
+func served(
+	hosts []string,
+	host string,
+) bool {
+	for _, h := range hosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueViolation returns the error returned when a write violates the
+// provided unique constraint.
+func uniqueViolation(
+	constraint string,
+) error {
+	return errors.Trace(model.ErrUniqueConstraintViolation{
+		Err: fmt.Errorf("constraint %s", constraint),
+	})
+}
+
+// newer orders objects from the most to the least recently created, as lists
+// are returned by SQL stores, breaking ties on tokens.
+func newer(
+	created1 time.Time,
+	token1 string,
+	created2 time.Time,
+	token2 string,
+) bool {
+	if created1.Equal(created2) {
+		return token1 > token2
+	}
+	return created1.After(created2)
+}
+
+// cloneAmount returns a copy of the amount that does not share its storage.
+func cloneAmount(
+	amount model.Amount,
+) model.Amount {
+	return model.Amount(*new(big.Int).Set((*big.Int)(&amount)))
+}
+
+// cloneAmountPtr returns a copy of the optional amount.
+func cloneAmountPtr(
+	amount *model.Amount,
+) *model.Amount {
+	if amount == nil {
+		return nil
+	}
+	c := cloneAmount(*amount)
+	return &c
+}
+
+// cloneString returns a copy of the optional string.
+func cloneString(
+	str *string,
+) *string {
+	if str == nil {
+		return nil
+	}
+	c := *str
+	return &c
+}
+
+// cloneTime returns a copy of the optional time.
+func cloneTime(
+	t *time.Time,
+) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+// Assets implements model.Store.
+func (s *Store) Assets() model.AssetRepository {
+	return assets{s}
+}
+
+// Balances implements model.Store.
+func (s *Store) Balances() model.BalanceRepository {
+	return balances{s}
+}
+
+// Offers implements model.Store.
+func (s *Store) Offers() model.OfferRepository {
+	return offers{s}
+}
+
+// Operations implements model.Store.
+func (s *Store) Operations() model.OperationRepository {
+	return operations{s}
+}
+
+// Transactions implements model.Store.
+func (s *Store) Transactions() model.TransactionRepository {
+	return transactions{s}
+}
+
+// Crossings implements model.Store.
+func (s *Store) Crossings() model.CrossingRepository {
+	return crossings{s}
+}
+
+// Tasks implements model.Store.
+func (s *Store) Tasks() model.TaskRepository {
+	return tasks{s}
+}
+
+// Users implements model.Store.
+func (s *Store) Users() model.UserRepository {
+	return users{s}
+}
+
+// APIKeys implements model.Store.
+func (s *Store) APIKeys() model.APIKeyRepository {
+	return apiKeys{s}
+}
+
+// Peers implements model.Store.
+func (s *Store) Peers() model.PeerRepository {
+	return peers{s}
+}
+
+// Schedules implements model.Store.
+func (s *Store) Schedules() model.ScheduleRepository {
+	return schedules{s}
+}
+
+// HealthChecks implements model.Store.
+func (s *Store) HealthChecks() model.HealthCheckRepository {
+	return healthChecks{s}
+}
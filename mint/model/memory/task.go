@@ -0,0 +1,332 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+// tasks is the in-memory model.TaskRepository.
+type tasks struct {
+	store *Store
+}
+
+func (r tasks) Create(
+	ctx context.Context,
+	task *model.Task,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		if _, ok := t.tasks[task.Token]; ok {
+			return uniqueViolation("tasks_pkey")
+		}
+		t.tasks[task.Token] = cloneTask(task)
+		return nil
+	})
+}
+
+func (r tasks) Save(
+	ctx context.Context,
+	task *model.Task,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		if tk, ok := t.tasks[task.Token]; ok {
+			tk = cloneTask(tk)
+			tk.Status = task.Status
+			tk.Retry = task.Retry
+			tk.Deadline = task.Deadline
+			tk.Requeued = cloneTime(task.Requeued)
+			tk.LeaseOwner = cloneString(task.LeaseOwner)
+			tk.LeaseExpiry = cloneTime(task.LeaseExpiry)
+			tk.LastError = cloneString(task.LastError)
+			t.tasks[task.Token] = tk
+		}
+		return nil
+	})
+}
+
+// claimable returns whether the task is not leased or its lease has expired.
+func claimable(
+	task *model.Task,
+	now time.Time,
+) bool {
+	return task.LeaseExpiry == nil || task.LeaseExpiry.Before(now)
+}
+
+// overdue returns the claimable pending tasks of the provided hosts whose
+// deadline is before now, sorted by deadline.
+func overdue(
+	t *tables,
+	hosts []string,
+	now time.Time,
+) []*model.Task {
+	list := []*model.Task{}
+	for _, tk := range t.tasks {
+		if served(hosts, tk.Mint) && tk.Status == mint.TkStPending &&
+			!tk.Deadline.After(now) && claimable(tk, now) {
+			list = append(list, tk)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Deadline.Equal(list[j].Deadline) {
+			return list[i].Deadline.Before(list[j].Deadline)
+		}
+		return newer(list[j].Created, list[j].Token,
+			list[i].Created, list[i].Token)
+	})
+	return list
+}
+
+func (r tasks) Claim(
+	ctx context.Context,
+	hosts []string,
+	owner string,
+	now time.Time,
+	expiry time.Time,
+) (*model.Task, error) {
+	var task *model.Task
+	err := r.store.update(ctx, func(t *tables) error {
+		list := overdue(t, hosts, now)
+		if len(list) == 0 {
+			return nil
+		}
+		task = cloneTask(list[0])
+		e := expiry.UTC()
+		task.LeaseOwner = &owner
+		task.LeaseExpiry = &e
+		t.tasks[task.Token] = cloneTask(task)
+		return nil
+	})
+	return task, err
+}
+
+func (r tasks) ClaimByToken(
+	ctx context.Context,
+	owner string,
+	token string,
+	now time.Time,
+	expiry time.Time,
+) (bool, error) {
+	claimed := false
+	err := r.store.update(ctx, func(t *tables) error {
+		tk, ok := t.tasks[token]
+		if !ok || tk.Mint != mint.GetHost(ctx) || !claimable(tk, now) ||
+			(tk.Status != mint.TkStPending && tk.Status != mint.TkStFailed) {
+			return nil
+		}
+		tk = cloneTask(tk)
+		e := expiry.UTC()
+		tk.LeaseOwner = &owner
+		tk.LeaseExpiry = &e
+		t.tasks[token] = tk
+		claimed = true
+		return nil
+	})
+	return claimed, err
+}
+
+func (r tasks) CancelByToken(
+	ctx context.Context,
+	token string,
+	now time.Time,
+) (bool, error) {
+	canceled := false
+	err := r.store.update(ctx, func(t *tables) error {
+		tk, ok := t.tasks[token]
+		if !ok || tk.Mint != mint.GetHost(ctx) || !claimable(tk, now) ||
+			tk.Status != mint.TkStPending {
+			return nil
+		}
+		tk = cloneTask(tk)
+		tk.Status = mint.TkStCanceled
+		tk.LeaseOwner = nil
+		tk.LeaseExpiry = nil
+		t.tasks[token] = tk
+		canceled = true
+		return nil
+	})
+	return canceled, err
+}
+
+func (r tasks) RenewLease(
+	ctx context.Context,
+	task *model.Task,
+	owner string,
+	expiry time.Time,
+) (bool, error) {
+	renewed := false
+	err := r.store.update(ctx, func(t *tables) error {
+		tk, ok := t.tasks[task.Token]
+		if !ok || tk.LeaseOwner == nil || *tk.LeaseOwner != owner {
+			return nil
+		}
+		tk = cloneTask(tk)
+		e := expiry.UTC()
+		tk.LeaseExpiry = &e
+		t.tasks[task.Token] = tk
+		renewed = true
+		return nil
+	})
+	return renewed, err
+}
+
+func (r tasks) Release(
+	ctx context.Context,
+	task *model.Task,
+	owner string,
+) (bool, error) {
+	released := false
+	err := r.store.update(ctx, func(t *tables) error {
+		tk, ok := t.tasks[task.Token]
+		if !ok || tk.LeaseOwner == nil || *tk.LeaseOwner != owner {
+			return nil
+		}
+		tk = cloneTask(tk)
+		tk.Status = task.Status
+		tk.Retry = task.Retry
+		tk.Deadline = task.Deadline.UTC()
+		tk.LastError = cloneString(task.LastError)
+		tk.LeaseOwner = nil
+		tk.LeaseExpiry = nil
+		t.tasks[task.Token] = tk
+		released = true
+		return nil
+	})
+	return released, err
+}
+
+func (r tasks) CountPending(
+	ctx context.Context,
+	hosts []string,
+) (int, error) {
+	count := 0
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, tk := range t.tasks {
+			if served(hosts, tk.Mint) && tk.Status == mint.TkStPending {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (r tasks) ListPending(
+	ctx context.Context,
+	hosts []string,
+) ([]*model.Task, error) {
+	list := []*model.Task{}
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, tk := range t.tasks {
+			if served(hosts, tk.Mint) && tk.Status == mint.TkStPending {
+				list = append(list, cloneTask(tk))
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return newer(list[j].Created, list[j].Token,
+			list[i].Created, list[i].Token)
+	})
+	return list, err
+}
+
+func (r tasks) LoadByToken(
+	ctx context.Context,
+	token string,
+) (*model.Task, error) {
+	var task *model.Task
+	err := r.store.view(ctx, func(t *tables) error {
+		tk, ok := t.tasks[token]
+		if ok && tk.Mint == mint.GetHost(ctx) {
+			task = cloneTask(tk)
+		}
+		return nil
+	})
+	return task, err
+}
+
+func (r tasks) List(
+	ctx context.Context,
+	createdBefore time.Time,
+	limit uint,
+	status mint.TkStatus,
+	name mint.TkName,
+	subject string,
+) ([]model.Task, error) {
+	list := []model.Task{}
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, tk := range t.tasks {
+			if tk.Mint == mint.GetHost(ctx) &&
+				(status == "" || tk.Status == status) &&
+				(name == "" || tk.Name == name) &&
+				(subject == "" || tk.Subject == subject) &&
+				tk.Created.Before(createdBefore) {
+				list = append(list, *cloneTask(tk))
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return newer(list[i].Created, list[i].Token,
+			list[j].Created, list[j].Token)
+	})
+	if uint(len(list)) > limit {
+		list = list[:limit]
+	}
+	return list, err
+}
+
+func (r tasks) DeleteCreatedBefore(
+	ctx context.Context,
+	status mint.TkStatus,
+	createdBefore time.Time,
+) (int64, error) {
+	count := int64(0)
+	err := r.store.update(ctx, func(t *tables) error {
+		for token, tk := range t.tasks {
+			if tk.Mint == mint.GetHost(ctx) && tk.Status == status &&
+				tk.Created.Before(createdBefore) && tk.LeaseOwner == nil {
+				delete(t.tasks, token)
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (r tasks) LoadOldestOverdue(
+	ctx context.Context,
+	hosts []string,
+	now time.Time,
+) (*model.Task, error) {
+	var task *model.Task
+	err := r.store.view(ctx, func(t *tables) error {
+		list := overdue(t, hosts, now)
+		if len(list) > 0 {
+			task = cloneTask(list[0])
+		}
+		return nil
+	})
+	return task, err
+}
+
+// cloneTask returns a copy of the task. Empty payloads are stored as null as
+// in SQL stores (see model.JSON).
+func cloneTask(
+	task *model.Task,
+) *model.Task {
+	c := *task
+	c.Payload = append(model.JSON{}, task.Payload...)
+	if len(c.Payload) == 0 {
+		c.Payload = model.JSON("null")
+	}
+	c.Requeued = cloneTime(task.Requeued)
+	c.LeaseOwner = cloneString(task.LeaseOwner)
+	c.LeaseExpiry = cloneTime(task.LeaseExpiry)
+	c.LastError = cloneString(task.LastError)
+	return &c
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+// transactions is the in-memory model.TransactionRepository.
+type transactions struct {
+	store *Store
+}
+
+func (r transactions) Create(
+	ctx context.Context,
+	transaction *model.Transaction,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		k := key{transaction.Mint, transaction.Owner, transaction.Token}
+		if _, ok := t.transactions[k]; ok {
+			return uniqueViolation("transactions_pkey")
+		}
+		t.transactions[k] = cloneTransaction(transaction)
+		return nil
+	})
+}
+
+func (r transactions) Save(
+	ctx context.Context,
+	transaction *model.Transaction,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		k := key{transaction.Mint, transaction.Owner, transaction.Token}
+		if tx, ok := t.transactions[k]; ok {
+			tx = cloneTransaction(tx)
+			tx.Status = transaction.Status
+			tx.Secret = cloneString(transaction.Secret)
+			t.transactions[k] = tx
+		}
+		return nil
+	})
+}
+
+func (r transactions) LoadByOwnerToken(
+	ctx context.Context,
+	owner string,
+	token string,
+	propagation mint.PgType,
+) (*model.Transaction, error) {
+	var transaction *model.Transaction
+	err := r.store.view(ctx, func(t *tables) error {
+		tx, ok := t.transactions[key{mint.GetHost(ctx), owner, token}]
+		if ok && tx.Propagation == propagation {
+			transaction = cloneTransaction(tx)
+		}
+		return nil
+	})
+	return transaction, err
+}
+
+func (r transactions) Load(
+	ctx context.Context,
+	owner string,
+	token string,
+	lock bool,
+) (*model.Transaction, error) {
+	var transaction *model.Transaction
+	err := r.store.load(ctx, lock, func(t *tables) error {
+		tx, ok := t.transactions[key{mint.GetHost(ctx), owner, token}]
+		if ok {
+			transaction = cloneTransaction(tx)
+		}
+		return nil
+	})
+	return transaction, err
+}
+
+func (r transactions) ListByAPIKey(
+	ctx context.Context,
+	apiKey string,
+) ([]*model.Transaction, error) {
+	list := []*model.Transaction{}
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, tx := range t.transactions {
+			if tx.Mint == mint.GetHost(ctx) &&
+				tx.Propagation == mint.PgTpCanonical &&
+				tx.APIKey != nil && *tx.APIKey == apiKey {
+				list = append(list, cloneTransaction(tx))
+			}
+		}
+		return nil
+	})
+	return list, err
+}
+
+// cloneTransaction returns a copy of the transaction.
+func cloneTransaction(
+	transaction *model.Transaction,
+) *model.Transaction {
+	c := *transaction
+	c.Amount = cloneAmount(transaction.Amount)
+	c.Path = append(model.OfPath{}, transaction.Path...)
+	c.Secret = cloneString(transaction.Secret)
+	c.APIKey = cloneString(transaction.APIKey)
+	return &c
+}
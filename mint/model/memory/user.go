@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+)
+
+// users is the in-memory model.UserRepository.
+type users struct {
+	store *Store
+}
+
+// checkUsername returns an error if another user of the mint host of the user
+// has its username.
+func checkUsername(
+	t *tables,
+	user *model.User,
+) error {
+	for _, u := range t.users {
+		if u.Token != user.Token && u.Mint == user.Mint &&
+			u.Username == user.Username {
+			return uniqueViolation("users_username_u")
+		}
+	}
+	return nil
+}
+
+func (r users) Create(
+	ctx context.Context,
+	user *model.User,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		if _, ok := t.users[user.Token]; ok {
+			return uniqueViolation("users_pkey")
+		}
+		if err := checkUsername(t, user); err != nil {
+			return err
+		}
+		t.users[user.Token] = cloneUser(user)
+		return nil
+	})
+}
+
+func (r users) Save(
+	ctx context.Context,
+	user *model.User,
+) error {
+	return r.store.update(ctx, func(t *tables) error {
+		u, ok := t.users[user.Token]
+		if !ok || u.Mint != user.Mint {
+			return nil
+		}
+		if err := checkUsername(t, user); err != nil {
+			return err
+		}
+		u = cloneUser(u)
+		u.Username = user.Username
+		u.PasswordHash = user.PasswordHash
+		u.Disabled = cloneTime(user.Disabled)
+		t.users[user.Token] = u
+		return nil
+	})
+}
+
+func (r users) LoadByToken(
+	ctx context.Context,
+	token string,
+) (*model.User, error) {
+	var user *model.User
+	err := r.store.view(ctx, func(t *tables) error {
+		u, ok := t.users[token]
+		if ok && u.Mint == mint.GetHost(ctx) {
+			user = cloneUser(u)
+		}
+		return nil
+	})
+	return user, err
+}
+
+func (r users) LoadByUsername(
+	ctx context.Context,
+	username string,
+) (*model.User, error) {
+	var user *model.User
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, u := range t.users {
+			if u.Mint == mint.GetHost(ctx) && u.Username == username {
+				user = cloneUser(u)
+			}
+		}
+		return nil
+	})
+	return user, err
+}
+
+func (r users) List(
+	ctx context.Context,
+	createdBefore time.Time,
+	limit uint,
+) ([]model.User, error) {
+	list := []model.User{}
+	err := r.store.view(ctx, func(t *tables) error {
+		for _, u := range t.users {
+			if u.Mint == mint.GetHost(ctx) && u.Created.Before(createdBefore) {
+				list = append(list, *cloneUser(u))
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return newer(list[i].Created, list[i].Token,
+			list[j].Created, list[j].Token)
+	})
+	if uint(len(list)) > limit {
+		list = list[:limit]
+	}
+	return list, err
+}
+
+// cloneUser returns a copy of the user.
+func cloneUser(
+	user *model.User,
+) *model.User {
+	c := *user
+	c.Disabled = cloneTime(user.Disabled)
+	return &c
+}
@@ -6,10 +6,7 @@ import (
 	"math/big"
 	"time"
 
-	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
-	sqlite3 "github.com/mattn/go-sqlite3"
-	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/token"
 	"github.com/spolu/settle/mint"
@@ -24,6 +21,7 @@ import (
 // - Propagated offers are indicatively stored on the mints of the offers's
 //   assets, to compute order books.
 type Offer struct {
+	Mint        string // Host of the mint the object belongs to.
 	Owner       string
 	Token       string
 	Created     time.Time
@@ -74,9 +72,10 @@ func CreateCanonicalOffer(
 	remainder Amount,
 ) (*Offer, error) {
 	offer := Offer{
+		Mint:        mint.GetHost(ctx),
 		Owner:       owner,
 		Token:       token.New("offer"),
-		Created:     time.Now().UTC(),
+		Created:     clock.Now(ctx).UTC(),
 		Propagation: mint.PgTpCanonical,
 
 		BaseAsset:  baseAsset,
@@ -89,25 +88,7 @@ func CreateCanonicalOffer(
 		Remainder: remainder,
 	}
 
-	ext := db.Ext(ctx, "mint")
-	if _, err := sqlx.NamedExec(ext, `
-INSERT INTO offers
-  (owner, token, created, propagation, base_asset, quote_asset,
-   base_price, quote_price, amount, status, remainder)
-VALUES
-  (:owner, :token, :created, :propagation, :base_asset, :quote_asset,
-   :base_price, :quote_price, :amount, :status, :remainder)
-`, offer); err != nil {
-		switch err := err.(type) {
-		case *pq.Error:
-			if err.Code.Name() == "unique_violation" {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		case sqlite3.Error:
-			if err.ExtendedCode == sqlite3.ErrConstraintUnique {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		}
+	if err := getStore(ctx).Offers().Create(ctx, &offer); err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -129,6 +110,7 @@ func CreatePropagatedOffer(
 	remainder Amount,
 ) (*Offer, error) {
 	offer := Offer{
+		Mint:        mint.GetHost(ctx),
 		Owner:       owner,
 		Token:       token,
 		Created:     created.UTC(),
@@ -144,25 +126,7 @@ func CreatePropagatedOffer(
 		Remainder: remainder,
 	}
 
-	ext := db.Ext(ctx, "mint")
-	if _, err := sqlx.NamedExec(ext, `
-INSERT INTO offers
-  (owner, token, created, propagation, base_asset, quote_asset,
-   base_price, quote_price, amount, status, remainder)
-VALUES
-  (:owner, :token, :created, :propagation, :base_asset, :quote_asset,
-   :base_price, :quote_price, :amount, :status, :remainder)
-`, offer); err != nil {
-		switch err := err.(type) {
-		case *pq.Error:
-			if err.Code.Name() == "unique_violation" {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		case sqlite3.Error:
-			if err.ExtendedCode == sqlite3.ErrConstraintUnique {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		}
+	if err := getStore(ctx).Offers().Create(ctx, &offer); err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -178,18 +142,7 @@ func (o *Offer) ID() string {
 func (o *Offer) Save(
 	ctx context.Context,
 ) error {
-	ext := db.Ext(ctx, "mint")
-	_, err := sqlx.NamedExec(ext, `
-UPDATE offers
-SET status = :status, remainder = :remainder
-WHERE owner = :owner
-  AND token = :token
-`, o)
-	if err != nil {
-		return errors.Trace(err)
-	}
-
-	return nil
+	return getStore(ctx).Offers().Save(ctx, o)
 }
 
 // LoadCanonicalOfferByOwnerToken attempts to load the canonical offer for the
@@ -199,31 +152,19 @@ func LoadCanonicalOfferByOwnerToken(
 	owner string,
 	token string,
 ) (*Offer, error) {
-	offer := Offer{
-		Owner:       owner,
-		Token:       token,
-		Propagation: mint.PgTpCanonical,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM offers
-WHERE owner = :owner
-  AND token = :token
-  AND propagation = :propagation
-`, offer); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&offer); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
-		return nil, errors.Trace(err)
-	}
+	return getStore(ctx).Offers().LoadByOwnerToken(ctx,
+		owner, token, mint.PgTpCanonical, false)
+}
 
-	return &offer, nil
+// LockCanonicalOfferByOwnerToken attempts to load the canonical offer for the
+// given owner and token, locking it until the end of the current transaction.
+func LockCanonicalOfferByOwnerToken(
+	ctx context.Context,
+	owner string,
+	token string,
+) (*Offer, error) {
+	return getStore(ctx).Offers().LoadByOwnerToken(ctx,
+		owner, token, mint.PgTpCanonical, true)
 }
 
 // LoadCanonicalOfferByID attempts to load the canonical offer for the given
@@ -239,6 +180,19 @@ func LoadCanonicalOfferByID(
 	return LoadCanonicalOfferByOwnerToken(ctx, owner, token)
 }
 
+// LockCanonicalOfferByID attempts to load the canonical offer for the given
+// id, locking it until the end of the current transaction.
+func LockCanonicalOfferByID(
+	ctx context.Context,
+	id string,
+) (*Offer, error) {
+	owner, token, err := mint.NormalizedOwnerAndTokenFromID(ctx, id)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return LockCanonicalOfferByOwnerToken(ctx, owner, token)
+}
+
 // LoadPropagatedOfferByOwnerToken attempts to load the propagated offer for
 // the given owner and token.
 func LoadPropagatedOfferByOwnerToken(
@@ -246,31 +200,8 @@ func LoadPropagatedOfferByOwnerToken(
 	owner string,
 	token string,
 ) (*Offer, error) {
-	offer := Offer{
-		Owner:       owner,
-		Token:       token,
-		Propagation: mint.PgTpPropagated,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM offers
-WHERE owner = :owner
-  AND token = :token
-  AND propagation = :propagation
-`, offer); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&offer); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	return &offer, nil
+	return getStore(ctx).Offers().LoadByOwnerToken(ctx,
+		owner, token, mint.PgTpPropagated, false)
 }
 
 // LoadPropagatedOfferByID attempts to load the propagated offer for the given
@@ -293,39 +224,8 @@ func LoadOfferListByBaseAsset(
 	limit uint,
 	asset string,
 ) ([]Offer, error) {
-	query := map[string]interface{}{
-		"base_asset":     asset,
-		"created_before": createdBefore.UTC(),
-		"limit":          limit,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM offers
-WHERE base_asset = :base_asset
-  AND created < :created_before
-ORDER BY created DESC
-LIMIT :limit
-`, query)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	offers := []Offer{}
-
-	defer rows.Close()
-	for rows.Next() {
-		o := Offer{}
-		err := rows.StructScan(&o)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-
-		offers = append(offers, o)
-	}
-
-	return offers, nil
+	return getStore(ctx).Offers().ListByBaseAsset(ctx,
+		asset, createdBefore, limit)
 }
 
 // LoadOfferListByQuoteAsset loads a balance list by quote asset.
@@ -335,37 +235,18 @@ func LoadOfferListByQuoteAsset(
 	limit uint,
 	asset string,
 ) ([]Offer, error) {
-	query := map[string]interface{}{
-		"quote_asset":    asset,
-		"created_before": createdBefore.UTC(),
-		"limit":          limit,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM offers
-WHERE quote_asset = :quote_asset
-  AND created < :created_before
-ORDER BY created DESC
-LIMIT :limit
-`, query)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	offers := []Offer{}
-
-	defer rows.Close()
-	for rows.Next() {
-		o := Offer{}
-		err := rows.StructScan(&o)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-
-		offers = append(offers, o)
-	}
+	return getStore(ctx).Offers().ListByQuoteAsset(ctx,
+		asset, createdBefore, limit)
+}
 
-	return offers, nil
+// LoadCanonicalOfferListByOwner loads the list of canonical offers of an
+// owner.
+func LoadCanonicalOfferListByOwner(
+	ctx context.Context,
+	createdBefore time.Time,
+	limit uint,
+	owner string,
+) ([]Offer, error) {
+	return getStore(ctx).Offers().ListByOwner(ctx,
+		owner, mint.PgTpCanonical, createdBefore, limit)
 }
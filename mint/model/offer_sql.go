@@ -0,0 +1,202 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+)
+
+// sqlOffers is the SQL OfferRepository.
+type sqlOffers struct{}
+
+func (r sqlOffers) Create(
+	ctx context.Context,
+	offer *Offer,
+) error {
+	ext := db.Ext(ctx, "mint")
+	if _, err := sqlx.NamedExec(ext, `
+INSERT INTO offers
+  (mint, owner, token, created, propagation, base_asset, quote_asset,
+   base_price, quote_price, amount, status, remainder)
+VALUES
+  (:mint, :owner, :token, :created, :propagation, :base_asset, :quote_asset,
+   :base_price, :quote_price, :amount, :status, :remainder)
+`, offer); err != nil {
+		return errors.Trace(sqlInsertError(err))
+	}
+
+	return nil
+}
+
+func (r sqlOffers) Save(
+	ctx context.Context,
+	offer *Offer,
+) error {
+	ext := db.Ext(ctx, "mint")
+	_, err := sqlx.NamedExec(ext, `
+UPDATE offers
+SET status = :status, remainder = :remainder
+WHERE mint = :mint
+  AND owner = :owner
+  AND token = :token
+`, offer)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+func (r sqlOffers) LoadByOwnerToken(
+	ctx context.Context,
+	owner string,
+	token string,
+	propagation mint.PgType,
+	lock bool,
+) (*Offer, error) {
+	offer := Offer{
+		Mint:        mint.GetHost(ctx),
+		Owner:       owner,
+		Token:       token,
+		Propagation: propagation,
+	}
+
+	forUpdate := ""
+	if lock {
+		forUpdate = db.ForUpdate(ctx, "mint")
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM offers
+WHERE mint = :mint
+  AND owner = :owner
+  AND token = :token
+  AND propagation = :propagation
+`+forUpdate, offer); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		return nil, nil
+	} else if err := rows.StructScan(&offer); err != nil {
+		defer rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &offer, nil
+}
+
+func (r sqlOffers) ListByBaseAsset(
+	ctx context.Context,
+	asset string,
+	createdBefore time.Time,
+	limit uint,
+) ([]Offer, error) {
+	return r.list(ctx, "base_asset", asset, createdBefore, limit)
+}
+
+func (r sqlOffers) ListByQuoteAsset(
+	ctx context.Context,
+	asset string,
+	createdBefore time.Time,
+	limit uint,
+) ([]Offer, error) {
+	return r.list(ctx, "quote_asset", asset, createdBefore, limit)
+}
+
+func (r sqlOffers) ListByOwner(
+	ctx context.Context,
+	owner string,
+	propagation mint.PgType,
+	createdBefore time.Time,
+	limit uint,
+) ([]Offer, error) {
+	query := map[string]interface{}{
+		"mint":           mint.GetHost(ctx),
+		"owner":          owner,
+		"propagation":    propagation,
+		"created_before": createdBefore.UTC(),
+		"limit":          limit,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM offers
+WHERE mint = :mint
+  AND owner = :owner
+  AND propagation = :propagation
+  AND created < :created_before
+ORDER BY created DESC
+LIMIT :limit
+`, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	offers := []Offer{}
+
+	defer rows.Close()
+	for rows.Next() {
+		o := Offer{}
+		err := rows.StructScan(&o)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		offers = append(offers, o)
+	}
+
+	return offers, nil
+}
+
+// list loads an offer list filtered on the provided column.
+func (r sqlOffers) list(
+	ctx context.Context,
+	column string,
+	value string,
+	createdBefore time.Time,
+	limit uint,
+) ([]Offer, error) {
+	query := map[string]interface{}{
+		"mint":           mint.GetHost(ctx),
+		"value":          value,
+		"created_before": createdBefore.UTC(),
+		"limit":          limit,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM offers
+WHERE mint = :mint
+  AND `+column+` = :value
+  AND created < :created_before
+ORDER BY created DESC
+LIMIT :limit
+`, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	offers := []Offer{}
+
+	defer rows.Close()
+	for rows.Next() {
+		o := Offer{}
+		err := rows.StructScan(&o)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		offers = append(offers, o)
+	}
+
+	return offers, nil
+}
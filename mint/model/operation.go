@@ -6,10 +6,7 @@ import (
 	"math/big"
 	"time"
 
-	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
-	sqlite3 "github.com/mattn/go-sqlite3"
-	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/token"
 	"github.com/spolu/settle/mint"
@@ -29,6 +26,7 @@ var MaxAssetAmount = new(big.Int).Exp(
 //   reserved).
 // - When part of a transaction, an operation refers the transaction and hop.
 type Operation struct {
+	Mint        string // Host of the mint the object belongs to.
 	Owner       string // Owner address.
 	Token       string
 	Created     time.Time
@@ -78,9 +76,10 @@ func CreateCanonicalOperation(
 	hop *int8,
 ) (*Operation, error) {
 	operation := Operation{
+		Mint:        mint.GetHost(ctx),
 		Owner:       owner,
 		Token:       token.New("operation"),
-		Created:     time.Now().UTC(),
+		Created:     clock.Now(ctx).UTC(),
 		Propagation: mint.PgTpCanonical,
 
 		Asset:       asset,
@@ -93,25 +92,7 @@ func CreateCanonicalOperation(
 		Hop:         hop,
 	}
 
-	ext := db.Ext(ctx, "mint")
-	if _, err := sqlx.NamedExec(ext, `
-INSERT INTO operations
-  (owner, token, created, propagation, asset, source, destination,
-   amount, status, txn, hop)
-VALUES
-  (:owner, :token, :created, :propagation, :asset, :source, :destination,
-   :amount, :status, :txn, :hop)
-`, operation); err != nil {
-		switch err := err.(type) {
-		case *pq.Error:
-			if err.Code.Name() == "unique_violation" {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		case sqlite3.Error:
-			if err.ExtendedCode == sqlite3.ErrConstraintUnique {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		}
+	if err := getStore(ctx).Operations().Create(ctx, &operation); err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -133,6 +114,7 @@ func CreatePropagatedOperation(
 	hop *int8,
 ) (*Operation, error) {
 	operation := Operation{
+		Mint:        mint.GetHost(ctx),
 		Owner:       owner,
 		Token:       token,
 		Created:     created.UTC(),
@@ -148,25 +130,7 @@ func CreatePropagatedOperation(
 		Hop:         hop,
 	}
 
-	ext := db.Ext(ctx, "mint")
-	if _, err := sqlx.NamedExec(ext, `
-INSERT INTO operations
-  (owner, token, created, propagation, asset, source, destination,
-   amount, status, txn, hop)
-VALUES
-  (:owner, :token, :created, :propagation, :asset, :source, :destination,
-   :amount, :status, :txn, :hop)
-`, operation); err != nil {
-		switch err := err.(type) {
-		case *pq.Error:
-			if err.Code.Name() == "unique_violation" {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		case sqlite3.Error:
-			if err.ExtendedCode == sqlite3.ErrConstraintUnique {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		}
+	if err := getStore(ctx).Operations().Create(ctx, &operation); err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -182,18 +146,7 @@ func (o *Operation) ID() string {
 func (o *Operation) Save(
 	ctx context.Context,
 ) error {
-	ext := db.Ext(ctx, "mint")
-	_, err := sqlx.NamedExec(ext, `
-UPDATE operations
-SET status = :status
-WHERE owner = :owner
-  AND token = :token
-`, o)
-	if err != nil {
-		return errors.Trace(err)
-	}
-
-	return nil
+	return getStore(ctx).Operations().Save(ctx, o)
 }
 
 // LoadCanonicalOperationByOwnerToken attempts to load the canonical operation
@@ -203,31 +156,8 @@ func LoadCanonicalOperationByOwnerToken(
 	owner string,
 	token string,
 ) (*Operation, error) {
-	operation := Operation{
-		Owner:       owner,
-		Token:       token,
-		Propagation: mint.PgTpCanonical,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM operations
-WHERE owner = :owner
-  AND token = :token
-  AND propagation = :propagation
-`, operation); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&operation); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	return &operation, nil
+	return getStore(ctx).Operations().LoadByOwnerToken(ctx,
+		owner, token, mint.PgTpCanonical)
 }
 
 // LoadCanonicalOperationByID attempts to load the canonical operation for the
@@ -250,30 +180,8 @@ func LoadCanonicalOperationByTransactionHop(
 	transaction string,
 	hop int8,
 ) (*Operation, error) {
-	operation := Operation{
-		Transaction: &transaction,
-		Hop:         &hop,
-		Propagation: mint.PgTpCanonical,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM operations
-WHERE txn = :txn
-  AND hop = :hop
-`, operation); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&operation); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	return &operation, nil
+	return getStore(ctx).Operations().LoadByTransactionHop(ctx,
+		transaction, hop)
 }
 
 // LoadCanonicalOperationsByTransaction loads all operations that are
@@ -282,35 +190,18 @@ func LoadCanonicalOperationsByTransaction(
 	ctx context.Context,
 	transaction string,
 ) ([]*Operation, error) {
-	query := map[string]interface{}{
-		"txn":         &transaction,
-		"propagation": mint.PgTpCanonical,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM operations
-WHERE txn = :txn
-  AND propagation = :propagation
-`, query)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	operations := []*Operation{}
-
-	defer rows.Close()
-	for rows.Next() {
-		op := Operation{}
-		err := rows.StructScan(&op)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		operations = append(operations, &op)
-	}
+	return getStore(ctx).Operations().ListByTransaction(ctx,
+		transaction, mint.PgTpCanonical)
+}
 
-	return operations, nil
+// LoadCanonicalReservedOperationsByDestinationHost loads all reserved
+// canonical operations whose destination is a user of the specified mint host.
+func LoadCanonicalReservedOperationsByDestinationHost(
+	ctx context.Context,
+	host string,
+) ([]*Operation, error) {
+	return getStore(ctx).Operations().ListByDestinationHost(ctx,
+		host, mint.PgTpCanonical, mint.TxStReserved)
 }
 
 // LoadPropagatedOperationByOwnerToken attempts to load the propagated
@@ -320,29 +211,6 @@ func LoadPropagatedOperationByOwnerToken(
 	owner string,
 	token string,
 ) (*Operation, error) {
-	operation := Operation{
-		Owner:       owner,
-		Token:       token,
-		Propagation: mint.PgTpPropagated,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM operations
-WHERE owner = :owner
-  AND token = :token
-  AND propagation = :propagation
-`, operation); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&operation); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	return &operation, nil
+	return getStore(ctx).Operations().LoadByOwnerToken(ctx,
+		owner, token, mint.PgTpPropagated)
 }
@@ -0,0 +1,184 @@
+package model
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+)
+
+// sqlOperations is the SQL OperationRepository.
+type sqlOperations struct{}
+
+func (r sqlOperations) Create(
+	ctx context.Context,
+	operation *Operation,
+) error {
+	ext := db.Ext(ctx, "mint")
+	if _, err := sqlx.NamedExec(ext, `
+INSERT INTO operations
+  (mint, owner, token, created, propagation, asset, source, destination,
+   amount, status, txn, hop)
+VALUES
+  (:mint, :owner, :token, :created, :propagation, :asset, :source, :destination,
+   :amount, :status, :txn, :hop)
+`, operation); err != nil {
+		return errors.Trace(sqlInsertError(err))
+	}
+
+	return nil
+}
+
+func (r sqlOperations) Save(
+	ctx context.Context,
+	operation *Operation,
+) error {
+	ext := db.Ext(ctx, "mint")
+	_, err := sqlx.NamedExec(ext, `
+UPDATE operations
+SET status = :status
+WHERE mint = :mint
+  AND owner = :owner
+  AND token = :token
+`, operation)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+func (r sqlOperations) LoadByOwnerToken(
+	ctx context.Context,
+	owner string,
+	token string,
+	propagation mint.PgType,
+) (*Operation, error) {
+	operation := Operation{
+		Mint:        mint.GetHost(ctx),
+		Owner:       owner,
+		Token:       token,
+		Propagation: propagation,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM operations
+WHERE mint = :mint
+  AND owner = :owner
+  AND token = :token
+  AND propagation = :propagation
+`, operation); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		return nil, nil
+	} else if err := rows.StructScan(&operation); err != nil {
+		defer rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &operation, nil
+}
+
+func (r sqlOperations) LoadByTransactionHop(
+	ctx context.Context,
+	transaction string,
+	hop int8,
+) (*Operation, error) {
+	operation := Operation{
+		Mint:        mint.GetHost(ctx),
+		Transaction: &transaction,
+		Hop:         &hop,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM operations
+WHERE mint = :mint
+  AND txn = :txn
+  AND hop = :hop
+`, operation); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		return nil, nil
+	} else if err := rows.StructScan(&operation); err != nil {
+		defer rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &operation, nil
+}
+
+func (r sqlOperations) ListByTransaction(
+	ctx context.Context,
+	transaction string,
+	propagation mint.PgType,
+) ([]*Operation, error) {
+	return r.list(ctx, `
+SELECT *
+FROM operations
+WHERE mint = :mint
+  AND txn = :txn
+  AND propagation = :propagation
+`, map[string]interface{}{
+		"mint":        mint.GetHost(ctx),
+		"txn":         &transaction,
+		"propagation": propagation,
+	})
+}
+
+func (r sqlOperations) ListByDestinationHost(
+	ctx context.Context,
+	host string,
+	propagation mint.PgType,
+	status mint.TxStatus,
+) ([]*Operation, error) {
+	return r.list(ctx, `
+SELECT *
+FROM operations
+WHERE mint = :mint
+  AND destination LIKE :destination
+  AND propagation = :propagation
+  AND status = :status
+`, map[string]interface{}{
+		"mint":        mint.GetHost(ctx),
+		"destination": "%@" + host,
+		"propagation": propagation,
+		"status":      status,
+	})
+}
+
+// list loads the operations returned by the provided query.
+func (r sqlOperations) list(
+	ctx context.Context,
+	query string,
+	arg map[string]interface{},
+) ([]*Operation, error) {
+	ext := db.Ext(ctx, "mint")
+	rows, err := sqlx.NamedQuery(ext, query, arg)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	operations := []*Operation{}
+
+	defer rows.Close()
+	for rows.Next() {
+		op := Operation{}
+		err := rows.StructScan(&op)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		operations = append(operations, &op)
+	}
+
+	return operations, nil
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/spolu/settle/lib/clock"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+)
+
+// Peer represents the policy applied to a peer mint as set with the admin API.
+// It takes precedence over the peer policy file of the mint.
+type Peer struct {
+	Mint    string // Host of the mint the object belongs to.
+	Host    string // Host of the peer mint.
+	Created time.Time
+
+	Policy      mint.PrPolicy
+	ExposureCap *Amount `db:"exposure_cap"` // Maximum reserved per asset.
+}
+
+// NewPeerResource generates a new resource.
+func NewPeerResource(
+	ctx context.Context,
+	peer *Peer,
+) mint.PeerResource {
+	r := mint.PeerResource{
+		Host:   peer.Host,
+		Source: "admin",
+		Policy: peer.Policy,
+	}
+	if peer.ExposureCap != nil {
+		r.ExposureCap = (*big.Int)(peer.ExposureCap)
+	}
+	return r
+}
+
+// CreatePeer creates and stores a new Peer.
+func CreatePeer(
+	ctx context.Context,
+	host string,
+	policy mint.PrPolicy,
+	exposureCap *Amount,
+) (*Peer, error) {
+	peer := Peer{
+		Mint:    mint.GetHost(ctx),
+		Host:    host,
+		Created: clock.Now(ctx).UTC(),
+
+		Policy:      policy,
+		ExposureCap: exposureCap,
+	}
+
+	if err := getStore(ctx).Peers().Create(ctx, &peer); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &peer, nil
+}
+
+// Save updates the object database representation with the in-memory values.
+func (p *Peer) Save(
+	ctx context.Context,
+) error {
+	return getStore(ctx).Peers().Save(ctx, p)
+}
+
+// Delete deletes the peer, reverting to the peer policy file of the mint.
+func (p *Peer) Delete(
+	ctx context.Context,
+) error {
+	return getStore(ctx).Peers().Delete(ctx, p)
+}
+
+// LoadPeerByHost attempts to load the peer with the given host.
+func LoadPeerByHost(
+	ctx context.Context,
+	host string,
+) (*Peer, error) {
+	return getStore(ctx).Peers().LoadByHost(ctx, host)
+}
+
+// LoadPeerList loads all the peers of the current mint host.
+func LoadPeerList(
+	ctx context.Context,
+) ([]Peer, error) {
+	return getStore(ctx).Peers().List(ctx)
+}
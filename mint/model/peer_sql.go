@@ -0,0 +1,128 @@
+package model
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+)
+
+// sqlPeers is the SQL PeerRepository.
+type sqlPeers struct{}
+
+func (r sqlPeers) Create(
+	ctx context.Context,
+	peer *Peer,
+) error {
+	ext := db.Ext(ctx, "mint")
+	if _, err := sqlx.NamedExec(ext, `
+INSERT INTO peers
+  (mint, host, created, policy, exposure_cap)
+VALUES
+  (:mint, :host, :created, :policy, :exposure_cap)
+`, peer); err != nil {
+		return errors.Trace(sqlInsertError(err))
+	}
+
+	return nil
+}
+
+func (r sqlPeers) Save(
+	ctx context.Context,
+	peer *Peer,
+) error {
+	ext := db.Ext(ctx, "mint")
+	_, err := sqlx.NamedExec(ext, `
+UPDATE peers
+SET policy = :policy, exposure_cap = :exposure_cap
+WHERE mint = :mint
+  AND host = :host
+`, peer)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+func (r sqlPeers) Delete(
+	ctx context.Context,
+	peer *Peer,
+) error {
+	ext := db.Ext(ctx, "mint")
+	_, err := sqlx.NamedExec(ext, `
+DELETE FROM peers
+WHERE mint = :mint
+  AND host = :host
+`, peer)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+func (r sqlPeers) LoadByHost(
+	ctx context.Context,
+	host string,
+) (*Peer, error) {
+	peer := Peer{
+		Mint: mint.GetHost(ctx),
+		Host: host,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM peers
+WHERE mint = :mint
+  AND host = :host
+`, peer); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		rows.Close()
+		return nil, nil
+	} else if err := rows.StructScan(&peer); err != nil {
+		rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &peer, nil
+}
+
+func (r sqlPeers) List(
+	ctx context.Context,
+) ([]Peer, error) {
+	query := map[string]interface{}{
+		"mint": mint.GetHost(ctx),
+	}
+
+	ext := db.Ext(ctx, "mint")
+	rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM peers
+WHERE mint = :mint
+ORDER BY host
+`, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	peers := []Peer{}
+
+	defer rows.Close()
+	for rows.Next() {
+		p := Peer{}
+		err := rows.StructScan(&p)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		peers = append(peers, p)
+	}
+
+	return peers, nil
+}
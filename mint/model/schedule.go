@@ -0,0 +1,105 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/spolu/settle/lib/clock"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+)
+
+// Schedule represents the persisted state of a periodic task for a mint host.
+type Schedule struct {
+	Mint    string // Host of the mint the object belongs to.
+	Name    mint.TkName
+	Created time.Time
+
+	Next      time.Time  // Time after which the periodic task is executed.
+	Last      *time.Time // Time of the last execution.
+	LastError *string    `db:"last_error"` // Error of the last execution.
+
+	LeaseOwner  *string    `db:"lease_owner"`  // Worker executing the task.
+	LeaseExpiry *time.Time `db:"lease_expiry"` // Expiry of the worker lease.
+}
+
+// CreateSchedule creates and stores a new Schedule.
+func CreateSchedule(
+	ctx context.Context,
+	name mint.TkName,
+	next time.Time,
+) (*Schedule, error) {
+	schedule := Schedule{
+		Mint:    mint.GetHost(ctx),
+		Name:    name,
+		Created: clock.Now(ctx).UTC(),
+		Next:    next.UTC(),
+	}
+
+	if err := getStore(ctx).Schedules().Create(ctx, &schedule); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &schedule, nil
+}
+
+// LoadScheduleByName attempts to load the schedule of the periodic task with
+// the given name for the current mint host.
+func LoadScheduleByName(
+	ctx context.Context,
+	name mint.TkName,
+) (*Schedule, error) {
+	return getStore(ctx).Schedules().LoadByName(ctx, name)
+}
+
+// ClaimSchedule leases the schedule with the earliest next execution before
+// now that is not leased by another worker (or whose lease has expired) to
+// owner until expiry. Schedules are claimed for all the mint hosts served by
+// this process. It returns nil if there is no schedule to claim. As for
+// tasks, claims are atomic across processes sharing the same DB.
+func ClaimSchedule(
+	ctx context.Context,
+	owner string,
+	now time.Time,
+	expiry time.Time,
+) (*Schedule, error) {
+	return getStore(ctx).Schedules().Claim(ctx,
+		mint.GetHosts(ctx), owner, now, expiry)
+}
+
+// RenewLease extends the lease of owner on the schedule until expiry. It
+// returns false if owner does not hold the lease anymore.
+func (o *Schedule) RenewLease(
+	ctx context.Context,
+	owner string,
+	expiry time.Time,
+) (bool, error) {
+	renewed, err := getStore(ctx).Schedules().RenewLease(ctx,
+		o, owner, expiry)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	if renewed {
+		e := expiry.UTC()
+		o.LeaseExpiry = &e
+	}
+	return renewed, nil
+}
+
+// Release saves the in-memory next execution, last execution and last error
+// of the schedule and releases the lease of owner on it. It returns false
+// (without saving) if owner does not hold the lease anymore.
+func (o *Schedule) Release(
+	ctx context.Context,
+	owner string,
+) (bool, error) {
+	released, err := getStore(ctx).Schedules().Release(ctx, o, owner)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	if released {
+		o.LeaseOwner = nil
+		o.LeaseExpiry = nil
+	}
+	return released, nil
+}
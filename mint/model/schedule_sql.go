@@ -0,0 +1,192 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+)
+
+// sqlSchedules is the SQL ScheduleRepository.
+type sqlSchedules struct{}
+
+func (r sqlSchedules) Create(
+	ctx context.Context,
+	schedule *Schedule,
+) error {
+	ext := db.Ext(ctx, "mint")
+	if _, err := sqlx.NamedExec(ext, `
+INSERT INTO schedules
+  (mint, name, created, next)
+VALUES
+  (:mint, :name, :created, :next)
+`, schedule); err != nil {
+		return errors.Trace(sqlInsertError(err))
+	}
+
+	return nil
+}
+
+func (r sqlSchedules) LoadByName(
+	ctx context.Context,
+	name mint.TkName,
+) (*Schedule, error) {
+	schedule := Schedule{
+		Mint: mint.GetHost(ctx),
+		Name: name,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM schedules
+WHERE mint = :mint
+  AND name = :name
+`, schedule); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		rows.Close()
+		return nil, nil
+	} else if err := rows.StructScan(&schedule); err != nil {
+		rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &schedule, nil
+}
+
+func (r sqlSchedules) Claim(
+	ctx context.Context,
+	hosts []string,
+	owner string,
+	now time.Time,
+	expiry time.Time,
+) (*Schedule, error) {
+	query := map[string]interface{}{
+		"hosts":        hosts,
+		"now":          now.UTC(),
+		"lease_owner":  owner,
+		"lease_expiry": expiry.UTC(),
+	}
+
+	ext := db.Ext(ctx, "mint")
+	for {
+		schedule := Schedule{}
+		if rows, err := namedQueryIn(ext, `
+SELECT *
+FROM schedules
+WHERE mint IN (:hosts)
+  AND next <= :now
+  AND (lease_expiry IS NULL OR lease_expiry < :now)
+ORDER BY next
+LIMIT 1
+`, query); err != nil {
+			return nil, errors.Trace(err)
+		} else if !rows.Next() {
+			rows.Close()
+			return nil, nil
+		} else if err := rows.StructScan(&schedule); err != nil {
+			rows.Close()
+			return nil, errors.Trace(err)
+		} else {
+			rows.Close()
+		}
+
+		query["mint"] = schedule.Mint
+		query["name"] = schedule.Name
+		res, err := sqlx.NamedExec(ext, `
+UPDATE schedules
+SET lease_owner = :lease_owner, lease_expiry = :lease_expiry
+WHERE mint = :mint
+  AND name = :name
+  AND next <= :now
+  AND (lease_expiry IS NULL OR lease_expiry < :now)
+`, query)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if count == 1 {
+			schedule.LeaseOwner = &owner
+			e := expiry.UTC()
+			schedule.LeaseExpiry = &e
+			return &schedule, nil
+		}
+		// The schedule was claimed by another worker in the meantime, look
+		// for another one.
+	}
+}
+
+func (r sqlSchedules) RenewLease(
+	ctx context.Context,
+	schedule *Schedule,
+	owner string,
+	expiry time.Time,
+) (bool, error) {
+	ext := db.Ext(ctx, "mint")
+	res, err := sqlx.NamedExec(ext, `
+UPDATE schedules
+SET lease_expiry = :lease_expiry
+WHERE mint = :mint
+  AND name = :name
+  AND lease_owner = :lease_owner
+`, map[string]interface{}{
+		"mint":         schedule.Mint,
+		"name":         schedule.Name,
+		"lease_owner":  owner,
+		"lease_expiry": expiry.UTC(),
+	})
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return count == 1, nil
+}
+
+func (r sqlSchedules) Release(
+	ctx context.Context,
+	schedule *Schedule,
+	owner string,
+) (bool, error) {
+	var last *time.Time
+	if schedule.Last != nil {
+		l := schedule.Last.UTC()
+		last = &l
+	}
+
+	ext := db.Ext(ctx, "mint")
+	res, err := sqlx.NamedExec(ext, `
+UPDATE schedules
+SET next = :next, last = :last, last_error = :last_error,
+  lease_owner = NULL, lease_expiry = NULL
+WHERE mint = :mint
+  AND name = :name
+  AND lease_owner = :lease_owner
+`, map[string]interface{}{
+		"mint":        schedule.Mint,
+		"name":        schedule.Name,
+		"next":        schedule.Next.UTC(),
+		"last":        last,
+		"last_error":  schedule.LastError,
+		"lease_owner": owner,
+	})
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return count == 1, nil
+}
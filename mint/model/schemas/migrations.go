@@ -0,0 +1,327 @@
+package schemas
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+)
+
+// Migrations upgrade the tables of mints created by previous versions of the
+// schemas above. Each migration embeds the schema of its table as of the
+// migration, so that later migrations of the same table still apply on top of
+// it. Values missing from previous versions (such as the mint host of each
+// row) are backfilled with the primary host of the mint.
+
+const (
+	usersMintSQL = `
+CREATE TABLE users(
+  mint VARCHAR(256) NOT NULL,
+  token VARCHAR(256) NOT NULL,
+  created TIMESTAMP NOT NULL,
+
+  username VARCHAR(256) NOT NULL,
+  password_hash VARCHAR(256) NOT NULL,
+
+  PRIMARY KEY(token),
+  CONSTRAINT users_username_u UNIQUE (mint, username)
+);
+`
+	assetsMintSQL = `
+CREATE TABLE assets(
+  mint VARCHAR(256) NOT NULL,        -- mint host
+  owner VARCHAR(256) NOT NULL,       -- owner address
+  token VARCHAR(256) NOT NULL,       -- token
+  created TIMESTAMP NOT NULL,
+  propagation VARCHAR(32) NOT NULL,  -- propagation type (canonical, propagated)
+
+  code VARCHAR(64) NOT NULL,    -- the code of the asset
+  scale SMALLINT,               -- factor by which the asset native is scaled
+
+  PRIMARY KEY(mint, owner, token),
+  CONSTRAINT assets_owner_code_scale_u UNIQUE (mint, owner, code, scale)
+);
+`
+	operationsMintSQL = `
+CREATE TABLE operations(
+  mint VARCHAR(256) NOT NULL,        -- mint host
+  owner VARCHAR(256) NOT NULL,       -- owner address
+  token VARCHAR(256) NOT NULL,       -- token
+  created TIMESTAMP NOT NULL,
+  propagation VARCHAR(32) NOT NULL,  -- propagation type (canonical, propagated)
+
+  asset VARCHAR(256) NOT NULL,       -- asset name
+  source VARCHAR(256) NOT NULL,      -- source address
+  destination VARCHAR(256) NOT NULL, -- destination address
+  amount VARCHAR(64) NOT NULL,       -- operation amount
+
+  status VARCHAR(32) NOT NULL,       -- status (reserved, settled, canceled)
+  txn VARCHAR(256),                  -- transaction id
+  hop SMALLINT,                      -- transaction hop
+
+  PRIMARY KEY(mint, owner, token)
+);
+`
+	balancesMintSQL = `
+CREATE TABLE balances(
+  mint VARCHAR(256) NOT NULL,        -- mint host
+  owner VARCHAR(256) NOT NULL,       -- owner address
+  token VARCHAR(256) NOT NULL,       -- token
+  created TIMESTAMP NOT NULL,
+  propagation VARCHAR(32) NOT NULL,  -- propagation type (canonical, propagated)
+
+  asset VARCHAR(256) NOT NULL,  -- asset name
+  holder VARCHAR(256) NOT NULL, -- balance holder address
+  value VARCHAR(64) NOT NULL,   -- balance value
+
+  PRIMARY KEY(mint, owner, token),
+  CONSTRAINT balances_asset_holder_u UNIQUE (mint, asset, holder)
+);
+`
+	offersMintSQL = `
+CREATE TABLE offers(
+  mint VARCHAR(256) NOT NULL,        -- mint host
+  owner VARCHAR(256) NOT NULL,       -- owner address
+  token VARCHAR(256) NOT NULL,       -- token
+  created TIMESTAMP NOT NULL,
+  propagation VARCHAR(32) NOT NULL,  -- propagation type (canonical, propagated)
+
+  base_asset VARCHAR(256) NOT NULL,  -- base asset name
+  quote_asset VARCHAR(256) NOT NULL, -- quote asset name
+
+  base_price VARCHAR(64) NOT NULL,   --  base asset price
+  quote_price VARCHAR(64) NOT NULL,  -- quote asset price
+  amount VARCHAR(64) NOT NULL,       -- amount of quote asset asked
+
+  status VARCHAR(32) NOT NULL,       -- status (active, closed, consumed)
+  remainder VARCHAR(64) NOT NULL,    -- remainder amount of quote asset asked
+
+  PRIMARY KEY(mint, owner, token)
+);
+`
+	transactionsMintSQL = `
+CREATE TABLE transactions(
+  mint VARCHAR(256) NOT NULL,        -- mint host
+  owner VARCHAR(256) NOT NULL,       -- owner address
+  token VARCHAR(256) NOT NULL,       -- token
+  created TIMESTAMP NOT NULL,
+  propagation VARCHAR(32) NOT NULL,  -- propagation type (canonical, propagated)
+
+  base_asset VARCHAR(256) NOT NULL,  -- base asset name
+  quote_asset VARCHAR(256) NOT NULL, -- quote asset name
+  amount VARCHAR(64) NOT NULL,       -- amount of quote asset asked
+  destination VARCHAR(256) NOT NULL, -- the recipient address
+  path VARCHAR(2048) NOT NULL,       -- join of offer ids
+
+  status VARCHAR(32) NOT NULL,       -- status (reserved, settled, canceled)
+  lock VARCHAR(256) NOT NULL,        -- lock = hex(scrypt(secret, id))
+  secret VARCHAR(256),               -- lock secret
+
+  PRIMARY KEY(mint, owner, token)
+);
+`
+	crossingsMintSQL = `
+CREATE TABLE crossings(
+  mint VARCHAR(256) NOT NULL,        -- mint host
+  owner VARCHAR(256) NOT NULL,       -- owner address
+  token VARCHAR(256) NOT NULL,       -- token
+  created TIMESTAMP NOT NULL,
+  propagation VARCHAR(32) NOT NULL,  -- propagation type (canonical, propagated)
+
+  offer VARCHAR(256) NOT NULL,  -- offer id
+  amount VARCHAR(64) NOT NULL,  -- crossing amount
+
+  status VARCHAR(32) NOT NULL,  -- status (reserved, settled, canceled)
+  txn VARCHAR(256) NOT NULL,    -- transaction id
+  hop SMALLINT NOT NULL,        -- transaction hop
+
+  PRIMARY KEY(mint, owner, token)
+);
+`
+)
+
+// rebuildWithMint returns a migration adding the mint column to a table whose
+// keys are scoped by mint, backfilled with the primary host.
+func rebuildWithMint(
+	table string,
+	schema string,
+	columns string,
+) db.Migration {
+	return db.Migration{
+		Name:   "mint_host",
+		Table:  table,
+		Column: "mint",
+		Migrate: func(ctx context.Context, tx *sqlx.Tx) error {
+			return db.RebuildTable(ctx, tx, table, schema,
+				"mint, "+columns, "CAST(? AS VARCHAR(256)), "+columns,
+				mint.GetHost(ctx))
+		},
+	}
+}
+
+// addColumn returns a migration adding a column to a table and running the
+// provided statements (with args as bind parameters) to backfill it.
+func addColumn(
+	name string,
+	table string,
+	column string,
+	definition string,
+	backfill []string,
+	args func(context.Context) []interface{},
+) db.Migration {
+	return db.Migration{
+		Name:   name,
+		Table:  table,
+		Column: column,
+		Migrate: func(ctx context.Context, tx *sqlx.Tx) error {
+			_, err := tx.Exec(
+				"ALTER TABLE " + table + " ADD COLUMN " + column + " " +
+					definition)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			for _, q := range backfill {
+				a := []interface{}{}
+				if args != nil {
+					a = args(ctx)
+				}
+				if _, err := tx.Exec(tx.Rebind(q), a...); err != nil {
+					return errors.Trace(err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// taskDeadlines returns a migration adding the deadline column to tasks,
+// backfilled with the deadline of their next execution (tasks used to compute
+// it from their creation time and retry count). Mints predating deadlines only
+// ran transaction expiries, retried every TransactionExpiryMs, and
+// propagations, retried with an exponential backoff.
+func taskDeadlines() db.Migration {
+	return db.Migration{
+		Name:   "deadline",
+		Table:  "tasks",
+		Column: "deadline",
+		Migrate: func(ctx context.Context, tx *sqlx.Tx) error {
+			_, err := tx.Exec("ALTER TABLE tasks ADD COLUMN deadline " +
+				"TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00'")
+			if err != nil {
+				return errors.Trace(err)
+			}
+
+			tasks := []struct {
+				Token   string
+				Created time.Time
+				Name    mint.TkName
+				Retry   uint
+			}{}
+			err = tx.Select(&tasks, `
+SELECT token, created, name, COALESCE(retry, 0) AS retry
+FROM tasks
+`)
+			if err != nil {
+				return errors.Trace(err)
+			}
+
+			expiry := time.Duration(mint.TransactionExpiryMs) * time.Millisecond
+			for _, t := range tasks {
+				deadline := t.Created
+				if t.Name == mint.TkExpireTransaction {
+					deadline = t.Created.Add(time.Duration(t.Retry+1) * expiry)
+				} else if t.Retry > 0 {
+					shift := t.Retry
+					if shift > 32 {
+						shift = 32
+					}
+					deadline = t.Created.Add(time.Duration(1<<shift-1) *
+						mint.PropagationRetryInterval)
+				}
+				_, err := tx.Exec(tx.Rebind(
+					"UPDATE tasks SET deadline = ? WHERE token = ?"),
+					deadline.UTC(), t.Token)
+				if err != nil {
+					return errors.Trace(err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// primaryHost returns the primary host of the mint as bind parameter.
+func primaryHost(
+	ctx context.Context,
+) []interface{} {
+	return []interface{}{mint.GetHost(ctx)}
+}
+
+func init() {
+	// Mints used to serve a single host and their rows were not scoped by
+	// mint host.
+	for _, m := range []db.Migration{
+		rebuildWithMint("users", usersMintSQL,
+			"token, created, username, password_hash"),
+		rebuildWithMint("assets", assetsMintSQL,
+			"owner, token, created, propagation, code, scale"),
+		rebuildWithMint("operations", operationsMintSQL,
+			"owner, token, created, propagation, asset, source, "+
+				"destination, amount, status, txn, hop"),
+		rebuildWithMint("balances", balancesMintSQL,
+			"owner, token, created, propagation, asset, holder, value"),
+		rebuildWithMint("offers", offersMintSQL,
+			"owner, token, created, propagation, base_asset, quote_asset, "+
+				"base_price, quote_price, amount, status, remainder"),
+		rebuildWithMint("transactions", transactionsMintSQL,
+			"owner, token, created, propagation, base_asset, quote_asset, "+
+				"amount, destination, path, status, lock, secret"),
+		rebuildWithMint("crossings", crossingsMintSQL,
+			"owner, token, created, propagation, offer, amount, status, "+
+				"txn, hop"),
+		addColumn("mint_host", "tasks", "mint",
+			"VARCHAR(256) NOT NULL DEFAULT ''",
+			[]string{"UPDATE tasks SET mint = ?"}, primaryHost),
+
+		// Transactions and tasks record the trace ID of the request that
+		// created them, rows created before have none.
+		addColumn("trace", "transactions", "trace",
+			"VARCHAR(256) NOT NULL DEFAULT ''", nil, nil),
+		addColumn("trace", "tasks", "trace",
+			"VARCHAR(256) NOT NULL DEFAULT ''", nil, nil),
+
+		// Tasks are leased by workers from the DB and their next execution
+		// deadline is persisted.
+		taskDeadlines(),
+		addColumn("lease_owner", "tasks", "lease_owner",
+			"VARCHAR(256)", nil, nil),
+		addColumn("lease_expiry", "tasks", "lease_expiry",
+			"TIMESTAMP", nil, nil),
+
+		// Tasks record the error of their last failed execution.
+		addColumn("last_error", "tasks", "last_error",
+			"VARCHAR(4096)", nil, nil),
+
+		// Tasks persist their arguments other than their subject in their
+		// payload. Tasks queued before have none (see parseLegacySubject).
+		addColumn("payload", "tasks", "payload",
+			"TEXT NOT NULL DEFAULT '{}'", nil, nil),
+
+		// Users can be disabled by admins.
+		addColumn("disabled", "users", "disabled", "TIMESTAMP", nil, nil),
+
+		// Canonical transactions record the API key that created them to
+		// enforce its pay limit, transactions created before have none.
+		addColumn("api_key", "transactions", "api_key",
+			"VARCHAR(256)", nil, nil),
+
+		// Tasks requeued by admins are retried from their requeue instead of
+		// their creation.
+		addColumn("requeued", "tasks", "requeued", "TIMESTAMP", nil, nil),
+	} {
+		db.RegisterMigration("mint", m)
+	}
+}
@@ -5,14 +5,16 @@ import "github.com/spolu/settle/lib/db"
 const (
 	usersSQL = `
 CREATE TABLE IF NOT EXISTS users(
+  mint VARCHAR(256) NOT NULL,
   token VARCHAR(256) NOT NULL,
   created TIMESTAMP NOT NULL,
 
   username VARCHAR(256) NOT NULL,
   password_hash VARCHAR(256) NOT NULL,
+  disabled TIMESTAMP,                -- time at which the user was disabled
 
   PRIMARY KEY(token),
-  CONSTRAINT users_username_u UNIQUE (username)
+  CONSTRAINT users_username_u UNIQUE (mint, username)
 );
 `
 )
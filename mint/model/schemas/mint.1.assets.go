@@ -5,6 +5,7 @@ import "github.com/spolu/settle/lib/db"
 const (
 	assetsSQL = `
 CREATE TABLE IF NOT EXISTS assets(
+  mint VARCHAR(256) NOT NULL,        -- mint host
   owner VARCHAR(256) NOT NULL,       -- owner address
   token VARCHAR(256) NOT NULL,       -- token
   created TIMESTAMP NOT NULL,
@@ -13,8 +14,8 @@ CREATE TABLE IF NOT EXISTS assets(
   code VARCHAR(64) NOT NULL,    -- the code of the asset
   scale SMALLINT,               -- factor by which the asset native is scaled
 
-  PRIMARY KEY(owner, token),
-  CONSTRAINT assets_owner_code_scale_u UNIQUE (owner, code, scale)
+  PRIMARY KEY(mint, owner, token),
+  CONSTRAINT assets_owner_code_scale_u UNIQUE (mint, owner, code, scale)
 );
 `
 )
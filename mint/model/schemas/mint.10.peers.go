@@ -0,0 +1,26 @@
+package schemas
+
+import "github.com/spolu/settle/lib/db"
+
+const (
+	peersSQL = `
+CREATE TABLE IF NOT EXISTS peers(
+  mint VARCHAR(256) NOT NULL,   -- mint host
+  host VARCHAR(256) NOT NULL,   -- peer mint host
+  created TIMESTAMP NOT NULL,
+
+  policy VARCHAR(32) NOT NULL,       -- allow, block
+  exposure_cap VARCHAR(64),          -- maximum reserved amount per asset
+
+  PRIMARY KEY(mint, host)
+);
+`
+)
+
+func init() {
+	db.RegisterSchema(
+		"mint",
+		"peers",
+		peersSQL,
+	)
+}
@@ -0,0 +1,23 @@
+package schemas
+
+import "github.com/spolu/settle/lib/db"
+
+const (
+	healthChecksSQL = `
+CREATE TABLE IF NOT EXISTS health_checks(
+  mint VARCHAR(256) NOT NULL,   -- mint host
+  token VARCHAR(256) NOT NULL,  -- token
+  created TIMESTAMP NOT NULL,
+
+  PRIMARY KEY(token)
+);
+`
+)
+
+func init() {
+	db.RegisterSchema(
+		"mint",
+		"health_checks",
+		healthChecksSQL,
+	)
+}
@@ -5,6 +5,7 @@ import "github.com/spolu/settle/lib/db"
 const (
 	operationsSQL = `
 CREATE TABLE IF NOT EXISTS operations(
+  mint VARCHAR(256) NOT NULL,        -- mint host
   owner VARCHAR(256) NOT NULL,       -- owner address
   token VARCHAR(256) NOT NULL,       -- token
   created TIMESTAMP NOT NULL,
@@ -19,7 +20,7 @@ CREATE TABLE IF NOT EXISTS operations(
   txn VARCHAR(256),                  -- transaction id
   hop SMALLINT,                      -- transaction hop
 
-  PRIMARY KEY(owner, token)
+  PRIMARY KEY(mint, owner, token)
 );
 `
 )
@@ -5,6 +5,7 @@ import "github.com/spolu/settle/lib/db"
 const (
 	balancesSQL = `
 CREATE TABLE IF NOT EXISTS balances(
+  mint VARCHAR(256) NOT NULL,        -- mint host
   owner VARCHAR(256) NOT NULL,       -- owner address
   token VARCHAR(256) NOT NULL,       -- token
   created TIMESTAMP NOT NULL,
@@ -14,8 +15,8 @@ CREATE TABLE IF NOT EXISTS balances(
   holder VARCHAR(256) NOT NULL, -- balance holder address
   value VARCHAR(64) NOT NULL,   -- balance value
 
-  PRIMARY KEY(owner, token),
-  CONSTRAINT balances_asset_holder_u UNIQUE (asset, holder)
+  PRIMARY KEY(mint, owner, token),
+  CONSTRAINT balances_asset_holder_u UNIQUE (mint, asset, holder)
 );
 `
 )
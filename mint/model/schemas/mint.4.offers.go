@@ -5,6 +5,7 @@ import "github.com/spolu/settle/lib/db"
 const (
 	offersSQL = `
 CREATE TABLE IF NOT EXISTS offers(
+  mint VARCHAR(256) NOT NULL,        -- mint host
   owner VARCHAR(256) NOT NULL,       -- owner address
   token VARCHAR(256) NOT NULL,       -- token
   created TIMESTAMP NOT NULL,
@@ -20,7 +21,7 @@ CREATE TABLE IF NOT EXISTS offers(
   status VARCHAR(32) NOT NULL,       -- status (active, closed, consumed)
   remainder VARCHAR(64) NOT NULL,    -- remainder amount of quote asset asked
 
-  PRIMARY KEY(owner, token)
+  PRIMARY KEY(mint, owner, token)
 );
 `
 )
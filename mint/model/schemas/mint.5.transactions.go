@@ -5,6 +5,7 @@ import "github.com/spolu/settle/lib/db"
 const (
 	transactionsSQL = `
 CREATE TABLE IF NOT EXISTS transactions(
+  mint VARCHAR(256) NOT NULL,        -- mint host
   owner VARCHAR(256) NOT NULL,       -- owner address
   token VARCHAR(256) NOT NULL,       -- token
   created TIMESTAMP NOT NULL,
@@ -20,7 +21,10 @@ CREATE TABLE IF NOT EXISTS transactions(
   lock VARCHAR(256) NOT NULL,        -- lock = hex(scrypt(secret, id))
   secret VARCHAR(256),               -- lock secret
 
-  PRIMARY KEY(owner, token)
+  trace VARCHAR(256) NOT NULL,       -- trace id of the creating request
+  api_key VARCHAR(256),              -- token of the creating API key if any
+
+  PRIMARY KEY(mint, owner, token)
 );
 `
 )
@@ -5,6 +5,7 @@ import "github.com/spolu/settle/lib/db"
 const (
 	crossingsSQL = `
 CREATE TABLE IF NOT EXISTS crossings(
+  mint VARCHAR(256) NOT NULL,        -- mint host
   owner VARCHAR(256) NOT NULL,       -- owner address
   token VARCHAR(256) NOT NULL,       -- token
   created TIMESTAMP NOT NULL,
@@ -17,7 +18,7 @@ CREATE TABLE IF NOT EXISTS crossings(
   txn VARCHAR(256) NOT NULL,    -- transaction id
   hop SMALLINT NOT NULL,        -- transaction hop
 
-  PRIMARY KEY(owner, token)
+  PRIMARY KEY(mint, owner, token)
 );
 `
 )
@@ -5,14 +5,25 @@ import "github.com/spolu/settle/lib/db"
 const (
 	tasksSQL = `
 CREATE TABLE IF NOT EXISTS tasks(
+  mint VARCHAR(256) NOT NULL,   -- mint host
   token VARCHAR(256) NOT NULL,  -- token
   created TIMESTAMP NOT NULL,
 
   name VARCHAR(256) NOT NULL,                      -- task name
   subject VARCHAR(256) NOT NULL,                   -- task subject
+  payload TEXT NOT NULL,                           -- task arguments (JSON)
 
   status VARCHAR(32) NOT NULL,       -- status (pending, succeeded, failed)
   retry INT,                         -- retry count
+  deadline TIMESTAMP NOT NULL,       -- next execution deadline
+  requeued TIMESTAMP,                -- last requeue by an admin
+
+  lease_owner VARCHAR(256),          -- worker executing the task
+  lease_expiry TIMESTAMP,            -- expiry of the worker lease
+
+  last_error VARCHAR(4096),          -- error of the last failed execution
+
+  trace VARCHAR(256) NOT NULL,       -- trace id of the queuing request
 
   PRIMARY KEY(token)
 );
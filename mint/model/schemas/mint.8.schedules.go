@@ -0,0 +1,30 @@
+package schemas
+
+import "github.com/spolu/settle/lib/db"
+
+const (
+	schedulesSQL = `
+CREATE TABLE IF NOT EXISTS schedules(
+  mint VARCHAR(256) NOT NULL,   -- mint host
+  name VARCHAR(256) NOT NULL,   -- periodic task name
+  created TIMESTAMP NOT NULL,
+
+  next TIMESTAMP NOT NULL,           -- next scheduled execution
+  last TIMESTAMP,                    -- last execution
+  last_error VARCHAR(4096),          -- error of the last failed execution
+
+  lease_owner VARCHAR(256),          -- worker executing the periodic task
+  lease_expiry TIMESTAMP,            -- expiry of the worker lease
+
+  PRIMARY KEY(mint, name)
+);
+`
+)
+
+func init() {
+	db.RegisterSchema(
+		"mint",
+		"schedules",
+		schedulesSQL,
+	)
+}
@@ -0,0 +1,32 @@
+package schemas
+
+import "github.com/spolu/settle/lib/db"
+
+const (
+	apiKeysSQL = `
+CREATE TABLE IF NOT EXISTS api_keys(
+  mint VARCHAR(256) NOT NULL,   -- mint host
+  owner VARCHAR(256) NOT NULL,  -- user token
+  token VARCHAR(256) NOT NULL,
+  created TIMESTAMP NOT NULL,
+
+  name VARCHAR(256) NOT NULL,
+  secret_hash VARCHAR(256) NOT NULL, -- hex encoded sha256 of the secret
+  scopes VARCHAR(256) NOT NULL,      -- comma separated list of scopes
+  pay_limit VARCHAR(64),             -- maximum total of transactions
+  pay_limit_asset VARCHAR(256),      -- asset in which the pay limit applies
+  expiry TIMESTAMP,
+  revoked TIMESTAMP,
+
+  PRIMARY KEY(token)
+);
+`
+)
+
+func init() {
+	db.RegisterSchema(
+		"mint",
+		"api_keys",
+		apiKeysSQL,
+	)
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// sqlStore is the Store backed by the SQL db of the context (see db.Ext).
+type sqlStore struct{}
+
+func (s sqlStore) Assets() AssetRepository {
+	return sqlAssets{}
+}
+
+func (s sqlStore) Balances() BalanceRepository {
+	return sqlBalances{}
+}
+
+func (s sqlStore) Offers() OfferRepository {
+	return sqlOffers{}
+}
+
+func (s sqlStore) Operations() OperationRepository {
+	return sqlOperations{}
+}
+
+func (s sqlStore) Transactions() TransactionRepository {
+	return sqlTransactions{}
+}
+
+func (s sqlStore) Crossings() CrossingRepository {
+	return sqlCrossings{}
+}
+
+func (s sqlStore) Tasks() TaskRepository {
+	return sqlTasks{}
+}
+
+func (s sqlStore) Users() UserRepository {
+	return sqlUsers{}
+}
+
+func (s sqlStore) APIKeys() APIKeyRepository {
+	return sqlAPIKeys{}
+}
+
+func (s sqlStore) Peers() PeerRepository {
+	return sqlPeers{}
+}
+
+func (s sqlStore) Schedules() ScheduleRepository {
+	return sqlSchedules{}
+}
+
+func (s sqlStore) HealthChecks() HealthCheckRepository {
+	return sqlHealthChecks{}
+}
+
+// sqlInsertError converts the dialect specific unique violation errors
+// returned by inserts into an ErrUniqueConstraintViolation.
+func sqlInsertError(
+	err error,
+) error {
+	switch e := err.(type) {
+	case *pq.Error:
+		if e.Code.Name() == "unique_violation" {
+			return ErrUniqueConstraintViolation{e}
+		}
+	case sqlite3.Error:
+		if e.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return ErrUniqueConstraintViolation{e}
+		}
+	}
+	return err
+}
+
+// namedQueryIn is similar to sqlx.NamedQuery but expands the slice arguments
+// of the query (as in `mint IN (:hosts)`).
+func namedQueryIn(
+	ext sqlx.Ext,
+	query string,
+	arg map[string]interface{},
+) (*sqlx.Rows, error) {
+	q, args, err := sqlx.Named(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	q, args, err = sqlx.In(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	return ext.Queryx(ext.Rebind(q), args...)
+}
@@ -0,0 +1,209 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/mint"
+)
+
+// Store gives access to the repositories storing the mint objects. The model
+// functions use the SQL store when the "mint" db is a SQL db (see db.WithDB)
+// and the backend itself when it implements Store (see db.WithBackend and the
+// memory package).
+//
+// Repositories run within the transaction of the context if any (see
+// db.Begin) and are scoped to the mint host of the context (see mint.GetHost)
+// unless stated otherwise. Objects returned by repositories are owned by the
+// caller and objects passed to them are not retained.
+type Store interface {
+	Assets() AssetRepository
+	Balances() BalanceRepository
+	Offers() OfferRepository
+	Operations() OperationRepository
+	Transactions() TransactionRepository
+	Crossings() CrossingRepository
+	Tasks() TaskRepository
+	Users() UserRepository
+	APIKeys() APIKeyRepository
+	Peers() PeerRepository
+	Schedules() ScheduleRepository
+	HealthChecks() HealthCheckRepository
+}
+
+// AssetRepository stores assets.
+type AssetRepository interface {
+	Create(ctx context.Context, asset *Asset) error
+	LoadByOwnerCodeScale(ctx context.Context,
+		owner string, code string, scale int8,
+		propagation mint.PgType, lock bool) (*Asset, error)
+	ListByOwner(ctx context.Context,
+		owner string, createdBefore time.Time, limit uint) ([]Asset, error)
+}
+
+// BalanceRepository stores balances. There is at most one balance per asset,
+// holder pair.
+type BalanceRepository interface {
+	Create(ctx context.Context, balance *Balance) error
+	Save(ctx context.Context, balance *Balance) error
+	LoadByAssetHolder(ctx context.Context,
+		asset string, holder string, lock bool) (*Balance, error)
+	LoadByOwnerToken(ctx context.Context,
+		owner string, token string,
+		propagation mint.PgType) (*Balance, error)
+	ListByHolder(ctx context.Context,
+		holder string, createdBefore time.Time, limit uint) ([]Balance, error)
+	ListByAsset(ctx context.Context,
+		asset string, createdBefore time.Time, limit uint) ([]Balance, error)
+}
+
+// OfferRepository stores offers.
+type OfferRepository interface {
+	Create(ctx context.Context, offer *Offer) error
+	Save(ctx context.Context, offer *Offer) error
+	LoadByOwnerToken(ctx context.Context,
+		owner string, token string,
+		propagation mint.PgType, lock bool) (*Offer, error)
+	ListByBaseAsset(ctx context.Context,
+		asset string, createdBefore time.Time, limit uint) ([]Offer, error)
+	ListByQuoteAsset(ctx context.Context,
+		asset string, createdBefore time.Time, limit uint) ([]Offer, error)
+	ListByOwner(ctx context.Context,
+		owner string, propagation mint.PgType,
+		createdBefore time.Time, limit uint) ([]Offer, error)
+}
+
+// OperationRepository stores operations.
+type OperationRepository interface {
+	Create(ctx context.Context, operation *Operation) error
+	Save(ctx context.Context, operation *Operation) error
+	LoadByOwnerToken(ctx context.Context,
+		owner string, token string,
+		propagation mint.PgType) (*Operation, error)
+	LoadByTransactionHop(ctx context.Context,
+		transaction string, hop int8) (*Operation, error)
+	ListByTransaction(ctx context.Context,
+		transaction string, propagation mint.PgType) ([]*Operation, error)
+	ListByDestinationHost(ctx context.Context,
+		host string, propagation mint.PgType,
+		status mint.TxStatus) ([]*Operation, error)
+}
+
+// TransactionRepository stores transactions.
+type TransactionRepository interface {
+	Create(ctx context.Context, transaction *Transaction) error
+	Save(ctx context.Context, transaction *Transaction) error
+	LoadByOwnerToken(ctx context.Context,
+		owner string, token string,
+		propagation mint.PgType) (*Transaction, error)
+	// Load loads a transaction regardless of its propagation.
+	Load(ctx context.Context,
+		owner string, token string, lock bool) (*Transaction, error)
+	// ListByAPIKey lists the canonical transactions created with an API key.
+	ListByAPIKey(ctx context.Context, apiKey string) ([]*Transaction, error)
+}
+
+// CrossingRepository stores crossings.
+type CrossingRepository interface {
+	Create(ctx context.Context, crossing *Crossing) error
+	Save(ctx context.Context, crossing *Crossing) error
+	LoadByTransactionHop(ctx context.Context,
+		transaction string, hop int8,
+		propagation mint.PgType) (*Crossing, error)
+	ListByTransaction(ctx context.Context,
+		transaction string, propagation mint.PgType) ([]*Crossing, error)
+	ListByOffer(ctx context.Context,
+		offer string, createdBefore time.Time, limit uint) ([]*Crossing, error)
+}
+
+// TaskRepository stores tasks. Claims, pending tasks and overdue tasks span
+// the provided mint hosts (the hosts served by the process).
+type TaskRepository interface {
+	Create(ctx context.Context, task *Task) error
+	Save(ctx context.Context, task *Task) error
+	// Claim leases the claimable task with the earliest deadline to owner.
+	Claim(ctx context.Context, hosts []string,
+		owner string, now time.Time, expiry time.Time) (*Task, error)
+	// ClaimByToken leases the task to owner if it is pending or failed and
+	// not leased, returning whether it was.
+	ClaimByToken(ctx context.Context,
+		owner string, token string,
+		now time.Time, expiry time.Time) (bool, error)
+	// CancelByToken marks the task as canceled if it is pending and not
+	// leased, returning whether it was.
+	CancelByToken(ctx context.Context,
+		token string, now time.Time) (bool, error)
+	RenewLease(ctx context.Context,
+		task *Task, owner string, expiry time.Time) (bool, error)
+	Release(ctx context.Context, task *Task, owner string) (bool, error)
+	CountPending(ctx context.Context, hosts []string) (int, error)
+	ListPending(ctx context.Context, hosts []string) ([]*Task, error)
+	LoadByToken(ctx context.Context, token string) (*Task, error)
+	List(ctx context.Context,
+		createdBefore time.Time, limit uint,
+		status mint.TkStatus, name mint.TkName, subject string) ([]Task, error)
+	DeleteCreatedBefore(ctx context.Context,
+		status mint.TkStatus, createdBefore time.Time) (int64, error)
+	LoadOldestOverdue(ctx context.Context,
+		hosts []string, now time.Time) (*Task, error)
+}
+
+// UserRepository stores users. Usernames are unique per mint host.
+type UserRepository interface {
+	Create(ctx context.Context, user *User) error
+	Save(ctx context.Context, user *User) error
+	LoadByToken(ctx context.Context, token string) (*User, error)
+	LoadByUsername(ctx context.Context, username string) (*User, error)
+	List(ctx context.Context,
+		createdBefore time.Time, limit uint) ([]User, error)
+}
+
+// APIKeyRepository stores API keys.
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *APIKey) error
+	Save(ctx context.Context, key *APIKey) error
+	LoadByToken(ctx context.Context,
+		token string, lock bool) (*APIKey, error)
+	ListByOwner(ctx context.Context,
+		owner string, createdBefore time.Time, limit uint) ([]APIKey, error)
+}
+
+// PeerRepository stores peers.
+type PeerRepository interface {
+	Create(ctx context.Context, peer *Peer) error
+	Save(ctx context.Context, peer *Peer) error
+	Delete(ctx context.Context, peer *Peer) error
+	LoadByHost(ctx context.Context, host string) (*Peer, error)
+	List(ctx context.Context) ([]Peer, error)
+}
+
+// ScheduleRepository stores schedules. Claims span the provided mint hosts
+// (the hosts served by the process).
+type ScheduleRepository interface {
+	Create(ctx context.Context, schedule *Schedule) error
+	LoadByName(ctx context.Context, name mint.TkName) (*Schedule, error)
+	// Claim leases the claimable schedule with the earliest next execution
+	// to owner.
+	Claim(ctx context.Context, hosts []string,
+		owner string, now time.Time, expiry time.Time) (*Schedule, error)
+	RenewLease(ctx context.Context,
+		schedule *Schedule, owner string, expiry time.Time) (bool, error)
+	Release(ctx context.Context,
+		schedule *Schedule, owner string) (bool, error)
+}
+
+// HealthCheckRepository stores health checks.
+type HealthCheckRepository interface {
+	Create(ctx context.Context, check *HealthCheck) error
+}
+
+// getStore returns the store to use for the "mint" db of the context.
+func getStore(
+	ctx context.Context,
+) Store {
+	if s, ok := db.GetBackend(ctx, "mint").(Store); ok {
+		return s
+	}
+	return sqlStore{}
+}
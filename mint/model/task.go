@@ -2,27 +2,65 @@ package model
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
-	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
-	sqlite3 "github.com/mattn/go-sqlite3"
-	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/token"
+	"github.com/spolu/settle/lib/trace"
 	"github.com/spolu/settle/mint"
 )
 
 // Task represents a task object.
 type Task struct {
+	Mint    string // Host of the mint the object belongs to.
 	Token   string
 	Created time.Time
 
 	Name    mint.TkName
 	Subject string
+	Payload JSON // Arguments of the task other than its subject.
 
-	Status mint.TkStatus
-	Retry  uint
+	Status   mint.TkStatus
+	Retry    uint
+	Deadline time.Time  // Time after which the task can be executed.
+	Requeued *time.Time // Time of the last requeue of the task by an admin.
+
+	LeaseOwner  *string    `db:"lease_owner"`  // Worker executing the task.
+	LeaseExpiry *time.Time `db:"lease_expiry"` // Expiry of the worker lease.
+
+	LastError *string `db:"last_error"` // Error of the last failed execution.
+
+	Trace string // Trace ID of the request that queued the task.
+}
+
+// NewTaskResource generates a new resource.
+func NewTaskResource(
+	ctx context.Context,
+	task *Task,
+) mint.TaskResource {
+	r := mint.TaskResource{
+		ID:        task.Token,
+		Created:   task.Created.UnixNano() / mint.TimeResolutionNs,
+		Name:      task.Name,
+		Subject:   task.Subject,
+		Payload:   json.RawMessage(task.Payload),
+		Status:    task.Status,
+		Retry:     task.Retry,
+		Deadline:  task.Deadline.UnixNano() / mint.TimeResolutionNs,
+		LastError: task.LastError,
+		Trace:     task.Trace,
+	}
+	if task.Requeued != nil {
+		requeued := task.Requeued.UnixNano() / mint.TimeResolutionNs
+		r.Requeued = &requeued
+	}
+	if task.LeaseExpiry != nil {
+		expiry := task.LeaseExpiry.UnixNano() / mint.TimeResolutionNs
+		r.LeaseOwner = task.LeaseOwner
+		r.LeaseExpiry = &expiry
+	}
+	return r
 }
 
 // CreateTask creates and stores a new Task.
@@ -31,88 +69,193 @@ func CreateTask(
 	created time.Time,
 	name mint.TkName,
 	subject string,
+	payload JSON,
 	status mint.TkStatus,
 	retry uint,
+	deadline time.Time,
 ) (*Task, error) {
 	task := Task{
+		Mint:    mint.GetHost(ctx),
 		Token:   token.New("task"),
 		Created: created.UTC(),
 
-		Name:    name,
-		Subject: subject,
-		Status:  status,
-		Retry:   retry,
+		Name:     name,
+		Subject:  subject,
+		Payload:  payload,
+		Status:   status,
+		Retry:    retry,
+		Deadline: deadline.UTC(),
+
+		Trace: trace.Get(ctx),
 	}
 
-	ext := db.Ext(ctx, "mint")
-	if _, err := sqlx.NamedExec(ext, `
-INSERT INTO tasks
-  (token, created, name, subject, status, retry)
-VALUES
-  (:token, :created, :name, :subject, :status, :retry)
-`, task); err != nil {
-		switch err := err.(type) {
-		case *pq.Error:
-			if err.Code.Name() == "unique_violation" {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		case sqlite3.Error:
-			if err.ExtendedCode == sqlite3.ErrConstraintUnique {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		}
+	if err := getStore(ctx).Tasks().Create(ctx, &task); err != nil {
 		return nil, errors.Trace(err)
 	}
 
 	return &task, nil
 }
 
+// Scheduled returns the time from which the retries of the task are
+// scheduled: its creation, or its last requeue if it was requeued.
+func (o *Task) Scheduled() time.Time {
+	if o.Requeued != nil {
+		return *o.Requeued
+	}
+	return o.Created
+}
+
 // Save updates the object database representation with the in-memory values.
 func (o *Task) Save(
 	ctx context.Context,
 ) error {
-	ext := db.Ext(ctx, "mint")
-	_, err := sqlx.NamedExec(ext, `
-UPDATE tasks
-SET status = :status, retry = :retry
-WHERE token = :token
-`, o)
+	return getStore(ctx).Tasks().Save(ctx, o)
+}
+
+// ClaimTask leases the pending task with the earliest deadline before now
+// that is not leased by another worker (or whose lease has expired) to owner
+// until expiry. Tasks are claimed for all the mint hosts served by this
+// process. It returns nil if there is no task to claim. Claims are atomic
+// across processes sharing the same DB: if another worker claims the same
+// task concurrently, only one of them gets it.
+func ClaimTask(
+	ctx context.Context,
+	owner string,
+	now time.Time,
+	expiry time.Time,
+) (*Task, error) {
+	return getStore(ctx).Tasks().Claim(ctx,
+		mint.GetHosts(ctx), owner, now, expiry)
+}
+
+// ClaimTaskByToken leases the task with the provided token to owner until
+// expiry regardless of its deadline, as long as it is pending or failed and
+// not leased by another worker. It returns nil if the task does not exist or
+// cannot be claimed.
+func ClaimTaskByToken(
+	ctx context.Context,
+	owner string,
+	token string,
+	now time.Time,
+	expiry time.Time,
+) (*Task, error) {
+	claimed, err := getStore(ctx).Tasks().ClaimByToken(ctx,
+		owner, token, now, expiry)
 	if err != nil {
-		return errors.Trace(err)
+		return nil, errors.Trace(err)
+	}
+	if !claimed {
+		return nil, nil
 	}
 
-	return nil
+	return LoadTaskByToken(ctx, token)
 }
 
-// LoadPendingTasks loads all tasks that are marked as pending.
-func LoadPendingTasks(
+// CancelTaskByToken marks the task with the provided token as canceled if it
+// is pending and not leased by a worker (or its lease has expired). It returns
+// false if the task does not exist or cannot be canceled. The check and the
+// update are atomic so that a task can't be claimed while being canceled.
+func CancelTaskByToken(
 	ctx context.Context,
-) ([]*Task, error) {
-	query := Task{
-		Status: mint.TkStPending,
+	token string,
+	now time.Time,
+) (bool, error) {
+	return getStore(ctx).Tasks().CancelByToken(ctx, token, now)
+}
+
+// RenewLease extends the lease of owner on the task until expiry. It returns
+// false if owner does not hold the lease anymore.
+func (o *Task) RenewLease(
+	ctx context.Context,
+	owner string,
+	expiry time.Time,
+) (bool, error) {
+	renewed, err := getStore(ctx).Tasks().RenewLease(ctx, o, owner, expiry)
+	if err != nil {
+		return false, errors.Trace(err)
 	}
+	if renewed {
+		e := expiry.UTC()
+		o.LeaseExpiry = &e
+	}
+	return renewed, nil
+}
 
-	ext := db.Ext(ctx, "mint")
-	rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM tasks
-WHERE status = :status
-`, query)
+// Release saves the in-memory status, retry, deadline and last error of the
+// task and releases the lease of owner on it. It returns false (without
+// saving) if owner does not hold the lease anymore.
+func (o *Task) Release(
+	ctx context.Context,
+	owner string,
+) (bool, error) {
+	released, err := getStore(ctx).Tasks().Release(ctx, o, owner)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return false, errors.Trace(err)
 	}
+	if released {
+		o.LeaseOwner = nil
+		o.LeaseExpiry = nil
+	}
+	return released, nil
+}
 
-	tasks := []*Task{}
+// CountPendingTasks counts the tasks that are marked as pending, for all the
+// mint hosts served by this process.
+func CountPendingTasks(
+	ctx context.Context,
+) (int, error) {
+	return getStore(ctx).Tasks().CountPending(ctx, mint.GetHosts(ctx))
+}
 
-	defer rows.Close()
-	for rows.Next() {
-		op := Task{}
-		err := rows.StructScan(&op)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		tasks = append(tasks, &op)
-	}
+// LoadPendingTasks loads all tasks that are marked as pending, for all the
+// mint hosts served by this process.
+func LoadPendingTasks(
+	ctx context.Context,
+) ([]*Task, error) {
+	return getStore(ctx).Tasks().ListPending(ctx, mint.GetHosts(ctx))
+}
 
-	return tasks, nil
+// LoadTaskByToken attempts to load the task with the given token.
+func LoadTaskByToken(
+	ctx context.Context,
+	token string,
+) (*Task, error) {
+	return getStore(ctx).Tasks().LoadByToken(ctx, token)
+}
+
+// LoadTaskList loads a list of tasks filtered by status, name and subject
+// (empty values match any task).
+func LoadTaskList(
+	ctx context.Context,
+	createdBefore time.Time,
+	limit uint,
+	status mint.TkStatus,
+	name mint.TkName,
+	subject string,
+) ([]Task, error) {
+	return getStore(ctx).Tasks().List(ctx,
+		createdBefore, limit, status, name, subject)
+}
+
+// DeleteTasksCreatedBefore deletes the tasks with the provided status created
+// before the provided time that are not leased by a worker. It returns the
+// number of tasks deleted.
+func DeleteTasksCreatedBefore(
+	ctx context.Context,
+	status mint.TkStatus,
+	createdBefore time.Time,
+) (int64, error) {
+	return getStore(ctx).Tasks().DeleteCreatedBefore(ctx,
+		status, createdBefore)
+}
+
+// LoadOldestOverdueTask loads the pending task with the oldest deadline among
+// the ones that are eligible for execution but not claimed by any worker, for
+// all the mint hosts served by this process. It returns nil if there is none.
+func LoadOldestOverdueTask(
+	ctx context.Context,
+	now time.Time,
+) (*Task, error) {
+	return getStore(ctx).Tasks().LoadOldestOverdue(ctx,
+		mint.GetHosts(ctx), now)
 }
@@ -0,0 +1,446 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+)
+
+// sqlTasks is the SQL TaskRepository.
+type sqlTasks struct{}
+
+func (r sqlTasks) Create(
+	ctx context.Context,
+	task *Task,
+) error {
+	ext := db.Ext(ctx, "mint")
+	if _, err := sqlx.NamedExec(ext, `
+INSERT INTO tasks
+  (mint, token, created, name, subject, payload, status, retry, deadline,
+   trace)
+VALUES
+  (:mint, :token, :created, :name, :subject, :payload, :status, :retry,
+   :deadline, :trace)
+`, task); err != nil {
+		return errors.Trace(sqlInsertError(err))
+	}
+
+	return nil
+}
+
+func (r sqlTasks) Save(
+	ctx context.Context,
+	task *Task,
+) error {
+	ext := db.Ext(ctx, "mint")
+	_, err := sqlx.NamedExec(ext, `
+UPDATE tasks
+SET status = :status, retry = :retry, deadline = :deadline,
+  requeued = :requeued, lease_owner = :lease_owner,
+  lease_expiry = :lease_expiry, last_error = :last_error
+WHERE token = :token
+`, task)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+func (r sqlTasks) Claim(
+	ctx context.Context,
+	hosts []string,
+	owner string,
+	now time.Time,
+	expiry time.Time,
+) (*Task, error) {
+	query := map[string]interface{}{
+		"hosts":        hosts,
+		"status":       mint.TkStPending,
+		"now":          now.UTC(),
+		"lease_owner":  owner,
+		"lease_expiry": expiry.UTC(),
+	}
+
+	ext := db.Ext(ctx, "mint")
+	for {
+		task := Task{}
+		if rows, err := namedQueryIn(ext, `
+SELECT *
+FROM tasks
+WHERE mint IN (:hosts)
+  AND status = :status
+  AND deadline <= :now
+  AND (lease_expiry IS NULL OR lease_expiry < :now)
+ORDER BY deadline, created
+LIMIT 1
+`, query); err != nil {
+			return nil, errors.Trace(err)
+		} else if !rows.Next() {
+			rows.Close()
+			return nil, nil
+		} else if err := rows.StructScan(&task); err != nil {
+			rows.Close()
+			return nil, errors.Trace(err)
+		} else {
+			rows.Close()
+		}
+
+		query["token"] = task.Token
+		res, err := sqlx.NamedExec(ext, `
+UPDATE tasks
+SET lease_owner = :lease_owner, lease_expiry = :lease_expiry
+WHERE token = :token
+  AND status = :status
+  AND (lease_expiry IS NULL OR lease_expiry < :now)
+`, query)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if count == 1 {
+			task.LeaseOwner = &owner
+			e := expiry.UTC()
+			task.LeaseExpiry = &e
+			return &task, nil
+		}
+		// The task was claimed by another worker in the meantime, look for
+		// another one.
+	}
+}
+
+func (r sqlTasks) ClaimByToken(
+	ctx context.Context,
+	owner string,
+	token string,
+	now time.Time,
+	expiry time.Time,
+) (bool, error) {
+	ext := db.Ext(ctx, "mint")
+	res, err := sqlx.NamedExec(ext, `
+UPDATE tasks
+SET lease_owner = :lease_owner, lease_expiry = :lease_expiry
+WHERE mint = :mint
+  AND token = :token
+  AND status IN (:pending, :failed)
+  AND (lease_expiry IS NULL OR lease_expiry < :now)
+`, map[string]interface{}{
+		"mint":         mint.GetHost(ctx),
+		"token":        token,
+		"pending":      mint.TkStPending,
+		"failed":       mint.TkStFailed,
+		"now":          now.UTC(),
+		"lease_owner":  owner,
+		"lease_expiry": expiry.UTC(),
+	})
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return count == 1, nil
+}
+
+func (r sqlTasks) CancelByToken(
+	ctx context.Context,
+	token string,
+	now time.Time,
+) (bool, error) {
+	ext := db.Ext(ctx, "mint")
+	res, err := sqlx.NamedExec(ext, `
+UPDATE tasks
+SET status = :canceled, lease_owner = NULL, lease_expiry = NULL
+WHERE mint = :mint
+  AND token = :token
+  AND status = :pending
+  AND (lease_expiry IS NULL OR lease_expiry < :now)
+`, map[string]interface{}{
+		"mint":     mint.GetHost(ctx),
+		"token":    token,
+		"pending":  mint.TkStPending,
+		"canceled": mint.TkStCanceled,
+		"now":      now.UTC(),
+	})
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return count == 1, nil
+}
+
+func (r sqlTasks) RenewLease(
+	ctx context.Context,
+	task *Task,
+	owner string,
+	expiry time.Time,
+) (bool, error) {
+	ext := db.Ext(ctx, "mint")
+	res, err := sqlx.NamedExec(ext, `
+UPDATE tasks
+SET lease_expiry = :lease_expiry
+WHERE token = :token
+  AND lease_owner = :lease_owner
+`, map[string]interface{}{
+		"token":        task.Token,
+		"lease_owner":  owner,
+		"lease_expiry": expiry.UTC(),
+	})
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return count == 1, nil
+}
+
+func (r sqlTasks) Release(
+	ctx context.Context,
+	task *Task,
+	owner string,
+) (bool, error) {
+	ext := db.Ext(ctx, "mint")
+	res, err := sqlx.NamedExec(ext, `
+UPDATE tasks
+SET status = :status, retry = :retry, deadline = :deadline,
+  last_error = :last_error, lease_owner = NULL, lease_expiry = NULL
+WHERE token = :token
+  AND lease_owner = :lease_owner
+`, map[string]interface{}{
+		"token":       task.Token,
+		"status":      task.Status,
+		"retry":       task.Retry,
+		"deadline":    task.Deadline.UTC(),
+		"last_error":  task.LastError,
+		"lease_owner": owner,
+	})
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return count == 1, nil
+}
+
+func (r sqlTasks) CountPending(
+	ctx context.Context,
+	hosts []string,
+) (int, error) {
+	ext := db.Ext(ctx, "mint")
+	rows, err := namedQueryIn(ext, `
+SELECT COUNT(*)
+FROM tasks
+WHERE mint IN (:hosts)
+  AND status = :status
+`, map[string]interface{}{
+		"hosts":  hosts,
+		"status": mint.TkStPending,
+	})
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	count := 0
+	if rows.Next() {
+		if err := rows.Scan(&count); err != nil {
+			return 0, errors.Trace(err)
+		}
+	}
+
+	return count, nil
+}
+
+func (r sqlTasks) ListPending(
+	ctx context.Context,
+	hosts []string,
+) ([]*Task, error) {
+	query := map[string]interface{}{
+		"hosts":  hosts,
+		"status": mint.TkStPending,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	rows, err := namedQueryIn(ext, `
+SELECT *
+FROM tasks
+WHERE mint IN (:hosts)
+  AND status = :status
+`, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	tasks := []*Task{}
+
+	defer rows.Close()
+	for rows.Next() {
+		op := Task{}
+		err := rows.StructScan(&op)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		tasks = append(tasks, &op)
+	}
+
+	return tasks, nil
+}
+
+func (r sqlTasks) LoadByToken(
+	ctx context.Context,
+	token string,
+) (*Task, error) {
+	task := Task{
+		Mint:  mint.GetHost(ctx),
+		Token: token,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM tasks
+WHERE mint = :mint
+  AND token = :token
+`, task); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		rows.Close()
+		return nil, nil
+	} else if err := rows.StructScan(&task); err != nil {
+		rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &task, nil
+}
+
+func (r sqlTasks) List(
+	ctx context.Context,
+	createdBefore time.Time,
+	limit uint,
+	status mint.TkStatus,
+	name mint.TkName,
+	subject string,
+) ([]Task, error) {
+	query := map[string]interface{}{
+		"mint":           mint.GetHost(ctx),
+		"status":         string(status),
+		"name":           string(name),
+		"subject":        subject,
+		"created_before": createdBefore.UTC(),
+		"limit":          limit,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM tasks
+WHERE mint = :mint
+  AND (:status = '' OR status = :status)
+  AND (:name = '' OR name = :name)
+  AND (:subject = '' OR subject = :subject)
+  AND created < :created_before
+ORDER BY created DESC
+LIMIT :limit
+`, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	tasks := []Task{}
+
+	defer rows.Close()
+	for rows.Next() {
+		t := Task{}
+		err := rows.StructScan(&t)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		tasks = append(tasks, t)
+	}
+
+	return tasks, nil
+}
+
+func (r sqlTasks) DeleteCreatedBefore(
+	ctx context.Context,
+	status mint.TkStatus,
+	createdBefore time.Time,
+) (int64, error) {
+	ext := db.Ext(ctx, "mint")
+	res, err := sqlx.NamedExec(ext, `
+DELETE FROM tasks
+WHERE mint = :mint
+  AND status = :status
+  AND created < :created_before
+  AND lease_owner IS NULL
+`, map[string]interface{}{
+		"mint":           mint.GetHost(ctx),
+		"status":         string(status),
+		"created_before": createdBefore.UTC(),
+	})
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	return count, nil
+}
+
+func (r sqlTasks) LoadOldestOverdue(
+	ctx context.Context,
+	hosts []string,
+	now time.Time,
+) (*Task, error) {
+	query := map[string]interface{}{
+		"hosts":  hosts,
+		"status": mint.TkStPending,
+		"now":    now.UTC(),
+	}
+
+	task := Task{}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := namedQueryIn(ext, `
+SELECT *
+FROM tasks
+WHERE mint IN (:hosts)
+  AND status = :status
+  AND deadline <= :now
+  AND (lease_expiry IS NULL OR lease_expiry < :now)
+ORDER BY deadline, created
+LIMIT 1
+`, query); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		rows.Close()
+		return nil, nil
+	} else if err := rows.StructScan(&task); err != nil {
+		rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &task, nil
+}
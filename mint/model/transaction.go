@@ -9,17 +9,16 @@ import (
 
 	"golang.org/x/crypto/scrypt"
 
-	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
-	sqlite3 "github.com/mattn/go-sqlite3"
-	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/token"
+	"github.com/spolu/settle/lib/trace"
 	"github.com/spolu/settle/mint"
 )
 
 // Transaction represents a transaction across a chain of offers.
 type Transaction struct {
+	Mint        string // Host of the mint the object belongs to.
 	Owner       string
 	Token       string
 	Created     time.Time
@@ -35,6 +34,9 @@ type Transaction struct {
 
 	Lock   string
 	Secret *string
+
+	Trace  string  // Trace ID of the request that created the transaction.
+	APIKey *string `db:"api_key"` // Token of the API key that created it.
 }
 
 // NewTransactionResource generates a new resource.
@@ -74,7 +76,7 @@ func NewTransactionResource(
 }
 
 // CreateCanonicalTransaction creates and stores a new canonical Transaction
-// object.
+// object. apiKey is the token of the API key used to create it if any.
 func CreateCanonicalTransaction(
 	ctx context.Context,
 	owner string,
@@ -84,6 +86,7 @@ func CreateCanonicalTransaction(
 	destination string,
 	path []string,
 	status mint.TxStatus,
+	apiKey *string,
 ) (*Transaction, error) {
 	tok := token.New("transaction")
 
@@ -95,9 +98,10 @@ func CreateCanonicalTransaction(
 	lock := base64.StdEncoding.EncodeToString(h)
 
 	transaction := Transaction{
+		Mint:        mint.GetHost(ctx),
 		Owner:       owner,
 		Token:       tok,
-		Created:     time.Now().UTC(),
+		Created:     clock.Now(ctx).UTC(),
 		Propagation: mint.PgTpCanonical,
 
 		BaseAsset:   baseAsset,
@@ -109,27 +113,13 @@ func CreateCanonicalTransaction(
 
 		Lock:   lock,
 		Secret: &secret,
+
+		Trace:  trace.Get(ctx),
+		APIKey: apiKey,
 	}
 
-	ext := db.Ext(ctx, "mint")
-	if _, err := sqlx.NamedExec(ext, `
-INSERT INTO transactions
-  (owner, token, created, propagation, base_asset, quote_asset,
-   amount, destination, path, status, lock, secret)
-VALUES
-  (:owner, :token, :created, :propagation, :base_asset, :quote_asset,
-   :amount, :destination, :path, :status,  :lock, :secret)
-`, transaction); err != nil {
-		switch err := err.(type) {
-		case *pq.Error:
-			if err.Code.Name() == "unique_violation" {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		case sqlite3.Error:
-			if err.ExtendedCode == sqlite3.ErrConstraintUnique {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		}
+	err = getStore(ctx).Transactions().Create(ctx, &transaction)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -152,6 +142,7 @@ func CreatePropagatedTransaction(
 	lock string,
 ) (*Transaction, error) {
 	transaction := Transaction{
+		Mint:        mint.GetHost(ctx),
 		Owner:       owner,
 		Token:       token,
 		Created:     created.UTC(),
@@ -165,27 +156,12 @@ func CreatePropagatedTransaction(
 		Status:      status,
 		Lock:        lock,
 		Secret:      nil,
+
+		Trace: trace.Get(ctx),
 	}
 
-	ext := db.Ext(ctx, "mint")
-	if _, err := sqlx.NamedExec(ext, `
-INSERT INTO transactions
-  (owner, token, created, propagation, base_asset, quote_asset,
-   amount, destination, path, status, lock, secret)
-VALUES
-  (:owner, :token, :created, :propagation, :base_asset, :quote_asset,
-   :amount, :destination, :path, :status, :lock, :secret)
-`, transaction); err != nil {
-		switch err := err.(type) {
-		case *pq.Error:
-			if err.Code.Name() == "unique_violation" {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		case sqlite3.Error:
-			if err.ExtendedCode == sqlite3.ErrConstraintUnique {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		}
+	err := getStore(ctx).Transactions().Create(ctx, &transaction)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -201,18 +177,7 @@ func (t *Transaction) ID() string {
 func (t *Transaction) Save(
 	ctx context.Context,
 ) error {
-	ext := db.Ext(ctx, "mint")
-	_, err := sqlx.NamedExec(ext, `
-UPDATE transactions
-SET status = :status, secret = :secret
-WHERE owner = :owner
-  AND token = :token
-`, t)
-	if err != nil {
-		return errors.Trace(err)
-	}
-
-	return nil
+	return getStore(ctx).Transactions().Save(ctx, t)
 }
 
 // LoadCanonicalTransactionByOwnerToken attempts to load the canonical
@@ -222,31 +187,8 @@ func LoadCanonicalTransactionByOwnerToken(
 	owner string,
 	token string,
 ) (*Transaction, error) {
-	transaction := Transaction{
-		Owner:       owner,
-		Token:       token,
-		Propagation: mint.PgTpCanonical,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM transactions
-WHERE owner = :owner
-  AND token = :token
-  AND propagation = :propagation
-`, transaction); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&transaction); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	return &transaction, nil
+	return getStore(ctx).Transactions().LoadByOwnerToken(ctx,
+		owner, token, mint.PgTpCanonical)
 }
 
 // LoadPropagatedTransactionByOwnerToken attempts to load the propagated
@@ -256,31 +198,27 @@ func LoadPropagatedTransactionByOwnerToken(
 	owner string,
 	token string,
 ) (*Transaction, error) {
-	transaction := Transaction{
-		Owner:       owner,
-		Token:       token,
-		Propagation: mint.PgTpPropagated,
-	}
+	return getStore(ctx).Transactions().LoadByOwnerToken(ctx,
+		owner, token, mint.PgTpPropagated)
+}
 
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM transactions
-WHERE owner = :owner
-  AND token = :token
-  AND propagation = :propagation
-`, transaction); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&transaction); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
+// SpentByAPIKey returns the total amount of the canonical transactions created
+// with the API key of the provided token that were not canceled.
+func SpentByAPIKey(
+	ctx context.Context,
+	apiKey string,
+) (*big.Int, error) {
+	transactions, err := getStore(ctx).Transactions().ListByAPIKey(ctx, apiKey)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
-
-	return &transaction, nil
+	spent := big.NewInt(0)
+	for _, t := range transactions {
+		if t.Status != mint.TxStCanceled {
+			spent.Add(spent, (*big.Int)(&t.Amount))
+		}
+	}
+	return spent, nil
 }
 
 // LoadTransactionByID attempts to load the transaction (canonical or
@@ -289,32 +227,29 @@ func LoadTransactionByID(
 	ctx context.Context,
 	id string,
 ) (*Transaction, error) {
-	owner, token, err := mint.NormalizedOwnerAndTokenFromID(ctx, id)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
+	return loadTransactionByID(ctx, id, false)
+}
 
-	transaction := Transaction{
-		Owner: owner,
-		Token: token,
-	}
+// LockTransactionByID attempts to load the transaction (canonical or
+// propagated) for the given owner and token, locking it until the end of the
+// current transaction. Reservations, settlements and cancellations lock the
+// transaction first so that concurrent requests for the same transaction are
+// serialized.
+func LockTransactionByID(
+	ctx context.Context,
+	id string,
+) (*Transaction, error) {
+	return loadTransactionByID(ctx, id, true)
+}
 
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM transactions
-WHERE owner = :owner
-  AND token = :token
-`, transaction); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&transaction); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
+func loadTransactionByID(
+	ctx context.Context,
+	id string,
+	lock bool,
+) (*Transaction, error) {
+	owner, token, err := mint.NormalizedOwnerAndTokenFromID(ctx, id)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
-
-	return &transaction, nil
+	return getStore(ctx).Transactions().Load(ctx, owner, token, lock)
 }
@@ -0,0 +1,161 @@
+package model
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+)
+
+// sqlTransactions is the SQL TransactionRepository.
+type sqlTransactions struct{}
+
+func (r sqlTransactions) Create(
+	ctx context.Context,
+	transaction *Transaction,
+) error {
+	ext := db.Ext(ctx, "mint")
+	if _, err := sqlx.NamedExec(ext, `
+INSERT INTO transactions
+  (mint, owner, token, created, propagation, base_asset, quote_asset,
+   amount, destination, path, status, lock, secret, trace, api_key)
+VALUES
+  (:mint, :owner, :token, :created, :propagation, :base_asset, :quote_asset,
+   :amount, :destination, :path, :status, :lock, :secret, :trace, :api_key)
+`, transaction); err != nil {
+		return errors.Trace(sqlInsertError(err))
+	}
+
+	return nil
+}
+
+func (r sqlTransactions) Save(
+	ctx context.Context,
+	transaction *Transaction,
+) error {
+	ext := db.Ext(ctx, "mint")
+	_, err := sqlx.NamedExec(ext, `
+UPDATE transactions
+SET status = :status, secret = :secret
+WHERE mint = :mint
+  AND owner = :owner
+  AND token = :token
+`, transaction)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+func (r sqlTransactions) LoadByOwnerToken(
+	ctx context.Context,
+	owner string,
+	token string,
+	propagation mint.PgType,
+) (*Transaction, error) {
+	transaction := Transaction{
+		Mint:        mint.GetHost(ctx),
+		Owner:       owner,
+		Token:       token,
+		Propagation: propagation,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM transactions
+WHERE mint = :mint
+  AND owner = :owner
+  AND token = :token
+  AND propagation = :propagation
+`, transaction); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		return nil, nil
+	} else if err := rows.StructScan(&transaction); err != nil {
+		defer rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &transaction, nil
+}
+
+func (r sqlTransactions) Load(
+	ctx context.Context,
+	owner string,
+	token string,
+	lock bool,
+) (*Transaction, error) {
+	transaction := Transaction{
+		Mint:  mint.GetHost(ctx),
+		Owner: owner,
+		Token: token,
+	}
+
+	forUpdate := ""
+	if lock {
+		forUpdate = db.ForUpdate(ctx, "mint")
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM transactions
+WHERE mint = :mint
+  AND owner = :owner
+  AND token = :token
+`+forUpdate, transaction); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		return nil, nil
+	} else if err := rows.StructScan(&transaction); err != nil {
+		defer rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &transaction, nil
+}
+
+func (r sqlTransactions) ListByAPIKey(
+	ctx context.Context,
+	apiKey string,
+) ([]*Transaction, error) {
+	query := Transaction{
+		Mint:        mint.GetHost(ctx),
+		Propagation: mint.PgTpCanonical,
+		APIKey:      &apiKey,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM transactions
+WHERE mint = :mint
+  AND propagation = :propagation
+  AND api_key = :api_key
+`, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	transactions := []*Transaction{}
+
+	defer rows.Close()
+	for rows.Next() {
+		transaction := Transaction{}
+		err := rows.StructScan(&transaction)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		transactions = append(transactions, &transaction)
+	}
+
+	return transactions, nil
+}
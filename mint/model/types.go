@@ -2,6 +2,7 @@ package model
 
 import (
 	"database/sql/driver"
+	"encoding/json"
 	"math/big"
 	"strings"
 
@@ -36,6 +37,16 @@ func (b *Amount) Scan(src interface{}) error {
 	return nil
 }
 
+// MarshalJSON implements json.Marshaler.
+func (b Amount) MarshalJSON() ([]byte, error) {
+	return (*big.Int)(&b).MarshalJSON()
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *Amount) UnmarshalJSON(text []byte) error {
+	return (*big.Int)(b).UnmarshalJSON(text)
+}
+
 // OfPath is an offer path and implemets sql.Scanner and dirver.Valuer for easy
 // serialization.
 type OfPath []string
@@ -64,3 +75,32 @@ func (p *OfPath) Scan(src interface{}) error {
 
 	return nil
 }
+
+// JSON is a raw JSON value and implements sql.Scanner and driver.Valuer to be
+// stored as text.
+type JSON json.RawMessage
+
+// Value implements driver.Valuer.
+func (j JSON) Value() (value driver.Value, err error) {
+	if len(j) == 0 {
+		return "null", nil
+	}
+	return string(j), nil
+}
+
+// Scan implements sql.Scanner.
+func (j *JSON) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		*j = append(JSON{}, src...)
+	case string:
+		*j = JSON(src)
+	default:
+		return errors.Newf("Incompatible type for JSON with value: %q", src)
+	}
+	if !json.Valid(*j) {
+		return errors.Newf("Invalid JSON value: %q", string(*j))
+	}
+
+	return nil
+}
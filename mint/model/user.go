@@ -3,26 +3,44 @@ package model
 import (
 	"context"
 	"encoding/base64"
+	"fmt"
 	"time"
 
-	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
-	sqlite3 "github.com/mattn/go-sqlite3"
-	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/errors"
 	"github.com/spolu/settle/lib/token"
+	"github.com/spolu/settle/mint"
 	"golang.org/x/crypto/scrypt"
 )
 
-// User represents a user object. User objects are not managed by the mint and
-// solely accesed in read-only mode, leaving user management to an external
-// system with access to the same underlying mintDB.
+// User represents a user object. Users are managed through the mint admin API
+// or by an external system with access to the same underlying mintDB.
 type User struct {
+	Mint    string // Host of the mint the object belongs to.
 	Token   string
 	Created time.Time
 
 	Username     string
-	PasswordHash string `db:"password_hash"`
+	PasswordHash string     `db:"password_hash"`
+	Disabled     *time.Time // Time at which the user was disabled if any.
+}
+
+// NewUserResource generates a new resource.
+func NewUserResource(
+	ctx context.Context,
+	user *User,
+) mint.UserResource {
+	r := mint.UserResource{
+		ID:       user.Token,
+		Created:  user.Created.UnixNano() / mint.TimeResolutionNs,
+		Username: user.Username,
+		Address:  fmt.Sprintf("%s@%s", user.Username, user.Mint),
+	}
+	if user.Disabled != nil {
+		disabled := user.Disabled.UnixNano() / mint.TimeResolutionNs
+		r.Disabled = &disabled
+	}
+	return r
 }
 
 // CreateUser creates and stores a new User object.
@@ -32,8 +50,9 @@ func CreateUser(
 	password string,
 ) (*User, error) {
 	user := User{
+		Mint:    mint.GetHost(ctx),
 		Token:   token.New("user"),
-		Created: time.Now().UTC(),
+		Created: clock.Now(ctx).UTC(),
 
 		Username: username,
 	}
@@ -45,23 +64,7 @@ func CreateUser(
 
 	user.PasswordHash = base64.StdEncoding.EncodeToString(h)
 
-	ext := db.Ext(ctx, "mint")
-	if _, err := sqlx.NamedExec(ext, `
-INSERT INTO users
-  (token, created, username, password_hash)
-VALUES
-  (:token, :created, :username, :password_hash)
-`, user); err != nil {
-		switch err := err.(type) {
-		case *pq.Error:
-			if err.Code.Name() == "unique_violation" {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		case sqlite3.Error:
-			if err.ExtendedCode == sqlite3.ErrConstraintUnique {
-				return nil, errors.Trace(ErrUniqueConstraintViolation{err})
-			}
-		}
+	if err := getStore(ctx).Users().Create(ctx, &user); err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -72,17 +75,7 @@ VALUES
 func (u *User) Save(
 	ctx context.Context,
 ) error {
-	ext := db.Ext(ctx, "mint")
-	_, err := sqlx.NamedExec(ext, `
-UPDATE users
-SET username = :username, password_hash = :password_hash
-WHERE token = :token
-`, u)
-	if err != nil {
-		return errors.Trace(err)
-	}
-
-	return nil
+	return getStore(ctx).Users().Save(ctx, u)
 }
 
 // LoadUserByToken attempts to load a user with the given user token.
@@ -90,27 +83,7 @@ func LoadUserByToken(
 	ctx context.Context,
 	token string,
 ) (*User, error) {
-	user := User{
-		Token: token,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM users
-WHERE token = :token
-`, user); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&user); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	return &user, nil
+	return getStore(ctx).Users().LoadByToken(ctx, token)
 }
 
 // LoadUserByUsername attempts to load a user with the given username.
@@ -118,27 +91,16 @@ func LoadUserByUsername(
 	ctx context.Context,
 	username string,
 ) (*User, error) {
-	user := User{
-		Username: username,
-	}
-
-	ext := db.Ext(ctx, "mint")
-	if rows, err := sqlx.NamedQuery(ext, `
-SELECT *
-FROM users
-WHERE username = :username
-`, user); err != nil {
-		return nil, errors.Trace(err)
-	} else if !rows.Next() {
-		return nil, nil
-	} else if err := rows.StructScan(&user); err != nil {
-		defer rows.Close()
-		return nil, errors.Trace(err)
-	} else if err := rows.Close(); err != nil {
-		return nil, errors.Trace(err)
-	}
+	return getStore(ctx).Users().LoadByUsername(ctx, username)
+}
 
-	return &user, nil
+// LoadUserList loads a list of users of the current mint host.
+func LoadUserList(
+	ctx context.Context,
+	createdBefore time.Time,
+	limit uint,
+) ([]User, error) {
+	return getStore(ctx).Users().List(ctx, createdBefore, limit)
 }
 
 // CheckPassword checks if the provided password matches the password hash
@@ -0,0 +1,147 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+)
+
+// sqlUsers is the SQL UserRepository.
+type sqlUsers struct{}
+
+func (r sqlUsers) Create(
+	ctx context.Context,
+	user *User,
+) error {
+	ext := db.Ext(ctx, "mint")
+	if _, err := sqlx.NamedExec(ext, `
+INSERT INTO users
+  (mint, token, created, username, password_hash)
+VALUES
+  (:mint, :token, :created, :username, :password_hash)
+`, user); err != nil {
+		return errors.Trace(sqlInsertError(err))
+	}
+
+	return nil
+}
+
+func (r sqlUsers) Save(
+	ctx context.Context,
+	user *User,
+) error {
+	ext := db.Ext(ctx, "mint")
+	_, err := sqlx.NamedExec(ext, `
+UPDATE users
+SET username = :username, password_hash = :password_hash,
+  disabled = :disabled
+WHERE mint = :mint
+  AND token = :token
+`, user)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+func (r sqlUsers) LoadByToken(
+	ctx context.Context,
+	token string,
+) (*User, error) {
+	user := User{
+		Mint:  mint.GetHost(ctx),
+		Token: token,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM users
+WHERE mint = :mint
+  AND token = :token
+`, user); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		return nil, nil
+	} else if err := rows.StructScan(&user); err != nil {
+		defer rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &user, nil
+}
+
+func (r sqlUsers) LoadByUsername(
+	ctx context.Context,
+	username string,
+) (*User, error) {
+	user := User{
+		Mint:     mint.GetHost(ctx),
+		Username: username,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	if rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM users
+WHERE mint = :mint
+  AND username = :username
+`, user); err != nil {
+		return nil, errors.Trace(err)
+	} else if !rows.Next() {
+		return nil, nil
+	} else if err := rows.StructScan(&user); err != nil {
+		defer rows.Close()
+		return nil, errors.Trace(err)
+	} else if err := rows.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &user, nil
+}
+
+func (r sqlUsers) List(
+	ctx context.Context,
+	createdBefore time.Time,
+	limit uint,
+) ([]User, error) {
+	query := map[string]interface{}{
+		"mint":           mint.GetHost(ctx),
+		"created_before": createdBefore.UTC(),
+		"limit":          limit,
+	}
+
+	ext := db.Ext(ctx, "mint")
+	rows, err := sqlx.NamedQuery(ext, `
+SELECT *
+FROM users
+WHERE mint = :mint
+  AND created < :created_before
+ORDER BY created DESC
+LIMIT :limit
+`, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	users := []User{}
+
+	defer rows.Close()
+	for rows.Next() {
+		u := User{}
+		err := rows.StructScan(&u)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		users = append(users, u)
+	}
+
+	return users, nil
+}
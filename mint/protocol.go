@@ -1,6 +1,9 @@
 package mint
 
-import "math/big"
+import (
+	"encoding/json"
+	"math/big"
+)
 
 const (
 	// ProtocolVersion is the current protocol version.
@@ -11,6 +14,11 @@ const (
 	// TransactionExpiryMs is the time it takes to attempt to cancel a
 	// transaction for this mint. Expressed in ms.
 	TransactionExpiryMs int64 = 1000 * 60 * 60
+	// MaxPathLength is the maximum number of offers in the path of a
+	// transaction.
+	MaxPathLength int = 16
+	// DiscoveryPath is the path at which mints serve their discovery document.
+	DiscoveryPath string = "/.well-known/settle-mint"
 )
 
 // PgType is the propagation type of an object.
@@ -53,6 +61,33 @@ const (
 	TxStCanceled TxStatus = "canceled"
 )
 
+// KyScope is a scope granted to an API key.
+type KyScope string
+
+const (
+	// KyScRead grants read access to the assets, balances, offers and
+	// transactions of the key owner.
+	KyScRead KyScope = "read"
+	// KyScOffers grants the creation and closing of offers.
+	KyScOffers KyScope = "offers"
+	// KyScPay grants the creation of transactions, up to the pay limit of the
+	// key if any.
+	KyScPay KyScope = "pay"
+	// KyScSettle grants the settlement and cancellation of transactions.
+	KyScSettle KyScope = "settle"
+)
+
+// PrPolicy is the policy applied by a mint to a peer mint.
+type PrPolicy string
+
+const (
+	// PrPlAllow allows propagations and transactions from and through a peer.
+	PrPlAllow PrPolicy = "allow"
+	// PrPlBlock rejects propagations and transactions from and through a
+	// peer.
+	PrPlBlock PrPolicy = "block"
+)
+
 // AssetResource is the representation of an asset in the mint API.
 type AssetResource struct {
 	ID          string `json:"id"`
@@ -143,3 +178,139 @@ type TransactionResource struct {
 	Operations []OperationResource `json:"operations"`
 	Crossings  []CrossingResource  `json:"crossings"`
 }
+
+// TaskResource is the representation of an async task in the mint admin API.
+type TaskResource struct {
+	ID      string `json:"id"`
+	Created int64  `json:"created"`
+
+	Name    TkName          `json:"name"`
+	Subject string          `json:"subject"`
+	Payload json.RawMessage `json:"payload"`
+
+	Status    TkStatus `json:"status"`
+	Retry     uint     `json:"retry"`
+	Deadline  int64    `json:"deadline"`
+	Requeued  *int64   `json:"requeued"`
+	LastError *string  `json:"last_error"`
+
+	LeaseOwner  *string `json:"lease_owner"`
+	LeaseExpiry *int64  `json:"lease_expiry"`
+
+	Trace string `json:"trace"`
+}
+
+// APIKeyResource is the representation of an API key in the mint API. The
+// secret is only returned once, upon creation.
+type APIKeyResource struct {
+	ID      string `json:"id"`
+	Created int64  `json:"created"`
+
+	Name          string    `json:"name"`
+	Scopes        []KyScope `json:"scopes"`
+	PayLimit      *big.Int  `json:"pay_limit"` // Total of transactions.
+	PayLimitAsset *string   `json:"pay_limit_asset"`
+	Expiry        *int64    `json:"expiry"`
+	Revoked       *int64    `json:"revoked"`
+
+	Secret *string `json:"secret,omitempty"`
+}
+
+// UserResource is the representation of a mint user in the mint admin API.
+type UserResource struct {
+	ID      string `json:"id"`
+	Created int64  `json:"created"`
+
+	Username string `json:"username"`
+	Address  string `json:"address"`
+	Disabled *int64 `json:"disabled"`
+}
+
+// PeerResource is the representation of the policy applied to a peer mint in
+// the mint admin API.
+type PeerResource struct {
+	Host   string `json:"host"`
+	Source string `json:"source"` // `file` or `admin`
+
+	Policy      PrPolicy `json:"policy"`
+	ExposureCap *big.Int `json:"exposure_cap"`
+}
+
+// MtFeature is a feature supported by a mint.
+type MtFeature string
+
+const (
+	// MtFtAPIKeys indicates that the mint supports scoped API keys.
+	MtFtAPIKeys MtFeature = "api_keys"
+	// MtFtPeerPolicy indicates that the mint enforces a peer policy, and may
+	// reject interactions with some mints.
+	MtFtPeerPolicy MtFeature = "peer_policy"
+	// MtFtTransactionCancel indicates that the mint supports the cancellation
+	// of reserved transactions.
+	MtFtTransactionCancel MtFeature = "transaction_cancel"
+)
+
+// FePolicy is the fee policy of a mint.
+type FePolicy string
+
+const (
+	// FePlNone indicates that the mint charges no fee on operations (spreads
+	// are expressed in the price of offers).
+	FePlNone FePolicy = "none"
+)
+
+// MintLimitsResource is the representation of the limits of a mint.
+type MintLimitsResource struct {
+	TransactionExpiry int64 `json:"transaction_expiry"` // in ms
+	MaxPathLength     int   `json:"max_path_length"`
+}
+
+// MintResource is the representation of the discovery document of a mint,
+// served at DiscoveryPath on its host. Its API may be served at a different
+// host, port and scheme than the ones inferred from its host.
+type MintResource struct {
+	ID         string             `json:"id"` // host of the mint
+	Versions   []string           `json:"versions"`
+	Scheme     string             `json:"scheme"`
+	Host       string             `json:"host"`
+	Port       int64              `json:"port"`
+	PublicKeys []string           `json:"public_keys"`
+	Limits     MintLimitsResource `json:"limits"`
+	FeePolicy  FePolicy           `json:"fee_policy"`
+	Features   []MtFeature        `json:"features"`
+}
+
+// HlStatus is the status of a health check.
+type HlStatus string
+
+const (
+	// HlStOK indicates a passing health check.
+	HlStOK HlStatus = "ok"
+	// HlStFailed indicates a failing health check.
+	HlStFailed HlStatus = "failed"
+)
+
+// HealthCheckResource is the representation of the result of a health check.
+type HealthCheckResource struct {
+	Name     string   `json:"name"`
+	Status   HlStatus `json:"status"`
+	Duration int64    `json:"duration"` // in ms
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// HealthResource is the representation of the health of a mint process. The
+// reachability of peers is informative and does not affect its status.
+type HealthResource struct {
+	Status HlStatus              `json:"status"`
+	Checks []HealthCheckResource `json:"checks"`
+	Peers  []HealthCheckResource `json:"peers,omitempty"`
+}
+
+// BreakerResource is the representation of the circuit breaker of the client
+// of a mint for a peer mint host.
+type BreakerResource struct {
+	Host     string `json:"host"`
+	State    string `json:"state"` // closed, open, half_open
+	Failures int    `json:"failures"`
+	Opened   *int64 `json:"opened"`
+}
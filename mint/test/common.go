@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/spolu/settle/lib/clock"
 	"github.com/spolu/settle/lib/db"
 	"github.com/spolu/settle/lib/env"
 	"github.com/spolu/settle/lib/logging"
@@ -22,11 +23,15 @@ import (
 	"github.com/spolu/settle/lib/requestlogger"
 	"github.com/spolu/settle/lib/svc"
 	"github.com/spolu/settle/lib/token"
+	"github.com/spolu/settle/lib/trace"
 	"github.com/spolu/settle/mint"
 	"github.com/spolu/settle/mint/app"
 	"github.com/spolu/settle/mint/async"
 	"github.com/spolu/settle/mint/lib/authentication"
+	"github.com/spolu/settle/mint/lib/hosting"
+	"github.com/spolu/settle/mint/lib/throttling"
 	"github.com/spolu/settle/mint/model"
+	"github.com/spolu/settle/mint/model/memory"
 	goji "goji.io"
 )
 
@@ -56,6 +61,8 @@ func init() {
 // Mint represents a test mint.
 type Mint struct {
 	Server  *httptest.Server
+	Backend *httptest.Server // Server proxied by Server, if any (see Network).
+	Clock   *clock.Fake      // Clock of the mint, if controlled by the test.
 	Mux     *goji.Mux
 	Env     *env.Env
 	DB      *sqlx.DB
@@ -68,14 +75,15 @@ type Mint struct {
 func CreateMint(
 	t *testing.T,
 ) *Mint {
-	ctx := context.Background()
-
-	mintEnv := env.Env{
-		Environment: env.QA,
-		Config:      map[env.ConfigKey]string{},
-	}
-	ctx = env.With(ctx, &mintEnv)
+	return createMint(t, context.Background())
+}
 
+// createMint creates a new test mint with an in-memory DB using the provided
+// context.
+func createMint(
+	t *testing.T,
+	ctx context.Context,
+) *Mint {
 	tmpFile :=
 		filepath.Join(os.TempDir(), token.New("test")+".db")
 
@@ -90,6 +98,51 @@ func CreateMint(
 	}
 	ctx = db.WithDB(ctx, "mint", mintDB)
 
+	m := setupMint(t, ctx)
+	m.DB = mintDB
+	m.TmpFile = tmpFile
+
+	return m
+}
+
+// CreateMintWithClock creates a new test mint like CreateMint whose time is
+// controlled by the provided fake clock (which can be shared with other
+// mints) and returns test.Mint object. See Advance to run the tasks due once
+// the clock was advanced.
+func CreateMintWithClock(
+	t *testing.T,
+	c *clock.Fake,
+) *Mint {
+	m := createMint(t, clock.With(context.Background(), c))
+	m.Clock = c
+
+	return m
+}
+
+// CreateMemoryMint creates a new test mint backed by an in-memory store (see
+// the memory package) instead of a SQL DB and returns test.Mint object. Its DB
+// is nil.
+func CreateMemoryMint(
+	t *testing.T,
+) *Mint {
+	ctx := context.Background()
+	ctx = db.WithBackend(ctx, "mint", memory.NewStore(ctx))
+
+	return setupMint(t, ctx)
+}
+
+// setupMint sets up and starts a test mint using the dbs and clock of the
+// context.
+func setupMint(
+	t *testing.T,
+	ctx context.Context,
+) *Mint {
+	mintEnv := env.Env{
+		Environment: env.QA,
+		Config:      map[env.ConfigKey]string{},
+	}
+	ctx = env.With(ctx, &mintEnv)
+
 	a, err := async.NewAsync(ctx)
 	if err != nil {
 		t.Fatal(err)
@@ -97,12 +150,16 @@ func CreateMint(
 	ctx = async.With(ctx, a)
 
 	mux := goji.NewMux()
+	mux.Use(trace.Middleware)
 	mux.Use(requestlogger.Middleware)
 	mux.Use(recoverer.Middleware)
 	mux.Use(db.Middleware(db.GetDBMap(ctx)))
 	mux.Use(env.Middleware(env.Get(ctx)))
+	mux.Use(clock.Middleware(clock.Get(ctx)))
 	mux.Use(async.Middleware(async.Get(ctx)))
+	mux.Use(hosting.Middleware)
 	mux.Use(authentication.Middleware)
+	mux.Use(throttling.Middleware)
 
 	(&app.Controller{}).Bind(mux)
 
@@ -110,14 +167,13 @@ func CreateMint(
 	// tasks when needed instead.
 
 	m := Mint{
-		Server:  httptest.NewServer(mux),
-		Mux:     mux,
-		Env:     &mintEnv,
-		DB:      mintDB,
-		Ctx:     ctx,
-		TmpFile: tmpFile,
+		Server: httptest.NewServer(mux),
+		Mux:    mux,
+		Env:    &mintEnv,
+		Ctx:    ctx,
 	}
 	m.Env.Config[mint.EnvCfgHost] = m.Server.URL[7:]
+	m.Env.Config[mint.EnvCfgAdminSecret] = token.New("admin")
 
 	logging.Logf(ctx, "Creating test mint: mint_host=%s",
 		m.Env.Config[mint.EnvCfgHost])
@@ -127,16 +183,43 @@ func CreateMint(
 
 // Close closes the mint after usage.
 func (m *Mint) Close() {
+	if m.DB == nil {
+		return
+	}
 	defer os.Remove(m.TmpFile)
 	m.DB.Close()
 }
 
+// Advance advances the fake clock shared by the provided mints by d and runs
+// the tasks that are due on each of them until none is left. It returns the
+// number of tasks run.
+func Advance(
+	c *clock.Fake,
+	d time.Duration,
+	mints ...*Mint,
+) int {
+	c.Advance(d)
+
+	count := 0
+	for {
+		run := 0
+		for _, m := range mints {
+			run += async.TestRunDue(m.Ctx)
+		}
+		if run == 0 {
+			return count
+		}
+		count += run
+	}
+}
+
 // MintUser reprensents a user of a mint, generally generated by CreateUser.
 type MintUser struct {
 	Mint     *Mint
 	Username string
 	Password string
 	Address  string
+	Host     string
 }
 
 var userFirstnames = []string{
@@ -147,23 +230,31 @@ var userFirstnames = []string{
 // CreateUser creates a user and generates an associated MintUser
 func (m *Mint) CreateUser(
 	t *testing.T,
+) *MintUser {
+	return m.CreateUserForHost(t, m.Server.URL[7:])
+}
+
+// CreateUserForHost creates a user on the specified host served by the mint
+// and generates an associated MintUser.
+func (m *Mint) CreateUserForHost(
+	t *testing.T,
+	host string,
 ) *MintUser {
 	userIdx++
 	username := token.New(userFirstnames[userIdx%len(userFirstnames)])
 	password := token.New("password")
 
-	_, err := model.CreateUser(m.Ctx, username, password)
+	_, err := model.CreateUser(mint.WithHost(m.Ctx, host), username, password)
 	if err != nil {
 		t.Fatal(err)
 	}
-	m.Env.Config[mint.EnvCfgHost] = m.Server.URL[7:]
 
-	logging.Logf(m.Ctx, "Creating test mint: minst_host=%s",
-		m.Env.Config[mint.EnvCfgHost])
+	logging.Logf(m.Ctx, "Creating test user: mint_host=%s", host)
 
 	return &MintUser{
 		m, username, password,
-		fmt.Sprintf("%s@%s", username, m.Env.Config[mint.EnvCfgHost]),
+		fmt.Sprintf("%s@%s", username, host),
+		host,
 	}
 }
 
@@ -182,6 +273,7 @@ func (m *Mint) Post(
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	if user != nil {
+		req.Host = user.Host
 		req.SetBasicAuth(user.Username, user.Password)
 	}
 
@@ -208,6 +300,38 @@ func (u *MintUser) Post(
 	return u.Mint.Post(t, u, path, params)
 }
 
+// Admin sends a request to a specified admin endpoint on the mint,
+// authenticated with the admin secret.
+func (m *Mint) Admin(
+	t *testing.T,
+	method string,
+	path string,
+	params url.Values,
+) (int, svc.Resp) {
+	req, err := http.NewRequest(method,
+		fmt.Sprintf("%s%s", m.Server.URL, path),
+		strings.NewReader(params.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization",
+		"Bearer "+m.Env.Config[mint.EnvCfgAdminSecret])
+
+	r, err := getDefaultHTTPClient().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	var raw svc.Resp
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		t.Fatal(err)
+	}
+
+	return r.StatusCode, raw
+}
+
 // Get gets a specified endpoint on the mint.
 func (m *Mint) Get(
 	t *testing.T,
@@ -220,6 +344,7 @@ func (m *Mint) Get(
 		t.Fatal(err)
 	}
 	if user != nil {
+		req.Host = user.Host
 		req.SetBasicAuth(user.Username, user.Password)
 	}
 
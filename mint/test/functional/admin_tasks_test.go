@@ -0,0 +1,291 @@
+package functional
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/async"
+	"github.com/spolu/settle/mint/async/task"
+	"github.com/spolu/settle/mint/model"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAdminTasks(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser, []*model.Task) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+	}
+
+	ctx := db.Begin(mint.WithHost(m[0].Ctx, u[0].Host), "mint")
+	defer db.LoggedRollback(ctx)
+
+	tk := []*model.Task{}
+	now := time.Now()
+	for _, status := range []mint.TkStatus{mint.TkStPending, mint.TkStFailed} {
+		// The subject is not a valid offer id so the task fails when run.
+		k, err := model.CreateTask(ctx,
+			now, task.TkPropagateOffer, "foo", model.JSON("{}"),
+			status, 0, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tk = append(tk, k)
+	}
+
+	db.Commit(ctx)
+
+	return m, u, tk
+}
+
+func tearDownAdminTasks(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+func TestAdminTasksAuthentication(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, _ := setupAdminTasks(t)
+	defer tearDownAdminTasks(t, m)
+
+	status, raw := u[0].Get(t, "/admin/tasks")
+
+	var e errors.ConcreteUserError
+	err := raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 401, status)
+	assert.Equal(t, "admin_secret_invalid", e.ErrCode)
+
+	m[0].Env.Config[mint.EnvCfgAdminSecret] = ""
+	status, raw = m[0].Admin(t, http.MethodGet, "/admin/tasks", url.Values{})
+
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 403, status)
+	assert.Equal(t, "admin_disabled", e.ErrCode)
+}
+
+func TestAdminListTasks(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, _, tk := setupAdminTasks(t)
+	defer tearDownAdminTasks(t, m)
+
+	status, raw := m[0].Admin(t, http.MethodGet,
+		"/admin/tasks?status=failed", url.Values{})
+
+	var tasks []mint.TaskResource
+	err := raw.Extract("tasks", &tasks)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 1, len(tasks))
+	assert.Equal(t, tk[1].Token, tasks[0].ID)
+	assert.Equal(t, task.TkPropagateOffer, tasks[0].Name)
+	assert.Equal(t, "foo", tasks[0].Subject)
+	assert.Equal(t, mint.TkStFailed, tasks[0].Status)
+
+	status, raw = m[0].Admin(t, http.MethodGet,
+		fmt.Sprintf("/admin/tasks?name=%s&subject=foo", task.TkPropagateOffer),
+		url.Values{})
+
+	err = raw.Extract("tasks", &tasks)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 2, len(tasks))
+
+	status, raw = m[0].Admin(t, http.MethodGet,
+		"/admin/tasks?status=foo", url.Values{})
+
+	var e errors.ConcreteUserError
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "status_invalid", e.ErrCode)
+}
+
+func TestAdminRequeueTask(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, _, tk := setupAdminTasks(t)
+	defer tearDownAdminTasks(t, m)
+
+	status, raw := m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/tasks/%s/requeue", tk[1].Token), url.Values{})
+
+	var r mint.TaskResource
+	err := raw.Extract("task", &r)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, tk[1].Token, r.ID)
+	assert.Equal(t, mint.TkStPending, r.Status)
+	assert.Equal(t, uint(0), r.Retry)
+
+	// The creation time of the task is preserved.
+	assert.Equal(t, tk[1].Created.UnixNano()/mint.TimeResolutionNs, r.Created)
+	assert.NotNil(t, r.Requeued)
+	assert.True(t, *r.Requeued >= r.Created)
+	assert.Equal(t, *r.Requeued, r.Deadline)
+
+	status, raw = m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/tasks/%s/requeue", tk[0].Token), url.Values{})
+
+	var e errors.ConcreteUserError
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "task_not_requeueable", e.ErrCode)
+}
+
+func TestAdminRequeueOldTask(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, _ := setupAdminTasks(t)
+	defer tearDownAdminTasks(t, m)
+
+	// A task created longer than its max age ago failed.
+	ctx := mint.WithHost(m[0].Ctx, u[0].Host)
+	created := time.Now().Add(-8 * 24 * time.Hour)
+	old, err := model.CreateTask(ctx,
+		created, task.TkPropagateOffer, "foo", model.JSON("{}"),
+		mint.TkStFailed, 0, created)
+	assert.Nil(t, err)
+
+	status, _ := m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/tasks/%s/requeue", old.Token), url.Values{})
+	assert.Equal(t, 200, status)
+
+	// The requeued task fails again and is retried from its requeue.
+	async.TestRunDue(m[0].Ctx)
+
+	old, err = model.LoadTaskByToken(ctx, old.Token)
+	assert.Nil(t, err)
+	assert.Equal(t, mint.TkStPending, old.Status)
+	assert.Equal(t, uint(1), old.Retry)
+	assert.WithinDuration(t, created, old.Created, time.Second)
+	assert.True(t, old.Deadline.After(*old.Requeued))
+}
+
+func TestAdminCancelTask(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, _, tk := setupAdminTasks(t)
+	defer tearDownAdminTasks(t, m)
+
+	status, raw := m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/tasks/%s/cancel", tk[0].Token), url.Values{})
+
+	var r mint.TaskResource
+	err := raw.Extract("task", &r)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, mint.TkStCanceled, r.Status)
+
+	status, raw = m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/tasks/%s/cancel", tk[0].Token), url.Values{})
+
+	var e errors.ConcreteUserError
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "task_not_cancelable", e.ErrCode)
+
+	// Canceled tasks can be requeued.
+	status, raw = m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/tasks/%s/requeue", tk[0].Token), url.Values{})
+
+	err = raw.Extract("task", &r)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, mint.TkStPending, r.Status)
+}
+
+func TestAdminCancelLeasedTask(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, tk := setupAdminTasks(t)
+	defer tearDownAdminTasks(t, m)
+
+	ctx := db.Begin(mint.WithHost(m[0].Ctx, u[0].Host), "mint")
+	claimed, err := model.ClaimTaskByToken(ctx,
+		"worker_foo", tk[0].Token, time.Now(), time.Now().Add(time.Minute))
+	assert.Nil(t, err)
+	assert.NotNil(t, claimed)
+	db.Commit(ctx)
+
+	status, raw := m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/tasks/%s/cancel", tk[0].Token), url.Values{})
+
+	var e errors.ConcreteUserError
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 409, status)
+	assert.Equal(t, "task_leased", e.ErrCode)
+
+	k, err := model.LoadTaskByToken(
+		mint.WithHost(m[0].Ctx, u[0].Host), tk[0].Token)
+	assert.Nil(t, err)
+	assert.Equal(t, mint.TkStPending, k.Status)
+}
+
+func TestAdminRunTask(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, _, tk := setupAdminTasks(t)
+	defer tearDownAdminTasks(t, m)
+
+	status, raw := m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/tasks/%s/run", tk[0].Token), url.Values{})
+
+	var r mint.TaskResource
+	err := raw.Extract("task", &r)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, mint.TkStPending, r.Status)
+	assert.Equal(t, uint(1), r.Retry)
+	assert.NotNil(t, r.LastError)
+	assert.Nil(t, r.LeaseOwner)
+
+	status, raw = m[0].Admin(t, http.MethodPost,
+		"/admin/tasks/task_foo/run", url.Values{})
+
+	var e errors.ConcreteUserError
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 404, status)
+	assert.Equal(t, "task_not_found", e.ErrCode)
+}
@@ -0,0 +1,198 @@
+package functional
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAdminUsers(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+	}
+
+	return m, u
+}
+
+func tearDownAdminUsers(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+func TestAdminUsersCreateAndList(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupAdminUsers(t)
+	defer tearDownAdminUsers(t, m)
+
+	status, raw := m[0].Admin(t, http.MethodPost, "/admin/users", url.Values{
+		"username": {"von.neumann"},
+		"password": {"password_foo"},
+	})
+	assert.Equal(t, 201, status)
+
+	var user mint.UserResource
+	err := raw.Extract("user", &user)
+	assert.Nil(t, err)
+
+	assert.Regexp(t, "^user_[a-zA-Z0-9]+$", user.ID)
+	assert.Equal(t, "von.neumann", user.Username)
+	assert.Equal(t, fmt.Sprintf("von.neumann@%s", u[0].Host), user.Address)
+	assert.Nil(t, user.Disabled)
+
+	// The created user can authenticate with its password.
+	created := &test.MintUser{
+		Mint:     m[0],
+		Username: "von.neumann",
+		Password: "password_foo",
+		Address:  user.Address,
+		Host:     u[0].Host,
+	}
+	status, _ = created.Get(t, "/balances")
+	assert.Equal(t, 200, status)
+
+	status, raw = m[0].Admin(t, http.MethodPost, "/admin/users", url.Values{
+		"username": {"von.neumann"},
+		"password": {"password_bar"},
+	})
+
+	var e errors.ConcreteUserError
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "username_taken", e.ErrCode)
+
+	status, raw = m[0].Admin(t, http.MethodPost, "/admin/users", url.Values{
+		"username": {"von neumann"},
+		"password": {"password_bar"},
+	})
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "username_invalid", e.ErrCode)
+
+	status, raw = m[0].Admin(t, http.MethodGet, "/admin/users", url.Values{})
+	assert.Equal(t, 200, status)
+
+	var users []mint.UserResource
+	err = raw.Extract("users", &users)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 2, len(users))
+	assert.Equal(t, user.ID, users[0].ID)
+	assert.Equal(t, u[0].Username, users[1].Username)
+
+	// Users can't access the admin API.
+	status, raw = u[0].Post(t, "/admin/users", url.Values{
+		"username": {"turing"},
+		"password": {"password_foo"},
+	})
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+	assert.Equal(t, 401, status)
+	assert.Equal(t, "admin_secret_invalid", e.ErrCode)
+}
+
+func TestAdminUsersDisable(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupAdminUsers(t)
+	defer tearDownAdminUsers(t, m)
+
+	key := createAPIKey(t, u[0], url.Values{
+		"name":     {"bookkeeping"},
+		"scopes[]": {"read"},
+	})
+
+	status, raw := m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/users/%s/disable", u[0].Username), url.Values{})
+	assert.Equal(t, 200, status)
+
+	var user mint.UserResource
+	err := raw.Extract("user", &user)
+	assert.Nil(t, err)
+	assert.NotNil(t, user.Disabled)
+
+	// Disabled users are rejected, including with their API keys.
+	status, raw = u[0].Get(t, "/balances")
+
+	var e errors.ConcreteUserError
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+	assert.Equal(t, 403, status)
+	assert.Equal(t, "user_disabled", e.ErrCode)
+
+	status, raw = keyRequest(t, u[0], *key.Secret,
+		http.MethodGet, "/balances", url.Values{})
+	assertKeyError(t, 403, "user_disabled", status, raw)
+
+	status, raw = m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/users/%s/enable", u[0].Username), url.Values{})
+	assert.Equal(t, 200, status)
+
+	err = raw.Extract("user", &user)
+	assert.Nil(t, err)
+	assert.Nil(t, user.Disabled)
+
+	status, _ = u[0].Get(t, "/balances")
+	assert.Equal(t, 200, status)
+
+	status, raw = m[0].Admin(t, http.MethodPost,
+		"/admin/users/foo/disable", url.Values{})
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+	assert.Equal(t, 404, status)
+	assert.Equal(t, "user_not_found", e.ErrCode)
+}
+
+func TestAdminUsersResetPassword(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupAdminUsers(t)
+	defer tearDownAdminUsers(t, m)
+
+	status, raw := m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/users/%s/password", u[0].Username), url.Values{
+			"password": {"short"},
+		})
+
+	var e errors.ConcreteUserError
+	err := raw.Extract("error", &e)
+	assert.Nil(t, err)
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "password_invalid", e.ErrCode)
+
+	status, _ = m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/users/%s/password", u[0].Username), url.Values{
+			"password": {"password_new"},
+		})
+	assert.Equal(t, 200, status)
+
+	status, raw = u[0].Get(t, "/balances")
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "password_invalid", e.ErrCode)
+
+	u[0].Password = "password_new"
+	status, _ = u[0].Get(t, "/balances")
+	assert.Equal(t, 200, status)
+}
@@ -0,0 +1,358 @@
+package functional
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAPIKeys(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser, []mint.AssetResource) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+		m[0].CreateUser(t),
+	}
+	a := []mint.AssetResource{
+		u[0].CreateAsset(t, "USD", 2),
+	}
+
+	return m, u, a
+}
+
+func tearDownAPIKeys(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+// createAPIKey creates an API key for the user. The bearer credential of the
+// key is returned as its secret.
+func createAPIKey(
+	t *testing.T,
+	u *test.MintUser,
+	params url.Values,
+) mint.APIKeyResource {
+	status, raw := u.Post(t, "/keys", params)
+	assert.Equal(t, 201, status)
+
+	var key mint.APIKeyResource
+	if err := raw.Extract("api_key", &key); err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+// keyRequest sends a request to the mint of the user authenticated with the
+// provided API key credential.
+func keyRequest(
+	t *testing.T,
+	u *test.MintUser,
+	credential string,
+	method string,
+	path string,
+	params url.Values,
+) (int, svc.Resp) {
+	req, err := http.NewRequest(method,
+		fmt.Sprintf("%s%s", u.Mint.Server.URL, path),
+		strings.NewReader(params.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+credential)
+
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	var raw svc.Resp
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		t.Fatal(err)
+	}
+
+	return r.StatusCode, raw
+}
+
+func assertKeyError(
+	t *testing.T,
+	expectedStatus int,
+	expectedCode string,
+	status int,
+	raw svc.Resp,
+) {
+	var e errors.ConcreteUserError
+	err := raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, expectedStatus, status)
+	assert.Equal(t, expectedCode, e.ErrCode)
+}
+
+func TestCreateAPIKey(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, a := setupAPIKeys(t)
+	defer tearDownAPIKeys(t, m)
+
+	key := createAPIKey(t, u[0], url.Values{
+		"name":            {"bookkeeping"},
+		"scopes[]":        {"read"},
+		"pay_limit":       {"100"},
+		"pay_limit_asset": {a[0].Name},
+	})
+
+	assert.Regexp(t, "^key_[a-zA-Z0-9]+$", key.ID)
+	assert.Equal(t, "bookkeeping", key.Name)
+	assert.Equal(t, []mint.KyScope{mint.KyScRead}, key.Scopes)
+	assert.Equal(t, big.NewInt(100), key.PayLimit)
+	assert.Equal(t, a[0].Name, *key.PayLimitAsset)
+	assert.Nil(t, key.Expiry)
+	assert.Nil(t, key.Revoked)
+	assert.NotNil(t, key.Secret)
+	assert.True(t, strings.HasPrefix(*key.Secret, key.ID+"."))
+
+	// The secret is not returned when listing keys.
+	status, raw := u[0].Get(t, "/keys")
+	assert.Equal(t, 200, status)
+
+	var keys []mint.APIKeyResource
+	err := raw.Extract("api_keys", &keys)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 1, len(keys))
+	assert.Equal(t, key.ID, keys[0].ID)
+	assert.Nil(t, keys[0].Secret)
+
+	// Other users don't see the key.
+	status, raw = u[1].Get(t, "/keys")
+	assert.Equal(t, 200, status)
+	err = raw.Extract("api_keys", &keys)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(keys))
+
+	status, raw = u[0].Post(t, "/keys", url.Values{
+		"name":     {"foo"},
+		"scopes[]": {"read", "admin"},
+	})
+	assertKeyError(t, 400, "scopes_invalid", status, raw)
+
+	status, raw = u[0].Post(t, "/keys", url.Values{
+		"name":     {"foo"},
+		"scopes[]": {"read"},
+		"expiry":   {"1000"},
+	})
+	assertKeyError(t, 400, "expiry_invalid", status, raw)
+}
+
+func TestAPIKeyReadScope(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, a := setupAPIKeys(t)
+	defer tearDownAPIKeys(t, m)
+
+	key := createAPIKey(t, u[0], url.Values{
+		"name":     {"bookkeeping"},
+		"scopes[]": {"read"},
+	})
+
+	status, raw := keyRequest(t, u[0], *key.Secret,
+		http.MethodGet, "/balances", url.Values{})
+	assert.Equal(t, 200, status)
+
+	status, _ = keyRequest(t, u[0], *key.Secret,
+		http.MethodGet, fmt.Sprintf("/assets/%s/balances", a[0].Name),
+		url.Values{})
+	assert.Equal(t, 200, status)
+
+	status, raw = keyRequest(t, u[0], *key.Secret,
+		http.MethodPost, "/transactions", url.Values{
+			"pair":        {fmt.Sprintf("%s/%s", a[0].Name, a[0].Name)},
+			"amount":      {"10"},
+			"destination": {u[1].Address},
+			"path[]":      {},
+		})
+	assertKeyError(t, 403, "api_key_scope_insufficient", status, raw)
+
+	status, raw = keyRequest(t, u[0], *key.Secret,
+		http.MethodPost, "/assets", url.Values{
+			"code":  {"EUR"},
+			"scale": {"2"},
+		})
+	assertKeyError(t, 403, "api_key_scope_insufficient", status, raw)
+
+	// API keys can't manage API keys.
+	status, raw = keyRequest(t, u[0], *key.Secret,
+		http.MethodPost, "/keys", url.Values{
+			"name":     {"escalation"},
+			"scopes[]": {"pay"},
+		})
+	assertKeyError(t, 403, "api_key_scope_insufficient", status, raw)
+
+	status, raw = keyRequest(t, u[0], key.ID+".foo",
+		http.MethodGet, "/balances", url.Values{})
+	assertKeyError(t, 401, "api_key_invalid", status, raw)
+}
+
+func TestAPIKeyPayLimit(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, a := setupAPIKeys(t)
+	defer tearDownAPIKeys(t, m)
+
+	// Pay limits are bound to an asset.
+	status, raw := u[0].Post(t, "/keys", url.Values{
+		"name":      {"payments"},
+		"scopes[]":  {"pay"},
+		"pay_limit": {"10"},
+	})
+	assertKeyError(t, 400, "asset_invalid", status, raw)
+
+	key := createAPIKey(t, u[0], url.Values{
+		"name":            {"payments"},
+		"scopes[]":        {"pay"},
+		"pay_limit":       {"10"},
+		"pay_limit_asset": {a[0].Name},
+	})
+
+	status, raw = keyRequest(t, u[0], *key.Secret,
+		http.MethodPost, "/transactions", url.Values{
+			"pair":        {fmt.Sprintf("%s/%s", a[0].Name, a[0].Name)},
+			"amount":      {"11"},
+			"destination": {u[1].Address},
+			"path[]":      {},
+		})
+	assertKeyError(t, 403, "pay_limit_exceeded", status, raw)
+
+	// The limit does not apply to amounts in other assets which are refused.
+	eur := u[0].CreateAsset(t, "EUR", 2)
+	status, raw = keyRequest(t, u[0], *key.Secret,
+		http.MethodPost, "/transactions", url.Values{
+			"pair":        {fmt.Sprintf("%s/%s", eur.Name, eur.Name)},
+			"amount":      {"1"},
+			"destination": {u[1].Address},
+			"path[]":      {},
+		})
+	assertKeyError(t, 403, "pay_limit_asset_invalid", status, raw)
+
+	status, raw = keyRequest(t, u[0], *key.Secret,
+		http.MethodPost, "/transactions", url.Values{
+			"pair":        {fmt.Sprintf("%s/%s", a[0].Name, a[0].Name)},
+			"amount":      {"10"},
+			"destination": {u[1].Address},
+			"path[]":      {},
+		})
+	assert.Equal(t, 201, status)
+
+	var tx mint.TransactionResource
+	err := raw.Extract("transaction", &tx)
+	assert.Nil(t, err)
+	assert.Equal(t, u[0].Address, tx.Owner)
+
+	// The limit applies to the total of the transactions of the key.
+	pay := func(amount string) (int, svc.Resp) {
+		return keyRequest(t, u[0], *key.Secret,
+			http.MethodPost, "/transactions", url.Values{
+				"pair":        {fmt.Sprintf("%s/%s", a[0].Name, a[0].Name)},
+				"amount":      {amount},
+				"destination": {u[1].Address},
+				"path[]":      {},
+			})
+	}
+	status, raw = pay("1")
+	assertKeyError(t, 403, "pay_limit_exceeded", status, raw)
+
+	// Canceled transactions don't count against the limit.
+	status, _ = u[0].Post(t,
+		fmt.Sprintf("/transactions/%s/cancel", tx.ID), url.Values{})
+	assert.Equal(t, 200, status)
+
+	status, _ = pay("4")
+	assert.Equal(t, 201, status)
+	status, _ = pay("6")
+	assert.Equal(t, 201, status)
+	status, raw = pay("1")
+	assertKeyError(t, 403, "pay_limit_exceeded", status, raw)
+
+	// The key is not granted the offers scope.
+	status, raw = keyRequest(t, u[0], *key.Secret,
+		http.MethodPost, "/offers", url.Values{
+			"pair":   {fmt.Sprintf("%s/%s", a[0].Name, a[0].Name)},
+			"price":  {"100/100"},
+			"amount": {"10"},
+		})
+	assertKeyError(t, 403, "api_key_scope_insufficient", status, raw)
+}
+
+func TestAPIKeyRevocationAndExpiry(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, _ := setupAPIKeys(t)
+	defer tearDownAPIKeys(t, m)
+
+	key := createAPIKey(t, u[0], url.Values{
+		"name":     {"bookkeeping"},
+		"scopes[]": {"read"},
+	})
+
+	// Other users can't revoke the key.
+	status, raw := u[1].Post(t,
+		fmt.Sprintf("/keys/%s/revoke", key.ID), url.Values{})
+	assertKeyError(t, 404, "key_not_found", status, raw)
+
+	status, raw = u[0].Post(t,
+		fmt.Sprintf("/keys/%s/revoke", key.ID), url.Values{})
+	assert.Equal(t, 200, status)
+
+	var revoked mint.APIKeyResource
+	err := raw.Extract("api_key", &revoked)
+	assert.Nil(t, err)
+	assert.NotNil(t, revoked.Revoked)
+
+	status, raw = keyRequest(t, u[0], *key.Secret,
+		http.MethodGet, "/balances", url.Values{})
+	assertKeyError(t, 401, "api_key_revoked", status, raw)
+
+	expiry := time.Now().Add(500*time.Millisecond).UnixNano() /
+		mint.TimeResolutionNs
+	key = createAPIKey(t, u[0], url.Values{
+		"name":     {"temporary"},
+		"scopes[]": {"read"},
+		"expiry":   {fmt.Sprintf("%d", expiry)},
+	})
+	assert.Equal(t, expiry, *key.Expiry)
+
+	status, _ = keyRequest(t, u[0], *key.Secret,
+		http.MethodGet, "/balances", url.Values{})
+	assert.Equal(t, 200, status)
+
+	time.Sleep(time.Until(time.Unix(0, expiry*mint.TimeResolutionNs)))
+
+	status, raw = keyRequest(t, u[0], *key.Secret,
+		http.MethodGet, "/balances", url.Values{})
+	assertKeyError(t, 401, "api_key_expired", status, raw)
+}
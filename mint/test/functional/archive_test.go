@@ -0,0 +1,232 @@
+package functional
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupArchive(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+		test.CreateMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+		m[0].CreateUser(t),
+	}
+
+	u[0].CreateAsset(t, "USD", 2)
+	u[1].CreateAsset(t, "EUR", 2)
+
+	status, _ := u[0].Post(t,
+		fmt.Sprintf("/offers"),
+		url.Values{
+			"pair":   {fmt.Sprintf("%s[USD.2]/%s[EUR.2]", u[0].Address, u[1].Address)},
+			"price":  {"1/1"},
+			"amount": {"100"},
+		})
+	assert.Equal(t, 201, status)
+
+	return m, u
+}
+
+func tearDownArchive(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+func exportArchive(
+	t *testing.T,
+	m *test.Mint,
+) (*bytes.Buffer, map[string]int) {
+	var buf bytes.Buffer
+
+	ctx := db.BeginSnapshot(m.Ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	counts, err := model.ExportArchive(ctx, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &buf, counts
+}
+
+func importArchive(
+	t *testing.T,
+	m *test.Mint,
+	archive string,
+) (map[string]int, error) {
+	ctx := db.Begin(m.Ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	counts, err := model.ImportArchive(ctx, strings.NewReader(archive))
+	if err == nil {
+		db.Commit(ctx)
+	}
+	return counts, err
+}
+
+// stripHeader removes the header line of an archive to compare archives
+// exported from different mints.
+func stripHeader(
+	archive string,
+) string {
+	return archive[strings.Index(archive, "\n")+1:]
+}
+
+func TestArchiveExportImport(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupArchive(t)
+	defer tearDownArchive(t, m)
+
+	archive, counts := exportArchive(t, m[0])
+	assert.Equal(t, 2, counts["users"])
+	assert.Equal(t, 2, counts["assets"])
+	assert.Equal(t, 1, counts["offers"])
+	assert.Equal(t, 0, counts["transactions"])
+
+	imported, err := importArchive(t, m[1], archive.String())
+	assert.Nil(t, err)
+	for table, count := range counts {
+		assert.Equal(t, count, imported[table])
+	}
+
+	// Restored data remains scoped to the host it was exported from.
+	ctx := mint.WithHost(m[1].Ctx, u[0].Host)
+
+	// Users can authenticate on the restored mint with their password.
+	user, err := model.LoadUserByUsername(ctx, u[0].Username)
+	assert.Nil(t, err)
+	assert.NotNil(t, user)
+	assert.Nil(t, user.CheckPassword(ctx, u[0].Password))
+
+	asset, err := model.LoadCanonicalAssetByOwnerCodeScale(ctx,
+		u[0].Address, "USD", 2)
+	assert.Nil(t, err)
+	assert.NotNil(t, asset)
+
+	restored, _ := exportArchive(t, m[1])
+	assert.Equal(t, stripHeader(archive.String()), stripHeader(restored.String()))
+}
+
+// downgradeArchive rewrites an archive exported from a single host mint as a
+// version 1 archive: the header holds the host and records have no mint.
+func downgradeArchive(
+	t *testing.T,
+	archive string,
+	host string,
+) string {
+	lines := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(archive), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		switch model.ArEntryType(entry["type"].(string)) {
+		case model.ArEnTpHeader:
+			entry["version"] = 1
+			entry["host"] = host
+			delete(entry, "hosts")
+		case model.ArEnTpRecord:
+			delete(entry["record"].(map[string]interface{}), "Mint")
+		}
+		raw, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(raw))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestArchiveImportV1(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupArchive(t)
+	defer tearDownArchive(t, m)
+
+	archive, counts := exportArchive(t, m[0])
+
+	imported, err := importArchive(t, m[1],
+		downgradeArchive(t, archive.String(), u[0].Host))
+	assert.Nil(t, err)
+	for table, count := range counts {
+		assert.Equal(t, count, imported[table])
+	}
+
+	// Records of version 1 archives are restored for the archive's host.
+	ctx := mint.WithHost(m[1].Ctx, u[0].Host)
+
+	user, err := model.LoadUserByUsername(ctx, u[0].Username)
+	assert.Nil(t, err)
+	assert.NotNil(t, user)
+	assert.Nil(t, user.CheckPassword(ctx, u[0].Password))
+
+	restored, _ := exportArchive(t, m[1])
+	assert.Equal(t, stripHeader(archive.String()), stripHeader(restored.String()))
+}
+
+func TestArchiveImportV1MissingHost(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, _ := setupArchive(t)
+	defer tearDownArchive(t, m)
+
+	archive, _ := exportArchive(t, m[0])
+
+	_, err := importArchive(t, m[1], downgradeArchive(t, archive.String(), ""))
+	assert.NotNil(t, err)
+}
+
+func TestArchiveImportNonEmpty(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, _ := setupArchive(t)
+	defer tearDownArchive(t, m)
+
+	archive, _ := exportArchive(t, m[0])
+
+	_, err := importArchive(t, m[0], archive.String())
+	assert.NotNil(t, err)
+}
+
+func TestArchiveImportTruncated(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, _ := setupArchive(t)
+	defer tearDownArchive(t, m)
+
+	archive, _ := exportArchive(t, m[0])
+	lines := strings.Split(strings.TrimSpace(archive.String()), "\n")
+	truncated := strings.Join(lines[:len(lines)-1], "\n")
+
+	_, err := importArchive(t, m[1], truncated)
+	assert.NotNil(t, err)
+
+	// The failed import was rolled back.
+	_, counts := exportArchive(t, m[1])
+	assert.Equal(t, 0, counts["users"])
+}
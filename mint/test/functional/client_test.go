@@ -0,0 +1,224 @@
+package functional
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/spolu/settle/lib/breaker"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/async"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupClient(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+		test.CreateMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+		m[1].CreateUser(t),
+	}
+
+	return m, u
+}
+
+func tearDownClient(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+func TestClientCircuitBreaker(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupClient(t)
+	defer tearDownClient(t, m)
+
+	a := u[1].CreateAsset(t, "USD", 2)
+	b := u[1].CreateAsset(t, "EUR", 2)
+	offer := u[1].CreateOffer(t,
+		fmt.Sprintf("%s/%s", a.Name, b.Name), "1/1", big.NewInt(100))
+
+	client := &mint.Client{}
+	err := client.Init(m[0].Ctx)
+	assert.Nil(t, err)
+
+	_, err = client.RetrieveOffer(m[0].Ctx, offer.ID)
+	assert.Nil(t, err)
+
+	// m[1] goes down.
+	m[1].Server.Close()
+
+	_, err = client.RetrieveOffer(m[0].Ctx, offer.ID)
+	assert.NotNil(t, err)
+	_, open := errors.Cause(err).(breaker.ErrOpen)
+	assert.False(t, open)
+
+	// The circuit opens during the retries of the second call.
+	_, err = client.RetrieveOffer(m[0].Ctx, offer.ID)
+	_, open = errors.Cause(err).(breaker.ErrOpen)
+	assert.True(t, open)
+
+	start := time.Now()
+	_, err = client.RetrieveOffer(m[0].Ctx, offer.ID)
+	_, open = errors.Cause(err).(breaker.ErrOpen)
+	assert.True(t, open)
+	assert.True(t, time.Now().Sub(start) < mint.RetryBackoff)
+
+	status, raw := m[0].Admin(t, http.MethodGet, "/admin/breakers",
+		url.Values{})
+	assert.Equal(t, 200, status)
+
+	var breakers []mint.BreakerResource
+	err = raw.Extract("breakers", &breakers)
+	assert.Nil(t, err)
+
+	found := false
+	for _, b := range breakers {
+		if b.Host == u[1].Host {
+			found = true
+			assert.Equal(t, string(breaker.Open), b.State)
+			assert.Equal(t, mint.BreakerThreshold, b.Failures)
+			assert.NotNil(t, b.Opened)
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestClientDeadline(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, _ := setupClient(t)
+	defer tearDownClient(t, m)
+
+	// A peer that never responds.
+	done := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-done:
+			case <-r.Context().Done():
+			}
+		}))
+	defer hung.Close()
+	defer close(done)
+
+	client := &mint.Client{}
+	err := client.Init(m[0].Ctx)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(m[0].Ctx, 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = client.RetrieveOffer(ctx,
+		fmt.Sprintf("foo@%s[offer_foo]", hung.URL[7:]))
+	assert.NotNil(t, err)
+	assert.True(t, time.Now().Sub(start) < time.Second)
+
+	// Calls interrupted by their caller don't count against the peer.
+	for i := 0; i < mint.BreakerThreshold; i++ {
+		ctx, cancel := context.WithTimeout(m[0].Ctx, 10*time.Millisecond)
+		_, err = client.RetrieveOffer(ctx,
+			fmt.Sprintf("foo@%s[offer_foo]", hung.URL[7:]))
+		cancel()
+		_, open := errors.Cause(err).(breaker.ErrOpen)
+		assert.False(t, open)
+	}
+}
+
+func TestClientOfferCache(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupClient(t)
+	defer tearDownClient(t, m)
+
+	a := u[1].CreateAsset(t, "USD", 2)
+	b := u[1].CreateAsset(t, "EUR", 2)
+	offer := u[1].CreateOffer(t,
+		fmt.Sprintf("%s/%s", a.Name, b.Name), "1/1", big.NewInt(100))
+
+	client := &mint.Client{}
+	err := client.Init(m[0].Ctx)
+	assert.Nil(t, err)
+
+	of, err := client.LookupOffer(m[0].Ctx, offer.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, offer.ID, of.ID)
+
+	as, err := client.LookupAsset(m[0].Ctx, a.Name)
+	assert.Nil(t, err)
+	assert.Equal(t, a.ID, as.ID)
+
+	// m[1] goes down but lookups are served from the cache.
+	m[1].Server.Close()
+
+	of, err = client.LookupOffer(m[0].Ctx, offer.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, offer.ID, of.ID)
+
+	as, err = client.LookupAsset(m[0].Ctx, a.Name)
+	assert.Nil(t, err)
+	assert.Equal(t, a.ID, as.ID)
+
+	// RetrieveOffer bypasses the cache.
+	_, err = client.RetrieveOffer(m[0].Ctx, offer.ID)
+	assert.NotNil(t, err)
+
+	mint.InvalidateOffer(offer.ID)
+
+	_, err = client.LookupOffer(m[0].Ctx, offer.ID)
+	assert.NotNil(t, err)
+}
+
+func TestClientOfferCacheInvalidation(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupClient(t)
+	defer tearDownClient(t, m)
+
+	u[0].CreateAsset(t, "USD", 2)
+	u[1].CreateAsset(t, "USD", 2)
+	offer := u[0].CreateOffer(t,
+		fmt.Sprintf("%s[USD.2]/%s[USD.2]", u[0].Address, u[1].Address),
+		"1/1", big.NewInt(100))
+	async.TestRunOne(m[0].Ctx)
+
+	client := &mint.Client{}
+	err := client.Init(m[1].Ctx)
+	assert.Nil(t, err)
+
+	of, err := client.LookupOffer(m[1].Ctx, offer.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, mint.OfStActive, of.Status)
+
+	status, _ := u[0].Post(t,
+		fmt.Sprintf("/offers/%s/close", offer.ID),
+		url.Values{})
+	assert.Equal(t, 200, status)
+
+	// The propagation of the closed offer to m[1] invalidates the cache.
+	async.TestRunOne(m[0].Ctx)
+
+	of, err = client.LookupOffer(m[1].Ctx, offer.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, mint.OfStClosed, of.Status)
+}
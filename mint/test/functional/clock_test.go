@@ -0,0 +1,91 @@
+package functional
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/spolu/settle/lib/clock"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockExpireTransaction(
+	t *testing.T,
+) {
+	t.Parallel()
+	c := clock.NewFake(time.Now())
+	m := []*test.Mint{
+		test.CreateMintWithClock(t, c),
+		test.CreateMintWithClock(t, c),
+		test.CreateMintWithClock(t, c),
+	}
+	defer tearDownCancelTransaction(t, m)
+
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+		m[1].CreateUser(t),
+		m[2].CreateUser(t),
+	}
+	a := []mint.AssetResource{
+		u[0].CreateAsset(t, "USD", 2),
+		u[1].CreateAsset(t, "USD", 2),
+		u[2].CreateAsset(t, "USD", 2),
+	}
+	o := []mint.OfferResource{
+		u[1].CreateOffer(t,
+			fmt.Sprintf("%s/%s", a[1].Name, a[0].Name),
+			"100/100", big.NewInt(100)),
+		u[2].CreateOffer(t,
+			fmt.Sprintf("%s/%s", a[2].Name, a[1].Name),
+			"100/100", big.NewInt(100)),
+	}
+	test.Advance(c, 0, m...)
+
+	status, raw := u[0].Post(t,
+		fmt.Sprintf("/transactions"),
+		url.Values{
+			"pair":        {fmt.Sprintf("%s/%s", a[0].Name, a[2].Name)},
+			"amount":      {"10"},
+			"destination": {u[2].Address},
+			"path[]":      {o[0].ID, o[1].ID},
+		})
+	assert.Equal(t, 201, status)
+
+	var tx mint.TransactionResource
+	err := raw.Extract("transaction", &tx)
+	assert.Nil(t, err)
+	assert.Equal(t,
+		c.Now().UnixNano()/mint.TimeResolutionNs, tx.Created)
+	assert.Equal(t, mint.TxStReserved, tx.Status)
+	test.Advance(c, 0, m...)
+
+	expiry := time.Duration(mint.TransactionExpiryMs) * time.Millisecond
+
+	// Nothing is due before the transaction expires.
+	assert.Equal(t, 0, test.Advance(c, expiry-time.Minute, m...))
+
+	assert.True(t, test.Advance(c, 2*time.Minute, m...) > 0)
+
+	status, raw = u[0].Get(t, fmt.Sprintf("/transactions/%s", tx.ID))
+	assert.Equal(t, 200, status)
+
+	err = raw.Extract("transaction", &tx)
+	assert.Nil(t, err)
+	assert.Equal(t, mint.TxStCanceled, tx.Status)
+
+	status, raw = u[0].Post(t,
+		fmt.Sprintf("/transactions/%s/settle", tx.ID),
+		url.Values{})
+
+	var e errors.ConcreteUserError
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 402, status)
+	assert.Equal(t, "settlement_failed", e.ErrCode)
+}
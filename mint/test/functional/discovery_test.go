@@ -0,0 +1,199 @@
+package functional
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDiscovery(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser, []mint.AssetResource) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+	}
+	a := []mint.AssetResource{
+		u[0].CreateAsset(t, "USD", 2),
+	}
+
+	return m, u, a
+}
+
+func tearDownDiscovery(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+func TestDiscoveryDocument(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, _ := setupDiscovery(t)
+	defer tearDownDiscovery(t, m)
+
+	status, raw := m[0].Get(t, nil, mint.DiscoveryPath)
+	assert.Equal(t, 200, status)
+
+	var doc mint.MintResource
+	err := raw.Extract("mint", &doc)
+	assert.Nil(t, err)
+
+	host, port, err := net.SplitHostPort(u[0].Host)
+	assert.Nil(t, err)
+
+	assert.Equal(t, u[0].Host, doc.ID)
+	assert.Equal(t, []string{mint.ProtocolVersion}, doc.Versions)
+	assert.Equal(t, "http", doc.Scheme)
+	assert.Equal(t, host, doc.Host)
+	assert.Equal(t, port, fmt.Sprintf("%d", doc.Port))
+	assert.Equal(t, []string{}, doc.PublicKeys)
+	assert.Equal(t, mint.TransactionExpiryMs, doc.Limits.TransactionExpiry)
+	assert.Equal(t, mint.MaxPathLength, doc.Limits.MaxPathLength)
+	assert.Equal(t, mint.FePlNone, doc.FeePolicy)
+	assert.Contains(t, doc.Features, mint.MtFtPeerPolicy)
+}
+
+func TestDiscoveryPublicURL(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, a := setupDiscovery(t)
+	defer tearDownDiscovery(t, m)
+
+	// Advertise the API under an explicit URL for the same server.
+	host, port, err := net.SplitHostPort(u[0].Host)
+	assert.Nil(t, err)
+	m[0].Env.Config[mint.EnvCfgPublicURL] = fmt.Sprintf(
+		"http://%s:%s", host, port)
+	m[0].Env.Config[mint.EnvCfgPublicKeys] = "sha256/foo, sha256/bar"
+
+	eur := u[0].CreateAsset(t, "EUR", 2)
+	offer := u[0].CreateOffer(t,
+		fmt.Sprintf("%s/%s", a[0].Name, eur.Name),
+		"1/1", big.NewInt(100))
+
+	client := &mint.Client{}
+	err = client.Init(m[0].Ctx)
+	assert.Nil(t, err)
+
+	doc, err := client.RetrieveMint(m[0].Ctx, u[0].Host)
+	assert.Nil(t, err)
+	assert.Equal(t, host, doc.Host)
+	assert.Equal(t, []string{"sha256/foo", "sha256/bar"}, doc.PublicKeys)
+
+	apiURL, err := client.MintURL(m[0].Ctx, u[0].Host,
+		fmt.Sprintf("/offers/%s", offer.ID), url.Values{})
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(apiURL.String(),
+		fmt.Sprintf("http://%s:%s/offers/", host, port)))
+
+	retrieved, err := client.RetrieveOffer(m[0].Ctx, offer.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, offer.ID, retrieved.ID)
+}
+
+func TestDiscoveryHostMismatch(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, _ := setupDiscovery(t)
+	defer tearDownDiscovery(t, m)
+
+	// Advertise the API on a host the mint does not control.
+	_, port, err := net.SplitHostPort(u[0].Host)
+	assert.Nil(t, err)
+	m[0].Env.Config[mint.EnvCfgPublicURL] = fmt.Sprintf(
+		"http://localhost:%s", port)
+
+	client := &mint.Client{}
+	err = client.Init(m[0].Ctx)
+	assert.Nil(t, err)
+
+	_, err = client.RetrieveMint(m[0].Ctx, u[0].Host)
+	assert.NotNil(t, err)
+
+	_, err = client.MintURL(m[0].Ctx, u[0].Host, "/offers", url.Values{})
+	assert.NotNil(t, err)
+}
+
+func TestDiscoveryLegacyMint(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, _, _ := setupDiscovery(t)
+	defer tearDownDiscovery(t, m)
+
+	// Mints predating discovery documents match the discovery path against
+	// their user routes.
+	calls := int32(0)
+	legacy := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"status_code":400,`+
+				`"err_code":"username_invalid","err_message":"invalid"}}`)
+		}))
+	defer legacy.Close()
+	host := strings.TrimPrefix(legacy.URL, "http://")
+
+	client := &mint.Client{}
+	err := client.Init(m[0].Ctx)
+	assert.Nil(t, err)
+
+	for i := 0; i < 2; i++ {
+		doc, err := client.RetrieveMint(m[0].Ctx, host)
+		assert.Nil(t, err)
+		assert.Nil(t, doc)
+	}
+	// The absence of discovery document is cached.
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	apiURL, err := client.MintURL(m[0].Ctx, host, "/offers", url.Values{})
+	assert.Nil(t, err)
+	assert.Equal(t, fmt.Sprintf("%s/offers", legacy.URL), apiURL.String())
+}
+
+func TestDiscoveryMaxPathLength(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, a := setupDiscovery(t)
+	defer tearDownDiscovery(t, m)
+
+	path := []string{}
+	for i := 0; i <= mint.MaxPathLength; i++ {
+		path = append(path, fmt.Sprintf("%s[offer_foo%d]", u[0].Address, i))
+	}
+
+	status, raw := u[0].Post(t, "/transactions", url.Values{
+		"pair":        {fmt.Sprintf("%s/%s", a[0].Name, a[0].Name)},
+		"amount":      {"10"},
+		"destination": {u[0].Address},
+		"path[]":      path,
+	})
+
+	var e errors.ConcreteUserError
+	err := raw.Extract("error", &e)
+	assert.Nil(t, err)
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "path_invalid", e.ErrCode)
+}
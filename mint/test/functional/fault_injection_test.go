@@ -0,0 +1,272 @@
+package functional
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// drainRounds is the number of rounds of async tasks run to let transactions
+// expire and their cancellation or settlement propagate.
+const drainRounds = 8
+
+var propagateTransactionPattern = regexp.MustCompile(
+	"^/transactions/[^/]+$")
+var settleTransactionPattern = regexp.MustCompile(
+	"^/transactions/[^/]+/settle$")
+var retrieveOfferPattern = regexp.MustCompile(
+	"^/offers/[^/]+$")
+
+// setupFaultInjection sets up a network where A (u[0]) pays C (u[2]) through
+// B's offer (o[1]) and C's offer (o[2]).
+func setupFaultInjection(
+	t *testing.T,
+) (
+	*test.Network,
+	[]*test.MintUser,
+	[]mint.AssetResource,
+	[]mint.OfferResource,
+) {
+	n := test.CreateNetwork(t, 3)
+	m := n.Mints
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+		m[1].CreateUser(t),
+		m[2].CreateUser(t),
+	}
+	a := []mint.AssetResource{
+		u[0].CreateAsset(t, "USD", 2),
+		u[1].CreateAsset(t, "USD", 2),
+		u[2].CreateAsset(t, "USD", 2),
+	}
+	o := []mint.OfferResource{
+		u[0].CreateOffer(t,
+			fmt.Sprintf("%s/%s", a[0].Name, a[2].Name),
+			"100/100", big.NewInt(100)),
+		u[1].CreateOffer(t,
+			fmt.Sprintf("%s/%s", a[1].Name, a[0].Name),
+			"100/100", big.NewInt(100)),
+		u[2].CreateOffer(t,
+			fmt.Sprintf("%s/%s", a[2].Name, a[1].Name),
+			"100/100", big.NewInt(100)),
+	}
+
+	return n, u, a, o
+}
+
+// createFaultInjectionTransaction creates a transaction from u[0] to u[2]
+// through o[1] and o[2] and returns the status and transaction returned.
+func createFaultInjectionTransaction(
+	t *testing.T,
+	u []*test.MintUser,
+	a []mint.AssetResource,
+	o []mint.OfferResource,
+) (int, mint.TransactionResource) {
+	status, raw := u[0].Post(t,
+		fmt.Sprintf("/transactions"),
+		url.Values{
+			"pair":        {fmt.Sprintf("%s/%s", a[0].Name, a[2].Name)},
+			"amount":      {"10"},
+			"destination": {u[2].Address},
+			"path[]":      {o[1].ID, o[2].ID},
+		})
+
+	var tx mint.TransactionResource
+	raw.Extract("transaction", &tx)
+
+	return status, tx
+}
+
+func hop(h int8) *int8 {
+	return &h
+}
+
+func TestFaultInjectionNoFault(
+	t *testing.T,
+) {
+	t.Parallel()
+	n, u, a, o := setupFaultInjection(t)
+	defer n.Close()
+
+	status, tx := createFaultInjectionTransaction(t, u, a, o)
+	assert.Equal(t, 201, status)
+
+	status, raw := u[0].Post(t,
+		fmt.Sprintf("/transactions/%s/settle", tx.ID),
+		url.Values{})
+	assert.Equal(t, 200, status)
+
+	var tx0 mint.TransactionResource
+	err := raw.Extract("transaction", &tx0)
+	assert.Nil(t, err)
+	assert.Equal(t, mint.TxStSettled, tx0.Status)
+
+	n.Drain(t, drainRounds)
+	n.AssertConserved(t)
+	assert.Equal(t, []string{tx.ID}, n.Transactions())
+}
+
+func TestFaultInjectionReservationFailsOnIntermediary(
+	t *testing.T,
+) {
+	t.Parallel()
+	n, u, a, o := setupFaultInjection(t)
+	defer n.Close()
+
+	// B fails reservation entirely.
+	n.Inject(test.Rule{
+		Mint:   1,
+		Method: "POST",
+		Path:   propagateTransactionPattern,
+		Hop:    hop(1),
+		Fault:  test.FtDrop,
+	})
+
+	status, _ := createFaultInjectionTransaction(t, u, a, o)
+	assert.Equal(t, 402, status)
+
+	n.Clear()
+	n.Drain(t, drainRounds)
+	n.AssertConserved(t)
+}
+
+func TestFaultInjectionReservationResponseLostByIntermediary(
+	t *testing.T,
+) {
+	t.Parallel()
+	n, u, a, o := setupFaultInjection(t)
+	defer n.Close()
+
+	// B passes the reservation through to C but its response is lost.
+	n.Inject(test.Rule{
+		Mint:   1,
+		Method: "POST",
+		Path:   propagateTransactionPattern,
+		Hop:    hop(1),
+		Fault:  test.FtDropResponse,
+	})
+
+	status, _ := createFaultInjectionTransaction(t, u, a, o)
+	assert.Equal(t, 402, status)
+
+	n.Clear()
+	n.Drain(t, drainRounds)
+	n.AssertConserved(t)
+}
+
+func TestFaultInjectionSettlementFailsOnDestination(
+	t *testing.T,
+) {
+	t.Parallel()
+	n, u, a, o := setupFaultInjection(t)
+	defer n.Close()
+
+	status, tx := createFaultInjectionTransaction(t, u, a, o)
+	assert.Equal(t, 201, status)
+
+	// A settles to C who fails, and is partitioned once it settled.
+	n.Inject(test.Rule{
+		Mint:   2,
+		Method: "POST",
+		Path:   settleTransactionPattern,
+		Fault:  test.FtDropResponse,
+		Times:  1,
+		Then: func(n *test.Network) {
+			n.Partition(2)
+		},
+	})
+
+	u[0].Post(t,
+		fmt.Sprintf("/transactions/%s/settle", tx.ID),
+		url.Values{})
+
+	n.Heal()
+	n.Drain(t, drainRounds)
+	n.AssertConserved(t)
+
+	// The secret was revealed so the transaction must eventually settle.
+	status, raw := u[2].Get(t, fmt.Sprintf("/transactions/%s", tx.ID))
+	assert.Equal(t, 200, status)
+
+	var tx2 mint.TransactionResource
+	err := raw.Extract("transaction", &tx2)
+	assert.Nil(t, err)
+	assert.Equal(t, mint.TxStSettled, tx2.Status)
+}
+
+func TestFaultInjectionDuplicateSettlement(
+	t *testing.T,
+) {
+	t.Parallel()
+	n, u, a, o := setupFaultInjection(t)
+	defer n.Close()
+
+	status, tx := createFaultInjectionTransaction(t, u, a, o)
+	assert.Equal(t, 201, status)
+
+	for i := range n.Mints {
+		n.Inject(test.Rule{
+			Mint:   i,
+			Method: "POST",
+			Path:   settleTransactionPattern,
+			Fault:  test.FtDuplicate,
+		})
+	}
+
+	status, raw := u[0].Post(t,
+		fmt.Sprintf("/transactions/%s/settle", tx.ID),
+		url.Values{})
+	assert.Equal(t, 200, status)
+
+	var tx0 mint.TransactionResource
+	err := raw.Extract("transaction", &tx0)
+	assert.Nil(t, err)
+	assert.Equal(t, mint.TxStSettled, tx0.Status)
+
+	n.Drain(t, drainRounds)
+	n.AssertConserved(t)
+}
+
+func TestFaultInjectionOfferPriceMismatch(
+	t *testing.T,
+) {
+	t.Parallel()
+	n, u, a, o := setupFaultInjection(t)
+	defer n.Close()
+
+	// B gives a better price for its offer to the first mint retrieving it
+	// than the one it reserves at.
+	n.Inject(test.Rule{
+		Mint:   1,
+		Method: "GET",
+		Path:   retrieveOfferPattern,
+		Fault:  test.FtRewrite,
+		Times:  1,
+		Rewrite: func(status int, raw svc.Resp) (int, svc.Resp) {
+			var offer mint.OfferResource
+			if err := raw.Extract("offer", &offer); err == nil {
+				offer.Price = "100/50"
+				raw["offer"] = format.JSONPtr(offer)
+			}
+			return status, raw
+		},
+	})
+
+	status, tx := createFaultInjectionTransaction(t, u, a, o)
+	if status == 201 {
+		u[0].Post(t,
+			fmt.Sprintf("/transactions/%s/settle", tx.ID),
+			url.Values{})
+	}
+
+	n.Drain(t, drainRounds)
+	n.AssertConserved(t)
+}
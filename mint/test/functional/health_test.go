@@ -0,0 +1,166 @@
+package functional
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/async"
+	"github.com/spolu/settle/mint/async/task"
+	"github.com/spolu/settle/mint/lib/health"
+	"github.com/spolu/settle/mint/model"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupHealth(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+		test.CreateMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+		m[1].CreateUser(t),
+	}
+
+	return m, u
+}
+
+func tearDownHealth(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+// healthChecks returns the status of the health checks by name.
+func healthChecks(
+	checks []mint.HealthCheckResource,
+) map[string]mint.HlStatus {
+	statuses := map[string]mint.HlStatus{}
+	for _, c := range checks {
+		statuses[c.Name] = c.Status
+	}
+	return statuses
+}
+
+func TestHealthLiveness(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, _ := setupHealth(t)
+	defer tearDownHealth(t, m)
+
+	status, raw := m[0].Get(t, nil, "/healthz")
+	assert.Equal(t, 200, status)
+
+	var s mint.HlStatus
+	err := raw.Extract("status", &s)
+	assert.Nil(t, err)
+	assert.Equal(t, mint.HlStOK, s)
+}
+
+func TestHealthReadiness(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupHealth(t)
+	defer tearDownHealth(t, m)
+
+	// No worker ran yet.
+	status, raw := m[0].Get(t, nil, "/readyz")
+	assert.Equal(t, 503, status)
+
+	var h mint.HealthResource
+	err := raw.Extract("health", &h)
+	assert.Nil(t, err)
+	assert.Equal(t, mint.HlStFailed, h.Status)
+	assert.Equal(t, map[string]mint.HlStatus{
+		"db":      mint.HlStOK,
+		"workers": mint.HlStFailed,
+		"tasks":   mint.HlStOK,
+	}, healthChecks(h.Checks))
+	assert.Nil(t, h.Peers)
+
+	async.TestRunOne(m[0].Ctx)
+
+	status, raw = m[0].Get(t, nil, "/readyz")
+	assert.Equal(t, 200, status)
+	err = raw.Extract("health", &h)
+	assert.Nil(t, err)
+	assert.Equal(t, mint.HlStOK, h.Status)
+
+	// A task left unclaimed long after its deadline fails readiness.
+	ctx := db.Begin(mint.WithHost(m[0].Ctx, u[0].Host), "mint")
+	defer db.LoggedRollback(ctx)
+
+	now := time.Now()
+	_, err = model.CreateTask(ctx,
+		now, task.TkPropagateOffer, "foo",
+		model.JSON("{}"), mint.TkStPending, 0,
+		now.Add(-2*health.MaxTaskOverdue))
+	assert.Nil(t, err)
+
+	db.Commit(ctx)
+
+	status, raw = m[0].Get(t, nil, "/readyz")
+	assert.Equal(t, 503, status)
+	err = raw.Extract("health", &h)
+	assert.Nil(t, err)
+	assert.Equal(t, mint.HlStFailed, healthChecks(h.Checks)["tasks"])
+}
+
+func TestHealthReadinessPeers(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupHealth(t)
+	defer tearDownHealth(t, m)
+
+	u[0].CreateAsset(t, "USD", 2)
+	u[1].CreateAsset(t, "USD", 2)
+	u[0].CreateOffer(t,
+		fmt.Sprintf("%s[USD.2]/%s[USD.2]", u[0].Address, u[1].Address),
+		"1/1", big.NewInt(100))
+
+	// Propagating the offer contacts m[1].
+	async.TestRunOne(m[0].Ctx)
+
+	// Checking peers requires the admin secret.
+	status, raw := m[0].Get(t, nil, "/readyz?peers=true")
+
+	var e errors.ConcreteUserError
+	err := raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 401, status)
+	assert.Equal(t, "admin_secret_invalid", e.ErrCode)
+
+	status, raw = m[0].Admin(t, http.MethodGet, "/readyz?peers=true",
+		url.Values{})
+	assert.Equal(t, 200, status)
+
+	var h mint.HealthResource
+	err = raw.Extract("health", &h)
+	assert.Nil(t, err)
+
+	found := false
+	for _, p := range h.Peers {
+		if strings.HasSuffix(p.Name, "://"+u[1].Host) {
+			found = true
+			assert.Equal(t, mint.HlStOK, p.Status)
+		}
+	}
+	assert.True(t, found)
+}
@@ -0,0 +1,188 @@
+package functional
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/async"
+	"github.com/spolu/settle/mint/model"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupHosting(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser, *httptest.Server) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+	}
+
+	// Serve a second host from the same mint process and DB, reachable on a
+	// distinct port of the same mux.
+	s := httptest.NewServer(m[0].Mux)
+	hosts := []string{
+		m[0].Server.URL[7:],
+		s.URL[7:],
+	}
+	m[0].Env.Config[mint.EnvCfgHost] = strings.Join(hosts, ",")
+
+	u := []*test.MintUser{
+		m[0].CreateUserForHost(t, hosts[0]),
+		m[0].CreateUserForHost(t, hosts[1]),
+	}
+	u[0].CreateAsset(t, "USD", 2)
+	u[1].CreateAsset(t, "USD", 2)
+
+	return m, u, s
+}
+
+func tearDownHosting(
+	t *testing.T,
+	mints []*test.Mint,
+	s *httptest.Server,
+) {
+	s.Close()
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+func TestHostingScopedUsers(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, s := setupHosting(t)
+	defer tearDownHosting(t, m, s)
+
+	status, raw := u[1].Get(t, "/assets")
+	assert.Equal(t, 200, status)
+
+	var assets []mint.AssetResource
+	err := raw.Extract("assets", &assets)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(assets))
+	assert.Equal(t, u[1].Address, assets[0].Owner)
+
+	// Users of one host cannot authenticate on the other host.
+	status, raw = m[0].Get(t, &test.MintUser{
+		Mint:     m[0],
+		Username: u[0].Username,
+		Password: u[0].Password,
+		Host:     u[1].Host,
+	}, "/assets")
+	var e errors.ConcreteUserError
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "username_invalid", e.ErrCode)
+}
+
+func TestHostingUnknownHost(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, s := setupHosting(t)
+	defer tearDownHosting(t, m, s)
+
+	req, err := http.NewRequest("GET",
+		fmt.Sprintf("%s/assets", m[0].Server.URL), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "unknown.example.com"
+	req.SetBasicAuth(u[0].Username, u[0].Password)
+
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	assert.Equal(t, 404, r.StatusCode)
+}
+
+func TestHostingPropagateOffer(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, s := setupHosting(t)
+	defer tearDownHosting(t, m, s)
+
+	status, raw := u[0].Post(t,
+		fmt.Sprintf("/offers"),
+		url.Values{
+			"pair":   {fmt.Sprintf("%s[USD.2]/%s[USD.2]", u[0].Address, u[1].Address)},
+			"price":  {"1/1"},
+			"amount": {"100"},
+		})
+	assert.Equal(t, 201, status)
+
+	var offer mint.OfferResource
+	err := raw.Extract("offer", &offer)
+	assert.Nil(t, err)
+
+	// The propagation task is executed for the host that created the offer
+	// and propagates it to the other host served by the same mint.
+	async.TestRunOne(m[0].Ctx)
+
+	owner, token, err := mint.NormalizedOwnerAndTokenFromID(m[0].Ctx, offer.ID)
+	assert.Nil(t, err)
+
+	of, err := model.LoadCanonicalOfferByOwnerToken(
+		mint.WithHost(m[0].Ctx, u[0].Host), owner, token)
+	assert.Nil(t, err)
+	assert.NotNil(t, of)
+
+	of, err = model.LoadPropagatedOfferByOwnerToken(
+		mint.WithHost(m[0].Ctx, u[1].Host), owner, token)
+	assert.Nil(t, err)
+	assert.NotNil(t, of)
+
+	// The propagated copy is not visible on the host of the offer owner.
+	of, err = model.LoadPropagatedOfferByOwnerToken(
+		mint.WithHost(m[0].Ctx, u[0].Host), owner, token)
+	assert.Nil(t, err)
+	assert.Nil(t, of)
+}
+
+func TestHostingPublicURL(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, s := setupHosting(t)
+	defer tearDownHosting(t, m, s)
+
+	// A single URL is ambiguous when serving several hosts.
+	m[0].Env.Config[mint.EnvCfgPublicURL] = "http://localhost:2406"
+	_, err := mint.GetPublicURL(m[0].Ctx)
+	assert.NotNil(t, err)
+
+	m[0].Env.Config[mint.EnvCfgPublicURL] = "unknown.example=http://localhost"
+	_, err = mint.GetPublicURL(m[0].Ctx)
+	assert.NotNil(t, err)
+
+	// Each host advertises its own public URL, defaulting to its host.
+	m[0].Env.Config[mint.EnvCfgPublicURL] = fmt.Sprintf(
+		"%s=http://localhost:2406", u[1].Host)
+
+	status, raw := u[0].Get(t, mint.DiscoveryPath)
+	assert.Equal(t, 200, status)
+	var doc mint.MintResource
+	err = raw.Extract("mint", &doc)
+	assert.Nil(t, err)
+	assert.Equal(t, u[0].Host, fmt.Sprintf("%s:%d", doc.Host, doc.Port))
+
+	status, raw = u[1].Get(t, mint.DiscoveryPath)
+	assert.Equal(t, 200, status)
+	err = raw.Extract("mint", &doc)
+	assert.Nil(t, err)
+	assert.Equal(t, "localhost", doc.Host)
+	assert.Equal(t, int64(2406), doc.Port)
+}
@@ -0,0 +1,210 @@
+package functional
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"testing"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupListOffers(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser, []mint.AssetResource, []mint.OfferResource) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+		test.CreateMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+		m[1].CreateUser(t),
+	}
+	a := []mint.AssetResource{
+		u[0].CreateAsset(t, "USD", 2),
+		u[1].CreateAsset(t, "USD", 2),
+	}
+
+	o := []mint.OfferResource{
+		u[1].CreateOffer(t,
+			fmt.Sprintf("%s/%s", a[1].Name, a[0].Name),
+			"100/100", big.NewInt(100)),
+		u[1].CreateOffer(t,
+			fmt.Sprintf("%s/%s", a[1].Name, a[0].Name),
+			"100/98", big.NewInt(50)),
+		u[0].CreateOffer(t,
+			fmt.Sprintf("%s/%s", a[0].Name, a[1].Name),
+			"100/100", big.NewInt(100)),
+	}
+
+	status, raw := u[0].Post(t,
+		fmt.Sprintf("/transactions"),
+		url.Values{
+			"pair":        {fmt.Sprintf("%s/%s", a[0].Name, a[1].Name)},
+			"amount":      {"10"},
+			"destination": {u[1].Address},
+			"path[]":      {o[0].ID},
+		})
+
+	assert.Equal(t, 201, status)
+
+	var tx mint.TransactionResource
+	err := raw.Extract("transaction", &tx)
+	assert.Nil(t, err)
+
+	status, raw = u[0].Post(t,
+		fmt.Sprintf("/transactions/%s/settle", tx.ID),
+		url.Values{})
+
+	assert.Equal(t, 200, status)
+
+	status, raw = u[1].Post(t,
+		fmt.Sprintf("/offers/%s/close", o[1].ID),
+		url.Values{})
+
+	assert.Equal(t, 200, status)
+
+	return m, u, a, o
+}
+
+func tearDownListOffers(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+func TestListOffers(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, _, o := setupListOffers(t)
+	defer tearDownListOffers(t, m)
+
+	status, raw := u[1].Get(t, fmt.Sprintf("/offers"))
+
+	var offers []mint.OfferResource
+	err := raw.Extract("offers", &offers)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 2, len(offers))
+
+	assert.Equal(t, o[1].ID, offers[0].ID)
+	assert.Equal(t, mint.OfStClosed, offers[0].Status)
+	assert.Equal(t, mint.PgTpCanonical, offers[0].Propagation)
+
+	assert.Equal(t, o[0].ID, offers[1].ID)
+	assert.Equal(t, mint.OfStActive, offers[1].Status)
+	assert.Equal(t, big.NewInt(100), offers[1].Amount)
+	assert.Equal(t, big.NewInt(90), offers[1].Remainder)
+
+	status, raw = u[0].Get(t, fmt.Sprintf("/offers"))
+
+	err = raw.Extract("offers", &offers)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 1, len(offers))
+	assert.Equal(t, o[2].ID, offers[0].ID)
+}
+
+func TestListOffersWithLimit(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, _, o := setupListOffers(t)
+	defer tearDownListOffers(t, m)
+
+	status, raw := u[1].Get(t, fmt.Sprintf("/offers?limit=1"))
+
+	var offers []mint.OfferResource
+	err := raw.Extract("offers", &offers)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 1, len(offers))
+	assert.Equal(t, o[1].ID, offers[0].ID)
+
+	status, raw = u[1].Get(t, fmt.Sprintf(
+		"/offers?created_before=%d", offers[0].Created))
+
+	err = raw.Extract("offers", &offers)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 1, len(offers))
+	assert.Equal(t, o[0].ID, offers[0].ID)
+}
+
+func TestListOfferCrossings(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, _, o := setupListOffers(t)
+	defer tearDownListOffers(t, m)
+
+	status, raw := u[1].Get(t,
+		fmt.Sprintf("/offers/%s/crossings", o[0].ID))
+
+	var crossings []mint.CrossingResource
+	err := raw.Extract("crossings", &crossings)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 1, len(crossings))
+
+	assert.Equal(t, o[0].ID, crossings[0].Offer)
+	assert.Equal(t, big.NewInt(10), crossings[0].Amount)
+	assert.Equal(t, mint.TxStSettled, crossings[0].Status)
+
+	status, raw = u[1].Get(t,
+		fmt.Sprintf("/offers/%s/crossings", o[1].ID))
+
+	err = raw.Extract("crossings", &crossings)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 0, len(crossings))
+}
+
+func TestListOfferCrossingsNotAuthorized(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, _, o := setupListOffers(t)
+	defer tearDownListOffers(t, m)
+
+	status, raw := u[0].Get(t,
+		fmt.Sprintf("/offers/%s/crossings", o[0].ID))
+
+	var e errors.ConcreteUserError
+	err := raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "not_authorized", e.ErrCode)
+}
+
+func TestListOfferCrossingsNotFound(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, _, _ := setupListOffers(t)
+	defer tearDownListOffers(t, m)
+
+	status, raw := u[1].Get(t,
+		fmt.Sprintf("/offers/%s[offer_foo]/crossings", u[1].Address))
+
+	var e errors.ConcreteUserError
+	err := raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 404, status)
+	assert.Equal(t, "offer_not_found", e.ErrCode)
+}
@@ -0,0 +1,187 @@
+package functional
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"testing"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/model"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupMemoryStore(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser) {
+	m := []*test.Mint{
+		test.CreateMemoryMint(t),
+		test.CreateMemoryMint(t),
+		test.CreateMemoryMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+		m[1].CreateUser(t),
+		m[2].CreateUser(t),
+	}
+
+	return m, u
+}
+
+func tearDownMemoryStore(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+func TestMemoryStoreSettleTransaction(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupMemoryStore(t)
+	defer tearDownMemoryStore(t, m)
+
+	a := []mint.AssetResource{
+		u[0].CreateAsset(t, "USD", 2),
+		u[1].CreateAsset(t, "USD", 2),
+		u[2].CreateAsset(t, "USD", 2),
+	}
+	o := []mint.OfferResource{
+		u[1].CreateOffer(t,
+			fmt.Sprintf("%s/%s", a[1].Name, a[0].Name),
+			"100/100", big.NewInt(100)),
+		u[2].CreateOffer(t,
+			fmt.Sprintf("%s/%s", a[2].Name, a[1].Name),
+			"98/100", big.NewInt(100)),
+	}
+
+	status, raw := u[0].Post(t,
+		fmt.Sprintf("/transactions"),
+		url.Values{
+			"pair":        {fmt.Sprintf("%s/%s", a[0].Name, a[2].Name)},
+			"amount":      {"10"},
+			"destination": {u[2].Address},
+			"path[]":      {o[0].ID, o[1].ID},
+		})
+
+	assert.Equal(t, 201, status)
+
+	var tx mint.TransactionResource
+	err := raw.Extract("transaction", &tx)
+	assert.Nil(t, err)
+
+	status, raw = u[0].Post(t,
+		fmt.Sprintf("/transactions/%s/settle", tx.ID),
+		url.Values{})
+
+	var tx0 mint.TransactionResource
+	err = raw.Extract("transaction", &tx0)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, mint.TxStSettled, tx0.Status)
+
+	status, raw = u[2].Get(t, fmt.Sprintf("/transactions/%s", tx0.ID))
+
+	var tx2 mint.TransactionResource
+	err = raw.Extract("transaction", &tx2)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, mint.TxStSettled, tx2.Status)
+	assert.Equal(t, 1, len(tx2.Crossings))
+	assert.Equal(t, mint.TxStSettled, tx2.Crossings[0].Status)
+
+	balance, err := model.LoadCanonicalBalanceByAssetHolder(m[0].Ctx,
+		a[0].Name, u[1].Address)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(11), (*big.Int)(&balance.Value))
+
+	balance, err = model.LoadCanonicalBalanceByAssetHolder(m[1].Ctx,
+		a[1].Name, u[2].Address)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(11), (*big.Int)(&balance.Value))
+}
+
+func TestMemoryStoreTransactionIsolation(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupMemoryStore(t)
+	defer tearDownMemoryStore(t, m)
+
+	// Objects created within a rolled back transaction are discarded.
+	ctx := db.Begin(m[0].Ctx, "mint")
+	_, err := model.CreateCanonicalAsset(ctx, u[0].Address, "USD", 2)
+	assert.Nil(t, err)
+	db.LoggedRollback(ctx)
+
+	asset, err := model.LoadCanonicalAssetByOwnerCodeScale(m[0].Ctx,
+		u[0].Address, "USD", 2)
+	assert.Nil(t, err)
+	assert.Nil(t, asset)
+
+	// Objects created within a transaction are only visible to other
+	// transactions once committed.
+	ctx = db.Begin(m[0].Ctx, "mint")
+	defer db.LoggedRollback(ctx)
+	_, err = model.CreateCanonicalAsset(ctx, u[0].Address, "USD", 2)
+	assert.Nil(t, err)
+
+	asset, err = model.LoadCanonicalAssetByOwnerCodeScale(ctx,
+		u[0].Address, "USD", 2)
+	assert.Nil(t, err)
+	assert.NotNil(t, asset)
+
+	asset, err = model.LoadCanonicalAssetByOwnerCodeScale(m[0].Ctx,
+		u[0].Address, "USD", 2)
+	assert.Nil(t, err)
+	assert.Nil(t, asset)
+
+	db.Commit(ctx)
+
+	asset, err = model.LoadCanonicalAssetByOwnerCodeScale(m[0].Ctx,
+		u[0].Address, "USD", 2)
+	assert.Nil(t, err)
+	assert.NotNil(t, asset)
+
+	// Unique constraints are enforced.
+	_, err = model.CreateCanonicalAsset(m[0].Ctx, u[0].Address, "USD", 2)
+	assert.NotNil(t, err)
+	_, ok := errors.Cause(err).(model.ErrUniqueConstraintViolation)
+	assert.True(t, ok)
+}
+
+func TestMemoryStoreSerializationFailure(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupMemoryStore(t)
+	defer tearDownMemoryStore(t, m)
+
+	ctx0 := db.Begin(m[0].Ctx, "mint")
+	defer db.LoggedRollback(ctx0)
+
+	// The first transaction reads before the second one commits a write.
+	asset, err := model.LoadCanonicalAssetByOwnerCodeScale(ctx0,
+		u[0].Address, "USD", 2)
+	assert.Nil(t, err)
+	assert.Nil(t, asset)
+
+	ctx1 := db.Begin(m[0].Ctx, "mint")
+	defer db.LoggedRollback(ctx1)
+	_, err = model.CreateCanonicalAsset(ctx1, u[0].Address, "EUR", 2)
+	assert.Nil(t, err)
+	db.Commit(ctx1)
+
+	// Writing from a stale snapshot fails and can be retried.
+	_, err = model.CreateCanonicalAsset(ctx0, u[0].Address, "USD", 2)
+	assert.NotNil(t, err)
+	assert.True(t, db.IsSerializationFailure(err))
+}
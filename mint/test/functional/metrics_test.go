@@ -0,0 +1,121 @@
+package functional
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/async"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupMetrics(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+		test.CreateMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+		m[1].CreateUser(t),
+	}
+
+	return m, u
+}
+
+func tearDownMetrics(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+// scrapeMetrics retrieves the metrics exposed by the mint, authenticated with
+// the admin secret.
+func scrapeMetrics(
+	t *testing.T,
+	m *test.Mint,
+) string {
+	req, err := http.NewRequest("GET",
+		fmt.Sprintf("%s/metrics", m.Server.URL), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization",
+		"Bearer "+m.Env.Config[mint.EnvCfgAdminSecret])
+
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	assert.Equal(t, 200, r.StatusCode)
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestMetrics(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupMetrics(t)
+	defer tearDownMetrics(t, m)
+
+	u[0].CreateAsset(t, "USD", 2)
+	u[1].CreateAsset(t, "USD", 2)
+	u[0].CreateOffer(t,
+		fmt.Sprintf("%s[USD.2]/%s[USD.2]", u[0].Address, u[1].Address),
+		"1/1", big.NewInt(100))
+
+	status, _ := u[0].Get(t, "/assets/foo")
+	assert.Equal(t, 400, status)
+
+	async.TestRunOne(m[0].Ctx)
+
+	// Peers that never responded are not labeled by host.
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	client := &mint.Client{}
+	err := client.Init(m[0].Ctx)
+	assert.Nil(t, err)
+	_, err = client.RetrieveOffer(m[0].Ctx,
+		fmt.Sprintf("foo@%s[offer_foo]", down.URL[7:]))
+	assert.NotNil(t, err)
+
+	// Metrics require the admin secret.
+	status, _ = m[0].Get(t, nil, "/metrics")
+	assert.Equal(t, 401, status)
+
+	metrics := scrapeMetrics(t, m[0])
+
+	for _, e := range []string{
+		"settle_mint_requests_total{endpoint=\"CreateAsset\",status=\"201\"}",
+		"settle_mint_requests_total{endpoint=\"RetrieveAsset\",status=\"400\"}",
+		"settle_mint_request_duration_seconds_count{endpoint=\"CreateOffer\"}",
+		"settle_mint_requests_total{endpoint=\"PropagateOffer\",status=\"201\"}",
+		"settle_mint_task_executions_total{task=\"PropagateOffer\"}",
+		fmt.Sprintf(
+			"settle_mint_client_requests_total{peer=%q,status=\"201\"}",
+			m[1].Server.URL[7:]),
+		"settle_mint_client_requests_total{peer=\"other\",status=\"error\"}",
+		"settle_mint_async_pending_tasks ",
+		"settle_db_transaction_duration_seconds_count{tag=\"mint\"," +
+			"outcome=\"commit\"}",
+	} {
+		assert.True(t, strings.Contains(metrics, e), e)
+	}
+}
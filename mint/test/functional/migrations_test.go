@@ -0,0 +1,273 @@
+package functional
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/async"
+	"github.com/spolu/settle/mint/async/task"
+	"github.com/spolu/settle/mint/model"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// Schemas of the tables as created by mints before the migrations.
+const (
+	usersV0SQL = `
+CREATE TABLE users(
+  token VARCHAR(256) NOT NULL,
+  created TIMESTAMP NOT NULL,
+  username VARCHAR(256) NOT NULL,
+  password_hash VARCHAR(256) NOT NULL,
+  PRIMARY KEY(token),
+  CONSTRAINT users_username_u UNIQUE (username)
+);
+`
+	assetsV0SQL = `
+CREATE TABLE assets(
+  owner VARCHAR(256) NOT NULL,
+  token VARCHAR(256) NOT NULL,
+  created TIMESTAMP NOT NULL,
+  propagation VARCHAR(32) NOT NULL,
+  code VARCHAR(64) NOT NULL,
+  scale SMALLINT,
+  PRIMARY KEY(owner, token),
+  CONSTRAINT assets_owner_code_scale_u UNIQUE (owner, code, scale)
+);
+`
+	offersV0SQL = `
+CREATE TABLE offers(
+  owner VARCHAR(256) NOT NULL,
+  token VARCHAR(256) NOT NULL,
+  created TIMESTAMP NOT NULL,
+  propagation VARCHAR(32) NOT NULL,
+  base_asset VARCHAR(256) NOT NULL,
+  quote_asset VARCHAR(256) NOT NULL,
+  base_price VARCHAR(64) NOT NULL,
+  quote_price VARCHAR(64) NOT NULL,
+  amount VARCHAR(64) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  remainder VARCHAR(64) NOT NULL,
+  PRIMARY KEY(owner, token)
+);
+`
+	transactionsV0SQL = `
+CREATE TABLE transactions(
+  owner VARCHAR(256) NOT NULL,
+  token VARCHAR(256) NOT NULL,
+  created TIMESTAMP NOT NULL,
+  propagation VARCHAR(32) NOT NULL,
+  base_asset VARCHAR(256) NOT NULL,
+  quote_asset VARCHAR(256) NOT NULL,
+  amount VARCHAR(64) NOT NULL,
+  destination VARCHAR(256) NOT NULL,
+  path VARCHAR(2048) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  lock VARCHAR(256) NOT NULL,
+  secret VARCHAR(256),
+  PRIMARY KEY(owner, token)
+);
+`
+	tasksV0SQL = `
+CREATE TABLE tasks(
+  token VARCHAR(256) NOT NULL,
+  created TIMESTAMP NOT NULL,
+  name VARCHAR(256) NOT NULL,
+  subject VARCHAR(256) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  retry INT,
+  PRIMARY KEY(token)
+);
+`
+)
+
+// downgradeTable recreates a table of the mint with a previous version of its
+// schema, keeping the provided columns of its rows.
+func downgradeTable(
+	t *testing.T,
+	m *test.Mint,
+	table string,
+	schema string,
+	columns string,
+) {
+	for _, q := range []string{
+		fmt.Sprintf("CREATE TABLE %s_v0 AS SELECT %s FROM %s",
+			table, columns, table),
+		fmt.Sprintf("DROP TABLE %s", table),
+		schema,
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s_v0",
+			table, columns, columns, table),
+		fmt.Sprintf("DROP TABLE %s_v0", table),
+	} {
+		if _, err := m.DB.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func setupMigrations(
+	t *testing.T,
+) (
+	[]*test.Mint,
+	[]*test.MintUser,
+	[]mint.AssetResource,
+	[]mint.OfferResource,
+	[]mint.TransactionResource,
+) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+		m[0].CreateUser(t),
+	}
+	a := []mint.AssetResource{
+		u[0].CreateAsset(t, "USD", 2),
+		u[1].CreateAsset(t, "USD", 2),
+	}
+	o := []mint.OfferResource{
+		u[1].CreateOffer(t,
+			fmt.Sprintf("%s/%s", a[1].Name, a[0].Name),
+			"100/100", big.NewInt(100)),
+	}
+
+	status, raw := u[0].Post(t,
+		fmt.Sprintf("/transactions"),
+		url.Values{
+			"pair":        {fmt.Sprintf("%s/%s", a[0].Name, a[1].Name)},
+			"amount":      {"10"},
+			"destination": {u[1].Address},
+			"path[]":      {o[0].ID},
+		})
+	assert.Equal(t, 201, status)
+
+	tx := []mint.TransactionResource{{}}
+	if err := raw.Extract("transaction", &tx[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	downgradeTable(t, m[0], "users", usersV0SQL,
+		"token, created, username, password_hash")
+	downgradeTable(t, m[0], "assets", assetsV0SQL,
+		"owner, token, created, propagation, code, scale")
+	downgradeTable(t, m[0], "offers", offersV0SQL,
+		"owner, token, created, propagation, base_asset, quote_asset, "+
+			"base_price, quote_price, amount, status, remainder")
+	downgradeTable(t, m[0], "transactions", transactionsV0SQL,
+		"owner, token, created, propagation, base_asset, quote_asset, "+
+			"amount, destination, path, status, lock, secret")
+	downgradeTable(t, m[0], "tasks", tasksV0SQL,
+		"token, created, name, subject, status, retry")
+
+	// Propagation tasks used to hold their hop in their subject.
+	_, err := m[0].DB.Exec(m[0].DB.Rebind(`
+INSERT INTO tasks (token, created, name, subject, status, retry)
+VALUES (?, ?, ?, ?, ?, ?)
+`), "task_legacy", time.Now().UTC(), task.TkPropagateSettlement,
+		fmt.Sprintf("%s|1", tx[0].ID), mint.TkStSucceeded, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m, u, a, o, tx
+}
+
+func tearDownMigrations(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+func TestMigrations(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, a, o, tx := setupMigrations(t)
+	defer tearDownMigrations(t, m)
+
+	// Migrations are idempotent.
+	for i := 0; i < 2; i++ {
+		err := db.CreateDBTables(m[0].Ctx, "mint", m[0].DB)
+		assert.Nil(t, err)
+	}
+
+	status, raw := u[0].Get(t, "/assets")
+
+	var assets []mint.AssetResource
+	err := raw.Extract("assets", &assets)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 1, len(assets))
+	assert.Equal(t, a[0].ID, assets[0].ID)
+
+	status, raw = u[1].Get(t, fmt.Sprintf("/offers/%s", o[0].ID))
+
+	var offer mint.OfferResource
+	err = raw.Extract("offer", &offer)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, o[0].ID, offer.ID)
+	assert.Equal(t, big.NewInt(90), offer.Remainder)
+
+	var hosts []string
+	err = m[0].DB.Select(&hosts, "SELECT DISTINCT mint FROM offers")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{u[1].Host}, hosts)
+
+	status, raw = u[0].Get(t, fmt.Sprintf("/transactions/%s", tx[0].ID))
+
+	var transaction mint.TransactionResource
+	err = raw.Extract("transaction", &transaction)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, tx[0].ID, transaction.ID)
+
+	var traces []string
+	err = m[0].DB.Select(&traces, "SELECT trace FROM transactions")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{""}, traces)
+
+	ctx := mint.WithHost(m[0].Ctx, u[0].Host)
+
+	var enabled int
+	err = m[0].DB.Get(&enabled,
+		"SELECT COUNT(*) FROM users WHERE disabled IS NULL")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, enabled)
+
+	// Tasks are scoped by mint and reconstructed from their legacy subject.
+	legacy, err := model.LoadTaskByToken(ctx, "task_legacy")
+	assert.Nil(t, err)
+	assert.NotNil(t, legacy)
+	assert.Equal(t, "", legacy.Trace)
+	assert.JSONEq(t, `{}`, string(legacy.Payload))
+
+	tk, err := async.Registrar[legacy.Name](ctx,
+		legacy.Created, legacy.Subject, legacy.Payload)
+	assert.Nil(t, err)
+	assert.Equal(t, tx[0].ID, tk.Subject())
+	assert.JSONEq(t, `{"hop":1}`, format.JSONString(tk.Payload()))
+
+	// The deadline of migrated tasks follows their retry policy.
+	expiries, err := model.LoadTaskList(ctx,
+		time.Now().Add(time.Second), 10, mint.TkStPending,
+		task.TkExpireTransaction, tx[0].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(expiries))
+	assert.WithinDuration(t,
+		expiries[0].Created.Add(
+			time.Duration(mint.TransactionExpiryMs)*time.Millisecond),
+		expiries[0].Deadline, time.Second)
+}
@@ -0,0 +1,237 @@
+package functional
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupPeers(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser, []mint.AssetResource) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+		test.CreateMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+		m[1].CreateUser(t),
+	}
+	a := []mint.AssetResource{
+		u[0].CreateAsset(t, "USD", 2),
+		u[1].CreateAsset(t, "USD", 2),
+	}
+
+	return m, u, a
+}
+
+func tearDownPeers(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+// pay creates a transaction from u[0] to u[1] in the asset of u[0].
+func pay(
+	t *testing.T,
+	u []*test.MintUser,
+	a []mint.AssetResource,
+	amount string,
+) (int, svc.Resp) {
+	return u[0].Post(t, "/transactions", url.Values{
+		"pair":        {fmt.Sprintf("%s/%s", a[0].Name, a[0].Name)},
+		"amount":      {amount},
+		"destination": {u[1].Address},
+		"path[]":      {},
+	})
+}
+
+func assertPeerError(
+	t *testing.T,
+	expectedStatus int,
+	expectedCode string,
+	status int,
+	raw svc.Resp,
+) {
+	var e errors.ConcreteUserError
+	err := raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, expectedStatus, status)
+	assert.Equal(t, expectedCode, e.ErrCode)
+}
+
+func TestPeersAdminBlock(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, a := setupPeers(t)
+	defer tearDownPeers(t, m)
+
+	status, raw := m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/peers/%s", u[1].Host), url.Values{
+			"policy": {"block"},
+		})
+	assert.Equal(t, 200, status)
+
+	var peer mint.PeerResource
+	err := raw.Extract("peer", &peer)
+	assert.Nil(t, err)
+	assert.Equal(t, u[1].Host, peer.Host)
+	assert.Equal(t, "admin", peer.Source)
+	assert.Equal(t, mint.PrPlBlock, peer.Policy)
+	assert.Nil(t, peer.ExposureCap)
+
+	// Outbound: m[0] refuses to pay users of m[1].
+	status, raw = pay(t, u, a, "10")
+	assertPeerError(t, 403, "peer_blocked", status, raw)
+
+	status, raw = m[0].Admin(t, http.MethodGet, "/admin/peers", url.Values{})
+	assert.Equal(t, 200, status)
+
+	var policy mint.PrPolicy
+	var peers []mint.PeerResource
+	err = raw.Extract("default", &policy)
+	assert.Nil(t, err)
+	err = raw.Extract("peers", &peers)
+	assert.Nil(t, err)
+	assert.Equal(t, mint.PrPlAllow, policy)
+	assert.Equal(t, []mint.PeerResource{peer}, peers)
+
+	status, _ = m[0].Admin(t, http.MethodDelete,
+		fmt.Sprintf("/admin/peers/%s", u[1].Host), url.Values{})
+	assert.Equal(t, 200, status)
+
+	status, _ = pay(t, u, a, "10")
+	assert.Equal(t, 201, status)
+
+	status, raw = m[0].Admin(t, http.MethodDelete,
+		fmt.Sprintf("/admin/peers/%s", u[1].Host), url.Values{})
+	assertPeerError(t, 404, "peer_not_found", status, raw)
+
+	// Inbound: m[1] refuses propagations from m[0].
+	status, raw = m[1].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/peers/%s", u[0].Host), url.Values{
+			"policy": {"block"},
+		})
+	assert.Equal(t, 200, status)
+
+	offer := u[0].CreateOffer(t,
+		fmt.Sprintf("%s/%s", a[0].Name, a[1].Name),
+		"1/1", big.NewInt(100))
+
+	status, raw = m[1].Post(t, nil,
+		fmt.Sprintf("/offers/%s", offer.ID), url.Values{})
+	assertPeerError(t, 403, "peer_blocked", status, raw)
+
+	status, raw = m[1].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/peers/%s", u[0].Host), url.Values{
+			"policy": {"deny"},
+		})
+	assertPeerError(t, 400, "policy_invalid", status, raw)
+}
+
+func TestPeersPolicyFile(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, a := setupPeers(t)
+	defer tearDownPeers(t, m)
+
+	dir, err := ioutil.TempDir("", "settle-peers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	blocked := filepath.Join(dir, "blocked.json")
+	err = ioutil.WriteFile(blocked, []byte(`{"default": "block"}`), 0644)
+	assert.Nil(t, err)
+	m[0].Env.Config[mint.EnvCfgPeerPolicy] = blocked
+
+	status, raw := pay(t, u, a, "10")
+	assertPeerError(t, 403, "peer_blocked", status, raw)
+
+	allowed := filepath.Join(dir, "allowed.json")
+	err = ioutil.WriteFile(allowed, []byte(fmt.Sprintf(
+		`{"default": "block", "peers": {"%s": {"policy": "allow"}}}`,
+		u[1].Host)), 0644)
+	assert.Nil(t, err)
+	m[0].Env.Config[mint.EnvCfgPeerPolicy] = allowed
+
+	status, _ = pay(t, u, a, "10")
+	assert.Equal(t, 201, status)
+
+	status, raw = m[0].Admin(t, http.MethodGet, "/admin/peers", url.Values{})
+	assert.Equal(t, 200, status)
+
+	var peers []mint.PeerResource
+	err = raw.Extract("peers", &peers)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(peers))
+	assert.Equal(t, u[1].Host, peers[0].Host)
+	assert.Equal(t, "file", peers[0].Source)
+
+	// The admin API takes precedence over the file.
+	status, _ = m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/peers/%s", u[1].Host), url.Values{
+			"policy": {"block"},
+		})
+	assert.Equal(t, 200, status)
+
+	status, raw = pay(t, u, a, "10")
+	assertPeerError(t, 403, "peer_blocked", status, raw)
+}
+
+func TestPeersExposureCap(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, a := setupPeers(t)
+	defer tearDownPeers(t, m)
+
+	status, raw := m[0].Admin(t, http.MethodPost,
+		fmt.Sprintf("/admin/peers/%s", u[1].Host), url.Values{
+			"policy":       {"allow"},
+			"exposure_cap": {"15"},
+		})
+	assert.Equal(t, 200, status)
+
+	var peer mint.PeerResource
+	err := raw.Extract("peer", &peer)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(15), peer.ExposureCap)
+
+	status, _ = pay(t, u, a, "10")
+	assert.Equal(t, 201, status)
+
+	// The exposure cap is enforced when reserving operations, as the plan
+	// gets executed, so it fails the transaction.
+	status, raw = pay(t, u, a, "10")
+	assertPeerError(t, 402, "transaction_failed", status, raw)
+
+	status, _ = pay(t, u, a, "5")
+	assert.Equal(t, 201, status)
+
+	// The exposure cap applies per asset.
+	eur := []mint.AssetResource{u[0].CreateAsset(t, "EUR", 2)}
+	status, _ = pay(t, u, eur, "10")
+	assert.Equal(t, 201, status)
+
+	status, raw = pay(t, u, eur, "10")
+	assertPeerError(t, 402, "transaction_failed", status, raw)
+}
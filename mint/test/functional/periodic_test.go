@@ -0,0 +1,161 @@
+package functional
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/async"
+	"github.com/spolu/settle/mint/async/task"
+	"github.com/spolu/settle/mint/model"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupPeriodic(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+	}
+	return m, u
+}
+
+func tearDownPeriodic(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+// claimSchedule claims a schedule on the mint DB on behalf of owner at the
+// provided time.
+func claimSchedule(
+	t *testing.T,
+	m *test.Mint,
+	owner string,
+	now time.Time,
+) *model.Schedule {
+	ctx := db.Begin(m.Ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	s, err := model.ClaimSchedule(ctx, owner, now, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Commit(ctx)
+
+	return s
+}
+
+func TestPeriodicPurgeTasks(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupPeriodic(t)
+	defer tearDownPeriodic(t, m)
+
+	ctx := db.Begin(mint.WithHost(m[0].Ctx, u[0].Host), "mint")
+	defer db.LoggedRollback(ctx)
+
+	now := time.Now()
+	old, err := model.CreateTask(ctx,
+		now.Add(-2*task.TaskRetention), task.TkPropagateOffer, "foo",
+		model.JSON("{}"), mint.TkStSucceeded, 0, now)
+	assert.Nil(t, err)
+	recent, err := model.CreateTask(ctx,
+		now, task.TkPropagateOffer, "foo",
+		model.JSON("{}"), mint.TkStSucceeded, 0, now)
+	assert.Nil(t, err)
+
+	db.Commit(ctx)
+
+	// The periodic task is scheduled but not due yet.
+	async.TestRunPeriodic(m[0].Ctx)
+
+	ctx = db.Begin(mint.WithHost(m[0].Ctx, u[0].Host), "mint")
+	defer db.LoggedRollback(ctx)
+
+	s, err := model.LoadScheduleByName(ctx, task.TkPurgeTasks)
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+	assert.Nil(t, s.Last)
+	assert.True(t, s.Next.After(now))
+
+	tk, err := model.LoadTaskByToken(ctx, old.Token)
+	assert.Nil(t, err)
+	assert.NotNil(t, tk)
+
+	db.Commit(ctx)
+
+	// Only one worker can claim a due schedule.
+	a := async.Get(m[0].Ctx)
+	claimed := claimSchedule(t, m[0], a.Owner, s.Next.Add(time.Second))
+	assert.NotNil(t, claimed)
+	assert.Equal(t, task.TkPurgeTasks, claimed.Name)
+	assert.Nil(t, claimSchedule(t, m[0], "worker_b", s.Next.Add(time.Second)))
+
+	a.RunPeriodic(claimed)
+
+	ctx = db.Begin(mint.WithHost(m[0].Ctx, u[0].Host), "mint")
+	defer db.LoggedRollback(ctx)
+
+	s, err = model.LoadScheduleByName(ctx, task.TkPurgeTasks)
+	assert.Nil(t, err)
+	assert.NotNil(t, s.Last)
+	assert.Nil(t, s.LastError)
+	assert.Nil(t, s.LeaseOwner)
+	assert.True(t, s.Next.After(*s.Last))
+
+	tk, err = model.LoadTaskByToken(ctx, old.Token)
+	assert.Nil(t, err)
+	assert.Nil(t, tk)
+
+	tk, err = model.LoadTaskByToken(ctx, recent.Token)
+	assert.Nil(t, err)
+	assert.NotNil(t, tk)
+}
+
+func TestPeriodicRecurrence(
+	t *testing.T,
+) {
+	t.Parallel()
+
+	// Friday.
+	after := time.Date(2017, 3, 3, 10, 7, 0, 0, time.UTC)
+
+	assert.Equal(t, after.Add(time.Hour), async.Every(time.Hour).Next(after))
+
+	r, err := async.ParseCron("*/15 * * * *")
+	assert.Nil(t, err)
+	assert.Equal(t,
+		time.Date(2017, 3, 3, 10, 15, 0, 0, time.UTC), r.Next(after))
+
+	r, err = async.ParseCron("30 2 * * 1-5")
+	assert.Nil(t, err)
+	assert.Equal(t,
+		time.Date(2017, 3, 6, 2, 30, 0, 0, time.UTC), r.Next(after))
+
+	r, err = async.ParseCron("0 0 1,15 * *")
+	assert.Nil(t, err)
+	assert.Equal(t,
+		time.Date(2017, 3, 15, 0, 0, 0, 0, time.UTC), r.Next(after))
+
+	r, err = async.ParseCron("0 0 31 2 *")
+	assert.Nil(t, err)
+	assert.True(t, r.Next(after).IsZero())
+
+	for _, expr := range []string{
+		"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "a * * * *",
+	} {
+		_, err = async.ParseCron(expr)
+		assert.NotNil(t, err, expr)
+	}
+}
@@ -0,0 +1,195 @@
+package functional
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/env"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/async/task"
+	"github.com/spolu/settle/mint/model"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTaskLease(
+	t *testing.T,
+) ([]*test.Mint, *model.Task) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+	}
+
+	ctx := db.Begin(m[0].Ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	now := time.Now()
+	tk, err := model.CreateTask(ctx,
+		now, task.TkPropagateOffer, "foo", model.JSON("{}"),
+		mint.TkStPending, 0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Commit(ctx)
+
+	return m, tk
+}
+
+func tearDownTaskLease(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+// claimTask claims a task on the mint DB on behalf of owner at the provided
+// time.
+func claimTask(
+	t *testing.T,
+	m *test.Mint,
+	owner string,
+	now time.Time,
+) *model.Task {
+	ctx := db.Begin(m.Ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	tk, err := model.ClaimTask(ctx, owner, now, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Commit(ctx)
+
+	return tk
+}
+
+func TestTaskLeaseExclusive(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, tk := setupTaskLease(t)
+	defer tearDownTaskLease(t, m)
+
+	now := time.Now()
+
+	claimed := claimTask(t, m[0], "worker_a", now)
+	assert.NotNil(t, claimed)
+	assert.Equal(t, tk.Token, claimed.Token)
+	assert.Equal(t, "worker_a", *claimed.LeaseOwner)
+
+	// The task is leased by worker_a.
+	assert.Nil(t, claimTask(t, m[0], "worker_b", now))
+
+	// The lease of worker_a expired, worker_b can claim the task.
+	claimed = claimTask(t, m[0], "worker_b", now.Add(2*time.Minute))
+	assert.NotNil(t, claimed)
+	assert.Equal(t, tk.Token, claimed.Token)
+
+	ctx := db.Begin(m[0].Ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	held, err := tk.RenewLease(ctx, "worker_a", now.Add(time.Minute))
+	assert.Nil(t, err)
+	assert.False(t, held)
+
+	held, err = tk.Release(ctx, "worker_a")
+	assert.Nil(t, err)
+	assert.False(t, held)
+
+	claimed.Status = mint.TkStSucceeded
+	held, err = claimed.Release(ctx, "worker_b")
+	assert.Nil(t, err)
+	assert.True(t, held)
+
+	db.Commit(ctx)
+
+	assert.Nil(t, claimTask(t, m[0], "worker_a", now.Add(time.Hour)))
+}
+
+func TestTaskLeaseDeadline(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, tk := setupTaskLease(t)
+	defer tearDownTaskLease(t, m)
+
+	now := time.Now()
+
+	claimed := claimTask(t, m[0], "worker_a", now)
+	assert.NotNil(t, claimed)
+
+	// The task failed and is released for a retry in one hour.
+	ctx := db.Begin(m[0].Ctx, "mint")
+	defer db.LoggedRollback(ctx)
+
+	claimed.Retry = 1
+	claimed.Deadline = now.Add(time.Hour)
+	held, err := claimed.Release(ctx, "worker_a")
+	assert.Nil(t, err)
+	assert.True(t, held)
+
+	db.Commit(ctx)
+
+	assert.Nil(t, claimTask(t, m[0], "worker_b", now))
+
+	claimed = claimTask(t, m[0], "worker_b", now.Add(2*time.Hour))
+	assert.NotNil(t, claimed)
+	assert.Equal(t, tk.Token, claimed.Token)
+	assert.Equal(t, uint(1), claimed.Retry)
+}
+
+func TestTaskLeaseHosts(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, tk := setupTaskLease(t)
+	defer tearDownTaskLease(t, m)
+
+	// A second process serving other hosts from the same DB.
+	other := &test.Mint{
+		Ctx: env.With(m[0].Ctx, &env.Env{
+			Environment: m[0].Env.Environment,
+			Config: map[env.ConfigKey]string{
+				mint.EnvCfgHost: "foo.example,bar.example",
+			},
+		}),
+	}
+
+	ctx := db.Begin(mint.WithHost(other.Ctx, "bar.example"), "mint")
+	defer db.LoggedRollback(ctx)
+
+	now := time.Now()
+	otk, err := model.CreateTask(ctx,
+		now, task.TkPropagateOffer, "bar", model.JSON("{}"),
+		mint.TkStPending, 0, now)
+	assert.Nil(t, err)
+
+	count, err := model.CountPendingTasks(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	db.Commit(ctx)
+
+	// Each process only sees and claims the tasks of the hosts it serves.
+	pending, err := model.LoadPendingTasks(m[0].Ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(pending))
+	assert.Equal(t, tk.Token, pending[0].Token)
+
+	overdue, err := model.LoadOldestOverdueTask(other.Ctx, now)
+	assert.Nil(t, err)
+	assert.Equal(t, otk.Token, overdue.Token)
+
+	claimed := claimTask(t, other, "worker_b", now)
+	assert.NotNil(t, claimed)
+	assert.Equal(t, otk.Token, claimed.Token)
+	assert.Nil(t, claimTask(t, other, "worker_b", now))
+
+	claimed = claimTask(t, m[0], "worker_a", now)
+	assert.NotNil(t, claimed)
+	assert.Equal(t, tk.Token, claimed.Token)
+	assert.Nil(t, claimTask(t, m[0], "worker_a", now))
+}
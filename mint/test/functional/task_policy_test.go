@@ -0,0 +1,157 @@
+package functional
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/format"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/async"
+	"github.com/spolu/settle/mint/async/task"
+	"github.com/spolu/settle/mint/model"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTaskPolicy(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+	}
+	return m, u
+}
+
+func tearDownTaskPolicy(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+func TestTaskPayload(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupTaskPolicy(t)
+	defer tearDownTaskPolicy(t, m)
+
+	ctx := db.Begin(mint.WithHost(m[0].Ctx, u[0].Host), "mint")
+	defer db.LoggedRollback(ctx)
+
+	now := time.Now()
+	err := async.Queue(ctx, task.NewPropagateSettlement(ctx, now, "foo", 2))
+	assert.Nil(t, err)
+
+	// A task missing its payload cannot be reconstructed.
+	invalid, err := model.CreateTask(ctx,
+		now, task.TkPropagateCancellation, "foo", model.JSON("{}"),
+		mint.TkStPending, 0, now)
+	assert.Nil(t, err)
+
+	tasks, err := model.LoadTaskList(ctx,
+		time.Now().Add(time.Second), 10, mint.TkStPending,
+		task.TkPropagateSettlement, "foo")
+	assert.Nil(t, err)
+
+	db.Commit(ctx)
+
+	assert.Equal(t, 1, len(tasks))
+	assert.JSONEq(t, `{"hop":2}`, string(tasks[0].Payload))
+
+	tk, err := async.Registrar[task.TkPropagateSettlement](ctx,
+		tasks[0].Created, tasks[0].Subject, tasks[0].Payload)
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", tk.Subject())
+	assert.Equal(t, task.NewPropagateSettlement(ctx,
+		tasks[0].Created, "foo", 2).Payload(), tk.Payload())
+
+	_, err = async.Registrar[task.TkPropagateSettlement](ctx,
+		tasks[0].Created, tasks[0].Subject, []byte("{}"))
+	assert.NotNil(t, err)
+
+	a := async.Get(m[0].Ctx)
+	claimed := claimTask(t, m[0], a.Owner, now.Add(time.Second))
+	for claimed != nil && claimed.Token != invalid.Token {
+		claimed = claimTask(t, m[0], a.Owner, now.Add(time.Second))
+	}
+	assert.NotNil(t, claimed)
+	a.RunOne(claimed)
+
+	ctx = db.Begin(mint.WithHost(m[0].Ctx, u[0].Host), "mint")
+	defer db.LoggedRollback(ctx)
+
+	reloaded, err := model.LoadTaskByToken(ctx, invalid.Token)
+	assert.Nil(t, err)
+	assert.Equal(t, mint.TkStFailed, reloaded.Status)
+	assert.NotNil(t, reloaded.LastError)
+	assert.Nil(t, reloaded.LeaseOwner)
+}
+
+func TestTaskLegacySubject(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, _ := setupTaskPolicy(t)
+	defer tearDownTaskPolicy(t, m)
+
+	now := time.Now()
+
+	// Tasks queued by previous versions have their hop in their subject and
+	// no payload.
+	for _, name := range []mint.TkName{
+		task.TkPropagateSettlement, task.TkPropagateCancellation,
+	} {
+		tk, err := async.Registrar[name](m[0].Ctx, now, "foo|2", []byte("{}"))
+		assert.Nil(t, err)
+		assert.Equal(t, "foo", tk.Subject())
+		assert.JSONEq(t, `{"hop":2}`, format.JSONString(tk.Payload()))
+
+		_, err = async.Registrar[name](m[0].Ctx, now, "foo|bar", []byte("{}"))
+		assert.NotNil(t, err)
+	}
+}
+
+func TestTaskRetryPolicy(
+	t *testing.T,
+) {
+	t.Parallel()
+
+	created := time.Now()
+
+	exponential := async.RetryPolicy{
+		Backoff:    async.BkExponential,
+		Interval:   time.Second,
+		MaxRetries: 4,
+		MaxAge:     time.Hour,
+	}
+	assert.Equal(t, created, exponential.DeadlineForRetry(created, 0))
+	assert.Equal(t, created.Add(7*time.Second),
+		exponential.DeadlineForRetry(created, 3))
+	assert.False(t, exponential.Exhausted(created, 4, created))
+	assert.True(t, exponential.Exhausted(created, 5, created))
+	assert.True(t, exponential.Exhausted(created, 1,
+		created.Add(2*time.Hour)))
+	assert.Equal(t, async.DefaultTaskTimeout, exponential.ExecutionTimeout())
+
+	linear := async.RetryPolicy{
+		Delay:    time.Hour,
+		Backoff:  async.BkLinear,
+		Interval: time.Hour,
+		Jitter:   0.5,
+		Timeout:  time.Second,
+	}
+	assert.Equal(t, created.Add(time.Hour), linear.DeadlineForRetry(created, 0))
+	for i := 0; i < 10; i++ {
+		d := linear.DeadlineForRetry(created, 2)
+		assert.False(t, d.Before(created.Add(3*time.Hour)))
+		assert.True(t, d.Before(created.Add(3*time.Hour+30*time.Minute)))
+	}
+	assert.Equal(t, time.Second, linear.ExecutionTimeout())
+}
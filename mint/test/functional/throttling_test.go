@@ -0,0 +1,244 @@
+package functional
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupThrottling(
+	t *testing.T,
+	limits string,
+) ([]*test.Mint, []*test.MintUser) {
+	m := []*test.Mint{
+		test.CreateMint(t),
+	}
+	u := []*test.MintUser{
+		m[0].CreateUser(t),
+		m[0].CreateUser(t),
+	}
+	m[0].Env.Config[mint.EnvCfgRateLimits] = limits
+
+	return m, u
+}
+
+func tearDownThrottling(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+// serveFrom serves a request to the mint as if it was sent from the provided
+// IP address, authenticated with the provided authorization header if any.
+func serveFrom(
+	t *testing.T,
+	m *test.Mint,
+	method string,
+	ip string,
+	host string,
+	path string,
+	authorization string,
+) int {
+	req := httptest.NewRequest(method, path, nil)
+	req.Host = host
+	req.RemoteAddr = ip + ":1234"
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	m.Mux.ServeHTTP(w, req)
+	return w.Code
+}
+
+// assertThrottled asserts that a response was rejected by a rate limit.
+func assertThrottled(
+	t *testing.T,
+	status int,
+	raw svc.Resp,
+) {
+	var e errors.ConcreteUserError
+	err := raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 429, status)
+	assert.Equal(t, "rate_limited", e.ErrCode)
+}
+
+func TestThrottleUser(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupThrottling(t, "user=2/m")
+	defer tearDownThrottling(t, m)
+
+	for i := 0; i < 2; i++ {
+		status, _ := u[0].Get(t, "/assets")
+		assert.Equal(t, 200, status)
+	}
+
+	status, raw := u[0].Get(t, "/assets")
+	assertThrottled(t, status, raw)
+
+	// Other users have their own budget.
+	status, _ = u[1].Get(t, "/assets")
+	assert.Equal(t, 200, status)
+
+	// The retry hint is returned in the Retry-After header.
+	req, err := http.NewRequest("GET",
+		fmt.Sprintf("%s/assets", m[0].Server.URL), nil)
+	assert.Nil(t, err)
+	req.SetBasicAuth(u[0].Username, u[0].Password)
+	r, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	r.Body.Close()
+
+	assert.Equal(t, 429, r.StatusCode)
+	retry, err := strconv.Atoi(r.Header.Get("Retry-After"))
+	assert.Nil(t, err)
+	assert.True(t, retry > 0 && retry <= 30)
+
+	// The admin API is not throttled.
+	for i := 0; i < 3; i++ {
+		status, _ := m[0].Admin(t, http.MethodGet, "/admin/tasks",
+			url.Values{})
+		assert.Equal(t, 200, status)
+	}
+}
+
+func TestThrottleExpensive(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupThrottling(t, "expensive=1/m")
+	defer tearDownThrottling(t, m)
+
+	status, _ := u[0].Post(t, "/transactions", url.Values{})
+	assert.Equal(t, 400, status)
+
+	status, raw := u[0].Post(t, "/transactions", url.Values{})
+	assertThrottled(t, status, raw)
+
+	// Other endpoints are not subject to the expensive budget.
+	status, _ = u[0].Get(t, "/assets")
+	assert.Equal(t, 200, status)
+}
+
+func TestThrottlePeer(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, _ := setupThrottling(t, "peer=2/m")
+	defer tearDownThrottling(t, m)
+
+	for i := 0; i < 2; i++ {
+		status, _ := m[0].Get(t, nil, "/transactions/foo")
+		assert.Equal(t, 400, status)
+	}
+
+	status, raw := m[0].Get(t, nil, "/transactions/foo")
+	assertThrottled(t, status, raw)
+}
+
+func TestThrottlePeerMint(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupThrottling(t, "peer=2/m")
+	defer tearDownThrottling(t, m)
+
+	host := m[0].Server.URL[7:]
+	foo := fmt.Sprintf("/offers/foo@%s[offer_foo]", u[0].Host)
+	bar := fmt.Sprintf("/offers/bar@%s[offer_bar]", u[0].Host)
+
+	// Propagations are keyed by the mint host they originate from.
+	for i := 0; i < 2; i++ {
+		assert.NotEqual(t, 429,
+			serveFrom(t, m[0], "POST", "127.0.0.1", host, foo, ""))
+	}
+	assert.Equal(t, 429,
+		serveFrom(t, m[0], "POST", "127.0.0.1", host, foo, ""))
+	assert.Equal(t, 429,
+		serveFrom(t, m[0], "POST", "127.0.0.1", host, bar, ""))
+
+	// Clients not at an address of the claimed host are keyed by IP.
+	assert.NotEqual(t, 429,
+		serveFrom(t, m[0], "POST", "10.0.0.1", host, foo, ""))
+}
+
+func TestThrottleLogin(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u := setupThrottling(t, "login=2/m")
+	defer tearDownThrottling(t, m)
+
+	invalid := &test.MintUser{
+		Mint:     m[0],
+		Username: u[0].Username,
+		Password: "foo",
+		Address:  u[0].Address,
+		Host:     u[0].Host,
+	}
+
+	for i := 0; i < 2; i++ {
+		status, raw := invalid.Get(t, "/assets")
+
+		var e errors.ConcreteUserError
+		err := raw.Extract("error", &e)
+		assert.Nil(t, err)
+
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "password_invalid", e.ErrCode)
+	}
+
+	// The username is throttled even with valid credentials.
+	status, raw := u[0].Get(t, "/assets")
+	assertThrottled(t, status, raw)
+
+	// But only from the address of the failed attempts.
+	req := httptest.NewRequest("GET", "/", nil)
+	req.SetBasicAuth(u[0].Username, u[0].Password)
+	assert.Equal(t, 200, serveFrom(t, m[0], "GET", "10.0.0.1", u[0].Host,
+		"/assets", req.Header.Get("Authorization")))
+
+	// Successful authentications are not accounted.
+	for i := 0; i < 3; i++ {
+		status, _ := u[1].Get(t, "/assets")
+		assert.Equal(t, 200, status)
+	}
+}
+
+func TestThrottleAdmin(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, _ := setupThrottling(t, "login=2/m")
+	defer tearDownThrottling(t, m)
+
+	host := m[0].Env.Config[mint.EnvCfgHost]
+	valid := "Bearer " + m[0].Env.Config[mint.EnvCfgAdminSecret]
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, 401, serveFrom(t, m[0], "GET", "10.0.0.1", host,
+			"/admin/users", "Bearer foo"))
+	}
+
+	// The address is throttled even with the valid secret.
+	assert.Equal(t, 429, serveFrom(t, m[0], "GET", "10.0.0.1", host,
+		"/admin/users", valid))
+
+	assert.Equal(t, 200, serveFrom(t, m[0], "GET", "10.0.0.2", host,
+		"/admin/users", valid))
+}
@@ -0,0 +1,181 @@
+package functional
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/spolu/settle/lib/db"
+	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/lib/trace"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/async/task"
+	"github.com/spolu/settle/mint/model"
+	"github.com/spolu/settle/mint/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTrace(
+	t *testing.T,
+) ([]*test.Mint, []*test.MintUser, []mint.AssetResource, []mint.OfferResource) {
+	return setupSettleTransaction(t)
+}
+
+func tearDownTrace(
+	t *testing.T,
+	mints []*test.Mint,
+) {
+	for _, m := range mints {
+		m.Close()
+	}
+}
+
+// postWithTrace posts to a specified endpoint on the mint of the user with the
+// provided trace ID (if not empty), returning the trace ID of the response.
+func postWithTrace(
+	t *testing.T,
+	user *test.MintUser,
+	id string,
+	path string,
+	params url.Values,
+) (int, svc.Resp, string) {
+	req, err := http.NewRequest("POST",
+		fmt.Sprintf("%s%s", user.Mint.Server.URL, path),
+		strings.NewReader(params.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if id != "" {
+		req.Header.Set(trace.Header, id)
+	}
+	req.Host = user.Host
+	req.SetBasicAuth(user.Username, user.Password)
+
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	var raw svc.Resp
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		t.Fatal(err)
+	}
+
+	return r.StatusCode, raw, r.Header.Get(trace.Header)
+}
+
+// loadTransactionTrace returns the trace ID stored with a transaction on a
+// mint.
+func loadTransactionTrace(
+	t *testing.T,
+	user *test.MintUser,
+	id string,
+) string {
+	ctx := db.Begin(mint.WithHost(user.Mint.Ctx, user.Host), "mint")
+	defer db.LoggedRollback(ctx)
+
+	tx, err := model.LoadTransactionByID(ctx, id)
+	assert.Nil(t, err)
+	assert.NotNil(t, tx)
+
+	return tx.Trace
+}
+
+func TestTraceErrorResponse(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, _, _ := setupTrace(t)
+	defer tearDownTrace(t, m)
+
+	status, raw, id := postWithTrace(t, u[0], "", "/transactions",
+		url.Values{})
+
+	var e errors.ConcreteUserError
+	err := raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 400, status)
+	assert.NotEqual(t, "", id)
+	assert.Equal(t, id, e.ErrTrace)
+
+	status, raw, id = postWithTrace(t, u[0], "trace_test", "/transactions",
+		url.Values{})
+
+	err = raw.Extract("error", &e)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "trace_test", id)
+	assert.Equal(t, "trace_test", e.ErrTrace)
+
+	// Invalid trace IDs are replaced by a new one.
+	for _, invalid := range []string{
+		"trace test", "trace\"test", strings.Repeat("a", 129),
+	} {
+		_, _, id = postWithTrace(t, u[0], invalid, "/transactions",
+			url.Values{})
+		assert.NotEqual(t, invalid, id)
+		assert.True(t, trace.IDRegexp.MatchString(id))
+	}
+}
+
+func TestTracePropagation(
+	t *testing.T,
+) {
+	t.Parallel()
+	m, u, a, o := setupTrace(t)
+	defer tearDownTrace(t, m)
+
+	status, raw, id := postWithTrace(t, u[0], "trace_create", "/transactions",
+		url.Values{
+			"pair":        {fmt.Sprintf("%s/%s", a[0].Name, a[2].Name)},
+			"amount":      {"10"},
+			"destination": {u[2].Address},
+			"path[]": {
+				o[1].ID,
+				o[2].ID,
+			},
+		})
+
+	var tx mint.TransactionResource
+	err := raw.Extract("transaction", &tx)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 201, status)
+	assert.Equal(t, "trace_create", id)
+
+	// The trace ID is passed to every mint involved in the transaction.
+	for i := range m {
+		assert.Equal(t, "trace_create", loadTransactionTrace(t, u[i], tx.ID))
+	}
+
+	status, _, id = postWithTrace(t, u[0], "trace_settle",
+		fmt.Sprintf("/transactions/%s/settle", tx.ID), url.Values{})
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "trace_settle", id)
+
+	// Tasks carry the trace ID of the request that queued them (offers
+	// crossed by the transaction are propagated).
+	count := 0
+	for i := range m {
+		ctx := db.Begin(m[i].Ctx, "mint")
+		tasks, err := model.LoadPendingTasks(ctx)
+		assert.Nil(t, err)
+		for _, tk := range tasks {
+			assert.NotEqual(t, "", tk.Trace)
+			if tk.Name == task.TkPropagateOffer && tk.Trace == "trace_create" {
+				count++
+			}
+		}
+		db.LoggedRollback(ctx)
+	}
+	assert.True(t, count > 0)
+}
@@ -0,0 +1,495 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spolu/settle/lib/logging"
+	"github.com/spolu/settle/lib/svc"
+	"github.com/spolu/settle/mint"
+	"github.com/spolu/settle/mint/async"
+	"github.com/spolu/settle/mint/model"
+)
+
+// Fault is the fault injected by a Network in the requests matching a Rule.
+type Fault string
+
+const (
+	// FtPass proxies the request unmodified (to trigger Rule.Then).
+	FtPass Fault = "pass"
+	// FtDrop closes the connection without proxying the request.
+	FtDrop Fault = "drop"
+	// FtDropResponse proxies the request and closes the connection without
+	// returning the response.
+	FtDropResponse Fault = "drop_response"
+	// FtDelay proxies the request after Rule.Delay.
+	FtDelay Fault = "delay"
+	// FtDuplicate proxies the request twice and returns the second response.
+	FtDuplicate Fault = "duplicate"
+	// FtRewrite proxies the request and returns the response as rewritten by
+	// Rule.Rewrite.
+	FtRewrite Fault = "rewrite"
+)
+
+// Rule selects requests received by a mint of a Network and the fault to
+// inject in them. The zero value of each selector matches all requests. As
+// mints address each other by host, requests are selected by the mint
+// receiving them, not the one sending them: Skip and Times can be used to
+// only target some of the requests a mint receives on a route.
+type Rule struct {
+	Mint   int            // Index of the mint receiving the request.
+	Method string         // Method of the request.
+	Path   *regexp.Regexp // Pattern matched against the request path.
+	Hop    *int8          // Hop of the request (form value).
+	Skip   int            // Number of matching requests to let through first.
+	Times  int            // Number of requests to apply the fault to (0: all).
+
+	Fault   Fault
+	Delay   time.Duration                                  // FtDelay.
+	Rewrite func(status int, raw svc.Resp) (int, svc.Resp) // FtRewrite.
+
+	// Then is called each time the rule applies, once the request was
+	// handled by the mint and before its response is returned (for example
+	// to partition mints mid-transaction).
+	Then func(n *Network)
+
+	matched int
+}
+
+// Network is a set of test mints each served behind a programmable proxy.
+// Mints are reachable only through their proxy (their host is the proxy
+// host) so that faults can be injected in the requests they receive, from
+// test users or from other mints of the network. The network records the
+// transactions returned by the mints to check their outcome.
+type Network struct {
+	Mints []*Mint
+
+	mutex        *sync.Mutex
+	rules        []*Rule
+	partitioned  map[int]bool
+	transactions map[string]bool
+}
+
+// CreateNetwork creates a network of size test mints.
+func CreateNetwork(
+	t *testing.T,
+	size int,
+) *Network {
+	n := Network{
+		Mints:        []*Mint{},
+		mutex:        &sync.Mutex{},
+		rules:        []*Rule{},
+		partitioned:  map[int]bool{},
+		transactions: map[string]bool{},
+	}
+	for i := 0; i < size; i++ {
+		m := CreateMint(t)
+		m.Backend = m.Server
+		m.Server = httptest.NewServer(n.proxy(i, m.Backend))
+		m.Env.Config[mint.EnvCfgHost] = m.Server.URL[7:]
+
+		logging.Logf(m.Ctx, "Proxying test mint: mint_host=%s backend=%s",
+			m.Env.Config[mint.EnvCfgHost], m.Backend.URL[7:])
+
+		n.Mints = append(n.Mints, m)
+	}
+	return &n
+}
+
+// Close closes the mints of the network after usage.
+func (n *Network) Close() {
+	for _, m := range n.Mints {
+		m.Server.Close()
+		m.Close()
+	}
+}
+
+// Inject adds a rule to the network. Rules are evaluated in the order they
+// were added and the first one matching a request applies.
+func (n *Network) Inject(
+	rule Rule,
+) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.rules = append(n.rules, &rule)
+}
+
+// Clear removes all the rules of the network.
+func (n *Network) Clear() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.rules = []*Rule{}
+}
+
+// Partition makes the provided mints unreachable: all the requests they
+// receive are dropped until the network is healed.
+func (n *Network) Partition(
+	mints ...int,
+) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	for _, i := range mints {
+		n.partitioned[i] = true
+	}
+}
+
+// Heal makes all the mints reachable again and closes the circuit breakers
+// opened by the partition.
+func (n *Network) Heal() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	for i := range n.partitioned {
+		mint.ResetBreaker(n.Mints[i].Server.URL[7:])
+	}
+	n.partitioned = map[int]bool{}
+}
+
+// Transactions returns the IDs of the transactions recorded by the network.
+func (n *Network) Transactions() []string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	ids := []string{}
+	for id := range n.transactions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// match returns the rule applying to a request received by mint i, if any.
+func (n *Network) match(
+	i int,
+	r *http.Request,
+	body []byte,
+) *Rule {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	var hop *int8
+	if form, err := url.ParseQuery(string(body)); err == nil {
+		if h, err := strconv.ParseInt(form.Get("hop"), 10, 8); err == nil {
+			v := int8(h)
+			hop = &v
+		}
+	}
+
+	for _, rule := range n.rules {
+		if rule.Mint != i ||
+			(rule.Method != "" && rule.Method != r.Method) ||
+			(rule.Path != nil && !rule.Path.MatchString(r.URL.Path)) ||
+			(rule.Hop != nil && (hop == nil || *hop != *rule.Hop)) {
+			continue
+		}
+		rule.matched++
+		if rule.matched <= rule.Skip ||
+			(rule.Times > 0 && rule.matched > rule.Skip+rule.Times) {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
+// record records the transaction returned in a response, if any.
+func (n *Network) record(
+	raw svc.Resp,
+) {
+	var tx mint.TransactionResource
+	if err := raw.Extract("transaction", &tx); err != nil || tx.ID == "" {
+		return
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.transactions[tx.ID] = true
+}
+
+// proxied is a response of a mint to a proxied request.
+type proxied struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// forward forwards a request to the backend of a mint.
+func forward(
+	backend *httptest.Server,
+	r *http.Request,
+	body []byte,
+) (*proxied, error) {
+	req, err := http.NewRequest(r.Method,
+		backend.URL+r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	req.Host = r.Host
+
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &proxied{res.StatusCode, res.Header, b}, nil
+}
+
+// drop closes the connection of a request without responding.
+func drop(
+	w http.ResponseWriter,
+) {
+	if h, ok := w.(http.Hijacker); ok {
+		if conn, _, err := h.Hijack(); err == nil {
+			conn.Close()
+			return
+		}
+	}
+	w.WriteHeader(http.StatusBadGateway)
+}
+
+// proxy returns the handler of the proxy of mint i.
+func (n *Network) proxy(
+	i int,
+	backend *httptest.Server,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			drop(w)
+			return
+		}
+
+		n.mutex.Lock()
+		partitioned := n.partitioned[i]
+		n.mutex.Unlock()
+		if partitioned {
+			drop(w)
+			return
+		}
+
+		rule := n.match(i, r, body)
+		fault := FtPass
+		if rule != nil {
+			fault = rule.Fault
+		}
+
+		switch fault {
+		case FtDrop:
+			drop(w)
+			return
+		case FtDelay:
+			time.Sleep(rule.Delay)
+		}
+
+		res, err := forward(backend, r, body)
+		if err == nil && fault == FtDuplicate {
+			res, err = forward(backend, r, body)
+		}
+		if err != nil {
+			drop(w)
+			return
+		}
+
+		var raw svc.Resp
+		if err := json.Unmarshal(res.body, &raw); err == nil {
+			if fault == FtRewrite {
+				res.status, raw = rule.Rewrite(res.status, raw)
+				res.body, _ = json.Marshal(raw)
+				res.header.Del("Content-Length")
+			}
+			n.record(raw)
+		}
+
+		if rule != nil && rule.Then != nil {
+			rule.Then(n)
+		}
+
+		if fault == FtDropResponse {
+			drop(w)
+			return
+		}
+
+		for k, v := range res.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(res.status)
+		w.Write(res.body)
+	})
+}
+
+// Drain runs the pending async tasks of all the mints regardless of their
+// deadline, as if transactions had expired, until no task is pending or
+// rounds rounds were run (failed executions are retried by the next round).
+func (n *Network) Drain(
+	t *testing.T,
+	rounds int,
+) {
+	for round := 0; round < rounds; round++ {
+		pending := 0
+		for _, m := range n.Mints {
+			tasks, err := model.LoadPendingTasks(m.Ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tk := range tasks {
+				pending++
+				_, err := async.Get(m.Ctx).RunTask(m.Ctx, tk.Token)
+				if err != nil {
+					logging.Logf(m.Ctx, "Error draining task: task=%s error=%s",
+						tk.Token, err.Error())
+				}
+			}
+		}
+		if pending == 0 {
+			return
+		}
+	}
+}
+
+// flow is the key of the settled amounts moved by operations.
+type flow struct {
+	mint   int
+	asset  string
+	holder string
+}
+
+// crossed is the key of the amounts crossing offers.
+type crossed struct {
+	mint  int
+	offer string
+}
+
+// AssertConserved checks that value was conserved across the network by the
+// transactions it recorded, once they are all settled or canceled (see
+// Drain):
+//   - no operation or crossing of a transaction is still reserved on any mint
+//     and all of them have the same status (no mint paid without being paid).
+//   - balances are the sum of the settled operations crediting and debiting
+//     them.
+//   - the remainder of the offers crossed is their amount minus the amount of
+//     their settled crossings.
+func (n *Network) AssertConserved(
+	t *testing.T,
+) {
+	flows := map[flow]*big.Int{}
+	crossings := map[crossed]*big.Int{}
+
+	for _, id := range n.Transactions() {
+		statuses := map[mint.TxStatus][]string{}
+		for i, m := range n.Mints {
+			ops, err := model.LoadCanonicalOperationsByTransaction(m.Ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, op := range ops {
+				statuses[op.Status] = append(statuses[op.Status],
+					fmt.Sprintf("operation %s on mint %d", op.ID(), i))
+				if op.Status != mint.TxStSettled {
+					continue
+				}
+				dst := flow{i, op.Asset, op.Destination}
+				src := flow{i, op.Asset, op.Source}
+				for _, f := range []flow{dst, src} {
+					if _, ok := flows[f]; !ok {
+						flows[f] = new(big.Int)
+					}
+				}
+				flows[dst].Add(flows[dst], (*big.Int)(&op.Amount))
+				flows[src].Sub(flows[src], (*big.Int)(&op.Amount))
+			}
+
+			crs, err := model.LoadCanonicalCrossingsByTransaction(m.Ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, cr := range crs {
+				statuses[cr.Status] = append(statuses[cr.Status],
+					fmt.Sprintf("crossing %s on mint %d", cr.ID(), i))
+				c := crossed{i, cr.Offer}
+				if _, ok := crossings[c]; !ok {
+					crossings[c] = new(big.Int)
+				}
+				if cr.Status != mint.TxStCanceled {
+					crossings[c].Add(crossings[c], (*big.Int)(&cr.Amount))
+				}
+			}
+		}
+
+		if len(statuses[mint.TxStReserved]) > 0 {
+			t.Errorf("Transaction %s is still reserved: %s",
+				id, strings.Join(statuses[mint.TxStReserved], ", "))
+		} else if len(statuses) > 1 {
+			t.Errorf("Transaction %s is partially settled: settled %s, "+
+				"canceled %s", id,
+				strings.Join(statuses[mint.TxStSettled], ", "),
+				strings.Join(statuses[mint.TxStCanceled], ", "))
+		}
+	}
+
+	for f, expected := range flows {
+		asset, err := mint.AssetResourceFromName(context.Background(), f.asset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if holder, err := mint.NormalizedAddress(
+			context.Background(), f.holder,
+		); err != nil {
+			t.Fatal(err)
+		} else if holder == asset.Owner {
+			// Asset owners don't hold balances in their assets.
+			continue
+		}
+
+		value := new(big.Int)
+		balance, err := model.LoadCanonicalBalanceByAssetHolder(
+			n.Mints[f.mint].Ctx, f.asset, f.holder)
+		if err != nil {
+			t.Fatal(err)
+		} else if balance != nil {
+			value = (*big.Int)(&balance.Value)
+		}
+		if value.Cmp(expected) != 0 {
+			t.Errorf("Balance of %s in %s on mint %d is %s, expected %s",
+				f.holder, f.asset, f.mint, value, expected)
+		}
+	}
+
+	for c, amount := range crossings {
+		offer, err := model.LoadCanonicalOfferByID(n.Mints[c.mint].Ctx, c.offer)
+		if err != nil {
+			t.Fatal(err)
+		} else if offer == nil {
+			t.Errorf("Offer %s not found on mint %d", c.offer, c.mint)
+			continue
+		}
+		expected := new(big.Int).Sub((*big.Int)(&offer.Amount), amount)
+		if (*big.Int)(&offer.Remainder).Cmp(expected) != 0 {
+			t.Errorf("Remainder of offer %s on mint %d is %s, expected %s",
+				c.offer, c.mint, (*big.Int)(&offer.Remainder), expected)
+		}
+	}
+}
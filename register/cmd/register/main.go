@@ -5,6 +5,7 @@ import (
 	"log"
 
 	"github.com/spolu/settle/lib/errors"
+	"github.com/spolu/settle/lib/logging"
 	"github.com/spolu/settle/register/app"
 )
 
@@ -24,6 +25,9 @@ var smpFlag string
 var smhFlag string
 var frmFlag string
 
+var lvlFlag string
+var lfmFlag string
+
 func init() {
 	flag.StringVar(&envFlag, "env",
 		"qa", "The environment to run in (qa, production), default: qa")
@@ -52,6 +56,11 @@ func init() {
 	flag.StringVar(&frmFlag, "from",
 		"", "The address the registration email are sent from")
 
+	flag.StringVar(&lvlFlag, "log_level",
+		"info", "The minimum level of logged entries (debug, info, warn, error), default: info")
+	flag.StringVar(&lfmFlag, "log_format",
+		"text", "The format of logged entries (text, json), default: text")
+
 	if fl := log.Flags(); fl&log.Ltime != 0 {
 		log.SetFlags(fl | log.Lmicroseconds)
 	}
@@ -62,6 +71,16 @@ func main() {
 		flag.Parse()
 	}
 
+	level, err := logging.ParseLevel(lvlFlag)
+	if err != nil {
+		log.Fatal(errors.Details(err))
+	}
+	format, err := logging.ParseFormat(lfmFlag)
+	if err != nil {
+		log.Fatal(errors.Details(err))
+	}
+	logging.Configure(level, format)
+
 	ctx, err := app.BackgroundContextFromFlags(
 		envFlag,
 		hstFlag, prtFlag,